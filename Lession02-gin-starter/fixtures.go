@@ -0,0 +1,39 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// The data /users and /products serve, it used to be hard-coded in main
+//
+//go:embed fixtures.json
+var fixturesJSON []byte
+
+type Fixtures struct {
+	Users    []string  `json:"users"`
+	Products []Product `json:"products"`
+}
+
+func loadFixtures() (Fixtures, error) {
+	var f Fixtures
+	if err := json.Unmarshal(fixturesJSON, &f); err != nil {
+		return f, fmt.Errorf("fixtures.json: %w", err)
+	}
+
+	// The same checks a POST would get
+	ids := map[int]bool{}
+	for _, p := range f.Products {
+		switch {
+		case p.ID <= 0 || ids[p.ID]:
+			return f, fmt.Errorf("fixtures.json: product %q: id must be positive and unique", p.Name)
+		case p.Name == "":
+			return f, fmt.Errorf("fixtures.json: product %d: name is required", p.ID)
+		case p.Price <= 0:
+			return f, fmt.Errorf("fixtures.json: product %q: price must be positive", p.Name)
+		}
+		ids[p.ID] = true
+	}
+	return f, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 
@@ -14,6 +15,11 @@ type Product struct {
 }
 
 func main() {
+	fixtures, err := loadFixtures()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	router := gin.Default()
 
 	// GET /ping -> "pong"
@@ -49,10 +55,8 @@ func main() {
 	})
 
 	router.GET("/users", func(c *gin.Context) {
-		users := []string{"Alice", "Bob", "Charlie"}
-
 		c.JSON(200, gin.H{
-			"data": users,
+			"data": fixtures.Users,
 		})
 	})
 
@@ -71,20 +75,8 @@ func main() {
 	})
 
 	router.GET("/products", func(c *gin.Context) {
-		// products := []gin.H{
-		// 	{"id": 1, "name": "Laptop", "price": 1000},
-		// 	{"id": 2, "name": "Phone", "price": 500},
-		// 	{"id": 3, "name": "Headphones", "price": 100},
-		// }
-
-		products := []Product{
-			{ID: 1, Name: "Laptop", Price: 999},
-			{ID: 2, Name: "Phone", Price: 500},
-			{ID: 3, Name: "Headphones", Price: 100},
-		}
-
 		c.JSON(200, gin.H{
-			"data": products,
+			"data": fixtures.Products,
 		})
 	})
 
@@ -0,0 +1,592 @@
+// Code generated by clientgen from the v1 routes and DTOs. DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/listquery"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// CommitReservation calls POST /api/v1/reservations/:id/commit: Commit a reservation.
+//
+// Takes the reserved quantity off the variant's stock.
+//
+// Headers: Idempotency-Key.
+func (c *Client) CommitReservation(ctx context.Context, path dto.ReservationUri, opts ...RequestOption) (*dto.ProductResponse, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   expandPath("/api/v1/reservations/:id/commit", path),
+	}
+	var out dto.ProductResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateCategory calls POST /api/v1/categories: Create a category.
+//
+// When the server checks image URLs in the background, X-Image-Check is the job doing it:
+// an image that is no good is dropped, with -ingest-images a good one is copied in.
+func (c *Client) CreateCategory(ctx context.Context, body dto.CreateCategoryRequest, opts ...RequestOption) (*dto.CreateCategoryResponse, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   "/api/v1/categories",
+		kind:   formBody,
+		body:   body,
+	}
+	var out dto.CreateCategoryResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateNotificationTicket calls POST /api/v1/notifications/tickets: Get a ticket for the notifications WebSocket.
+//
+// Admins only. Open ws://<host>/api/v1/notifications?ticket=<ticket> within 30s, once;
+// clients that can set headers may send Authorization on the handshake instead.
+// Send {"op":"subscribe","topics":["product:12","category:*"]} (or unsubscribe, ping) and get
+// {"type":"event","topic":"product:12","event":{...}} for every change to those resources.
+// A client too slow to keep up gets an error message and is disconnected.
+func (c *Client) CreateNotificationTicket(ctx context.Context, opts ...RequestOption) (*dto.NotificationTicket, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   "/api/v1/notifications/tickets",
+	}
+	var out dto.NotificationTicket
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateProduct calls POST /api/v1/products: Create a product.
+//
+// When the server checks image URLs in the background, X-Image-Check is the job doing it:
+// a product showing an image that is no good is taken off the shelf (display false, stock 0).
+//
+// Headers: Idempotency-Key.
+func (c *Client) CreateProduct(ctx context.Context, body dto.CreateProductRequest, opts ...RequestOption) (*dto.CreateProductResponse, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   "/api/v1/products",
+		kind:   jsonBody,
+		body:   body,
+	}
+	var out dto.CreateProductResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateUser calls POST /api/v1/users: Create a user.
+func (c *Client) CreateUser(ctx context.Context, body dto.CreateUserRequest, opts ...RequestOption) (*dto.UserResponse, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   "/api/v1/users",
+		kind:   jsonBody,
+		body:   body,
+	}
+	var out dto.UserResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateWebhook calls POST /api/v1/webhooks: Subscribe an endpoint to events.
+//
+// Every event is POSTed as JSON with X-Webhook-Event, X-Webhook-Delivery and
+// X-Webhook-Signature: t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed with secret>.
+// Anything but 2xx is retried with backoff, 410 Gone stops the retries.
+//
+// Headers: Idempotency-Key.
+func (c *Client) CreateWebhook(ctx context.Context, body dto.CreateWebhookRequest, opts ...RequestOption) (*dto.WebhookResponse, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   "/api/v1/webhooks",
+		kind:   jsonBody,
+		body:   body,
+	}
+	var out dto.WebhookResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteProduct calls DELETE /api/v1/products/:id: Delete a product.
+//
+// Headers: If-Match (required).
+func (c *Client) DeleteProduct(ctx context.Context, path dto.ProductUri, opts ...RequestOption) (*dto.MessageResponse, error) {
+	req := call{
+		method: http.MethodDelete,
+		path:   expandPath("/api/v1/products/:id", path),
+	}
+	var out dto.MessageResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteUser calls DELETE /api/v1/users/:id: Delete a user.
+//
+// Headers: If-Match (required).
+func (c *Client) DeleteUser(ctx context.Context, path dto.UserQuery, opts ...RequestOption) (*dto.MessageResponse, error) {
+	req := call{
+		method: http.MethodDelete,
+		path:   expandPath("/api/v1/users/:id", path),
+	}
+	var out dto.MessageResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteWebhook calls DELETE /api/v1/webhooks/:id: Delete a webhook.
+func (c *Client) DeleteWebhook(ctx context.Context, path dto.WebhookUri, opts ...RequestOption) error {
+	req := call{
+		method: http.MethodDelete,
+		path:   expandPath("/api/v1/webhooks/:id", path),
+	}
+	return c.do(ctx, req, nil, opts)
+}
+
+// ExportProducts calls GET /api/v1/products/export: Export every product as CSV or NDJSON.
+//
+// Rows can be imported again as they are.
+func (c *Client) ExportProducts(ctx context.Context, query dto.ExportQuery, opts ...RequestOption) (io.ReadCloser, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   "/api/v1/products/export",
+		query:  query,
+	}
+	return c.stream(ctx, req, opts)
+}
+
+// GetCategories calls GET /api/v1/categories: List categories.
+func (c *Client) GetCategories(ctx context.Context, query dto.ListQuery, opts ...RequestOption) (*listquery.Page[models.Category], error) {
+	req := call{
+		method: http.MethodGet,
+		path:   "/api/v1/categories",
+		query:  query,
+	}
+	var out listquery.Page[models.Category]
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetCategoryProducts calls GET /api/v1/categories/:id/products: List the products of a category.
+//
+// Includes the products of all subcategories unless direct=true. Products with display false are left out.
+func (c *Client) GetCategoryProducts(ctx context.Context, path dto.CategoryUri, query dto.CategoryProductsQuery, opts ...RequestOption) (*listquery.Page[models.Product], error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/categories/:id/products", path),
+		query:  query,
+	}
+	var out listquery.Page[models.Product]
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetCategoryTree calls GET /api/v1/categories/:id/tree: Get a category with its subcategories.
+func (c *Client) GetCategoryTree(ctx context.Context, path dto.CategoryUri, opts ...RequestOption) (*dto.CategoryTreeResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/categories/:id/tree", path),
+	}
+	var out dto.CategoryTreeResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetImport calls GET /api/v1/imports/:id: Get the progress of an import.
+func (c *Client) GetImport(ctx context.Context, path dto.ImportUri, opts ...RequestOption) (*dto.ImportResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/imports/:id", path),
+	}
+	var out dto.ImportResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetImportResults calls GET /api/v1/imports/:id/results: Stream the per-row results of an import.
+//
+// One line per row, in row order, as rows are done. The stream ends with the import.
+func (c *Client) GetImportResults(ctx context.Context, path dto.ImportUri, opts ...RequestOption) (io.ReadCloser, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/imports/:id/results", path),
+	}
+	return c.stream(ctx, req, opts)
+}
+
+// GetJob calls GET /api/v1/jobs/:id: Get the state of a background job.
+//
+// Poll until status is succeeded (result is set) or dead (last_error says why).
+func (c *Client) GetJob(ctx context.Context, path dto.JobUri, opts ...RequestOption) (*dto.JobResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/jobs/:id", path),
+	}
+	var out dto.JobResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProductByID calls GET /api/v1/products/:id: Get a product.
+//
+// Headers: If-None-Match.
+func (c *Client) GetProductByID(ctx context.Context, path dto.ProductUri, opts ...RequestOption) (*dto.ProductResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/products/:id", path),
+	}
+	var out dto.ProductResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProductByLang calls GET /api/v1/products/category/:lang: Products by programming language.
+//
+// Not product categories, those are under /categories/{id}/products.
+func (c *Client) GetProductByLang(ctx context.Context, path dto.ProductLangUri, opts ...RequestOption) (*dto.ProductLangResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/products/category/:lang", path),
+	}
+	var out dto.ProductLangResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProductBySlug calls GET /api/v1/products/slug/:slug: Get a product by slug.
+//
+// Old slugs (the product was renamed since) answer 301 to the current one.
+//
+// Headers: If-None-Match.
+func (c *Client) GetProductBySlug(ctx context.Context, path dto.ProductSlugUri, opts ...RequestOption) (*dto.ProductResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/products/slug/:slug", path),
+	}
+	var out dto.ProductResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProducts calls GET /api/v1/products: Search products.
+//
+// Full-text search with pagination, products with display false are left out. sort is a comma separated list of name, price, created_at, prefix with - for descending.
+func (c *Client) GetProducts(ctx context.Context, query dto.ProductQuery, opts ...RequestOption) (*listquery.Page[dto.ProductSearchResult], error) {
+	req := call{
+		method: http.MethodGet,
+		path:   "/api/v1/products",
+		query:  query,
+	}
+	var out listquery.Page[dto.ProductSearchResult]
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUserByID calls GET /api/v1/users/:id: Get a user.
+//
+// Headers: If-None-Match.
+func (c *Client) GetUserByID(ctx context.Context, path dto.UserQuery, opts ...RequestOption) (*dto.UserResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/users/:id", path),
+	}
+	var out dto.UserResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUserBySlug calls GET /api/v1/users/slug/:slug: Find a user by slug.
+//
+// Old slugs (the user was renamed since) answer 301 to the current one.
+func (c *Client) GetUserBySlug(ctx context.Context, path dto.UserSlugQuery, opts ...RequestOption) (*dto.UserSlugResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/users/slug/:slug", path),
+	}
+	var out dto.UserSlugResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUserByUUID calls GET /api/v1/users/uuid/:uuid: Check a user UUID.
+func (c *Client) GetUserByUUID(ctx context.Context, path dto.UserUUIDQuery, opts ...RequestOption) (*dto.UserUUIDResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/users/uuid/:uuid", path),
+	}
+	var out dto.UserUUIDResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUserWithoutSlug calls GET /api/v1/users/slug: User slug placeholder.
+func (c *Client) GetUserWithoutSlug(ctx context.Context, opts ...RequestOption) (*dto.UserSlugResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   "/api/v1/users/slug",
+	}
+	var out dto.UserSlugResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUsers calls GET /api/v1/users: List users.
+func (c *Client) GetUsers(ctx context.Context, query dto.ListQuery, opts ...RequestOption) (*listquery.Page[models.User], error) {
+	req := call{
+		method: http.MethodGet,
+		path:   "/api/v1/users",
+		query:  query,
+	}
+	var out listquery.Page[models.User]
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWebhookDeliveries calls GET /api/v1/webhooks/:id/deliveries: List the delivery attempts of a webhook.
+func (c *Client) GetWebhookDeliveries(ctx context.Context, path dto.WebhookUri, query dto.WebhookDeliveriesQuery, opts ...RequestOption) (*dto.WebhookDeliveriesResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   expandPath("/api/v1/webhooks/:id/deliveries", path),
+		query:  query,
+	}
+	var out dto.WebhookDeliveriesResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWebhooks calls GET /api/v1/webhooks: List webhooks.
+func (c *Client) GetWebhooks(ctx context.Context, opts ...RequestOption) (*dto.WebhookListResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   "/api/v1/webhooks",
+	}
+	var out dto.WebhookListResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImportProducts calls POST /api/v1/products/import: Import products from CSV or NDJSON.
+//
+// Rows are CreateProductRequest bodies, validated like POST /products. The import runs in the background:
+// follow it on /imports/{id}. The file can also be sent as the raw body, with Content-Type text/csv or application/x-ndjson.
+//
+// Headers: Idempotency-Key.
+func (c *Client) ImportProducts(ctx context.Context, query dto.ImportQuery, file Upload, opts ...RequestOption) (*dto.ImportResponse, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   "/api/v1/products/import",
+		query:  query,
+		kind:   multipartBody,
+	}
+	req.files = append(req.files, filePart{field: "file", uploads: []Upload{file}})
+	var out dto.ImportResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// MoveCategory calls PUT /api/v1/categories/:id/parent: Move a category under another parent.
+//
+// The subtree moves along. parent_id 0 makes it a top level category; moving it under its own subtree is a 409.
+//
+// Headers: If-Match (required).
+func (c *Client) MoveCategory(ctx context.Context, path dto.CategoryUri, body dto.MoveCategoryRequest, opts ...RequestOption) (*dto.CategoryResponse, error) {
+	req := call{
+		method: http.MethodPut,
+		path:   expandPath("/api/v1/categories/:id/parent", path),
+		kind:   jsonBody,
+		body:   body,
+	}
+	var out dto.CategoryResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReleaseReservation calls DELETE /api/v1/reservations/:id: Release a reservation.
+func (c *Client) ReleaseReservation(ctx context.Context, path dto.ReservationUri, opts ...RequestOption) (*dto.MessageResponse, error) {
+	req := call{
+		method: http.MethodDelete,
+		path:   expandPath("/api/v1/reservations/:id", path),
+	}
+	var out dto.MessageResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReserveStock calls POST /api/v1/products/:id/reservations: Reserve stock of a variant.
+//
+// Holds the quantity until the reservation is committed, released or expires (15 minutes by default).
+//
+// Headers: Idempotency-Key.
+func (c *Client) ReserveStock(ctx context.Context, path dto.ProductUri, body dto.ReserveRequest, opts ...RequestOption) (*dto.ReservationResponse, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   expandPath("/api/v1/products/:id/reservations", path),
+		kind:   jsonBody,
+		body:   body,
+	}
+	var out dto.ReservationResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Stream calls GET /api/v1/events: Follow upload and job progress as Server-Sent Events.
+//
+// Topic uploads has an "upload" event per file of upload-multiple, topic jobs an "import" event
+// when a bulk import starts and ends, and for admins a "job" event per job queue change.
+// Needs an API token (Authorization: Bearer <token>): callers see their own events, admins everyone's.
+// Comment lines come as heartbeats. On reconnect, Last-Event-ID replays the missed events,
+// or a "reset" event comes first when they are no longer kept.
+//
+// Headers: Last-Event-ID.
+func (c *Client) Stream(ctx context.Context, query dto.StreamQuery, opts ...RequestOption) (io.ReadCloser, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   "/api/v1/events",
+		query:  query,
+	}
+	return c.stream(ctx, req, opts)
+}
+
+// SuggestProducts calls GET /api/v1/products/suggest: Autocomplete product names and tags.
+func (c *Client) SuggestProducts(ctx context.Context, query dto.SuggestQuery, opts ...RequestOption) (*dto.SuggestResponse, error) {
+	req := call{
+		method: http.MethodGet,
+		path:   "/api/v1/products/suggest",
+		query:  query,
+	}
+	var out dto.SuggestResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateProduct calls PUT /api/v1/products/:id: Replace a product.
+//
+// Headers: If-Match (required).
+func (c *Client) UpdateProduct(ctx context.Context, path dto.ProductUri, body dto.CreateProductRequest, opts ...RequestOption) (*dto.ProductResponse, error) {
+	req := call{
+		method: http.MethodPut,
+		path:   expandPath("/api/v1/products/:id", path),
+		kind:   jsonBody,
+		body:   body,
+	}
+	var out dto.ProductResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateUser calls PUT /api/v1/users/:id: Replace a user.
+//
+// Headers: If-Match (required).
+func (c *Client) UpdateUser(ctx context.Context, path dto.UserQuery, body dto.CreateUserRequest, opts ...RequestOption) (*dto.UserResponse, error) {
+	req := call{
+		method: http.MethodPut,
+		path:   expandPath("/api/v1/users/:id", path),
+		kind:   jsonBody,
+		body:   body,
+	}
+	var out dto.UserResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadCategoryImage calls POST /api/v1/categories/upload: Upload a category image.
+//
+// jpg, jpeg or png, at most 2MB.
+//
+// Headers: Idempotency-Key.
+func (c *Client) UploadCategoryImage(ctx context.Context, query dto.UploadCategoryQuery, body dto.UploadCategoryForm, image Upload, opts ...RequestOption) (*dto.UploadCategoryResponse, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   "/api/v1/categories/upload",
+		query:  query,
+		kind:   multipartBody,
+		body:   body,
+	}
+	req.files = append(req.files, filePart{field: "image", uploads: []Upload{image}})
+	var out dto.UploadCategoryResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadMultipleCategoryImages calls POST /api/v1/categories/upload-multiple: Upload up to 5 category images.
+//
+// Headers: Idempotency-Key, X-Upload-ID.
+func (c *Client) UploadMultipleCategoryImages(ctx context.Context, images []Upload, opts ...RequestOption) (*dto.UploadMultipleResponse, error) {
+	req := call{
+		method: http.MethodPost,
+		path:   "/api/v1/categories/upload-multiple",
+		kind:   multipartBody,
+	}
+	req.files = append(req.files, filePart{field: "images", uploads: images})
+	var out dto.UploadMultipleResponse
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,267 @@
+// Package client is a typed Go client for the v1 API. The methods in
+// api_gen.go are generated from the server's own routes and DTOs, so the
+// request and response types are the ones the handlers bind and answer.
+package client
+
+//go:generate go run ../cmd/clientgen -out api_gen.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	baseURL   string
+	http      *http.Client
+	retry     RetryPolicy
+	userAgent string
+}
+
+// RetryPolicy retries GET, PUT and DELETE, and POSTs that carry an
+// Idempotency-Key, on network errors, 429 and 502/503/504.
+type RetryPolicy struct {
+	MaxAttempts int // first try included, 1 turns retries off
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}
+
+type Option func(*Client)
+
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New creates a client for baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		http:      &http.Client{Timeout: 30 * time.Second},
+		retry:     DefaultRetryPolicy,
+		userAgent: "lession03-client/1",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// RequestOption adds headers to one call or captures its response metadata
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	header http.Header
+	meta   *ResponseMeta
+}
+
+func WithHeader(key, value string) RequestOption {
+	return func(o *requestOptions) { o.header.Set(key, value) }
+}
+
+// WithIfMatch is required by the PUT and DELETE endpoints, pass the ETag of the last GET
+func WithIfMatch(etag string) RequestOption { return WithHeader("If-Match", etag) }
+
+// WithIfNoneMatch makes a GET return ErrNotModified while etag is still current
+func WithIfNoneMatch(etag string) RequestOption { return WithHeader("If-None-Match", etag) }
+
+// WithIdempotencyKey makes a POST safe to retry, the server replays the first response
+func WithIdempotencyKey(key string) RequestOption { return WithHeader("Idempotency-Key", key) }
+
+// ResponseMeta is what the typed result leaves out: status and headers
+type ResponseMeta struct {
+	StatusCode int
+	Header     http.Header
+}
+
+func (m *ResponseMeta) ETag() string { return m.Header.Get("ETag") }
+
+// CaptureResponse fills meta once the call returns, also on errors
+func CaptureResponse(meta *ResponseMeta) RequestOption {
+	return func(o *requestOptions) { o.meta = meta }
+}
+
+type bodyKind int
+
+const (
+	noBody bodyKind = iota
+	jsonBody
+	formBody
+	multipartBody
+)
+
+// call is one endpoint invocation, built by the generated methods
+type call struct {
+	method string
+	path   string
+	query  any // struct with form tags
+	kind   bodyKind
+	body   any
+	files  []filePart
+}
+
+func (c *Client) do(ctx context.Context, req call, out any, opts []RequestOption) error {
+	resp, ro, err := c.send(ctx, req, "application/json", opts)
+	if err != nil {
+		return err
+	}
+	return decodeResponse(resp, out, ro.meta)
+}
+
+// stream is do for endpoints answering CSV or NDJSON: the caller reads
+// and closes the body. Errors are still decoded into an *APIError.
+func (c *Client) stream(ctx context.Context, req call, opts []RequestOption) (io.ReadCloser, error) {
+	resp, ro, err := c.send(ctx, req, "*/*", opts)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, decodeResponse(resp, nil, ro.meta)
+	}
+	if ro.meta != nil {
+		ro.meta.StatusCode = resp.StatusCode
+		ro.meta.Header = resp.Header
+	}
+	return resp.Body, nil
+}
+
+// send runs the request with retries and returns the last response, unread
+func (c *Client) send(ctx context.Context, req call, accept string, opts []RequestOption) (*http.Response, requestOptions, error) {
+	ro := requestOptions{header: http.Header{}}
+	for _, opt := range opts {
+		opt(&ro)
+	}
+
+	// encoded once, every attempt sends the same bytes (and multipart boundary)
+	body, contentType, err := encodeBody(req)
+	if err != nil {
+		return nil, ro, err
+	}
+	url := c.baseURL + req.path
+	if req.query != nil {
+		if q := encodeValues(req.query); len(q) > 0 {
+			url += "?" + q.Encode()
+		}
+	}
+
+	attempts := 1
+	if c.retry.MaxAttempts > 1 && (idempotent(req.method) || ro.header.Get("Idempotency-Key") != "") {
+		attempts = c.retry.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, url, r)
+		if err != nil {
+			return nil, ro, err
+		}
+		for k, v := range ro.header {
+			httpReq.Header[k] = v
+		}
+		httpReq.Header.Set("Accept", accept)
+		httpReq.Header.Set("User-Agent", c.userAgent)
+		if contentType != "" {
+			httpReq.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := c.http.Do(httpReq)
+		if attempt < attempts && retryable(ctx, resp, err) {
+			wait := c.retry.backoff(attempt, resp)
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+			select {
+			case <-time.After(wait):
+				continue
+			case <-ctx.Done():
+				return nil, ro, ctx.Err()
+			}
+		}
+		return resp, ro, err
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff doubles BaseDelay per attempt up to MaxDelay, with jitter over the
+// upper half. A Retry-After in seconds wins when the server sends one.
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s >= 0 {
+			return min(time.Duration(s)*time.Second, p.MaxDelay)
+		}
+	}
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func decodeResponse(resp *http.Response, out any, meta *ResponseMeta) error {
+	defer resp.Body.Close()
+	if meta != nil {
+		meta.StatusCode = resp.StatusCode
+		meta.Header = resp.Header
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return ErrNotModified
+	case resp.StatusCode >= 300:
+		return newAPIError(resp, data)
+	case out == nil || len(data) == 0:
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("client: decoding %s %s: %w", resp.Request.Method, resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+var ErrNotModified = errors.New("client: not modified")
@@ -0,0 +1,270 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/client"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	v1handler "github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/api/v1/handler"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/clientgen"
+)
+
+var fastRetry = client.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func newClient(t *testing.T) *client.Client {
+	return client.New(apitest.New(t).Server().URL, client.WithRetryPolicy(fastRetry))
+}
+
+func validProduct(name string) dto.CreateProductRequest {
+	return apitest.ProductRequest(func(p *dto.CreateProductRequest) { p.Name = name })
+}
+
+func TestGeneratedClientIsUpToDate(t *testing.T) {
+	want, err := clientgen.Generate(apitest.New(t).Engine.Routes(), v1handler.Docs(), "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("api_gen.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("api_gen.go is stale, run go generate ./client")
+	}
+}
+
+func TestProductLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t)
+
+	created, err := c.CreateProduct(ctx, validProduct("Gopher Tee"), client.WithIdempotencyKey("create-gopher-tee"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.ID == 0 || created.Data.Name != "Gopher Tee" {
+		t.Fatalf("create returned %+v", created)
+	}
+	id := dto.ProductUri{ID: created.ID}
+
+	var meta client.ResponseMeta
+	got, err := c.GetProductByID(ctx, id, client.CaptureResponse(&meta))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Data.Name != "Gopher Tee" || meta.ETag() == "" {
+		t.Fatalf("get returned %+v, etag %q", got.Data, meta.ETag())
+	}
+	if _, err := c.GetProductByID(ctx, id, client.WithIfNoneMatch(meta.ETag())); !errors.Is(err, client.ErrNotModified) {
+		t.Fatalf("conditional get: want ErrNotModified, got %v", err)
+	}
+
+	update := validProduct("Gopher Tee v2")
+	if _, err := c.UpdateProduct(ctx, id, update); client.StatusCode(err) != http.StatusPreconditionRequired {
+		t.Fatalf("update without If-Match: want 428, got %v", err)
+	}
+	if _, err := c.UpdateProduct(ctx, id, update, client.WithIfMatch(meta.ETag())); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if _, err := c.UpdateProduct(ctx, id, update, client.WithIfMatch(meta.ETag())); client.StatusCode(err) != http.StatusPreconditionFailed {
+		t.Fatalf("update with stale ETag: want 412, got %v", err)
+	}
+
+	page, err := c.GetProducts(ctx, dto.ProductQuery{Search: "gopher", Limit: 5})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if page.Meta.Total != 1 || page.Data[0].ID != created.ID {
+		t.Fatalf("search returned %+v", page)
+	}
+
+	suggestions, err := c.SuggestProducts(ctx, dto.SuggestQuery{Q: "gop"})
+	if err != nil {
+		t.Fatalf("suggest: %v", err)
+	}
+	if len(suggestions.Suggestions) == 0 {
+		t.Fatal("suggest returned nothing")
+	}
+
+	if _, err := c.GetProductByID(ctx, id, client.CaptureResponse(&meta)); err != nil {
+		t.Fatalf("get after update: %v", err)
+	}
+	if _, err := c.DeleteProduct(ctx, id, client.WithIfMatch(meta.ETag())); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := c.GetProductByID(ctx, id); client.StatusCode(err) != http.StatusNotFound {
+		t.Fatalf("get after delete: want 404, got %v", err)
+	}
+}
+
+func TestIdempotentCreateIsReplayed(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t)
+
+	first, err := c.CreateProduct(ctx, validProduct("Replay Mug"), client.WithIdempotencyKey("k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta client.ResponseMeta
+	second, err := c.CreateProduct(ctx, validProduct("Replay Mug"), client.WithIdempotencyKey("k1"), client.CaptureResponse(&meta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID != first.ID || meta.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("want replay of %d, got %d (replayed=%q)", first.ID, second.ID, meta.Header.Get("Idempotent-Replayed"))
+	}
+}
+
+func TestValidationErrorsAreTyped(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t)
+
+	bad := validProduct("ab") // min=3
+	bad.Price.Amount = 0
+	_, err := c.CreateProduct(ctx, bad)
+
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("want *client.APIError, got %T %v", err, err)
+	}
+	if !apiErr.IsValidation() || apiErr.Message == "" {
+		t.Fatalf("want a validation error, got %+v", apiErr)
+	}
+	fields, ok := client.ValidationErrors(err)
+	if !ok {
+		t.Fatal("ValidationErrors: not a validation error")
+	}
+	for _, f := range []string{"Name", "Price.Amount"} {
+		if fields[f] == "" {
+			t.Errorf("no message for %s in %v", f, fields)
+		}
+	}
+
+	if _, err := c.GetProducts(ctx, dto.ProductQuery{Search: "x"}); client.StatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("short search: want 400, got %v", err)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t)
+
+	page, err := c.GetUsers(ctx, dto.ListQuery{Sort: "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Meta.Total != 3 || page.Data[0].Name != "Alice" {
+		t.Fatalf("seeded users: %+v", page)
+	}
+
+	created, err := c.CreateUser(ctx, dto.CreateUserRequest{Name: "Dana", Slug: "dana-user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := c.GetUserByUUID(ctx, dto.UserUUIDQuery{UUID: created.Data.UUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UUID != created.Data.UUID {
+		t.Fatalf("uuid lookup: %+v", user)
+	}
+	if _, err := c.GetUserBySlug(ctx, dto.UserSlugQuery{Slug: "dana-user"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUserBySlug(ctx, dto.UserSlugQuery{Slug: "Not A Slug"}); client.StatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("bad slug: want 400, got %v", err)
+	}
+}
+
+func TestCategoryUploads(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t)
+
+	created, err := c.CreateCategory(ctx, dto.CreateCategoryRequest{
+		Name: "Shirts", ImageURL: "https://example.com/shirts.png",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := c.GetCategories(ctx, dto.ListQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Meta.Total != 1 || list.Data[0].ID != created.ID {
+		t.Fatalf("categories: %+v", list)
+	}
+
+	up, err := c.UploadCategoryImage(ctx,
+		dto.UploadCategoryQuery{Source: "admin"},
+		dto.UploadCategoryForm{Name: "Shirts", Description: "summer"},
+		client.NewUpload("shirts.png", apitest.PNG),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up.Name != "Shirts" || up.Source != "admin" || up.File == "" {
+		t.Fatalf("upload: %+v", up)
+	}
+
+	_, err = c.UploadCategoryImage(ctx, dto.UploadCategoryQuery{}, dto.UploadCategoryForm{Name: "Shirts"},
+		client.NewUpload("notes.txt", []byte("hello")))
+	if client.StatusCode(err) != http.StatusBadRequest {
+		t.Fatalf("text upload: want 400, got %v", err)
+	}
+
+	multi, err := c.UploadMultipleCategoryImages(ctx, []client.Upload{
+		client.NewUpload("a.png", apitest.PNG),
+		client.NewUpload("b.txt", []byte("hello")),
+	}, client.WithIdempotencyKey("multi-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(multi.Files) != 1 || len(multi.Failed) != 1 || multi.Failed[0].File != "b.txt" {
+		t.Fatalf("multi upload: %+v", multi)
+	}
+}
+
+func TestRetriesWithBackoff(t *testing.T) {
+	h := apitest.New(t)
+	var calls atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if calls.Add(1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		h.Engine.ServeHTTP(w, req)
+	}))
+	defer flaky.Close()
+
+	ctx := context.Background()
+	c := client.New(flaky.URL, client.WithRetryPolicy(fastRetry))
+
+	if _, err := c.GetUsers(ctx, dto.ListQuery{}); err != nil {
+		t.Fatalf("GET should survive two 503s: %v", err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("want 3 attempts, got %d", n)
+	}
+
+	// a POST without Idempotency-Key could create twice, so it is not retried
+	calls.Store(0)
+	if _, err := c.CreateUser(ctx, dto.CreateUserRequest{Name: "Erin"}); client.StatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("want 503, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("want 1 attempt, got %d", n)
+	}
+
+	calls.Store(0)
+	if _, err := c.CreateProduct(ctx, validProduct("Retry Cap"), client.WithIdempotencyKey("retry-cap")); err != nil {
+		t.Fatalf("keyed POST should be retried: %v", err)
+	}
+}
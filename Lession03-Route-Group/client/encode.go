@@ -0,0 +1,195 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Upload is one file of a multipart request. Data is kept in memory so a
+// retry can send it again.
+type Upload struct {
+	Filename    string
+	ContentType string // sniffed from Data when empty
+	Data        []byte
+}
+
+// NewUpload wraps bytes you already have, e.g. NewUpload("logo.png", png)
+func NewUpload(filename string, data []byte) Upload {
+	return Upload{Filename: filename, Data: data}
+}
+
+// UploadFile reads a file from disk
+func UploadFile(path string) (Upload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Upload{}, err
+	}
+	return Upload{Filename: filepath.Base(path), Data: data}, nil
+}
+
+type filePart struct {
+	field   string
+	uploads []Upload
+}
+
+func encodeBody(req call) ([]byte, string, error) {
+	switch req.kind {
+	case jsonBody:
+		data, err := json.Marshal(req.body)
+		return data, "application/json", err
+	case formBody:
+		return []byte(encodeValues(req.body).Encode()), "application/x-www-form-urlencoded", nil
+	case multipartBody:
+		return encodeMultipart(req)
+	}
+	return nil, "", nil
+}
+
+func encodeMultipart(req call) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	if req.body != nil {
+		for key, values := range encodeValues(req.body) {
+			for _, v := range values {
+				if err := w.WriteField(key, v); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+	}
+	for _, f := range req.files {
+		for _, u := range f.uploads {
+			ct := u.ContentType
+			if ct == "" {
+				ct = http.DetectContentType(u.Data)
+			}
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, f.field, u.Filename))
+			h.Set("Content-Type", ct)
+			part, err := w.CreatePart(h)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := part.Write(u.Data); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// encodeValues is the reverse of gin's form binding: every set field with a
+// `form` tag becomes a value, zero values are left out
+func encodeValues(v any) url.Values {
+	out := url.Values{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return out
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct {
+		addFields(out, rv)
+	}
+	return out
+}
+
+func addFields(out url.Values, rv reflect.Value) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("form"), ",")
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if sf.Anonymous && name == "" && fv.Kind() == reflect.Struct {
+			addFields(out, fv)
+			continue
+		}
+		if !sf.IsExported() || fv.IsZero() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		for fv.Kind() == reflect.Pointer {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fv.Len(); j++ {
+				out.Add(name, formatValue(fv.Index(j)))
+			}
+			continue
+		}
+		out.Add(name, formatValue(fv))
+	}
+}
+
+func formatValue(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// expandPath fills the :params of route from the `uri` fields of v
+func expandPath(route string, v any) string {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	var pairs []string
+	for i := 0; i < rv.NumField(); i++ {
+		name, _, _ := strings.Cut(rv.Type().Field(i).Tag.Get("uri"), ",")
+		if name != "" && name != "-" {
+			pairs = append(pairs, name, formatValue(rv.Field(i)))
+		}
+	}
+	return expandPathValues(route, pairs...)
+}
+
+// expandPathValues takes name, value pairs
+func expandPathValues(route string, pairs ...string) string {
+	values := map[string]string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		values[pairs[i]] = pairs[i+1]
+	}
+	segs := strings.Split(route, "/")
+	for i, seg := range segs {
+		if seg != "" && (seg[0] == ':' || seg[0] == '*') {
+			segs[i] = url.PathEscape(values[seg[1:]])
+		}
+	}
+	return strings.Join(segs, "/")
+}
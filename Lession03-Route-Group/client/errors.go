@@ -0,0 +1,63 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+)
+
+// APIError is any non 2xx answer, decoded from the server's dto.ErrorResponse
+type APIError struct {
+	StatusCode int
+	Message    string            // "error"
+	Detail     string            // "msg", when the handler adds one
+	Fields     map[string]string // field name -> message, from utils.FormatValidationErrors
+	Header     http.Header
+	Body       []byte // raw body, e.g. the "failed" list of a multi upload
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if len(e.Fields) > 0 {
+		return fmt.Sprintf("api error %d: %s %v", e.StatusCode, msg, e.Fields)
+	}
+	return fmt.Sprintf("api error %d: %s", e.StatusCode, msg)
+}
+
+// IsValidation reports a 400 with per-field messages
+func (e *APIError) IsValidation() bool {
+	return e.StatusCode == http.StatusBadRequest && len(e.Fields) > 0
+}
+
+func newAPIError(resp *http.Response, data []byte) *APIError {
+	e := &APIError{StatusCode: resp.StatusCode, Header: resp.Header, Body: data}
+	var body dto.ErrorResponse
+	if json.Unmarshal(data, &body) == nil {
+		e.Message, e.Detail, e.Fields = body.Error, body.Msg, body.Fields
+	}
+	return e
+}
+
+// ValidationErrors returns the field errors of err when it is a validation failure
+func ValidationErrors(err error) (map[string]string, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.IsValidation() {
+		return apiErr.Fields, true
+	}
+	return nil, false
+}
+
+// StatusCode is the HTTP status of err, 0 when it did not come from the server
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+// Command clientgen regenerates client/api_gen.go, run it through
+// `go generate ./client` after changing a route or a DTO.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	v1handler "github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/api/v1/handler"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/clientgen"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+)
+
+func main() {
+	out := flag.String("out", "api_gen.go", "file to write")
+	pkg := flag.String("pkg", "client", "package name of the generated file")
+	flag.Parse()
+
+	gin.SetMode(gin.ReleaseMode)
+	r := router.New(router.Config{UploadDir: os.TempDir()})
+
+	src, err := clientgen.Generate(r.Routes(), v1handler.Docs(), *pkg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
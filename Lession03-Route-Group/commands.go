@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/client"
+	v1handler "github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/api/v1/handler"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/seed"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/stream"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/webhook"
+)
+
+func runServe(ctx context.Context, a *app, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("serve takes no arguments, got %q", args)
+	}
+	if err := a.cfg.Validate(); err != nil {
+		return err
+	}
+	if err := a.cfg.PrepareUploadDir(); err != nil {
+		return err
+	}
+
+	fixtures, err := a.cfg.LoadFixtures()
+	if err != nil {
+		return err
+	}
+	queue, err := a.Queue(ctx)
+	if err != nil {
+		return err
+	}
+	rc := a.cfg.Router()
+	rc.Jobs = queue
+	rc.DB = queue.DB()
+	rc.Progress = stream.NewBroker(router.ProgressHistory)
+	rc.Webhooks = webhook.New(queue, webhook.Config{})
+	engine := router.New(rc)
+	if a.cfg.cleanupScheduled() {
+		err := queue.Schedule(ctx, "cleanup-uploads", a.cfg.CleanupSchedule, v1handler.CleanupUploadsJob, nil)
+		if err != nil {
+			return err
+		}
+	}
+	if fixtures != nil {
+		report, err := seed.New(seed.InProcess(engine)).Apply(ctx, fixtures)
+		if err != nil {
+			return err
+		}
+		log.Printf("seeded %s", report)
+	}
+
+	// The workers and the outbox relay stop with the server, jobs they are
+	// in go back to the queue
+	workCtx, stopWork := context.WithCancel(ctx)
+	worked := make(chan struct{}, 2)
+	go func() {
+		queue.Run(workCtx)
+		worked <- struct{}{}
+	}()
+	go func() {
+		rc.Webhooks.Run(workCtx)
+		worked <- struct{}{}
+	}()
+	defer func() {
+		stopWork()
+		<-worked
+		<-worked
+	}()
+
+	srv := &http.Server{Addr: a.cfg.Addr, Handler: engine}
+	// Event streams never finish on their own
+	srv.RegisterOnShutdown(rc.Progress.Close)
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	log.Printf("listening on %s", a.cfg.Addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Let requests in flight finish, up to a point
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func runRoutes(_ context.Context, a *app, _ []string) error {
+	mode := gin.Mode()
+	gin.SetMode(gin.ReleaseMode) // no [GIN-debug] lines for every route
+	routes := a.Engine().Routes()
+	gin.SetMode(mode)
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATH\tHANDLER")
+	for _, rt := range routes {
+		// github.com/.../handler.(*ProductHandler).GetProducts-fm -> handler.(*ProductHandler).GetProducts
+		handler := strings.TrimSuffix(rt.Handler, "-fm")
+		if i := strings.LastIndex(handler, "/"); i >= 0 {
+			handler = handler[i+1:]
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", rt.Method, rt.Path, handler)
+	}
+	return w.Flush()
+}
+
+func runValidateConfig(_ context.Context, a *app, _ []string) error {
+	if err := a.cfg.Validate(); err != nil {
+		return err
+	}
+	cache := a.cfg.Cache
+	if u, err := url.Parse(cache); err == nil {
+		cache = u.Redacted() // no Redis password in the output
+	}
+	fmt.Fprintf(a.out, "config ok\n  addr              %s\n  db                %s\n  upload-dir        %s\n  verify-images     %t\n  ingest-images     %t\n  fixtures          %s\n  synthetic         %d\n  cleanup-schedule  %s\n  cache             %s\n",
+		a.cfg.Addr, a.cfg.DatabasePath, a.cfg.UploadDir, a.cfg.VerifyImages, a.cfg.IngestImages, a.cfg.Fixtures, a.cfg.Synthetic, a.cfg.CleanupSchedule, cache)
+	return nil
+}
+
+// runSeed posts the -fixtures (the demo catalogue by default) and the
+// -synthetic products to a running server. Records already there are skipped.
+func runSeed(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	fs.SetOutput(a.out)
+	url := fs.String("url", serverURL(a.cfg.Addr), "base URL of the server to seed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := a.cfg
+	if cfg.Fixtures == "" && cfg.Synthetic == 0 {
+		cfg.Fixtures = "demo"
+	}
+	fixtures, err := cfg.LoadFixtures()
+	if err != nil {
+		return err
+	}
+	report, err := seed.New(client.New(*url)).Apply(ctx, fixtures)
+	fmt.Fprintln(a.out, report)
+	return err
+}
+
+// serverURL is where serve listens on addr, seen from this machine
+func serverURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
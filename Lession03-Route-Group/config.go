@@ -0,0 +1,226 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/cache"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/db"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/remoteimage"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/seed"
+)
+
+// Config is shared by every command. Each field has an environment variable
+// and a global flag, the flag wins: lession03 -addr :9090 serve
+type Config struct {
+	Addr         string // LESSION03_ADDR
+	DatabasePath string // DATABASE_PATH
+	UploadDir    string // LESSION03_UPLOAD_DIR
+	VerifyImages bool   // LESSION03_VERIFY_IMAGES, fetch image URLs to check they are images
+	IngestImages bool   // LESSION03_INGEST_IMAGES, copy category images into UploadDir
+	Fixtures     string // LESSION03_FIXTURES, "demo" or a fixture file serve and seed load
+	Synthetic    int    // LESSION03_SYNTHETIC, how many made up products serve and seed add
+	// LESSION03_CLEANUP_SCHEDULE, cron spec of the job removing unused
+	// uploads, "off" (or empty) to never run it
+	CleanupSchedule string
+	// LESSION03_CACHE, where serve caches product and user reads: "off"
+	// (or empty, the default), "memory", or redis://[:password@]host[:port][/db]
+	// to share them across replicas
+	Cache string
+	// LESSION03_TRUSTED_PROXIES, comma separated addresses or CIDRs of the
+	// reverse proxies whose X-Forwarded-For is believed, none by default
+	TrustedProxies string
+}
+
+// loadConfig reads the environment through getenv, then the global flags in
+// args, and returns what is left of args (the command and its arguments)
+func loadConfig(args []string, getenv func(string) string, out io.Writer) (Config, []string, error) {
+	envBool := func(key string) bool {
+		b, _ := strconv.ParseBool(getenv(key))
+		return b
+	}
+	envInt := func(key string) int {
+		n, _ := strconv.Atoi(getenv(key))
+		return n
+	}
+
+	var cfg Config
+	fs := flag.NewFlagSet("lession03", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() { usage(out); fs.PrintDefaults() }
+	fs.StringVar(&cfg.Addr, "addr", cmp.Or(getenv("LESSION03_ADDR"), ":8080"), "address serve listens on, $LESSION03_ADDR")
+	fs.StringVar(&cfg.DatabasePath, "db", cmp.Or(getenv("DATABASE_PATH"), db.DefaultPath), "SQLite database file, $DATABASE_PATH")
+	fs.StringVar(&cfg.UploadDir, "upload-dir", cmp.Or(getenv("LESSION03_UPLOAD_DIR"), router.DefaultUploadDir), "where category images are saved, $LESSION03_UPLOAD_DIR")
+	fs.BoolVar(&cfg.VerifyImages, "verify-images", envBool("LESSION03_VERIFY_IMAGES"), "fetch image URLs to check they serve images, $LESSION03_VERIFY_IMAGES")
+	fs.BoolVar(&cfg.IngestImages, "ingest-images", envBool("LESSION03_INGEST_IMAGES"), "copy category images into -upload-dir (needs -verify-images), $LESSION03_INGEST_IMAGES")
+	fs.StringVar(&cfg.Fixtures, "fixtures", getenv("LESSION03_FIXTURES"), `"demo" or a .yaml/.json fixture file, $LESSION03_FIXTURES`)
+	fs.IntVar(&cfg.Synthetic, "synthetic", envInt("LESSION03_SYNTHETIC"), "add this many synthetic products, for load tests, $LESSION03_SYNTHETIC")
+	fs.StringVar(&cfg.CleanupSchedule, "cleanup-schedule", cmp.Or(getenv("LESSION03_CLEANUP_SCHEDULE"), "@daily"), `cron spec of the unused upload cleanup, "off" for never, $LESSION03_CLEANUP_SCHEDULE`)
+	fs.StringVar(&cfg.Cache, "cache", cmp.Or(getenv("LESSION03_CACHE"), "off"), `"off", "memory" or a redis:// URL, $LESSION03_CACHE`)
+	fs.StringVar(&cfg.TrustedProxies, "trusted-proxies", getenv("LESSION03_TRUSTED_PROXIES"), "comma separated proxy addresses or CIDRs allowed to set X-Forwarded-For, $LESSION03_TRUSTED_PROXIES")
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// Validate returns every problem at once, joined
+func (c Config) Validate() error {
+	var errs []error
+	if _, port, err := net.SplitHostPort(c.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("addr %q: %w", c.Addr, err))
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		errs = append(errs, fmt.Errorf("addr %q: bad port", c.Addr))
+	}
+
+	if dir := filepath.Dir(c.DatabasePath); !isDir(dir) {
+		errs = append(errs, fmt.Errorf("db %q: directory %s does not exist", c.DatabasePath, dir))
+	}
+	if err := checkUploadDir(c.UploadDir); err != nil {
+		errs = append(errs, fmt.Errorf("upload-dir %q: %w", c.UploadDir, err))
+	}
+
+	if c.cleanupScheduled() {
+		if _, err := jobs.ParseCron(c.CleanupSchedule); err != nil {
+			errs = append(errs, fmt.Errorf("cleanup-schedule: %w", err))
+		}
+	}
+	if _, err := c.responseCache(); err != nil {
+		errs = append(errs, fmt.Errorf("cache: %w", err))
+	}
+	for _, p := range c.trustedProxies() {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			if _, err := netip.ParseAddr(p); err != nil {
+				errs = append(errs, fmt.Errorf("trusted-proxies: %q is not an address or CIDR", p))
+			}
+		}
+	}
+	if c.IngestImages && !c.VerifyImages {
+		errs = append(errs, errors.New("ingest-images needs verify-images"))
+	}
+	if c.Synthetic < 0 {
+		errs = append(errs, errors.New("synthetic can't be negative"))
+	} else if f, err := c.LoadFixtures(); err != nil {
+		errs = append(errs, fmt.Errorf("fixtures: %w", err))
+	} else if f != nil {
+		if err := f.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("fixtures: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadFixtures is -fixtures plus -synthetic products, nil when both are unset
+func (c Config) LoadFixtures() (*seed.Fixtures, error) {
+	f := &seed.Fixtures{}
+	switch c.Fixtures {
+	case "":
+		if c.Synthetic == 0 {
+			return nil, nil
+		}
+	case "demo":
+		f = seed.Demo()
+	default:
+		var err error
+		if f, err = seed.LoadFile(c.Fixtures); err != nil {
+			return nil, err
+		}
+	}
+	if c.Synthetic > 0 {
+		f.Append(seed.Synthetic(c.Synthetic, 1))
+	}
+	return f, nil
+}
+
+func (c Config) cleanupScheduled() bool {
+	return c.CleanupSchedule != "" && c.CleanupSchedule != "off"
+}
+
+// responseCache is the store -cache names, nil for "off"
+func (c Config) responseCache() (*cache.Cache, error) {
+	switch c.Cache {
+	case "", "off":
+		return nil, nil
+	case "memory":
+		return cache.New(cache.NewLRU(0)), nil
+	}
+	rc, err := cache.ParseRedisURL(c.Cache)
+	if err != nil {
+		return nil, err
+	}
+	return cache.New(cache.NewRedis(rc)), nil
+}
+
+// trustedProxies is -trusted-proxies split, nil when empty
+func (c Config) trustedProxies() []string {
+	var proxies []string
+	for p := range strings.SplitSeq(c.TrustedProxies, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// checkUploadDir looks at dir, or the closest parent that exists when serve
+// is still to create it, without touching anything: validate-config only
+// reads. The permission bits are a first look, PrepareUploadDir tries for real.
+func checkUploadDir(dir string) error {
+	for p := filepath.Clean(dir); ; p = filepath.Dir(p) {
+		fi, err := os.Stat(p)
+		if errors.Is(err, fs.ErrNotExist) && p != filepath.Dir(p) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%s is not a directory", p)
+		}
+		if fi.Mode().Perm()&0o222 == 0 {
+			return fmt.Errorf("%s is not writable", p)
+		}
+		return nil
+	}
+}
+
+// PrepareUploadDir creates the upload dir and checks a file can be written
+// in it, for serve to fail at start rather than on the first upload
+func (c Config) PrepareUploadDir() error {
+	if err := os.MkdirAll(c.UploadDir, os.ModePerm); err != nil {
+		return fmt.Errorf("upload-dir: %w", err)
+	}
+	f, err := os.CreateTemp(c.UploadDir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("upload-dir %q is not writable: %w", c.UploadDir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Router is the part of c the HTTP server needs
+func (c Config) Router() router.Config {
+	rc := router.Config{UploadDir: c.UploadDir, IngestImages: c.IngestImages, TrustedProxies: c.trustedProxies()}
+	if c.VerifyImages {
+		rc.Images = remoteimage.New(remoteimage.Config{})
+	}
+	rc.Cache, _ = c.responseCache() // checked by Validate
+	return rc
+}
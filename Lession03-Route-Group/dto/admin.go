@@ -0,0 +1,7 @@
+package dto
+
+// CreateAdminRequest is what `lession03 user create-admin` takes as flags
+type CreateAdminRequest struct {
+	Name  string `form:"name" normalize:"trim,nfc" binding:"required,min=3,max=100"`
+	Email string `form:"email" normalize:"trim,lower" binding:"required,email"`
+}
@@ -2,14 +2,30 @@
 package dto
 
 type CreateCategoryRequest struct {
-	Name        string `form:"name" binding:"required,min=3,max=100"`
-	Description string `form:"description" binding:"omitempty,max=255"`
-	ImageURL    string `form:"image_url" binding:"required,url,imgext"`
+	Name        string `form:"name" normalize:"trim,nfc" binding:"required,min=3,max=100"`
+	Description string `form:"description" normalize:"trim" binding:"omitempty,max=255"`
+	ImageURL    string `form:"image_url" normalize:"trim" binding:"required,url,imgext"`
+	ParentID    int    `form:"parent_id" binding:"omitempty,gt=0"` // empty for a top level category
+}
+
+type CategoryUri struct {
+	ID int `uri:"id" binding:"gt=0"`
+}
+
+// MoveCategoryRequest puts a category (with its subtree) under another one, 0 for the top level
+type MoveCategoryRequest struct {
+	ParentID int `json:"parent_id" binding:"gte=0"`
+}
+
+// CategoryProductsQuery is ListQuery plus whether subcategories count
+type CategoryProductsQuery struct {
+	ListQuery
+	Direct bool `form:"direct"` // only products assigned to the category itself
 }
 
 type UploadCategoryForm struct {
-	Name        string `form:"name" binding:"required,min=3,max=100"`
-	Description string `form:"description" binding:"omitempty,max=500"`
+	Name        string `form:"name" normalize:"trim,nfc" binding:"required,min=3,max=100"`
+	Description string `form:"description" normalize:"trim" binding:"omitempty,max=500"`
 }
 
 type UploadCategoryQuery struct {
@@ -0,0 +1,5 @@
+package dto
+
+type JobUri struct {
+	ID string `uri:"id" binding:"required,uuid4"`
+}
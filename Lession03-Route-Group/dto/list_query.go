@@ -0,0 +1,10 @@
+package dto
+
+// ListQuery is the pagination part shared by list endpoints (users, categories).
+// Sort looks like "-created_at,name": comma separated, "-" for descending.
+type ListQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,gt=0,lte=100"`
+	Offset int    `form:"offset" binding:"omitempty,gte=0"`
+	Cursor string `form:"cursor" binding:"omitempty,base64rawurl"`
+	Sort   string `form:"sort" normalize:"trim" binding:"omitempty,max=100"`
+}
@@ -0,0 +1,7 @@
+package dto
+
+// NotificationsQuery is the WebSocket handshake of GET /notifications.
+// Browsers pass a ticket, other clients can send Authorization instead.
+type NotificationsQuery struct {
+	Ticket string `form:"ticket" binding:"omitempty,max=64"`
+}
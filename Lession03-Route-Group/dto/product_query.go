@@ -1,39 +1,103 @@
 package dto
 
 type ProductQuery struct {
-	Search string `form:"search" validate:"required,min=3,max=50,alphanumspace"`
-	Limit  int    `form:"limit" validate:"omitempty,gt=0"`
-	Email  string `form:"email" binding:"omitempty,email"`
-	Date   string `form:"date" binding:"omitempty,datetime=2006-01-02"`
+	Search   string `form:"search" normalize:"trim,nfc" binding:"required,min=3,max=50,alphanumspace"`
+	Limit    int    `form:"limit" binding:"omitempty,gt=0,lte=100"` // 0 means listquery.DefaultLimit
+	Offset   int    `form:"offset" binding:"omitempty,gte=0"`
+	Cursor   string `form:"cursor" binding:"omitempty,base64rawurl"`
+	Sort     string `form:"sort" normalize:"trim" binding:"omitempty,max=100"` // e.g. "-price,name"
+	PriceMin *int64 `form:"price_min" binding:"omitempty,gte=0"`               // minor units of Currency
+	PriceMax *int64 `form:"price_max" binding:"omitempty,gte=0"`
+	Currency string `form:"currency" normalize:"trim,upper" binding:"required_with=PriceMin PriceMax,omitempty,iso4217"` // only products priced in it
+	Email    string `form:"email" normalize:"trim,lower" binding:"omitempty,email"`
+	Date     string `form:"date" binding:"omitempty,datetime=2006-01-02"` // products created on this day
+}
+
+// SuggestQuery is for the search box, so unlike ProductQuery.Search one character is enough
+type SuggestQuery struct {
+	Q     string `form:"q" normalize:"trim,nfc" binding:"required,max=50"`
+	Limit int    `form:"limit" binding:"omitempty,gt=0,lte=10"`
 }
 
 type ProductLangUri struct {
 	Lang string `uri:"lang" binding:"required,oneof=php golang python"`
 }
 
+type ProductUri struct {
+	ID int `uri:"id" binding:"gt=0"`
+}
+
+// ProductSlugUri has no min length: slugs made from short names ("mug") are short
+type ProductSlugUri struct {
+	Slug string `uri:"slug" binding:"required,max=100,slug"`
+}
+
 type ProductImage struct {
-	URL     string `json:"url" binding:"required,url,imgext"`
+	URL     string `json:"url" normalize:"trim" binding:"required,url,imgext"`
 	AltText string `json:"alt_text" binding:"omitempty,max=100"`
 }
 type AvartarImage struct {
-	URL string `json:"url" binding:"required,url,imgext"`
+	URL string `json:"url" normalize:"trim" binding:"required,url,imgext"`
 	Alt string `json:"alt" binding:"omitempty,max=100"`
 }
 type ProductInfo struct {
-	InfoKey   string `json:"info_key" binding:"required"`
-	InfoValue string `json:"info_value" binding:"required"`
+	InfoKey   string `json:"info_key" normalize:"trim" binding:"required"`
+	InfoValue string `json:"info_value" normalize:"trim" binding:"required"`
 }
 
 type CreateProductRequest struct {
 	ProductInfo map[string]ProductInfo `json:"product_info" binding:"required,dive"`
 	Avartar     AvartarImage           `json:"avartar" binding:"required"`
-	Tags        []string               `json:"tags" binding:"omitempty,dive,required,min=2,max=30"`
+	Tags        []string               `json:"tags" normalize:"trim,nfc" binding:"omitempty,dive,required,min=2,max=30"`
 	Image       []ProductImage         `json:"image" binding:"required,dive"` // // ✅ Required & dive into each item
 	Display     *bool                  `json:"display" binding:"omitempty"`   //By changing Display to a pointer (*bool), you can detect if it was omitted:
-	Name        string                 `json:"name" binding:"required,min=3,max=100"`
-	Description string                 `json:"description" binding:"omitempty,max=500"`
-	Price       float64                `json:"price" binding:"required,gt=0,lte=100"`
-	Stock       int                    `json:"stock" binding:"required,gte=0"`
-	Email       string                 `json:"email" binding:"omitempty,email"`
+	Name        string                 `json:"name" normalize:"trim,nfc" binding:"required,min=3,max=100"`
+	Slug        string                 `json:"slug,omitempty" normalize:"trim" binding:"omitempty,max=100,slug"` // made from Name when empty
+	Description string                 `json:"description" normalize:"trim" binding:"omitempty,max=500"`
+	Price       MoneyRequest           `json:"price" binding:"required"`
+	Stock       int                    `json:"stock" binding:"gte=0"`
+	Email       string                 `json:"email" normalize:"trim,lower" binding:"omitempty,email"`
+	Variants    []VariantRequest       `json:"variants,omitempty" binding:"omitempty,max=50,unique=SKU,dive"`
+	CategoryIDs []int                  `json:"category_ids,omitempty" binding:"omitempty,max=20,unique,dive,gt=0"`
 	CreatedAt   string                 `json:"created_at,omitempty"` // return to client, but not accepted from client
 }
+
+// MoneyRequest is an amount in minor units: 1250 USD is $12.50, 250000 VND is 250.000đ
+type MoneyRequest struct {
+	Amount   int64  `json:"amount" binding:"gt=0"`
+	Currency string `json:"currency" normalize:"trim,upper" binding:"required,iso4217"`
+}
+
+type VariantRequest struct {
+	SKU     string            `json:"sku" normalize:"trim,upper" binding:"required,max=64,sku"`
+	Options map[string]string `json:"options,omitempty" normalize:"trim" binding:"omitempty,max=5,dive,keys,oneof=size colour material,endkeys,required,max=50"`
+	Prices  []MoneyRequest    `json:"prices" binding:"required,min=1,unique=Currency,dive"`
+	Stock   int               `json:"stock" binding:"gte=0"`
+	Images  []ProductImage    `json:"images,omitempty" binding:"omitempty,max=10,dive"`
+}
+
+type ReserveRequest struct {
+	SKU        string `json:"sku" normalize:"trim,upper" binding:"required,max=64,sku"`
+	Quantity   int    `json:"quantity" binding:"required,gt=0,lte=1000"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty" binding:"omitempty,gte=60,lte=3600"` // default 900
+}
+
+type ReservationUri struct {
+	ID string `uri:"id" binding:"required,uuid4"`
+}
+
+// ImportQuery goes with POST /products/import. Format defaults to what the
+// Content-Type or the file name says.
+type ImportQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv ndjson"`
+	DryRun bool   `form:"dry_run"` // validate every row, create nothing
+}
+
+type ImportUri struct {
+	ID string `uri:"id" binding:"required,uuid4"`
+}
+
+// ExportQuery goes with GET /products/export. Format defaults to the Accept header, then ndjson.
+type ExportQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=csv ndjson"`
+}
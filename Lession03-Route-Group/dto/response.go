@@ -0,0 +1,256 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/listquery"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/suggest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// Response shapes. Handlers still write gin.H, these structs spell out the
+// same keys so the OpenAPI document can describe them.
+
+// ErrorResponse is every 4xx/5xx body. Fields comes from utils.FormatValidationErrors.
+type ErrorResponse struct {
+	Error  string            `json:"error"`
+	Fields map[string]string `json:"fields,omitempty"`
+	Msg    string            `json:"msg,omitempty"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// ProductSearchResult is a product in GET /products, with why it matched
+type ProductSearchResult struct {
+	models.Product
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+type ProductPage = listquery.Page[ProductSearchResult]
+
+type ProductResponse struct {
+	Message string         `json:"message"`
+	Data    models.Product `json:"data"`
+}
+
+type CreateProductResponse struct {
+	Message string               `json:"message"`
+	ID      int                  `json:"id"`
+	Slug    string               `json:"slug"`
+	Data    CreateProductRequest `json:"data"`
+}
+
+type ReservationResponse struct {
+	Message string             `json:"message"`
+	Data    models.Reservation `json:"data"`
+}
+
+// ImportSummary is the state of a bulk import, without its per-row results
+type ImportSummary struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"` // running, done or failed
+	Format     string     `json:"format"`
+	DryRun     bool       `json:"dry_run"`
+	Rows       int        `json:"rows"`
+	Created    int        `json:"created"`
+	Valid      int        `json:"valid"` // dry run: rows that would have been created
+	Invalid    int        `json:"invalid"`
+	Failed     int        `json:"failed"`
+	Error      string     `json:"error,omitempty"` // why the input as a whole couldn't be read
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type ImportResponse struct {
+	Message string        `json:"message"`
+	Data    ImportSummary `json:"data"`
+}
+
+// ImportResult is one line of GET /imports/:id/results. Error, Msg and
+// Fields are what POST /products would have answered for the row.
+type ImportResult struct {
+	Line   int               `json:"line"`
+	Status string            `json:"status"` // created, valid, invalid or failed
+	ID     int               `json:"id,omitempty"`
+	Slug   string            `json:"slug,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Msg    string            `json:"msg,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// UploadProgress is the "upload" event of GET /events?topic=uploads, one per
+// file of a POST /categories/upload-multiple
+type UploadProgress struct {
+	UploadID string `json:"upload_id"` // X-Upload-ID of the request, or one made up
+	File     string `json:"file"`
+	Done     int    `json:"done"` // files processed so far, this one included
+	Total    int    `json:"total"`
+	Status   string `json:"status"` // saved or failed
+	URL      string `json:"url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// StreamReset is the "reset" event of GET /events: events after the client's
+// Last-Event-ID were lost, it should reload what it shows
+type StreamReset struct {
+	Message string `json:"message"`
+}
+
+// Job is the state of a background job, for clients polling GET /jobs/:id
+type Job struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	Status      string     `json:"status"` // queued, running, succeeded or dead
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	LastError   string     `json:"last_error,omitempty"` // of the last failed attempt
+	Result      any        `json:"result,omitempty"`     // once succeeded, depends on the kind
+	RunAt       time.Time  `json:"run_at"`               // queued: not before
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+type JobResponse struct {
+	Message string `json:"message"`
+	Data    Job    `json:"data"`
+}
+
+// UploadCleanup is the result of an uploads.cleanup job
+type UploadCleanup struct {
+	Removed []string `json:"removed"` // file names
+	Kept    int      `json:"kept"`
+}
+
+// ImageCheckResult is the result of a products.check-images or
+// categories.check-image job
+type ImageCheckResult struct {
+	Failed   map[string]string `json:"failed,omitempty"`    // field -> why its URL is no good
+	Hidden   bool              `json:"hidden,omitempty"`    // the product was hidden for them
+	ImageURL *string           `json:"image_url,omitempty"` // the category's image now, "" when it was dropped
+}
+
+// NotificationTicket opens one GET /notifications WebSocket before ExpiresAt
+type NotificationTicket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Webhook is a subscription. Secret, the key of the X-Webhook-Signature
+// HMAC, is only in the answer to POST /webhooks.
+type Webhook struct {
+	ID          string    `json:"id"`
+	URL         string    `json:"url"`
+	Events      []string  `json:"events"`
+	Secret      string    `json:"secret,omitempty"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type WebhookResponse struct {
+	Message string  `json:"message"`
+	Data    Webhook `json:"data"`
+}
+
+type WebhookListResponse struct {
+	Data []Webhook `json:"data"`
+}
+
+// WebhookDelivery is one attempt to deliver an event
+type WebhookDelivery struct {
+	EventID    string    `json:"event_id"`
+	EventType  string    `json:"event_type"`
+	DeliveryID string    `json:"delivery_id"` // X-Webhook-Delivery, the same across retries
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"` // absent when no response came
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Data []WebhookDelivery `json:"data"`
+}
+
+type ProductLangResponse struct {
+	Language string `json:"language"`
+	Message  string `json:"message"`
+}
+
+type SuggestResponse struct {
+	Query       string               `json:"query"`
+	Suggestions []suggest.Suggestion `json:"suggestions"`
+}
+
+type UserPage = listquery.Page[models.User]
+
+type UserResponse struct {
+	ID      int         `json:"id,omitempty"`
+	Message string      `json:"message"`
+	Data    models.User `json:"data"`
+}
+
+type UserUUIDResponse struct {
+	UUID    string `json:"uuid"`
+	Message string `json:"message"`
+}
+
+type UserSlugResponse struct {
+	Type    string       `json:"type,omitempty"`
+	Slug    string       `json:"slug,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Data    *models.User `json:"data,omitempty"`
+}
+
+type CategoryPage = listquery.Page[models.Category]
+
+// CategoryNode is a category with its subcategories, all the way down
+type CategoryNode struct {
+	models.Category
+	Children []*CategoryNode `json:"children,omitempty"`
+}
+
+type CategoryTreeResponse struct {
+	Data *CategoryNode `json:"data"`
+}
+
+type CategoryResponse struct {
+	Message string          `json:"message"`
+	Data    models.Category `json:"data"`
+}
+
+type CategoryProductPage = listquery.Page[models.Product]
+
+type CreateCategoryResponse struct {
+	Message string                `json:"message"`
+	ID      int                   `json:"id"`
+	Data    CreateCategoryRequest `json:"data"`
+}
+
+type UploadCategoryResponse struct {
+	Message     string `json:"message"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	UserID      string `json:"user_id"`
+	Source      string `json:"source"`
+	File        string `json:"file"`
+	Path        string `json:"path"`
+	Size        string `json:"size"`
+}
+
+type FailedUpload struct {
+	File  string `json:"file"`
+	Error string `json:"error"`
+}
+
+type UploadMultipleResponse struct {
+	Message  string         `json:"message,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Files    []string       `json:"files,omitempty"`
+	Failed   []FailedUpload `json:"failed"`
+	UploadID string         `json:"upload_id"` // of its events on GET /events?topic=uploads
+}
@@ -0,0 +1,6 @@
+package dto
+
+// StreamQuery picks the topics of GET /events, ?topic=uploads&topic=jobs
+type StreamQuery struct {
+	Topic []string `form:"topic" binding:"required,min=1,dive,oneof=uploads jobs"`
+}
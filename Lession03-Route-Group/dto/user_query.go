@@ -11,5 +11,12 @@ type UserQuery struct {
 }
 
 type UserSlugQuery struct {
-	Slug string `uri:"slug" binding:"required,min=5,max=100,slug"`
+	Slug string `uri:"slug" binding:"required,max=100,slug"` // generated slugs can be shorter than 5
+}
+
+// CreateUserRequest is the body of POST /users and PUT /users/:id
+type CreateUserRequest struct {
+	Name  string `json:"name" normalize:"trim,nfc" binding:"required,min=3,max=100"`
+	Email string `json:"email" normalize:"trim,lower" binding:"omitempty,email"`
+	Slug  string `json:"slug" normalize:"trim" binding:"omitempty,min=5,max=100,slug"` // made from Name when empty
 }
@@ -0,0 +1,17 @@
+package dto
+
+// CreateWebhookRequest subscribes url to events, see package events for the types
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" normalize:"trim" binding:"required,http_url,max=2048"`
+	Events      []string `json:"events" binding:"required,min=1,dive,oneof=product.created product.updated product.deleted product.stock_changed category.created category.updated user.created user.updated user.deleted"`
+	Description string   `json:"description" normalize:"trim" binding:"omitempty,max=255"`
+}
+
+type WebhookUri struct {
+	ID string `uri:"id" binding:"required,uuid4"`
+}
+
+// WebhookDeliveriesQuery goes with GET /webhooks/:id/deliveries, newest first
+type WebhookDeliveriesQuery struct {
+	Limit int `form:"limit" binding:"omitempty,gt=0,lte=500"` // 50 when empty
+}
@@ -0,0 +1,313 @@
+package v1handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/bulk"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/middleware"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
+)
+
+// MaxImportBytes caps the file of one import, about 20000 typical products
+const MaxImportBytes = 32 << 20
+
+// ImportProducts takes a CSV or NDJSON file of CreateProductRequest rows,
+// as the "file" of a multipart form or as the raw body, and answers 202
+// right away. Each row then goes through what POST /products does, in the
+// background; follow it on GET /imports/:id and /imports/:id/results.
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	var query dto.ImportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	// The job outlives the request, so the body is kept in a temp file
+	spool, format, err := spoolImport(c, bulk.Format(query.Format))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			content.Render(c, http.StatusRequestEntityTooLarge, gin.H{"error": "Import file too large", "msg": "at most 32MB"})
+		case errors.Is(err, errNoFormat):
+			content.Render(c, http.StatusBadRequest, gin.H{"error": "Unknown import format", "msg": err.Error()})
+		default:
+			content.Render(c, http.StatusBadRequest, gin.H{"error": "Invalid request format", "msg": err.Error()})
+		}
+		return
+	}
+
+	// A bad CSV header fails the request, not the job
+	dec, err := bulk.NewDecoder(format, spool)
+	if err != nil {
+		removeSpool(spool)
+		content.Render(c, http.StatusBadRequest, gin.H{"error": "Invalid request format", "msg": err.Error()})
+		return
+	}
+
+	job := h.imports.Start(format, query.DryRun, func(job *bulk.Job) error {
+		defer removeSpool(spool)
+		seen := map[string]bool{}
+		for {
+			rec, line, err := dec.Decode()
+			var rowErr *bulk.RowError
+			switch {
+			case errors.Is(err, io.EOF):
+				return nil
+			case errors.As(err, &rowErr):
+				job.Add(dto.ImportResult{Line: line, Status: bulk.RowInvalid, Error: "Invalid request format", Msg: rowErr.Msg})
+			case err != nil:
+				return err
+			default:
+				job.Add(h.importRow(rec.CreateProductRequest, line, query.DryRun, seen))
+			}
+		}
+	})
+
+	// Its start and end go to GET /events?topic=jobs, for the caller only
+	// (admins only when anonymous)
+	owner := middleware.User(c)
+	h.progress.Publish(JobsTopic, owner, "import", job.Summary())
+	go func() {
+		job.Wait(context.Background())
+		h.progress.Publish(JobsTopic, owner, "import", job.Summary())
+	}()
+
+	summary := job.Summary()
+	c.Header("Location", "/api/v1/imports/"+summary.ID)
+	content.Render(c, http.StatusAccepted, gin.H{
+		"message": "Import started",
+		"data":    summary,
+	})
+}
+
+var errNoFormat = errors.New("pass ?format=csv or ?format=ndjson, or a .csv or .ndjson file")
+
+// spoolImport copies the upload into a temp file, rewound, and settles its format
+func spoolImport(c *gin.Context, format bulk.Format) (*os.File, bulk.Format, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, MaxImportBytes+1<<20)
+
+	var src io.Reader = c.Request.Body
+	contentType, filename := c.ContentType(), ""
+	if strings.HasPrefix(contentType, "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", err
+		}
+		if fh.Size > MaxImportBytes {
+			return nil, "", &http.MaxBytesError{Limit: MaxImportBytes}
+		}
+		var f multipart.File
+		if f, err = fh.Open(); err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		src, contentType, filename = f, fh.Header.Get("Content-Type"), fh.Filename
+	}
+	if format == "" {
+		if format = bulk.FormatFor(contentType, filename); format == "" {
+			return nil, "", errNoFormat
+		}
+	}
+
+	spool, err := os.CreateTemp("", "lession03-import-*")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = io.Copy(spool, io.LimitReader(src, MaxImportBytes+1)); err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if fi, statErr := spool.Stat(); err == nil && statErr == nil && fi.Size() > MaxImportBytes {
+		err = &http.MaxBytesError{Limit: MaxImportBytes}
+	}
+	if err != nil {
+		removeSpool(spool)
+		return nil, "", err
+	}
+	return spool, format, nil
+}
+
+func removeSpool(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// importRow is CreateProduct for one row, with the response body as the result.
+// seen holds the names and slugs of earlier rows, a dry run creates nothing
+// the repository could catch duplicates with.
+func (h *ProductHandler) importRow(req dto.CreateProductRequest, line int, dryRun bool, seen map[string]bool) dto.ImportResult {
+	invalid := func(body dto.ErrorResponse) dto.ImportResult {
+		return dto.ImportResult{Line: line, Status: bulk.RowInvalid, Error: body.Error, Msg: body.Msg, Fields: body.Fields}
+	}
+
+	req.CreatedAt = "" // not accepted from clients
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			return invalid(dto.ErrorResponse{Error: "Validation failed", Fields: utils.FormatValidationErrors(ve)})
+		}
+		return invalid(dto.ErrorResponse{Error: "Invalid request format", Msg: err.Error()})
+	}
+	if req.Display == nil {
+		trueVal := true
+		req.Display = &trueVal
+	}
+	if failed := h.images.check(context.Background(), productImageURLs(newProductModel(req))); failed != nil {
+		return invalid(*failed)
+	}
+
+	nameKey, slugKey := "name:"+strings.ToLower(req.Name), "slug:"+req.Slug
+	switch {
+	case seen[nameKey] || h.ProductNameExists(req.Name, 0):
+		return invalid(nameTaken)
+	case req.Slug != "" && (seen[slugKey] || h.products.SlugTaken(req.Slug, 0)):
+		return invalid(slugTaken)
+	}
+	if missing := h.categories.Missing(req.CategoryIDs); len(missing) > 0 {
+		return invalid(unknownCategories(missing))
+	}
+	seen[nameKey] = true
+	if req.Slug != "" {
+		seen[slugKey] = true
+	}
+	if dryRun {
+		return dto.ImportResult{Line: line, Status: bulk.RowValid}
+	}
+
+	product := newProductModel(req)
+	product.CreatedAt = time.Now()
+	if err := h.products.Create(&product); err != nil {
+		log.Printf("import line %d: %v", line, err)
+		return dto.ImportResult{Line: line, Status: bulk.RowFailed, Error: "Failed to save product"}
+	}
+	publishCtx(context.Background(), h.events, events.ProductCreated, product.ID, product)
+	return dto.ImportResult{Line: line, Status: bulk.RowCreated, ID: product.ID, Slug: product.Slug}
+}
+
+// bindImport finds the import of :id, writes the 400/404 itself
+func (h *ProductHandler) bindImport(c *gin.Context) (*bulk.Job, bool) {
+	var uri dto.ImportUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		respondBindError(c, err)
+		return nil, false
+	}
+	job, ok := h.imports.Find(uri.ID)
+	if !ok {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Import not found"})
+	}
+	return job, ok
+}
+
+// GetImport is the progress of an import: its status and counts per outcome
+func (h *ProductHandler) GetImport(c *gin.Context) {
+	job, ok := h.bindImport(c)
+	if !ok {
+		return
+	}
+	content.Render(c, http.StatusOK, gin.H{
+		"message": "Import " + job.Summary().Status,
+		"data":    job.Summary(),
+	})
+}
+
+// GetImportResults streams one NDJSON line per row, as rows are done,
+// until the import finishes
+func (h *ProductHandler) GetImportResults(c *gin.Context) {
+	job, ok := h.bindImport(c)
+	if !ok {
+		return
+	}
+	c.Header("Content-Type", bulk.NDJSON.ContentType())
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	err := job.Results(c.Request.Context(), func(r dto.ImportResult) error {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+		c.Writer.Flush() // followers see each row as it is done
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Printf("import results: %v", err)
+	}
+}
+
+// ExportProducts streams the whole catalogue as CSV or NDJSON, in the
+// format ImportProducts reads
+func (h *ProductHandler) ExportProducts(c *gin.Context) {
+	var query dto.ExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	format := bulk.Format(query.Format)
+	if format == "" {
+		format = bulk.NDJSON
+		if c.NegotiateFormat(bulk.NDJSON.ContentType(), "text/csv") == "text/csv" {
+			format = bulk.CSV
+		}
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="products.`+string(format)+`"`)
+	c.Status(http.StatusOK)
+
+	enc := bulk.NewEncoder(format, c.Writer)
+	for i, p := range h.products.FindAll() {
+		if err := enc.Encode(bulk.RecordFor(p)); err != nil {
+			log.Printf("export: %v", err)
+			return
+		}
+		if i%100 == 99 {
+			_ = enc.Flush()
+			c.Writer.Flush()
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
+
+// respondBindError is the 400 for a query or :id that didn't bind
+func respondBindError(c *gin.Context, err error) {
+	if bodyUnsupported(c, err) {
+		return
+	}
+	var ve validator.ValidationErrors
+	if errors.As(err, &ve) {
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":  "Validation failed",
+			"fields": utils.FormatValidationErrors(ve),
+		})
+		return
+	}
+	content.Render(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// bodyUnsupported answers 415 when content.Bind doesn't know the Content-Type
+func bodyUnsupported(c *gin.Context, err error) bool {
+	if !errors.Is(err, content.ErrUnsupportedMediaType) {
+		return false
+	}
+	content.Render(c, http.StatusUnsupportedMediaType, gin.H{
+		"error": "Unsupported Content-Type",
+		"msg":   "send one of " + strings.Join(content.MediaTypes(), ", "),
+	})
+	return true
+}
@@ -1,79 +1,115 @@
 package v1handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
-	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/listquery"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/middleware"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/precondition"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/remoteimage"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/repository"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/stream"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
 	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
 )
 
 type CategoryHandler struct {
-	validate *validator.Validate
+	categories *repository.CategoryRepository
+	products   *repository.ProductRepository // for GET /categories/:id/products
+	uploadDir  string                        // where uploaded images are saved, served under /api/static/categories
+	images     ImageCheck
+	events     events.Publisher
+	progress   *stream.Broker // per file of upload-multiple, see GET /events
 }
 
-func NewCategoryHandler() *CategoryHandler {
-	v := validator.New()
-	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		_ = v.RegisterValidation("alphanumspace", utils.AlphaNumSpace)
-		_ = v.RegisterValidation("imgext", utils.ValidateImageExtension)
-	}
-	return &CategoryHandler{validate: v}
+func NewCategoryHandler(categories *repository.CategoryRepository, products *repository.ProductRepository, uploadDir string, images ImageCheck, publisher events.Publisher, progress *stream.Broker) *CategoryHandler {
+	utils.SetupBinding()
+	return &CategoryHandler{categories: categories, products: products, uploadDir: uploadDir, images: images, events: publisher, progress: progress}
 }
 
+// UploadMultipleCategoryImages saves up to 5 images, each file's outcome
+// also goes out as an "upload" event of GET /events?topic=uploads
 func (h *CategoryHandler) UploadMultipleCategoryImages(c *gin.Context) {
+	uploadID := c.GetHeader(UploadIDHeader)
+	if len(uploadID) > 64 {
+		content.Render(c, http.StatusBadRequest, gin.H{"error": "Invalid " + UploadIDHeader, "msg": "at most 64 characters"})
+		return
+	}
+	if uploadID == "" {
+		uploadID = uuid.NewString()
+	}
+
 	form, err := c.MultipartForm()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
+		content.Render(c, http.StatusBadRequest, gin.H{"error": "Failed to parse multipart form"})
 		return
 	}
 
 	files := form.File["images"]
 	if len(files) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No files uploaded"})
+		content.Render(c, http.StatusBadRequest, gin.H{"error": "No files uploaded"})
 		return
 	}
 
 	const maxImages = 5
 	if len(files) > maxImages {
-		c.JSON(http.StatusBadRequest, gin.H{
+		content.Render(c, http.StatusBadRequest, gin.H{
 			"error": fmt.Sprintf("Maximum %d images are allowed", maxImages),
 		})
 		return
 	}
 
-	uploadPath := "uploads/categories"
+	uploadPath := h.uploadDir
 	if err := os.MkdirAll(uploadPath, os.ModePerm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
 		return
 	}
 
 	var uploadedFiles []string
 	var failedFiles []map[string]string
 
-	for _, fileHeader := range files {
+	owner := middleware.User(c) // anonymous uploads are for admins' eyes only
+	for i, fileHeader := range files {
+		progress := dto.UploadProgress{UploadID: uploadID, File: fileHeader.Filename, Done: i + 1, Total: len(files)}
 		fileName, err := validateAndSaveImage(fileHeader, uploadPath)
 		if err != nil {
 			failedFiles = append(failedFiles, map[string]string{
 				"file":  fileHeader.Filename,
 				"error": err.Error(),
 			})
-			continue
+			progress.Status, progress.Error = "failed", err.Error()
+		} else {
+			uploadedFiles = append(uploadedFiles, fileName)
+			progress.Status, progress.URL = "saved", "/static/categories/"+fileName
 		}
-		uploadedFiles = append(uploadedFiles, fileName)
+		h.progress.Publish(UploadsTopic, owner, "upload", progress)
 	}
 
 	if len(uploadedFiles) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":  "No valid images uploaded",
-			"failed": failedFiles,
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":     "No valid images uploaded",
+			"failed":    failedFiles,
+			"upload_id": uploadID,
 		})
 		return
 	}
@@ -83,11 +119,12 @@ func (h *CategoryHandler) UploadMultipleCategoryImages(c *gin.Context) {
 		uploadedURLs = append(uploadedURLs, fmt.Sprintf("/static/categories/%s", name))
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	content.Render(c, http.StatusOK, gin.H{
 		"message": "Some or all files uploaded successfully",
 		//"files":   uploadedFiles,
-		"files":  uploadedURLs,
-		"failed": failedFiles,
+		"files":     uploadedURLs,
+		"failed":    failedFiles,
+		"upload_id": uploadID,
 	})
 }
 
@@ -141,7 +178,7 @@ func (h *CategoryHandler) UploadCategoryImage(c *gin.Context) {
 	// Bind query parameters
 	var query dto.UploadCategoryQuery
 	if err := c.ShouldBindQuery(&query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		content.Render(c, http.StatusBadRequest, gin.H{
 			"error":  "Invalid query parameters",
 			"fields": utils.FormatValidationErrors(err),
 		})
@@ -151,7 +188,7 @@ func (h *CategoryHandler) UploadCategoryImage(c *gin.Context) {
 	// Bind form fields
 	var form dto.UploadCategoryForm
 	if err := c.ShouldBind(&form); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		content.Render(c, http.StatusBadRequest, gin.H{
 			"error":  "Validation failed",
 			"fields": utils.FormatValidationErrors(err),
 		})
@@ -161,39 +198,39 @@ func (h *CategoryHandler) UploadCategoryImage(c *gin.Context) {
 	// File validation
 	fileHeader, err := c.FormFile("image")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Image file is required"})
+		content.Render(c, http.StatusBadRequest, gin.H{"error": "Image file is required"})
 		return
 	}
 
 	file, err := fileHeader.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot open uploaded file"})
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Cannot open uploaded file"})
 		return
 	}
 	defer file.Close()
 
 	// ✅ Check file extension
 	if err := utils.ValidateFileExtension(fileHeader.Filename, utils.AllowedExtensions); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		content.Render(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// ✅ Check MIME content
 	if err := utils.ValidateImageMIME(file); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		content.Render(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// ✅ Check file size
 	const maxSize = 2 << 20 // 2MB
 	if err := utils.ValidateFileSize(fileHeader.Size, maxSize); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		content.Render(c, http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	uploadPath := "uploads/categories"
+	uploadPath := h.uploadDir
 	if err := os.MkdirAll(uploadPath, os.ModePerm); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
 		return
 	}
 
@@ -202,11 +239,11 @@ func (h *CategoryHandler) UploadCategoryImage(c *gin.Context) {
 	dst := filepath.Join(uploadPath, newFileName)
 
 	if err := c.SaveUploadedFile(fileHeader, dst); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	content.Render(c, http.StatusOK, gin.H{
 		"message":     "File uploaded successfully",
 		"name":        form.Name,
 		"description": form.Description,
@@ -222,15 +259,353 @@ func (h *CategoryHandler) CreateCategory(c *gin.Context) {
 	var req dto.CreateCategoryRequest
 
 	if err := c.ShouldBindWith(&req, binding.Form); err != nil {
-		c.JSON(400, gin.H{
+		content.Render(c, 400, gin.H{
 			"error":  "Validation failed",
 			"fields": utils.FormatValidationErrors(err),
 		})
 		return
 	}
 
-	c.JSON(201, gin.H{
+	if req.ParentID != 0 && len(h.categories.Missing([]int{req.ParentID})) > 0 {
+		respondParentNotFound(c)
+		return
+	}
+
+	var ingested string // our copy of the image, removed if the category isn't saved
+	if h.images.Verifier != nil && h.images.Ingest && !h.images.queued() {
+		name, err := h.images.Verifier.Ingest(c.Request.Context(), req.ImageURL, h.uploadDir)
+		if err != nil {
+			content.Render(c, http.StatusBadRequest, gin.H{
+				"error":  "Image URL check failed",
+				"fields": gin.H{"ImageURL": err.Error()},
+			})
+			return
+		}
+		ingested = name
+		req.ImageURL = "/api/static/categories/" + name
+	} else if !h.images.verify(c, map[string]string{"ImageURL": req.ImageURL}) {
+		return
+	}
+
+	category := models.Category{
+		Name:        req.Name,
+		Description: req.Description,
+		ImageURL:    req.ImageURL,
+		ParentID:    req.ParentID,
+	}
+	err := h.categories.Create(&category)
+	if err != nil && ingested != "" {
+		os.Remove(filepath.Join(h.uploadDir, ingested))
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		respondParentNotFound(c)
+		return
+	} else if err != nil {
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to save category"})
+		return
+	}
+	publish(c, h.events, events.CategoryCreated, category.ID, category)
+	h.images.enqueue(c, CategoryImageJob, category.ID, map[string]string{"ImageURL": category.ImageURL})
+
+	content.Render(c, 201, gin.H{
 		"message": "Category created successfully",
+		"id":      category.ID,
 		"data":    req,
 	})
 }
+
+// Sort fields a client may ask for on GET /categories
+var categorySortFields = map[string]listquery.Comparator[models.Category]{
+	"name": func(a, b models.Category) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	},
+	"created_at": func(a, b models.Category) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	},
+}
+
+var categoryListSpec = listquery.Spec{
+	SortFields: []string{"name", "created_at"},
+}
+
+func (h *CategoryHandler) GetCategories(c *gin.Context) {
+	params, ok := bindListQuery(c, categoryListSpec)
+	if !ok {
+		return
+	}
+
+	categories := h.categories.FindAll()
+	listquery.Sort(categories, params.Sort, categorySortFields)
+
+	content.Render(c, http.StatusOK, listquery.NewPage(c.Request.URL, categories, params))
+}
+
+func respondParentNotFound(c *gin.Context) {
+	content.Render(c, http.StatusBadRequest, gin.H{
+		"error":  "Validation failed",
+		"fields": gin.H{"ParentID": "Parent category not found"},
+	})
+}
+
+func bindCategoryID(c *gin.Context) (int, bool) {
+	var uri dto.CategoryUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":  "Validation failed",
+			"fields": utils.FormatValidationErrors(err),
+		})
+		return 0, false
+	}
+	return uri.ID, true
+}
+
+// GetCategoryTree returns the category with all its subcategories nested
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	id, ok := bindCategoryID(c)
+	if !ok {
+		return
+	}
+
+	subtree, err := h.categories.Subtree(id)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	// Subtree lists parents first, so every parent has a node by the time its children come
+	nodes := make(map[int]*dto.CategoryNode, len(subtree))
+	for _, cat := range subtree {
+		node := &dto.CategoryNode{Category: cat}
+		nodes[cat.ID] = node
+		if parent, ok := nodes[cat.ParentID]; ok && cat.ID != id {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	c.Header("ETag", precondition.ETag(subtree[0].Version))
+	content.Render(c, http.StatusOK, gin.H{"data": nodes[id]})
+}
+
+// Sort fields a client may ask for on GET /categories/:id/products
+var categoryProductSortFields = map[string]listquery.Comparator[models.Product]{
+	"name": func(a, b models.Product) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	},
+	"price": func(a, b models.Product) int {
+		return comparePrices(a.Price, b.Price)
+	},
+	"created_at": func(a, b models.Product) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	},
+}
+
+var categoryProductListSpec = listquery.Spec{
+	SortFields: []string{"name", "price", "created_at"},
+}
+
+// GetCategoryProducts lists the products of the category and, unless
+// ?direct=true, of all its subcategories
+func (h *CategoryHandler) GetCategoryProducts(c *gin.Context) {
+	id, ok := bindCategoryID(c)
+	if !ok {
+		return
+	}
+
+	var query dto.CategoryProductsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":  "Invalid query parameters",
+			"fields": utils.FormatValidationErrors(err),
+		})
+		return
+	}
+	params, err := listquery.Parse(listquery.Request{
+		Limit:  query.Limit,
+		Offset: query.Offset,
+		Cursor: query.Cursor,
+		Sort:   query.Sort,
+	}, categoryProductListSpec)
+	if err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	subtree, err := h.categories.Subtree(id)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+	ids := map[int]bool{id: true}
+	if !query.Direct {
+		for _, cat := range subtree {
+			ids[cat.ID] = true
+		}
+	}
+
+	// products off the shelf are left out, like on GET /products
+	products := slices.DeleteFunc(h.products.FindByCategories(ids), func(p models.Product) bool { return !p.Display })
+	listquery.Sort(products, params.Sort, categoryProductSortFields)
+
+	content.Render(c, http.StatusOK, listquery.NewPage(c.Request.URL, products, params))
+}
+
+// MoveCategory puts the category, with its subtree, under another parent.
+// Moving it into its own subtree would make a cycle and is a 409.
+func (h *CategoryHandler) MoveCategory(c *gin.Context) {
+	id, ok := bindCategoryID(c)
+	if !ok {
+		return
+	}
+
+	current, err := h.categories.FindByID(id)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+	if !precondition.IfMatch(c, precondition.ETag(current.Version)) {
+		return
+	}
+
+	var req dto.MoveCategoryRequest
+	if err := content.Bind(c, &req); err != nil {
+		if bodyUnsupported(c, err) {
+			return
+		}
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":  "Validation failed",
+			"fields": utils.FormatValidationErrors(err),
+		})
+		return
+	}
+	if req.ParentID != 0 && len(h.categories.Missing([]int{req.ParentID})) > 0 {
+		respondParentNotFound(c)
+		return
+	}
+
+	moved, err := h.categories.Move(id, req.ParentID, current.Version)
+	switch {
+	case errors.Is(err, repository.ErrCycle):
+		content.Render(c, http.StatusConflict, gin.H{"error": "A category can't move under itself or its own subcategories"})
+		return
+	case errors.Is(err, repository.ErrVersionConflict):
+		latest, _ := h.categories.FindByID(id)
+		precondition.Failed(c, precondition.ETag(latest.Version))
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	case err != nil:
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to move category"})
+		return
+	}
+	publish(c, h.events, events.CategoryUpdated, moved.ID, moved)
+
+	c.Header("ETag", precondition.ETag(moved.Version))
+	content.Render(c, http.StatusOK, gin.H{
+		"message": "Category moved",
+		"data":    moved,
+	})
+}
+
+// CleanupUploadsJob is the job kind of CleanupUploads
+const CleanupUploadsJob = "uploads.cleanup"
+
+// OrphanAge is how long an upload may stay unused before CleanupUploads
+// removes it. Images are uploaded before the category that uses them is
+// created, so fresh files are left alone.
+const OrphanAge = 24 * time.Hour
+
+// CleanupUploads removes the files of the upload dir no category image
+// points to, once they are OrphanAge old. It is the uploads.cleanup job.
+func (h *CategoryHandler) CleanupUploads(ctx context.Context, _ jobs.Job) (any, error) {
+	// Uploads are named by UUID, a remote image of the same name is
+	// unlikely and would only keep a file
+	used := map[string]bool{}
+	for _, cat := range h.categories.FindAll() {
+		if u, err := url.Parse(cat.ImageURL); err == nil {
+			used[path.Base(u.Path)] = true
+		}
+	}
+
+	entries, err := os.ReadDir(h.uploadDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return dto.UploadCleanup{Removed: []string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := dto.UploadCleanup{Removed: []string{}}
+	cutoff := time.Now().Add(-OrphanAge)
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if used[e.Name()] || info.ModTime().After(cutoff) {
+			res.Kept++
+			continue
+		}
+		if err := os.Remove(filepath.Join(h.uploadDir, e.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		res.Removed = append(res.Removed, e.Name())
+	}
+	return res, nil
+}
+
+// CategoryImageJob is the job kind of CheckImage
+const CategoryImageJob = "categories.check-image"
+
+// CheckImage verifies the image URL of a saved category, see
+// ImageCheck.Queue. With Ingest the image is copied into the upload dir and
+// the category points at the copy; an image that is no good is dropped.
+// Nothing is done when the category is gone or its image changed since.
+func (h *CategoryHandler) CheckImage(ctx context.Context, job jobs.Job) (any, error) {
+	var p imageCheckJob
+	if err := job.Decode(&p); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	imageURL := p.URLs["ImageURL"]
+
+	var name string
+	var err error
+	if h.images.Ingest {
+		name, err = h.images.Verifier.Ingest(ctx, imageURL, h.uploadDir)
+	} else {
+		_, err = h.images.Verifier.Verify(ctx, imageURL)
+	}
+	res := dto.ImageCheckResult{}
+	switch {
+	case err == nil && name == "":
+		return res, nil
+	case err == nil:
+		imageURL = "/api/static/categories/" + name
+	case remoteimage.Rejected(err):
+		res.Failed = map[string]string{"ImageURL": err.Error()}
+		imageURL = ""
+	default:
+		return nil, err
+	}
+
+	cat, err := h.categories.FindByID(p.ID)
+	if err == nil && cat.ImageURL == p.URLs["ImageURL"] {
+		cat, err = h.categories.SetImage(cat.ID, cat.Version, imageURL)
+	} else if err == nil {
+		err = repository.ErrNotFound // not the image we checked any more
+	}
+	if err != nil {
+		if name != "" {
+			os.Remove(filepath.Join(h.uploadDir, name)) // nobody points at the copy
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return res, nil
+		}
+		return nil, err
+	}
+	publishCtx(ctx, h.events, events.CategoryUpdated, cat.ID, cat)
+	res.ImageURL = &cat.ImageURL
+	return res, nil
+}
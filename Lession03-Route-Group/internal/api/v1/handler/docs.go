@@ -0,0 +1,286 @@
+package v1handler
+
+import (
+	"net/http"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/bulk"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/openapi"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
+)
+
+var (
+	ifMatch = openapi.HeaderParam{
+		Name: "If-Match", Required: true,
+		Description: "ETag from the last GET, the write fails with 412 if the resource changed since",
+	}
+	ifNoneMatch = openapi.HeaderParam{
+		Name:        "If-None-Match",
+		Description: "ETag the client already has, answered with 304 when still current",
+	}
+	idempotencyKey = openapi.HeaderParam{
+		Name:        "Idempotency-Key",
+		Description: "Retries with the same key replay the first response instead of creating a duplicate",
+	}
+	uploadID = openapi.HeaderParam{
+		Name:        UploadIDHeader,
+		Description: "Names the upload in its GET /events progress, at most 64 characters; one is made up without it",
+	}
+)
+
+// Docs describes every v1 handler: which DTOs it binds and what it answers.
+// The routes themselves are read from the gin engine.
+func Docs() *openapi.Registry {
+	r := openapi.NewRegistry()
+
+	// Custom validator tags, registered in the New*Handler constructors
+	r.Pattern("slug", utils.SlugPattern)
+	r.Pattern("sku", utils.SKUPattern)
+	r.Pattern("alphanumspace", utils.AlphaNumSpacePattern)
+	r.Pattern("imgext", utils.ImageExtensionPattern)
+
+	// users
+	r.Describe((*UserHandler).GetUsers, openapi.Operation{
+		Summary: "List users", Tags: []string{"users"},
+		Query: dto.ListQuery{}, Response: dto.UserPage{},
+	})
+	r.Describe((*UserHandler).GetUserByUUID, openapi.Operation{
+		Summary: "Check a user UUID", Tags: []string{"users"},
+		Path: dto.UserUUIDQuery{}, Response: dto.UserUUIDResponse{},
+	})
+	r.Describe((*UserHandler).GetUserWithoutSlug, openapi.Operation{
+		Summary: "User slug placeholder", Tags: []string{"users"},
+		Response: dto.UserSlugResponse{},
+	})
+	r.Describe((*UserHandler).GetUserBySlug, openapi.Operation{
+		Summary: "Find a user by slug", Tags: []string{"users"},
+		Description: "Old slugs (the user was renamed since) answer 301 to the current one.",
+		Path:        dto.UserSlugQuery{}, Response: dto.UserSlugResponse{},
+		Errors: []int{http.StatusMovedPermanently, http.StatusNotFound},
+	})
+	r.Describe((*UserHandler).GetUserByID, openapi.Operation{
+		Summary: "Get a user", Tags: []string{"users"},
+		Path: dto.UserQuery{}, Headers: []openapi.HeaderParam{ifNoneMatch},
+		Response: dto.UserResponse{}, Errors: []int{http.StatusNotModified, http.StatusNotFound},
+	})
+	r.Describe((*UserHandler).CreateUser, openapi.Operation{
+		Summary: "Create a user", Tags: []string{"users"},
+		Body: dto.CreateUserRequest{}, Status: http.StatusCreated, Response: dto.UserResponse{},
+	})
+	r.Describe((*UserHandler).UpdateUser, openapi.Operation{
+		Summary: "Replace a user", Tags: []string{"users"},
+		Path: dto.UserQuery{}, Body: dto.CreateUserRequest{}, Headers: []openapi.HeaderParam{ifMatch},
+		Response: dto.UserResponse{},
+		Errors:   []int{http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+	})
+	r.Describe((*UserHandler).DeleteUser, openapi.Operation{
+		Summary: "Delete a user", Tags: []string{"users"},
+		Path: dto.UserQuery{}, Headers: []openapi.HeaderParam{ifMatch},
+		Response: dto.MessageResponse{},
+		Errors:   []int{http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+	})
+
+	// products
+	r.Describe((*ProductHandler).GetProducts, openapi.Operation{
+		Summary: "Search products", Tags: []string{"products"},
+		Description: "Full-text search with pagination, products with display false are left out. sort is a comma separated list of name, price, created_at, prefix with - for descending.",
+		Query:       dto.ProductQuery{}, Response: dto.ProductPage{},
+	})
+	r.Describe((*ProductHandler).SuggestProducts, openapi.Operation{
+		Summary: "Autocomplete product names and tags", Tags: []string{"products"},
+		Query: dto.SuggestQuery{}, Response: dto.SuggestResponse{},
+	})
+	r.Describe((*ProductHandler).GetProductByLang, openapi.Operation{
+		Summary: "Products by programming language", Tags: []string{"products"},
+		Description: "Not product categories, those are under /categories/{id}/products.",
+		Path:        dto.ProductLangUri{}, Response: dto.ProductLangResponse{},
+	})
+	r.Describe((*ProductHandler).GetProductBySlug, openapi.Operation{
+		Summary: "Get a product by slug", Tags: []string{"products"},
+		Description: "Old slugs (the product was renamed since) answer 301 to the current one.",
+		Path:        dto.ProductSlugUri{}, Headers: []openapi.HeaderParam{ifNoneMatch},
+		Response: dto.ProductResponse{},
+		Errors:   []int{http.StatusMovedPermanently, http.StatusNotModified, http.StatusNotFound},
+	})
+	r.Describe((*ProductHandler).GetProductByID, openapi.Operation{
+		Summary: "Get a product", Tags: []string{"products"},
+		Path: dto.ProductUri{}, Headers: []openapi.HeaderParam{ifNoneMatch},
+		Response: dto.ProductResponse{}, Errors: []int{http.StatusNotModified, http.StatusNotFound},
+	})
+	r.Describe((*ProductHandler).CreateProduct, openapi.Operation{
+		Summary: "Create a product", Tags: []string{"products"},
+		Description: "When the server checks image URLs in the background, X-Image-Check is the job doing it:\n" +
+			"a product showing an image that is no good is taken off the shelf (display false, stock 0).",
+		Body: dto.CreateProductRequest{}, Headers: []openapi.HeaderParam{idempotencyKey},
+		Status: http.StatusCreated, Response: dto.CreateProductResponse{},
+		Errors: []int{http.StatusConflict, http.StatusUnprocessableEntity},
+	})
+	r.Describe((*ProductHandler).UpdateProduct, openapi.Operation{
+		Summary: "Replace a product", Tags: []string{"products"},
+		Path: dto.ProductUri{}, Body: dto.CreateProductRequest{}, Headers: []openapi.HeaderParam{ifMatch},
+		Response: dto.ProductResponse{},
+		Errors:   []int{http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+	})
+	r.Describe((*ProductHandler).DeleteProduct, openapi.Operation{
+		Summary: "Delete a product", Tags: []string{"products"},
+		Path: dto.ProductUri{}, Headers: []openapi.HeaderParam{ifMatch},
+		Response: dto.MessageResponse{},
+		Errors:   []int{http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+	})
+	r.Describe((*ProductHandler).ReserveStock, openapi.Operation{
+		Summary: "Reserve stock of a variant", Tags: []string{"inventory"},
+		Description: "Holds the quantity until the reservation is committed, released or expires (15 minutes by default).",
+		Path:        dto.ProductUri{}, Body: dto.ReserveRequest{}, Headers: []openapi.HeaderParam{idempotencyKey},
+		Status: http.StatusCreated, Response: dto.ReservationResponse{},
+		Errors: []int{http.StatusNotFound, http.StatusConflict},
+	})
+	r.Describe((*ProductHandler).CommitReservation, openapi.Operation{
+		Summary: "Commit a reservation", Tags: []string{"inventory"},
+		Description: "Takes the reserved quantity off the variant's stock.",
+		Path:        dto.ReservationUri{}, Headers: []openapi.HeaderParam{idempotencyKey},
+		Response: dto.ProductResponse{}, Errors: []int{http.StatusNotFound},
+	})
+	r.Describe((*ProductHandler).ReleaseReservation, openapi.Operation{
+		Summary: "Release a reservation", Tags: []string{"inventory"},
+		Path: dto.ReservationUri{}, Response: dto.MessageResponse{},
+		Errors: []int{http.StatusNotFound},
+	})
+
+	// bulk import and export
+	r.Describe((*ProductHandler).ImportProducts, openapi.Operation{
+		Summary: "Import products from CSV or NDJSON", Tags: []string{"bulk"},
+		Description: "Rows are CreateProductRequest bodies, validated like POST /products. The import runs in the background:\n" +
+			"follow it on /imports/{id}. The file can also be sent as the raw body, with Content-Type text/csv or application/x-ndjson.",
+		Query: dto.ImportQuery{}, BodyKind: openapi.MultipartBody,
+		Files:   []openapi.FileField{{Name: "file", Description: ".csv or .ndjson, max 32MB"}},
+		Headers: []openapi.HeaderParam{idempotencyKey},
+		Status:  http.StatusAccepted, Response: dto.ImportResponse{},
+		Errors: []int{http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+	})
+	r.Describe((*ProductHandler).GetImport, openapi.Operation{
+		Summary: "Get the progress of an import", Tags: []string{"bulk"},
+		Path: dto.ImportUri{}, Response: dto.ImportResponse{},
+		Errors: []int{http.StatusNotFound},
+	})
+	r.Describe((*ProductHandler).GetImportResults, openapi.Operation{
+		Summary: "Stream the per-row results of an import", Tags: []string{"bulk"},
+		Description: "One line per row, in row order, as rows are done. The stream ends with the import.",
+		Path:        dto.ImportUri{}, Produces: []string{"application/x-ndjson"}, Response: dto.ImportResult{},
+		Errors: []int{http.StatusNotFound},
+	})
+	r.Describe((*ProductHandler).ExportProducts, openapi.Operation{
+		Summary: "Export every product as CSV or NDJSON", Tags: []string{"bulk"},
+		Description: "Rows can be imported again as they are.",
+		Query:       dto.ExportQuery{}, Produces: []string{"text/csv", "application/x-ndjson"}, Response: bulk.Record{},
+	})
+
+	// jobs
+	r.Describe((*JobHandler).GetJob, openapi.Operation{
+		Summary: "Get the state of a background job", Tags: []string{"jobs"},
+		Description: "Poll until status is succeeded (result is set) or dead (last_error says why).",
+		Path:        dto.JobUri{}, Response: dto.JobResponse{},
+		Errors: []int{http.StatusNotFound, http.StatusServiceUnavailable},
+	})
+
+	// events
+	r.Describe((*StreamHandler).Stream, openapi.Operation{
+		Summary: "Follow upload and job progress as Server-Sent Events", Tags: []string{"events"},
+		Description: "Topic uploads has an \"upload\" event per file of upload-multiple, topic jobs an \"import\" event\n" +
+			"when a bulk import starts and ends, and for admins a \"job\" event per job queue change.\n" +
+			"Needs an API token (Authorization: Bearer <token>): callers see their own events, admins everyone's.\n" +
+			"Comment lines come as heartbeats. On reconnect, Last-Event-ID replays the missed events,\n" +
+			"or a \"reset\" event comes first when they are no longer kept.",
+		Query: dto.StreamQuery{}, Produces: []string{"text/event-stream"},
+		Headers: []openapi.HeaderParam{{
+			Name: "Last-Event-ID", Description: "id of the last event received, sent by EventSource on reconnect",
+		}},
+		Errors: []int{http.StatusUnauthorized},
+	})
+
+	// notifications
+	r.Describe((*NotificationHandler).CreateNotificationTicket, openapi.Operation{
+		Summary: "Get a ticket for the notifications WebSocket", Tags: []string{"notifications"},
+		Description: "Admins only. Open ws://<host>/api/v1/notifications?ticket=<ticket> within 30s, once;\n" +
+			"clients that can set headers may send Authorization on the handshake instead.\n" +
+			"Send {\"op\":\"subscribe\",\"topics\":[\"product:12\",\"category:*\"]} (or unsubscribe, ping) and get\n" +
+			"{\"type\":\"event\",\"topic\":\"product:12\",\"event\":{...}} for every change to those resources.\n" +
+			"A client too slow to keep up gets an error message and is disconnected.",
+		Status: http.StatusCreated, Response: dto.NotificationTicket{},
+		Errors: []int{http.StatusUnauthorized, http.StatusForbidden},
+	})
+
+	// webhooks, admins only
+	webhookErrors := []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusServiceUnavailable}
+	r.Describe((*WebhookHandler).GetWebhooks, openapi.Operation{
+		Summary: "List webhooks", Tags: []string{"webhooks"},
+		Response: dto.WebhookListResponse{}, Errors: webhookErrors,
+	})
+	r.Describe((*WebhookHandler).CreateWebhook, openapi.Operation{
+		Summary: "Subscribe an endpoint to events", Tags: []string{"webhooks"},
+		Description: "Every event is POSTed as JSON with X-Webhook-Event, X-Webhook-Delivery and\n" +
+			"X-Webhook-Signature: t=<unix seconds>,v1=<hex HMAC-SHA256 of \"<t>.<body>\" keyed with secret>.\n" +
+			"Anything but 2xx is retried with backoff, 410 Gone stops the retries.",
+		Body: dto.CreateWebhookRequest{}, Headers: []openapi.HeaderParam{idempotencyKey},
+		Status: http.StatusCreated, Response: dto.WebhookResponse{},
+		Errors: append([]int{http.StatusConflict, http.StatusUnprocessableEntity}, webhookErrors...),
+	})
+	r.Describe((*WebhookHandler).DeleteWebhook, openapi.Operation{
+		Summary: "Delete a webhook", Tags: []string{"webhooks"},
+		Path: dto.WebhookUri{}, Errors: append([]int{http.StatusNotFound}, webhookErrors...),
+	})
+	r.Describe((*WebhookHandler).GetWebhookDeliveries, openapi.Operation{
+		Summary: "List the delivery attempts of a webhook", Tags: []string{"webhooks"},
+		Path: dto.WebhookUri{}, Query: dto.WebhookDeliveriesQuery{}, Response: dto.WebhookDeliveriesResponse{},
+		Errors: append([]int{http.StatusNotFound}, webhookErrors...),
+	})
+
+	// categories
+	r.Describe((*CategoryHandler).GetCategories, openapi.Operation{
+		Summary: "List categories", Tags: []string{"categories"},
+		Query: dto.ListQuery{}, Response: dto.CategoryPage{},
+	})
+	r.Describe((*CategoryHandler).CreateCategory, openapi.Operation{
+		Summary: "Create a category", Tags: []string{"categories"},
+		Description: "When the server checks image URLs in the background, X-Image-Check is the job doing it:\n" +
+			"an image that is no good is dropped, with -ingest-images a good one is copied in.",
+		Body: dto.CreateCategoryRequest{}, BodyKind: openapi.FormBody,
+		Status: http.StatusCreated, Response: dto.CreateCategoryResponse{},
+	})
+	r.Describe((*CategoryHandler).GetCategoryTree, openapi.Operation{
+		Summary: "Get a category with its subcategories", Tags: []string{"categories"},
+		Path: dto.CategoryUri{}, Response: dto.CategoryTreeResponse{},
+		Errors: []int{http.StatusNotFound},
+	})
+	r.Describe((*CategoryHandler).GetCategoryProducts, openapi.Operation{
+		Summary: "List the products of a category", Tags: []string{"categories"},
+		Description: "Includes the products of all subcategories unless direct=true. Products with display false are left out.",
+		Path:        dto.CategoryUri{}, Query: dto.CategoryProductsQuery{}, Response: dto.CategoryProductPage{},
+		Errors: []int{http.StatusNotFound},
+	})
+	r.Describe((*CategoryHandler).MoveCategory, openapi.Operation{
+		Summary: "Move a category under another parent", Tags: []string{"categories"},
+		Description: "The subtree moves along. parent_id 0 makes it a top level category; moving it under its own subtree is a 409.",
+		Path:        dto.CategoryUri{}, Body: dto.MoveCategoryRequest{}, Headers: []openapi.HeaderParam{ifMatch},
+		Response: dto.CategoryResponse{},
+		Errors:   []int{http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+	})
+	r.Describe((*CategoryHandler).UploadCategoryImage, openapi.Operation{
+		Summary: "Upload a category image", Tags: []string{"categories"},
+		Description: "jpg, jpeg or png, at most 2MB.",
+		Query:       dto.UploadCategoryQuery{}, Body: dto.UploadCategoryForm{}, BodyKind: openapi.MultipartBody,
+		Files:    []openapi.FileField{{Name: "image", Description: "jpg, jpeg or png, max 2MB"}},
+		Headers:  []openapi.HeaderParam{idempotencyKey},
+		Response: dto.UploadCategoryResponse{},
+		Errors:   []int{http.StatusConflict, http.StatusUnprocessableEntity},
+	})
+	r.Describe((*CategoryHandler).UploadMultipleCategoryImages, openapi.Operation{
+		Summary: "Upload up to 5 category images", Tags: []string{"categories"},
+		BodyKind: openapi.MultipartBody,
+		Files:    []openapi.FileField{{Name: "images", Multiple: true, Description: "jpg, jpeg or png, max 2MB each"}},
+		Headers:  []openapi.HeaderParam{idempotencyKey, uploadID},
+		Response: dto.UploadMultipleResponse{},
+		Errors:   []int{http.StatusConflict, http.StatusUnprocessableEntity},
+	})
+
+	return r
+}
@@ -0,0 +1,21 @@
+package v1handler
+
+import (
+	"context"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+)
+
+// publish tells the rest of the system about a change the handler just
+// made. The change is made and answered either way, a failure is logged.
+func publish(c *gin.Context, p events.Publisher, typ string, id int, data any) {
+	publishCtx(context.WithoutCancel(c.Request.Context()), p, typ, id, data)
+}
+
+func publishCtx(ctx context.Context, p events.Publisher, typ string, id int, data any) {
+	if err := p.Publish(ctx, events.New(typ, id, data)); err != nil {
+		log.Printf("publish %s: %v", typ, err)
+	}
+}
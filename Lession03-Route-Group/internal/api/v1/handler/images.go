@@ -0,0 +1,113 @@
+package v1handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/remoteimage"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// ImageCheck is the optional remote check of image URLs in request bodies.
+// The zero value checks nothing, the imgext tag is all there is then.
+type ImageCheck struct {
+	Verifier *remoteimage.Verifier
+	Ingest   bool // categories: copy the image into the upload dir and point ImageURL at our copy
+	// Queue, when set, checks the images with a job once the product or
+	// category is saved (ProductImagesJob, CategoryImageJob) instead of
+	// holding the request on remote servers
+	Queue *jobs.Queue
+}
+
+// ImageCheckHeader points at the job checking the images of what was just
+// saved, for clients to poll
+const ImageCheckHeader = "X-Image-Check"
+
+// imageCheckJob is the payload of the image check jobs. The URLs are the
+// ones to check: the resource may have changed, or the server restarted
+// with other data under the same id, by the time the job runs.
+type imageCheckJob struct {
+	ID   int               `json:"id"`
+	URLs map[string]string `json:"urls"`
+}
+
+func (ic ImageCheck) queued() bool {
+	return ic.Verifier != nil && ic.Queue != nil
+}
+
+// verify checks urls (field -> URL) concurrently, writes the 400 itself.
+// With a Queue it passes them, enqueue checks them later.
+func (ic ImageCheck) verify(c *gin.Context, urls map[string]string) bool {
+	if ic.queued() {
+		return true
+	}
+	if failed := ic.check(c.Request.Context(), urls); failed != nil {
+		content.Render(c, http.StatusBadRequest, *failed)
+		return false
+	}
+	return true
+}
+
+// enqueue has a job of kind check the urls of the saved resource id, when
+// there is a Queue. The resource is saved either way, a failure is logged.
+func (ic ImageCheck) enqueue(c *gin.Context, kind string, id int, urls map[string]string) {
+	if !ic.queued() || len(urls) == 0 {
+		return
+	}
+	job, err := ic.Queue.Enqueue(c.Request.Context(), kind, imageCheckJob{ID: id, URLs: urls})
+	if err != nil {
+		log.Printf("enqueue %s of %d: %v", kind, id, err)
+		return
+	}
+	c.Header(ImageCheckHeader, "/api/v1/jobs/"+job.ID)
+}
+
+// check is verify without the response, nil when every URL is fine
+func (ic ImageCheck) check(ctx context.Context, urls map[string]string) *dto.ErrorResponse {
+	if ic.Verifier == nil || len(urls) == 0 {
+		return nil
+	}
+	failed := ic.Verifier.VerifyAll(ctx, urls)
+	if len(failed) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]string, len(failed))
+	for field, err := range failed {
+		fields[field] = err.Error()
+	}
+	return &dto.ErrorResponse{Error: "Image URL check failed", Fields: fields}
+}
+
+// rejected is what the URLs failed with, by field. The error is set when
+// some couldn't be reached: the job fails, to try again later.
+func rejected(failed map[string]error) (map[string]string, error) {
+	fields := make(map[string]string, len(failed))
+	for field, err := range failed {
+		if !remoteimage.Rejected(err) {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+		fields[field] = err.Error()
+	}
+	return fields, nil
+}
+
+// Keyed like FormatValidationErrors keys the fields of the request
+func productImageURLs(p models.Product) map[string]string {
+	urls := map[string]string{"Avartar.URL": p.Avatar.URL}
+	for i, img := range p.Images {
+		urls[fmt.Sprintf("Image[%d].URL", i)] = img.URL
+	}
+	for i, v := range p.Variants {
+		for j, img := range v.Images {
+			urls[fmt.Sprintf("Variants[%d].Images[%d].URL", i, j)] = img.URL
+		}
+	}
+	return urls
+}
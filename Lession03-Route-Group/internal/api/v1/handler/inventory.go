@@ -0,0 +1,119 @@
+package v1handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/repository"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
+)
+
+// ReserveStock holds stock of one variant for an order. The order then
+// commits the reservation (stock goes down) or releases it; left alone it
+// expires and the stock is available again. All three publish
+// product.stock_changed, expiring doesn't.
+func (h *ProductHandler) ReserveStock(c *gin.Context) {
+	id, ok := bindProductID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.ReserveRequest
+	if err := content.Bind(c, &req); err != nil {
+		if bodyUnsupported(c, err) {
+			return
+		}
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			content.Render(c, http.StatusBadRequest, gin.H{
+				"error":  "Validation failed",
+				"fields": utils.FormatValidationErrors(ve),
+			})
+			return
+		}
+		content.Render(c, http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	res, err := h.products.Reserve(id, req.SKU, req.Quantity, time.Duration(req.TTLSeconds)*time.Second)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Product or variant not found"})
+		return
+	case errors.Is(err, repository.ErrInsufficientStock):
+		content.Render(c, http.StatusConflict, gin.H{"error": "Not enough stock"})
+		return
+	case err != nil:
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to reserve stock"})
+		return
+	}
+	if product, err := h.products.FindByID(id); err == nil {
+		publish(c, h.events, events.ProductStockChanged, id, product)
+	}
+
+	content.Render(c, http.StatusCreated, gin.H{
+		"message": "Stock reserved",
+		"data":    res,
+	})
+}
+
+func (h *ProductHandler) CommitReservation(c *gin.Context) {
+	var uri dto.ReservationUri
+	if !bindReservationID(c, &uri) {
+		return
+	}
+
+	product, err := h.products.Commit(uri.ID)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+	publish(c, h.events, events.ProductStockChanged, product.ID, product)
+
+	content.Render(c, http.StatusOK, gin.H{
+		"message": "Reservation committed",
+		"data":    product,
+	})
+}
+
+func (h *ProductHandler) ReleaseReservation(c *gin.Context) {
+	var uri dto.ReservationUri
+	if !bindReservationID(c, &uri) {
+		return
+	}
+
+	product, err := h.products.Release(uri.ID)
+	if err != nil {
+		respondReservationError(c, err)
+		return
+	}
+	publish(c, h.events, events.ProductStockChanged, product.ID, product)
+
+	content.Render(c, http.StatusOK, gin.H{"message": "Reservation released"})
+}
+
+func bindReservationID(c *gin.Context, uri *dto.ReservationUri) bool {
+	if err := c.ShouldBindUri(uri); err != nil {
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":  "Validation failed",
+			"fields": utils.FormatValidationErrors(err),
+		})
+		return false
+	}
+	return true
+}
+
+// Expired reservations are gone too, the stock is back already
+func respondReservationError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Reservation not found or expired"})
+		return
+	}
+	content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to update reservation"})
+}
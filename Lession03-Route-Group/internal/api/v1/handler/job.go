@@ -0,0 +1,59 @@
+package v1handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+)
+
+type JobHandler struct {
+	queue *jobs.Queue // nil when the server runs without a database
+}
+
+func NewJobHandler(queue *jobs.Queue) *JobHandler {
+	return &JobHandler{queue: queue}
+}
+
+// GetJob is the state of a background job, for clients to poll until it
+// has succeeded or is dead
+func (h *JobHandler) GetJob(c *gin.Context) {
+	var uri dto.JobUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if h.queue == nil {
+		content.Render(c, http.StatusServiceUnavailable, gin.H{"error": "Job queue is not running"})
+		return
+	}
+
+	job, err := h.queue.Find(c.Request.Context(), uri.ID)
+	if errors.Is(err, jobs.ErrNotFound) {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("find job %s: %v", uri.ID, err)
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to load job"})
+		return
+	}
+	content.Render(c, http.StatusOK, dto.JobResponse{Message: "Job " + string(job.Status), Data: jobDTO(job)})
+}
+
+func jobDTO(j jobs.Job) dto.Job {
+	out := dto.Job{
+		ID: j.ID, Kind: j.Kind, Status: string(j.Status), Attempts: j.Attempts, MaxAttempts: j.MaxAttempts,
+		LastError: j.LastError, RunAt: j.RunAt, CreatedAt: j.CreatedAt, UpdatedAt: j.UpdatedAt,
+		StartedAt: j.StartedAt, FinishedAt: j.FinishedAt,
+	}
+	if len(j.Result) > 0 && string(j.Result) != "null" {
+		out.Result = json.RawMessage(j.Result)
+	}
+	return out
+}
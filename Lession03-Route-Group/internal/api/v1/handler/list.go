@@ -0,0 +1,49 @@
+package v1handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/listquery"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
+)
+
+// bindListQuery binds ?limit&offset&cursor&sort and checks them against spec.
+// It writes the 400 response itself and returns false when something is wrong.
+func bindListQuery(c *gin.Context, spec listquery.Spec) (listquery.Params, bool) {
+	var query dto.ListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":  "Invalid query parameters",
+			"fields": utils.FormatValidationErrors(err),
+		})
+		return listquery.Params{}, false
+	}
+
+	params, err := listquery.Parse(listquery.Request{
+		Limit:  query.Limit,
+		Offset: query.Offset,
+		Cursor: query.Cursor,
+		Sort:   query.Sort,
+	}, spec)
+	if err != nil {
+		respondListError(c, err)
+		return listquery.Params{}, false
+	}
+	return params, true
+}
+
+func respondListError(c *gin.Context, err error) {
+	var fe *listquery.FieldError
+	if errors.As(err, &fe) {
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":  "Invalid query parameters",
+			"fields": fe.Fields(),
+		})
+		return
+	}
+	content.Render(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,53 @@
+package v1handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/hub"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/middleware"
+	"golang.org/x/net/websocket"
+)
+
+type NotificationHandler struct {
+	hub *hub.Hub
+}
+
+func NewNotificationHandler(h *hub.Hub) *NotificationHandler {
+	return &NotificationHandler{hub: h}
+}
+
+// CreateNotificationTicket lets the admin calling open the notifications
+// WebSocket from a browser, which can't authenticate the handshake itself
+func (h *NotificationHandler) CreateNotificationTicket(c *gin.Context) {
+	ticket, expires := h.hub.Ticket(middleware.Principal(c))
+	content.Render(c, http.StatusCreated, dto.NotificationTicket{Ticket: ticket, ExpiresAt: expires})
+}
+
+// Notifications upgrades to the WebSocket of package hub, for admins: with
+// a ticket from CreateNotificationTicket or an admin's Authorization header
+func (h *NotificationHandler) Notifications(c *gin.Context) {
+	var query dto.NotificationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	principal := middleware.Principal(c)
+	if query.Ticket != "" {
+		p, ok := h.hub.Redeem(query.Ticket)
+		if !ok {
+			content.Render(c, http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
+			return
+		}
+		principal = p
+	} else if middleware.RequireAdmin(c); c.IsAborted() {
+		return
+	}
+
+	// No Origin check: the handshake is authenticated by ticket or header,
+	// never by cookies, so another site can't open it for a visitor
+	srv := websocket.Server{Handler: func(ws *websocket.Conn) { h.hub.Serve(ws, principal) }}
+	srv.ServeHTTP(c.Writer, c.Request)
+}
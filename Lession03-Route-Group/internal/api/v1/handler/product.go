@@ -1,74 +1,121 @@
 package v1handler
 
 import (
-	"encoding/json"
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
-	"github.com/google/uuid"
 	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/bulk"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/listquery"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/precondition"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/repository"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/search"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/stream"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/suggest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
 	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
 )
 
 type ProductHandler struct {
-	validate *validator.Validate
+	products   *repository.ProductRepository
+	categories *repository.CategoryRepository // to check category_ids
+	index      *search.Index
+	suggester  *suggest.Suggester
+	images     ImageCheck
+	imports    *bulk.Jobs
+	events     events.Publisher
+	progress   *stream.Broker // import status, see GET /events
 }
 
-func NewProductHandler() *ProductHandler {
-	v := validator.New()
-	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		_ = v.RegisterValidation("alphanumspace", utils.AlphaNumSpace)
-		_ = v.RegisterValidation("imgext", utils.ValidateImageExtension)
+// index and suggester must already be synced with products (see SyncProducts on each)
+func NewProductHandler(products *repository.ProductRepository, categories *repository.CategoryRepository, index *search.Index, suggester *suggest.Suggester, images ImageCheck, publisher events.Publisher, progress *stream.Broker) *ProductHandler {
+	utils.SetupBinding()
+	return &ProductHandler{
+		products: products, categories: categories, index: index, suggester: suggester, images: images,
+		imports: bulk.NewJobs(), events: publisher, progress: progress,
 	}
-	return &ProductHandler{validate: v}
-}
-func isUUID(u string) bool {
-	_, err := uuid.Parse(u)
-	return err == nil
 }
+
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
-	log.Printf("🔍 Request: %s %s from %s", c.Request.Method, c.FullPath(), c.ClientIP())
-	log.Printf("📦 Content-Type: %s", c.ContentType())
-	log.Printf("🧾 Headers: %+v", c.Request.Header)
+	req, ok := bindProductRequest(c)
+	if !ok || !h.images.verify(c, productImageURLs(newProductModel(req))) {
+		return
+	}
+
+	// Auto set created_at timestamp
+	now := time.Now()
+	req.CreatedAt = now.Format("2006-01-02 15:04:05")
+
+	// ✅ Uniqueness check
+	if h.ProductNameExists(req.Name, 0) {
+		respondNameTaken(c)
+		return
+	}
+	if req.Slug != "" && h.products.SlugTaken(req.Slug, 0) {
+		respondSlugTaken(c)
+		return
+	}
+	if missing := h.categories.Missing(req.CategoryIDs); len(missing) > 0 {
+		respondUnknownCategories(c, missing)
+		return
+	}
+
+	product := newProductModel(req)
+	product.CreatedAt = now
+	if err := h.products.Create(&product); err != nil {
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to save product"})
+		return
+	}
+	publish(c, h.events, events.ProductCreated, product.ID, product)
+	h.images.enqueue(c, ProductImagesJob, product.ID, productImageURLs(product))
+
+	c.Header("ETag", precondition.ETag(product.Version))
+	content.Render(c, http.StatusCreated, gin.H{
+		"message": "New product created",
+		"id":      product.ID,
+		"slug":    product.Slug,
+		"data":    req,
+	})
+}
 
-	// If needed, read body manually (use with care — affects binding)
-	// body, _ := io.ReadAll(c.Request.Body)
-	// log.Printf("Raw body: %s", string(body))
+// bindProductRequest binds and validates the body shared by create and update,
+// in any format of package content.
+// It writes the 400 response itself and returns false when the body is not valid.
+func bindProductRequest(c *gin.Context) (dto.CreateProductRequest, bool) {
 	var req dto.CreateProductRequest
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := content.Bind(c, &req); err != nil {
+		if bodyUnsupported(c, err) {
+			return req, false
+		}
 		var ve validator.ValidationErrors
 		if errors.As(err, &ve) {
-			c.JSON(http.StatusBadRequest, gin.H{
+			content.Render(c, http.StatusBadRequest, gin.H{
 				"error":  "Validation failed",
 				"fields": utils.FormatValidationErrors(ve),
 			})
 		} else {
 			// Not a validation error — probably a binding type mismatch
-			log.Println("❌ JSON Bind Error:", err)
-			c.JSON(http.StatusBadRequest, gin.H{
+			log.Println("❌ Bind Error:", err)
+			content.Render(c, http.StatusBadRequest, gin.H{
 				"error": "Invalid request format",
 				"msg":   err.Error(), // Show the actual error for debugging
 			})
 		}
-		return
-	}
-
-	// ✅ Manual validation for map keys
-	for key := range req.ProductInfo {
-		if !isUUID(key) {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": fmt.Sprintf("product_info key '%s' is not a valid UUID", key),
-			})
-			return
-		}
+		return req, false
 	}
 
 	// ✅ Set default if field is not provided (still false)
@@ -76,47 +123,275 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		trueVal := true
 		req.Display = &trueVal
 	}
+	return req, true
+}
 
-	// Print the received JSON
-	log.Printf("Received product: %+v\n", req)
+func respondNameTaken(c *gin.Context) {
+	content.Render(c, http.StatusBadRequest, nameTaken)
+}
 
-	//If you want pretty-print JSON, use json.MarshalIndent:
-	b, _ := json.MarshalIndent(req, "", "  ")
-	log.Println("Request body:", string(b))
+// The bodies of the 400s below, imports report them per row
+var nameTaken = dto.ErrorResponse{
+	Error:  "Product name already exists",
+	Fields: map[string]string{"name": "This product name is already in use"},
+}
 
-	// Auto set created_at timestamp
-	now := time.Now().Format("2006-01-02 15:04:05")
-	req.CreatedAt = now
+// bindProductID reads :id, writes the 400 itself when it is not a positive integer
+func bindProductID(c *gin.Context) (int, bool) {
+	var uri dto.ProductUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			content.Render(c, http.StatusBadRequest, gin.H{
+				"error":  "Validation failed",
+				"fields": utils.FormatValidationErrors(ve),
+			})
+			return 0, false
+		}
+		content.Render(c, http.StatusBadRequest, gin.H{"error": "ID must be a valid positive integer"})
+		return 0, false
+	}
+	return uri.ID, true
+}
 
-	// ✅ Uniqueness check
-	if h.ProductNameExists(req.Name) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Product name already exists",
-			"fields": gin.H{
-				"name": "This product name is already in use",
-			},
-		})
+func (h *ProductHandler) GetProductByID(c *gin.Context) {
+	id, ok := bindProductID(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "New product created",
-		"data":    req,
+	product, err := h.products.FindByID(id)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	h.suggester.Viewed(id)
+
+	// The client already has this version: 304, no body
+	if precondition.NotModified(c, precondition.ETag(product.Version)) {
+		return
+	}
+
+	content.Render(c, http.StatusOK, gin.H{
+		"message": "Product details for ID " + strconv.Itoa(id),
+		"data":    product,
 	})
 }
 
-// Example with dummy memory check
-func (h *ProductHandler) ProductNameExists(name string) bool {
-	// Replace with DB logic like:
-	// err := db.Where("name = ?", name).First(&product).Error
-	existing := []string{"Golang T-shirt", "Python Mug"}
+// GetProductBySlug is GetProductByID for links with a readable URL.
+// An old slug (the product was renamed since) answers 301 to the current one.
+func (h *ProductHandler) GetProductBySlug(c *gin.Context) {
+	var uri dto.ProductSlugUri
+	if !bindSlug(c, &uri) {
+		return
+	}
+
+	product, err := h.products.FindBySlug(uri.Slug)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if product.Slug != uri.Slug {
+		redirectToSlug(c, product.Slug)
+		return
+	}
+	h.suggester.Viewed(product.ID)
 
-	for _, n := range existing {
-		if strings.EqualFold(n, name) {
-			return true
+	if precondition.NotModified(c, precondition.ETag(product.Version)) {
+		return
+	}
+
+	content.Render(c, http.StatusOK, gin.H{
+		"message": "Product details for slug " + product.Slug,
+		"data":    product,
+	})
+}
+
+// UpdateProduct replaces the whole product. The client must send If-Match
+// with the ETag it read, so two admins can't overwrite each other silently.
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	id, ok := bindProductID(c)
+	if !ok {
+		return
+	}
+
+	current, err := h.products.FindByID(id)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if !precondition.IfMatch(c, precondition.ETag(current.Version)) {
+		return
+	}
+
+	req, ok := bindProductRequest(c)
+	if !ok || !h.images.verify(c, productImageURLs(newProductModel(req))) {
+		return
+	}
+
+	if h.ProductNameExists(req.Name, id) {
+		respondNameTaken(c)
+		return
+	}
+	if req.Slug != "" && h.products.SlugTaken(req.Slug, id) {
+		respondSlugTaken(c)
+		return
+	}
+	if missing := h.categories.Missing(req.CategoryIDs); len(missing) > 0 {
+		respondUnknownCategories(c, missing)
+		return
+	}
+
+	product := newProductModel(req)
+	product.ID = id
+	product.Version = current.Version
+	if err := h.products.Update(&product); err != nil {
+		h.respondWriteError(c, id, err)
+		return
+	}
+	publish(c, h.events, events.ProductUpdated, product.ID, product)
+	h.images.enqueue(c, ProductImagesJob, product.ID, productImageURLs(product))
+
+	c.Header("ETag", precondition.ETag(product.Version))
+	content.Render(c, http.StatusOK, gin.H{
+		"message": "Updated product with ID " + strconv.Itoa(id),
+		"data":    product,
+	})
+}
+
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	id, ok := bindProductID(c)
+	if !ok {
+		return
+	}
+
+	current, err := h.products.FindByID(id)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+	if !precondition.IfMatch(c, precondition.ETag(current.Version)) {
+		return
+	}
+
+	if err := h.products.Delete(id, current.Version); err != nil {
+		h.respondWriteError(c, id, err)
+		return
+	}
+	publish(c, h.events, events.ProductDeleted, id, events.Deleted{ID: id})
+
+	content.Render(c, http.StatusOK, gin.H{
+		"message": "Deleted product with ID " + strconv.Itoa(id),
+	})
+}
+
+func respondUnknownCategories(c *gin.Context, missing []int) {
+	content.Render(c, http.StatusBadRequest, unknownCategories(missing))
+}
+
+func unknownCategories(missing []int) dto.ErrorResponse {
+	return dto.ErrorResponse{
+		Error:  "Validation failed",
+		Fields: map[string]string{"CategoryIDs": fmt.Sprintf("Unknown categories: %v", missing)},
+	}
+}
+
+// respondWriteError maps repository errors of Update/Delete to a response.
+// A conflict here means someone wrote between our If-Match check and the write.
+func (h *ProductHandler) respondWriteError(c *gin.Context, id int, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Product not found"})
+	case errors.Is(err, repository.ErrVersionConflict):
+		latest, findErr := h.products.FindByID(id)
+		if findErr != nil {
+			content.Render(c, http.StatusNotFound, gin.H{"error": "Product not found"})
+			return
+		}
+		precondition.Failed(c, precondition.ETag(latest.Version))
+	case errors.Is(err, repository.ErrReserved):
+		content.Render(c, http.StatusConflict, gin.H{"error": "A variant removed or restocked below its open reservations"})
+	default:
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to save product"})
+	}
+}
+
+// exceptID lets an update keep its own name, pass 0 when creating.
+// The demo catalogue, with the names that used to be hard-coded here, is in seed/demo.yaml.
+func (h *ProductHandler) ProductNameExists(name string, exceptID int) bool {
+	return h.products.NameExists(name, exceptID)
+}
+
+func newProductModel(req dto.CreateProductRequest) models.Product {
+	p := models.Product{
+		Name:        req.Name,
+		Slug:        req.Slug,
+		Description: req.Description,
+		Price:       models.Money{Amount: req.Price.Amount, Currency: req.Price.Currency},
+		Stock:       req.Stock,
+		Tags:        req.Tags,
+		Display:     req.Display != nil && *req.Display,
+		Email:       req.Email,
+		Avatar:      models.Image{URL: req.Avartar.URL, AltText: req.Avartar.Alt},
+		CategoryIDs: req.CategoryIDs,
+		Info:        make(map[string]models.Info, len(req.ProductInfo)),
+	}
+	for _, img := range req.Image {
+		p.Images = append(p.Images, models.Image{URL: img.URL, AltText: img.AltText})
+	}
+	for key, info := range req.ProductInfo {
+		p.Info[key] = models.Info{InfoKey: info.InfoKey, InfoValue: info.InfoValue}
+	}
+	for _, vr := range req.Variants {
+		v := models.Variant{SKU: vr.SKU, Options: vr.Options, Stock: vr.Stock}
+		for _, m := range vr.Prices {
+			v.Prices = append(v.Prices, models.Money{Amount: m.Amount, Currency: m.Currency})
 		}
+		for _, img := range vr.Images {
+			v.Images = append(v.Images, models.Image{URL: img.URL, AltText: img.AltText})
+		}
+		p.Variants = append(p.Variants, v)
+	}
+	return p
+}
+
+// ProductImagesJob is the job kind of CheckImages
+const ProductImagesJob = "products.check-images"
+
+// CheckImages verifies the image URLs of a saved product, see
+// ImageCheck.Queue. A product showing images that are no good is taken off
+// the shelf (display false, stock 0, like the hidden rule of the request)
+// until they are fixed, the listings and suggestions leave it out. Nothing
+// is done when the product is gone or its images changed since, the update
+// queued its own check.
+func (h *ProductHandler) CheckImages(ctx context.Context, job jobs.Job) (any, error) {
+	var p imageCheckJob
+	if err := job.Decode(&p); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	failed, err := rejected(h.images.Verifier.VerifyAll(ctx, p.URLs))
+	if err != nil {
+		return nil, err
+	}
+	res := dto.ImageCheckResult{Failed: failed}
+	if len(failed) == 0 {
+		return res, nil
+	}
+
+	product, err := h.products.FindByID(p.ID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !maps.Equal(productImageURLs(product), p.URLs)) {
+		return res, nil
+	}
+	if err != nil || !product.Display {
+		return res, err
 	}
-	return false
+	product.Display, product.Stock = false, 0
+	if err := h.products.Update(&product); err != nil {
+		return nil, err // changed meanwhile, the retry looks again
+	}
+	publishCtx(ctx, h.events, events.ProductUpdated, product.ID, product)
+	res.Hidden = true
+	return res, nil
 }
 
 // If using a real DB (e.g., GORM + MySQL/PostgreSQL):
@@ -131,50 +406,119 @@ func (h *ProductHandler) GetProductByLang(c *gin.Context) {
 
 	if err := c.ShouldBindUri(&uri); err != nil {
 		// Handle validation error
-		c.JSON(http.StatusBadRequest, gin.H{
+		content.Render(c, http.StatusBadRequest, gin.H{
 			"error":  "Validation failed",
 			"fields": utils.FormatValidationErrors(err),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	content.Render(c, http.StatusOK, gin.H{
 		"language": uri.Lang,
 		"message":  "Products filtered by language: " + uri.Lang,
 	})
 }
 
+// Sort fields a client may ask for on GET /products
+var productSortFields = map[string]listquery.Comparator[dto.ProductSearchResult]{
+	"name": func(a, b dto.ProductSearchResult) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	},
+	"price": func(a, b dto.ProductSearchResult) int {
+		return comparePrices(a.Price, b.Price)
+	},
+	"created_at": func(a, b dto.ProductSearchResult) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	},
+}
+
+// comparePrices orders by currency, then amount: 1000 VND is not more than 5 USD
+func comparePrices(a, b models.Money) int {
+	return cmp.Or(strings.Compare(a.Currency, b.Currency), cmp.Compare(a.Amount, b.Amount))
+}
+
+var productListSpec = listquery.Spec{
+	SortFields: []string{"name", "price", "created_at"},
+}
+
 func (h *ProductHandler) GetProducts(c *gin.Context) {
 	var query dto.ProductQuery
 
 	// Bind query params
 	if err := c.ShouldBindQuery(&query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		content.Render(c, http.StatusBadRequest, gin.H{
 			"error":  "Invalid query parameters",
 			"fields": utils.FormatValidationErrors(err),
 		})
 		return
 	}
 
-	// Default value for limit
-	if query.Limit == 0 {
-		query.Limit = 10
+	if query.PriceMin != nil && query.PriceMax != nil && *query.PriceMax < *query.PriceMin {
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":  "Invalid query parameters",
+			"fields": gin.H{"price_max": "price_max must be greater than or equal to price_min"},
+		})
+		return
 	}
 
-	// Re-validate using custom validator (e.g., alphanumspace, min/max)
-	if err := h.validate.Struct(query); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":  "Validation failed",
+	params, err := listquery.Parse(listquery.Request{
+		Limit:  query.Limit,
+		Offset: query.Offset,
+		Cursor: query.Cursor,
+		Sort:   query.Sort,
+	}, productListSpec)
+	if err != nil {
+		respondListError(c, err)
+		return
+	}
+
+	// Best match first; an explicit ?sort= re-orders the matches afterwards
+	var items []dto.ProductSearchResult
+	for _, hit := range h.index.Search(query.Search) {
+		p, err := h.products.FindByID(hit.ID)
+		if err != nil {
+			continue // deleted between the search and now
+		}
+		if !p.Display {
+			continue // off the shelf, see CheckImages
+		}
+		if query.Currency != "" && p.Price.Currency != query.Currency {
+			continue
+		}
+		if !listquery.InRange(p.Price.Amount, query.PriceMin, query.PriceMax) {
+			continue
+		}
+		// Date was already checked against 2006-01-02 by the binding
+		if query.Date != "" && p.CreatedAt.Format(time.DateOnly) != query.Date {
+			continue
+		}
+		items = append(items, dto.ProductSearchResult{Product: p, Score: hit.Score, Highlights: hit.Snippets})
+	}
+	listquery.Sort(items, params.Sort, productSortFields)
+
+	content.Render(c, http.StatusOK, listquery.NewPage(c.Request.URL, items, params))
+}
+
+const defaultSuggestions = 5
+
+// SuggestProducts answers the search box on every keystroke, so it only reads
+// precomputed data and lets the browser cache the answer for a little while
+func (h *ProductHandler) SuggestProducts(c *gin.Context) {
+	var query dto.SuggestQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		content.Render(c, http.StatusBadRequest, gin.H{
+			"error":  "Invalid query parameters",
 			"fields": utils.FormatValidationErrors(err),
 		})
 		return
 	}
+	if query.Limit == 0 {
+		query.Limit = defaultSuggestions
+	}
 
-	// Success
-	c.JSON(http.StatusOK, gin.H{
-		"search":  query.Search,
-		"limit":   query.Limit,
-		"message": "Success",
-		"email":   query.Email,
+	c.Header("Cache-Control", "public, max-age=30")
+	content.Render(c, http.StatusOK, gin.H{
+		"query":       query.Q,
+		"suggestions": h.suggester.Suggest(query.Q, query.Limit),
 	})
 }
@@ -0,0 +1,48 @@
+package v1handler
+
+import (
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
+)
+
+// bindSlug binds the :slug of uri (a *dto.ProductSlugUri or *dto.UserSlugQuery), writes the 400 itself
+func bindSlug(c *gin.Context, uri any) bool {
+	if err := c.ShouldBindUri(uri); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			content.Render(c, http.StatusBadRequest, gin.H{
+				"error":  "Validation failed",
+				"fields": utils.FormatValidationErrors(ve),
+			})
+			return false
+		}
+		content.Render(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// redirectToSlug answers a request for an old slug with a 301 to the current
+// one, same route, same query string
+func redirectToSlug(c *gin.Context, current string) {
+	u := *c.Request.URL
+	u.Path = path.Join(path.Dir(u.Path), current)
+	u.RawPath = ""
+	c.Redirect(http.StatusMovedPermanently, u.String())
+}
+
+func respondSlugTaken(c *gin.Context) {
+	content.Render(c, http.StatusBadRequest, slugTaken)
+}
+
+var slugTaken = dto.ErrorResponse{
+	Error:  "Slug already exists",
+	Fields: map[string]string{"slug": "This slug is already in use"},
+}
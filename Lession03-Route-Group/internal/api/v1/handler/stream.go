@@ -0,0 +1,105 @@
+package v1handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/middleware"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/stream"
+)
+
+// Topics of GET /events
+const (
+	UploadsTopic = "uploads" // "upload" events, per file of upload-multiple
+	JobsTopic    = "jobs"    // "import" events of bulk imports, "job" events of the job queue
+)
+
+// UploadIDHeader lets a client name its upload-multiple request, to pick its
+// events out of the stream before the response comes
+const UploadIDHeader = "X-Upload-ID"
+
+type StreamHandler struct {
+	broker *stream.Broker
+	// Heartbeat is how often an idle stream gets a comment line, so the
+	// client and the proxies in between know it is still alive
+	Heartbeat time.Duration
+}
+
+func NewStreamHandler(broker *stream.Broker) *StreamHandler {
+	return &StreamHandler{broker: broker, Heartbeat: 15 * time.Second}
+}
+
+// Stream sends the events of the topics as Server-Sent Events until the
+// client goes away. A caller authenticated with an API token sees the events
+// of its own uploads and imports, admins see everyone's and the job queue's.
+// Reconnecting with Last-Event-ID replays what was missed, or starts with a
+// "reset" event when that is no longer known.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	var query dto.StreamQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	var lastID uint64
+	if v := c.GetHeader("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID", "msg": "expected the id of an event of this stream"})
+			return
+		}
+		lastID = id
+	}
+
+	principal, admin := middleware.User(c), middleware.IsAdmin(c)
+	sub, missed, complete := h.broker.Subscribe(query.Topic, func(e stream.Event) bool {
+		return admin || (e.Owner != "" && e.Owner == principal)
+	}, lastID)
+	defer sub.Close()
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no") // or nginx holds the events back
+	c.Status(http.StatusOK)
+	w := c.Writer
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+	if !complete {
+		sse.Encode(w, sse.Event{Event: "reset", Data: dto.StreamReset{Message: "Some events were missed, reload the current state"}})
+	}
+	for _, e := range missed {
+		writeEvent(w, e)
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return // fell behind, the client reconnects and catches up
+			}
+			writeEvent(w, e)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		w.Flush()
+	}
+}
+
+func writeEvent(w gin.ResponseWriter, e stream.Event) {
+	sse.Encode(w, sse.Event{Id: strconv.FormatUint(e.ID, 10), Event: e.Name, Data: e.Data})
+}
+
+// JobChanged is a jobs.Queue observer. Queue jobs belong to nobody, only
+// admins see them.
+func (h *StreamHandler) JobChanged(j jobs.Job) {
+	h.broker.Publish(JobsTopic, "", "job", jobDTO(j))
+}
@@ -1,85 +1,147 @@
 package v1handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/listquery"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/precondition"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/repository"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
 	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
 )
 
-type UserHandler struct {
-	validate *validator.Validate
+// UserStore keeps the users: repository.UserRepository in memory, or
+// repository.SQLUserRepository in the database lession03 user create-admin
+// writes to
+type UserStore interface {
+	Create(u *models.User) error
+	Update(u *models.User) error
+	Delete(id, version int) error
+	FindByID(id int) (models.User, error)
+	FindBySlug(s string) (models.User, error)
+	SlugTaken(s string, exceptID int) bool
+	FindAll() ([]models.User, error)
 }
 
-func NewUserHandler() *UserHandler {
-	v := validator.New()
-	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		_ = v.RegisterValidation("alphanumspace", utils.AlphaNumSpace)
-		_ = v.RegisterValidation("slug", utils.ValidateSlug)
-	}
+type UserHandler struct {
+	users  UserStore
+	events events.Publisher
+}
 
-	return &UserHandler{validate: v}
+func NewUserHandler(users UserStore, publisher events.Publisher) *UserHandler {
+	utils.SetupBinding()
+	return &UserHandler{users: users, events: publisher}
 }
 
-func (h *UserHandler) GetUserByID(c *gin.Context) {
+// bindUserID reads :id, writes the 400 itself when it is not a positive integer
+func bindUserID(c *gin.Context) (int, bool) {
 	var uri dto.UserQuery
 
 	if err := c.ShouldBindUri(&uri); err != nil {
 		if ve, ok := err.(validator.ValidationErrors); ok {
-			c.JSON(http.StatusBadRequest, gin.H{
+			content.Render(c, http.StatusBadRequest, gin.H{
 				"error":  "Validation failed",
 				"fields": utils.FormatValidationErrors(ve),
 			})
-			return
+			return 0, false
 		}
 		// Handle parse error (e.g. string instead of int)
-		//c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		c.JSON(http.StatusBadRequest, gin.H{
+		//content.Render(c, http.StatusBadRequest, gin.H{"error": err.Error()})
+		content.Render(c, http.StatusBadRequest, gin.H{
 			"error": "ID must be a valid positive integer",
 		})
+		return 0, false
+	}
+	return uri.ID, true
+}
+
+func (h *UserHandler) GetUserByID(c *gin.Context) {
+	id, ok := bindUserID(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id":      uri.ID,
+	user, err := h.users.FindByID(id)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if precondition.NotModified(c, precondition.ETag(user.Version)) {
+		return
+	}
+
+	content.Render(c, http.StatusOK, gin.H{
+		"id":      user.ID,
 		"message": "User ID is valid",
+		"data":    user,
 	})
 }
 
+// Sort fields a client may ask for on GET /users
+var userSortFields = map[string]listquery.Comparator[models.User]{
+	"name": func(a, b models.User) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	},
+	"created_at": func(a, b models.User) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	},
+}
+
+var userListSpec = listquery.Spec{
+	SortFields: []string{"name", "created_at"},
+}
+
 func (h *UserHandler) GetUsers(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "List of all users (V1)",
-		"users":   []string{"Alice", "Bob", "Charlie"},
-	})
+	params, ok := bindListQuery(c, userListSpec)
+	if !ok {
+		return
+	}
+
+	users, err := h.users.FindAll()
+	if err != nil {
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
+		return
+	}
+	listquery.Sort(users, params.Sort, userSortFields)
+
+	content.Render(c, http.StatusOK, listquery.NewPage(c.Request.URL, users, params))
 }
 
 func (h *UserHandler) GetUserWithoutSlug(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	content.Render(c, http.StatusOK, gin.H{
 		"slug": "no news",
 	})
 }
 
 func (h *UserHandler) GetUserBySlug(c *gin.Context) {
 	var uri dto.UserSlugQuery
+	if !bindSlug(c, &uri) {
+		return
+	}
 
-	if err := c.ShouldBindUri(&uri); err != nil {
-		if ve, ok := err.(validator.ValidationErrors); ok {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":  "Validation failed",
-				"fields": utils.FormatValidationErrors(ve),
-			})
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	user, err := h.users.FindBySlug(uri.Slug)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.Slug != uri.Slug {
+		redirectToSlug(c, user.Slug)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	content.Render(c, http.StatusOK, gin.H{
 		"type":    "Slug User",
-		"message": "User details for slug: " + uri.Slug,
+		"slug":    user.Slug,
+		"message": "User details for slug: " + user.Slug,
+		"data":    user,
 	})
 }
 
@@ -88,39 +150,154 @@ func (h *UserHandler) GetUserByUUID(c *gin.Context) {
 
 	if err := c.ShouldBindUri(&uri); err != nil {
 		if ve, ok := err.(validator.ValidationErrors); ok {
-			c.JSON(http.StatusBadRequest, gin.H{
+			content.Render(c, http.StatusBadRequest, gin.H{
 				"error":  "Validation failed",
 				"fields": utils.FormatValidationErrors(ve),
 			})
 			return
 		}
 
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
+		content.Render(c, http.StatusBadRequest, gin.H{"error": "Invalid path parameter"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	content.Render(c, http.StatusOK, gin.H{
 		"uuid":    uri.UUID,
 		"message": "Valid UUID",
 	})
 }
 
+// bindUserRequest binds the body of create and update, writes the 400 itself
+func bindUserRequest(c *gin.Context) (dto.CreateUserRequest, bool) {
+	var req dto.CreateUserRequest
+	if err := content.Bind(c, &req); err != nil {
+		if bodyUnsupported(c, err) {
+			return req, false
+		}
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			content.Render(c, http.StatusBadRequest, gin.H{
+				"error":  "Validation failed",
+				"fields": utils.FormatValidationErrors(ve),
+			})
+			return req, false
+		}
+		content.Render(c, http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return req, false
+	}
+	return req, true
+}
+
+// slugTaken reports whether another user (not exceptID) already has slug
+func (h *UserHandler) slugTaken(slug string, exceptID int) bool {
+	if slug == "" {
+		return false
+	}
+	return h.users.SlugTaken(slug, exceptID)
+}
+
 func (h *UserHandler) CreateUser(c *gin.Context) {
-	c.JSON(http.StatusCreated, gin.H{
+	req, ok := bindUserRequest(c)
+	if !ok {
+		return
+	}
+	if h.slugTaken(req.Slug, 0) {
+		respondSlugTaken(c)
+		return
+	}
+
+	user := models.User{Name: req.Name, Email: req.Email, Slug: req.Slug}
+	if err := h.users.Create(&user); err != nil {
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to save user"})
+		return
+	}
+	publish(c, h.events, events.UserCreated, user.ID, user)
+
+	c.Header("ETag", precondition.ETag(user.Version))
+	content.Render(c, http.StatusCreated, gin.H{
 		"message": "New user created",
+		"data":    user,
 	})
 }
 
+// UpdateUser needs If-Match with the ETag from GET /users/:id
 func (h *UserHandler) UpdateUser(c *gin.Context) {
-	id := c.Param("id")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Updated user with ID " + id,
+	id, ok := bindUserID(c)
+	if !ok {
+		return
+	}
+
+	current, err := h.users.FindByID(id)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !precondition.IfMatch(c, precondition.ETag(current.Version)) {
+		return
+	}
+
+	req, ok := bindUserRequest(c)
+	if !ok {
+		return
+	}
+	if h.slugTaken(req.Slug, id) {
+		respondSlugTaken(c)
+		return
+	}
+
+	user := models.User{ID: id, Name: req.Name, Email: req.Email, Slug: req.Slug, Version: current.Version}
+	if err := h.users.Update(&user); err != nil {
+		h.respondWriteError(c, id, err)
+		return
+	}
+	publish(c, h.events, events.UserUpdated, user.ID, user)
+
+	c.Header("ETag", precondition.ETag(user.Version))
+	content.Render(c, http.StatusOK, gin.H{
+		"message": "Updated user with ID " + strconv.Itoa(id),
+		"data":    user,
 	})
 }
 
+// DeleteUser needs If-Match with the ETag from GET /users/:id
 func (h *UserHandler) DeleteUser(c *gin.Context) {
-	id := c.Param("id")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Deleted user with ID " + id,
+	id, ok := bindUserID(c)
+	if !ok {
+		return
+	}
+
+	current, err := h.users.FindByID(id)
+	if err != nil {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !precondition.IfMatch(c, precondition.ETag(current.Version)) {
+		return
+	}
+
+	if err := h.users.Delete(id, current.Version); err != nil {
+		h.respondWriteError(c, id, err)
+		return
+	}
+	publish(c, h.events, events.UserDeleted, id, events.Deleted{ID: id})
+
+	content.Render(c, http.StatusOK, gin.H{
+		"message": "Deleted user with ID " + strconv.Itoa(id),
 	})
 }
+
+// respondWriteError maps repository errors of Update/Delete to a response
+func (h *UserHandler) respondWriteError(c *gin.Context, id int, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		content.Render(c, http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, repository.ErrVersionConflict):
+		latest, findErr := h.users.FindByID(id)
+		if findErr != nil {
+			content.Render(c, http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		precondition.Failed(c, precondition.ETag(latest.Version))
+	default:
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to save user"})
+	}
+}
@@ -0,0 +1,140 @@
+package v1handler
+
+import (
+	"cmp"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/webhook"
+)
+
+type WebhookHandler struct {
+	webhooks *webhook.Service // nil when the server runs without a database
+}
+
+func NewWebhookHandler(webhooks *webhook.Service) *WebhookHandler {
+	return &WebhookHandler{webhooks: webhooks}
+}
+
+// available writes the 503 itself when there is no webhook service
+func (h *WebhookHandler) available(c *gin.Context) bool {
+	if h.webhooks == nil {
+		content.Render(c, http.StatusServiceUnavailable, gin.H{"error": "Webhooks are not available"})
+		return false
+	}
+	return true
+}
+
+// CreateWebhook subscribes an endpoint to events. The answer carries the
+// signing secret, the only time it is shown.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req dto.CreateWebhookRequest
+	if err := content.Bind(c, &req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if !h.available(c) {
+		return
+	}
+
+	w, err := h.webhooks.Create(c.Request.Context(), req.URL, req.Events, req.Description)
+	if err != nil {
+		log.Printf("create webhook: %v", err)
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to save webhook"})
+		return
+	}
+	c.Header("Location", "/api/v1/webhooks/"+w.ID)
+	content.Render(c, http.StatusCreated, dto.WebhookResponse{
+		Message: "Webhook created, keep the secret: it is not shown again",
+		Data:    webhookDTO(w, true),
+	})
+}
+
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	if !h.available(c) {
+		return
+	}
+	all, err := h.webhooks.List(c.Request.Context())
+	if err != nil {
+		log.Printf("list webhooks: %v", err)
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to load webhooks"})
+		return
+	}
+	out := dto.WebhookListResponse{Data: []dto.Webhook{}}
+	for _, w := range all {
+		out.Data = append(out.Data, webhookDTO(w, false))
+	}
+	content.Render(c, http.StatusOK, out)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	var uri dto.WebhookUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if !h.available(c) {
+		return
+	}
+	err := h.webhooks.Delete(c.Request.Context(), uri.ID)
+	if errors.Is(err, webhook.ErrNotFound) {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("delete webhook %s: %v", uri.ID, err)
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	content.Render(c, http.StatusOK, gin.H{"message": "Deleted webhook " + uri.ID})
+}
+
+// GetWebhookDeliveries is the delivery log of a webhook: every attempt,
+// newest first
+func (h *WebhookHandler) GetWebhookDeliveries(c *gin.Context) {
+	var uri dto.WebhookUri
+	if err := c.ShouldBindUri(&uri); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	var query dto.WebhookDeliveriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		respondBindError(c, err)
+		return
+	}
+	if !h.available(c) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.webhooks.Find(ctx, uri.ID); errors.Is(err, webhook.ErrNotFound) {
+		content.Render(c, http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	attempts, err := h.webhooks.Deliveries(ctx, uri.ID, cmp.Or(query.Limit, 50))
+	if err != nil {
+		log.Printf("deliveries of webhook %s: %v", uri.ID, err)
+		content.Render(c, http.StatusInternalServerError, gin.H{"error": "Failed to load deliveries"})
+		return
+	}
+	out := dto.WebhookDeliveriesResponse{Data: []dto.WebhookDelivery{}}
+	for _, d := range attempts {
+		out.Data = append(out.Data, dto.WebhookDelivery{
+			EventID: d.EventID, EventType: d.EventType, DeliveryID: d.JobID, Attempt: d.Attempt,
+			StatusCode: d.StatusCode, Error: d.Error, DurationMS: d.Duration.Milliseconds(), CreatedAt: d.CreatedAt,
+		})
+	}
+	content.Render(c, http.StatusOK, out)
+}
+
+func webhookDTO(w webhook.Webhook, withSecret bool) dto.Webhook {
+	out := dto.Webhook{ID: w.ID, URL: w.URL, Events: w.Events, Description: w.Description, CreatedAt: w.CreatedAt}
+	if withSecret {
+		out.Secret = w.Secret
+	}
+	return out
+}
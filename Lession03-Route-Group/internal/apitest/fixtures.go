@@ -0,0 +1,104 @@
+package apitest
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/url"
+	"strconv"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+)
+
+// Magic numbers are all http.DetectContentType looks at
+var (
+	PNG  = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+	JPEG = append([]byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), make([]byte, 64)...)
+)
+
+// ProductRequest is a CreateProductRequest that passes validation, mods change it from there:
+//
+//	apitest.ProductRequest(func(p *dto.CreateProductRequest) { p.Price.Amount = 0 })
+func ProductRequest(mods ...func(*dto.CreateProductRequest)) dto.CreateProductRequest {
+	req := dto.CreateProductRequest{
+		Name:        "Gopher Tee",
+		Description: "Soft cotton tee",
+		Price:       dto.MoneyRequest{Amount: 2500, Currency: "USD"},
+		Stock:       10,
+		Tags:        []string{"clothes", "golang"},
+		Avartar:     dto.AvartarImage{URL: "https://example.com/avatar.png", Alt: "avatar"},
+		Image:       []dto.ProductImage{{URL: "https://example.com/front.jpg", AltText: "front"}},
+		ProductInfo: map[string]dto.ProductInfo{
+			"3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f": {InfoKey: "size", InfoValue: "L"},
+		},
+	}
+	for _, mod := range mods {
+		mod(&req)
+	}
+	return req
+}
+
+// WithVariant is a ProductRequest mod adding the variant TEE-GO-L,
+// 5 in stock at $25.00 or 600.000đ
+func WithVariant(p *dto.CreateProductRequest) {
+	p.Variants = append(p.Variants, dto.VariantRequest{
+		SKU:     "TEE-GO-L",
+		Options: map[string]string{"size": "L", "colour": "blue"},
+		Prices:  []dto.MoneyRequest{{Amount: 2500, Currency: "USD"}, {Amount: 600000, Currency: "VND"}},
+		Stock:   5,
+	})
+}
+
+// CategoryRequest is a CreateCategoryRequest that passes validation
+func CategoryRequest(mods ...func(*dto.CreateCategoryRequest)) dto.CreateCategoryRequest {
+	req := dto.CreateCategoryRequest{
+		Name:        "Shirts",
+		Description: "Everything with sleeves",
+		ImageURL:    "https://example.com/shirts.png",
+	}
+	for _, mod := range mods {
+		mod(&req)
+	}
+	return req
+}
+
+// CategoryForm is req as POST /categories expects it
+func CategoryForm(req dto.CreateCategoryRequest) url.Values {
+	form := url.Values{
+		"name":        {req.Name},
+		"description": {req.Description},
+		"image_url":   {req.ImageURL},
+	}
+	if req.ParentID != 0 {
+		form.Set("parent_id", strconv.Itoa(req.ParentID))
+	}
+	return form
+}
+
+// MultipartBuilder writes a multipart/form-data body field by field
+type MultipartBuilder struct {
+	buf bytes.Buffer
+	w   *multipart.Writer
+}
+
+func NewMultipart() *MultipartBuilder {
+	b := &MultipartBuilder{}
+	b.w = multipart.NewWriter(&b.buf)
+	return b
+}
+
+func (b *MultipartBuilder) Field(name, value string) *MultipartBuilder {
+	_ = b.w.WriteField(name, value)
+	return b
+}
+
+func (b *MultipartBuilder) File(field, filename string, data []byte) *MultipartBuilder {
+	part, _ := b.w.CreateFormFile(field, filename)
+	_, _ = part.Write(data)
+	return b
+}
+
+// Multipart sends what b has written so far as the request body
+func Multipart(b *MultipartBuilder) RequestOption {
+	_ = b.w.Close()
+	return Body(b.w.FormDataContentType(), b.buf.Bytes())
+}
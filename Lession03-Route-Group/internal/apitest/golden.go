@@ -0,0 +1,80 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// volatile keys differ on every run, their values are replaced by "<key>".
+// UUIDs are masked wherever they appear, that covers the generated upload names too.
+var volatile = map[string]bool{"created_at": true, "updated_at": true, "expires_at": true}
+
+var uuidPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
+// Golden compares the JSON body with testdata/golden/<name>.json, after
+// masking timestamps, UUIDs and the temporary upload directory. Run the tests with
+// -update to write the files.
+func (r *Response) Golden(name string) *Response {
+	r.t.Helper()
+	data := r.Body.Bytes()
+	if r.uploadDir != "" {
+		data = bytes.ReplaceAll(data, []byte(r.uploadDir), []byte("<upload_dir>"))
+	}
+	var body any
+	if err := json.Unmarshal(data, &body); err != nil {
+		r.t.Fatalf("golden %s: body is not JSON: %v\n%s", name, err, r.Body.String())
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(mask(body)); err != nil {
+		r.t.Fatal(err)
+	}
+	got := buf.Bytes()
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			r.t.Fatal(err)
+		}
+		return r
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		r.t.Fatalf("golden %s: %v (run with -update to create it)", name, err)
+	}
+	if !bytes.Equal(got, want) {
+		r.t.Errorf("golden %s mismatch, run with -update if the change is intended\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+	return r
+}
+
+func mask(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			if volatile[k] && x != nil {
+				v[k] = "<" + k + ">"
+				continue
+			}
+			v[k] = mask(x)
+		}
+	case []any:
+		for i, x := range v {
+			v[i] = mask(x)
+		}
+	case string:
+		return uuidPattern.ReplaceAllString(v, "<uuid>")
+	}
+	return v
+}
@@ -0,0 +1,173 @@
+// Package apitest runs requests against the real router in process, for
+// handler tests: fixtures for the request DTOs, a multipart builder and
+// golden-file assertions of JSON responses.
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/db"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/migrate"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+)
+
+// Harness is one fresh router with empty repositories (plus the seeded users)
+type Harness struct {
+	t         testing.TB
+	Engine    *gin.Engine
+	UploadDir string
+}
+
+func New(t testing.TB) *Harness {
+	t.Helper()
+	return NewWithConfig(t, router.Config{})
+}
+
+// NewWithConfig is New for tests that turn optional features on.
+// UploadDir, when empty, is a temp dir like with New.
+func NewWithConfig(t testing.TB, cfg router.Config) *Harness {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if cfg.UploadDir == "" {
+		cfg.UploadDir = t.TempDir()
+	}
+	return &Harness{t: t, Engine: router.New(cfg), UploadDir: cfg.UploadDir}
+}
+
+// Queue is a job queue on a migrated database of its own, for
+// NewWithConfig(t, router.Config{Jobs: apitest.Queue(t)}). Nothing works it,
+// tests run jobs with RunNext.
+func Queue(t testing.TB) *jobs.Queue {
+	t.Helper()
+	conn, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	runner, err := migrate.New(conn, db.Migrations, db.MigrationsDir, "apitest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runner.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return jobs.New(conn)
+}
+
+// Server serves the harness router over a real socket, closed with the test
+func (h *Harness) Server() *httptest.Server {
+	srv := httptest.NewServer(h.Engine)
+	h.t.Cleanup(srv.Close)
+	return srv
+}
+
+type RequestOption func(*http.Request)
+
+func Header(key, value string) RequestOption {
+	return func(r *http.Request) { r.Header.Set(key, value) }
+}
+
+// JSON sends v as the request body
+func JSON(v any) RequestOption {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return Body("application/json", data)
+}
+
+// Form sends values urlencoded
+func Form(values url.Values) RequestOption {
+	return Body("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+func Body(contentType string, data []byte) RequestOption {
+	return func(r *http.Request) {
+		r.Body = http.NoBody
+		if len(data) > 0 {
+			r.Body = readCloser{bytes.NewReader(data)}
+		}
+		r.ContentLength = int64(len(data))
+		r.Header.Set("Content-Type", contentType)
+	}
+}
+
+type readCloser struct{ *bytes.Reader }
+
+func (readCloser) Close() error { return nil }
+
+// Do runs one request through the router
+func (h *Harness) Do(method, path string, opts ...RequestOption) *Response {
+	h.t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	for _, opt := range opts {
+		opt(req)
+	}
+	rec := httptest.NewRecorder()
+	h.Engine.ServeHTTP(rec, req)
+	return &Response{t: h.t, ResponseRecorder: rec, uploadDir: h.UploadDir}
+}
+
+// CreateProduct posts req and returns the new id, failing the test on anything but 201
+func (h *Harness) CreateProduct(req dto.CreateProductRequest) int {
+	h.t.Helper()
+	var out dto.CreateProductResponse
+	h.Do(http.MethodPost, "/api/v1/products", JSON(req)).Expect(http.StatusCreated).Decode(&out)
+	return out.ID
+}
+
+// CreateCategory posts req as a form and returns the new id, failing the test on anything but 201
+func (h *Harness) CreateCategory(req dto.CreateCategoryRequest) int {
+	h.t.Helper()
+	var out dto.CreateCategoryResponse
+	h.Do(http.MethodPost, "/api/v1/categories", Form(CategoryForm(req))).Expect(http.StatusCreated).Decode(&out)
+	return out.ID
+}
+
+// ETag of the resource at path, from a plain GET
+func (h *Harness) ETag(path string) string {
+	h.t.Helper()
+	return h.Do(http.MethodGet, path).Expect(http.StatusOK).Header().Get("ETag")
+}
+
+type Response struct {
+	t testing.TB
+	*httptest.ResponseRecorder
+	uploadDir string
+}
+
+// Expect fails the test when the status isn't want
+func (r *Response) Expect(want int) *Response {
+	r.t.Helper()
+	if r.Code != want {
+		r.t.Fatalf("status %d, want %d\nbody: %s", r.Code, want, strings.TrimSpace(r.Body.String()))
+	}
+	return r
+}
+
+func (r *Response) Decode(v any) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body.Bytes(), v); err != nil {
+		r.t.Fatalf("decoding %s: %v", r.Body.String(), err)
+	}
+	return r
+}
+
+// Fields is the "fields" map of an error response
+func (r *Response) Fields() map[string]string {
+	r.t.Helper()
+	var body dto.ErrorResponse
+	r.Decode(&body)
+	return body.Fields
+}
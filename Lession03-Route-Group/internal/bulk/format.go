@@ -0,0 +1,306 @@
+// Package bulk reads and writes products in bulk, as CSV or NDJSON, and
+// keeps track of import jobs. Rows are CreateProductRequest bodies, so an
+// export can be imported again as is.
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+)
+
+func (f Format) ContentType() string {
+	if f == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// FormatFor guesses the format from a Content-Type or a file name, "" when it can't
+func FormatFor(contentType, filename string) Format {
+	switch {
+	case strings.HasPrefix(contentType, "text/csv"), strings.HasSuffix(strings.ToLower(filename), ".csv"):
+		return CSV
+	case strings.HasPrefix(contentType, "application/x-ndjson"), strings.HasPrefix(contentType, "application/jsonl"),
+		strings.HasSuffix(strings.ToLower(filename), ".ndjson"), strings.HasSuffix(strings.ToLower(filename), ".jsonl"):
+		return NDJSON
+	}
+	return ""
+}
+
+// Record is one row: the request body, plus the ID on export (ignored on import)
+type Record struct {
+	ID int `json:"id,omitempty"`
+	dto.CreateProductRequest
+}
+
+// RecordFor is p as the request that would create it again
+func RecordFor(p models.Product) Record {
+	display := p.Display
+	req := dto.CreateProductRequest{
+		Name:        p.Name,
+		Slug:        p.Slug,
+		Description: p.Description,
+		Price:       dto.MoneyRequest{Amount: p.Price.Amount, Currency: p.Price.Currency},
+		Stock:       p.Stock,
+		Tags:        p.Tags,
+		Display:     &display,
+		Email:       p.Email,
+		Avartar:     dto.AvartarImage{URL: p.Avatar.URL, Alt: p.Avatar.AltText},
+		CategoryIDs: p.CategoryIDs,
+		ProductInfo: make(map[string]dto.ProductInfo, len(p.Info)),
+	}
+	for _, img := range p.Images {
+		req.Image = append(req.Image, dto.ProductImage{URL: img.URL, AltText: img.AltText})
+	}
+	for key, info := range p.Info {
+		req.ProductInfo[key] = dto.ProductInfo{InfoKey: info.InfoKey, InfoValue: info.InfoValue}
+	}
+	for _, v := range p.Variants {
+		vr := dto.VariantRequest{SKU: v.SKU, Options: v.Options, Stock: v.Stock}
+		for _, m := range v.Prices {
+			vr.Prices = append(vr.Prices, dto.MoneyRequest{Amount: m.Amount, Currency: m.Currency})
+		}
+		for _, img := range v.Images {
+			vr.Images = append(vr.Images, dto.ProductImage{URL: img.URL, AltText: img.AltText})
+		}
+		req.Variants = append(req.Variants, vr)
+	}
+	return Record{ID: p.ID, CreateProductRequest: req}
+}
+
+// Columns of the CSV format. Lists are "|" separated, nested objects are
+// JSON, price is in minor units of currency like in the request.
+var Columns = []string{
+	"id", "name", "slug", "description", "price", "currency", "stock", "display", "email", "tags",
+	"category_ids", "avatar_url", "avatar_alt", "images", "product_info", "variants",
+}
+
+// RowError is a row that can't even be turned into a request, a bad number
+// or broken JSON. The API answers those with "Invalid request format".
+type RowError struct {
+	Line int
+	Msg  string
+}
+
+func (e *RowError) Error() string { return fmt.Sprintf("line %d: %s", e.Line, e.Msg) }
+
+// Decoder reads records one at a time. Decode returns a *RowError for a bad
+// row (the next call goes on with the next row) and io.EOF at the end.
+// Any other error means the input as a whole is unreadable.
+type Decoder interface {
+	Decode() (Record, int, error) // the record and the line it starts on
+}
+
+func NewDecoder(f Format, r io.Reader) (Decoder, error) {
+	switch f {
+	case CSV:
+		return newCSVDecoder(r)
+	case NDJSON:
+		return &ndjsonDecoder{r: bufio.NewReader(r)}, nil
+	}
+	return nil, fmt.Errorf("bulk: unknown format %q", f)
+}
+
+type ndjsonDecoder struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (d *ndjsonDecoder) Decode() (Record, int, error) {
+	for {
+		data, err := d.r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Record{}, 0, err
+		}
+		if len(data) == 0 && err != nil {
+			return Record{}, 0, io.EOF
+		}
+		d.line++
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return Record{}, d.line, &RowError{Line: d.line, Msg: err.Error()}
+		}
+		return rec, d.line, nil
+	}
+}
+
+type csvDecoder struct {
+	r      *csv.Reader
+	column map[string]int
+}
+
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 0 // every row as wide as the header
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("bulk: empty CSV, want a header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := &csvDecoder{r: cr, column: map[string]int{}}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(Columns, name) {
+			return nil, fmt.Errorf("bulk: unknown CSV column %q", name)
+		}
+		d.column[name] = i
+	}
+	if _, ok := d.column["name"]; !ok {
+		return nil, errors.New(`bulk: CSV header has no "name" column`)
+	}
+	return d, nil
+}
+
+func (d *csvDecoder) Decode() (Record, int, error) {
+	row, err := d.r.Read()
+	if err != nil {
+		var perr *csv.ParseError
+		if errors.As(err, &perr) && errors.Is(perr.Err, csv.ErrFieldCount) {
+			return Record{}, perr.StartLine, &RowError{Line: perr.StartLine, Msg: "row has a different number of columns than the header"}
+		}
+		return Record{}, 0, err
+	}
+	line, _ := d.r.FieldPos(0)
+
+	cell := func(name string) string {
+		if i, ok := d.column[name]; ok {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	var rec Record
+	var problems []string
+	parse := func(name string, fn func(string) error) {
+		if s := cell(name); s != "" {
+			if err := fn(s); err != nil {
+				problems = append(problems, fmt.Sprintf("column %q: %v", name, err))
+			}
+		}
+	}
+
+	rec.Name = cell("name")
+	rec.Slug = cell("slug")
+	rec.Description = cell("description")
+	rec.Email = cell("email")
+	rec.Avartar = dto.AvartarImage{URL: cell("avatar_url"), Alt: cell("avatar_alt")}
+	rec.Price.Currency = cell("currency")
+	parse("price", func(s string) (err error) { rec.Price.Amount, err = strconv.ParseInt(s, 10, 64); return })
+	parse("stock", func(s string) (err error) { rec.Stock, err = strconv.Atoi(s); return })
+	parse("display", func(s string) error {
+		b, err := strconv.ParseBool(s)
+		rec.Display = &b
+		return err
+	})
+	parse("tags", func(s string) error { rec.Tags = strings.Split(s, "|"); return nil })
+	parse("category_ids", func(s string) error {
+		for _, part := range strings.Split(s, "|") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return err
+			}
+			rec.CategoryIDs = append(rec.CategoryIDs, id)
+		}
+		return nil
+	})
+	parse("images", func(s string) error { return json.Unmarshal([]byte(s), &rec.Image) })
+	parse("product_info", func(s string) error { return json.Unmarshal([]byte(s), &rec.ProductInfo) })
+	parse("variants", func(s string) error { return json.Unmarshal([]byte(s), &rec.Variants) })
+
+	if len(problems) > 0 {
+		return Record{}, line, &RowError{Line: line, Msg: strings.Join(problems, "; ")}
+	}
+	return rec, line, nil
+}
+
+// Encoder writes records, Flush after the last one
+type Encoder interface {
+	Encode(Record) error
+	Flush() error
+}
+
+func NewEncoder(f Format, w io.Writer) Encoder {
+	if f == CSV {
+		return &csvEncoder{w: csv.NewWriter(w)}
+	}
+	bw := bufio.NewWriter(w)
+	return &ndjsonEncoder{w: bw, enc: json.NewEncoder(bw)}
+}
+
+type ndjsonEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(rec Record) error { return e.enc.Encode(rec) }
+func (e *ndjsonEncoder) Flush() error            { return e.w.Flush() }
+
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func (e *csvEncoder) Encode(rec Record) error {
+	if !e.wroteHeader {
+		if err := e.w.Write(Columns); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+	jsonCell := func(v any, empty bool) string {
+		if empty {
+			return ""
+		}
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	ids := make([]string, len(rec.CategoryIDs))
+	for i, id := range rec.CategoryIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	display := ""
+	if rec.Display != nil {
+		display = strconv.FormatBool(*rec.Display)
+	}
+	return e.w.Write([]string{
+		strconv.Itoa(rec.ID), rec.Name, rec.Slug, rec.Description,
+		strconv.FormatInt(rec.Price.Amount, 10), rec.Price.Currency, strconv.Itoa(rec.Stock), display, rec.Email,
+		strings.Join(rec.Tags, "|"), strings.Join(ids, "|"), rec.Avartar.URL, rec.Avartar.Alt,
+		jsonCell(rec.Image, len(rec.Image) == 0),
+		jsonCell(rec.ProductInfo, len(rec.ProductInfo) == 0),
+		jsonCell(rec.Variants, len(rec.Variants) == 0),
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	if !e.wroteHeader { // an empty catalogue still gets its header
+		if err := e.w.Write(Columns); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
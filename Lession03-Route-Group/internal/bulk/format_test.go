@@ -0,0 +1,75 @@
+package bulk
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSVDecoderReportsRowsAndGoesOn(t *testing.T) {
+	in := "\ufeffName, Price ,tags\n" +
+		"Gopher Tee,25,clothes|golang\n" +
+		"\n" +
+		"Gopher Mug,abc,\n" +
+		"Short row\n" +
+		"\"Multi\nline\",3,\n"
+	d, err := NewDecoder(CSV, strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec, line, err := d.Decode()
+	if err != nil || line != 2 || rec.Name != "Gopher Tee" || rec.Price.Amount != 25 || len(rec.Tags) != 2 {
+		t.Fatalf("row 1: %+v line %d, %v", rec, line, err)
+	}
+	var rowErr *RowError
+	for _, want := range []struct {
+		line int
+		msg  string
+	}{{4, `column "price"`}, {5, "number of columns"}} {
+		_, line, err = d.Decode()
+		if !errors.As(err, &rowErr) || line != want.line || !strings.Contains(rowErr.Msg, want.msg) {
+			t.Fatalf("line %d: got line %d, %v", want.line, line, err)
+		}
+	}
+	rec, line, err = d.Decode()
+	if err != nil || line != 6 || rec.Name != "Multi\nline" {
+		t.Fatalf("quoted row: %+v line %d, %v", rec, line, err)
+	}
+	if _, _, err = d.Decode(); !errors.Is(err, io.EOF) {
+		t.Fatalf("end: %v", err)
+	}
+}
+
+func TestCSVDecoderChecksHeader(t *testing.T) {
+	for in, want := range map[string]string{
+		"":              "empty CSV",
+		"slug,price\n":  `no "name" column`,
+		"name,colour\n": `unknown CSV column "colour"`,
+	} {
+		if _, err := NewDecoder(CSV, strings.NewReader(in)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: %v, want %q", in, err, want)
+		}
+	}
+}
+
+func TestNDJSONDecoderSkipsBlankLines(t *testing.T) {
+	d, _ := NewDecoder(NDJSON, strings.NewReader("{\"name\":\"A\"}\n\n{bad\n{\"name\":\"B\"}"))
+	var got []string
+	for {
+		rec, line, err := d.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			got = append(got, fmt.Sprintf("error@%d", line))
+			continue
+		}
+		got = append(got, fmt.Sprintf("%s@%d", rec.Name, line))
+	}
+	if strings.Join(got, " ") != "A@1 error@3 B@4" {
+		t.Errorf("got %v", got)
+	}
+}
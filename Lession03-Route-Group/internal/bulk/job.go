@@ -0,0 +1,174 @@
+package bulk
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+)
+
+// Job statuses
+const (
+	Running = "running"
+	Done    = "done"
+	Failed  = "failed" // the input itself was unreadable, Error says why
+)
+
+// Row outcomes
+const (
+	RowCreated = "created"
+	RowValid   = "valid"   // dry run: would have been created
+	RowInvalid = "invalid" // the API would have answered 400
+	RowFailed  = "failed"  // valid, but saving it went wrong
+)
+
+// Job is one import running in the background. Results can be followed
+// while it runs, see Results.
+type Job struct {
+	mu      sync.Mutex
+	summary dto.ImportSummary
+	results []dto.ImportResult
+	changed chan struct{} // closed and replaced on every change
+}
+
+// Add records the outcome of one row
+func (j *Job) Add(r dto.ImportResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.results = append(j.results, r)
+	j.summary.Rows++
+	switch r.Status {
+	case RowCreated:
+		j.summary.Created++
+	case RowValid:
+		j.summary.Valid++
+	case RowInvalid:
+		j.summary.Invalid++
+	case RowFailed:
+		j.summary.Failed++
+	}
+	j.broadcast()
+}
+
+// Finish ends the job, err is nil when the whole input was read
+func (j *Job) Finish(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now().UTC()
+	j.summary.FinishedAt = &now
+	j.summary.Status = Done
+	if err != nil {
+		j.summary.Status = Failed
+		j.summary.Error = err.Error()
+	}
+	j.broadcast()
+}
+
+func (j *Job) broadcast() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *Job) Summary() dto.ImportSummary {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.summary
+}
+
+// Wait returns once the job is finished, or ctx is done
+func (j *Job) Wait(ctx context.Context) error {
+	for {
+		j.mu.Lock()
+		finished := j.summary.Status != Running
+		changed := j.changed
+		j.mu.Unlock()
+		if finished {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Results calls fn with every result, in row order, as they come in, and
+// returns once the job is finished and fn saw them all, or ctx is done
+func (j *Job) Results(ctx context.Context, fn func(dto.ImportResult) error) error {
+	for next := 0; ; {
+		j.mu.Lock()
+		batch := j.results[next:len(j.results):len(j.results)]
+		finished := j.summary.Status != Running
+		changed := j.changed
+		j.mu.Unlock()
+
+		for _, r := range batch {
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+		next += len(batch)
+		if finished && len(batch) == 0 {
+			return nil
+		}
+		if len(batch) > 0 {
+			continue // more may have come in meanwhile
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Jobs keeps the imports of this process. Finished jobs are dropped after
+// Retention, their results are meant to be fetched soon after.
+type Jobs struct {
+	mu        sync.Mutex
+	jobs      map[string]*Job
+	Retention time.Duration
+}
+
+func NewJobs() *Jobs {
+	return &Jobs{jobs: map[string]*Job{}, Retention: time.Hour}
+}
+
+// Start registers a running job and runs work with it in a new goroutine
+func (s *Jobs) Start(format Format, dryRun bool, work func(*Job) error) *Job {
+	j := &Job{
+		summary: dto.ImportSummary{
+			ID: uuid.NewString(), Status: Running, Format: string(format), DryRun: dryRun,
+			CreatedAt: time.Now().UTC(),
+		},
+		changed: make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.prune()
+	s.jobs[j.summary.ID] = j
+	s.mu.Unlock()
+
+	go func() { j.Finish(work(j)) }()
+	return j
+}
+
+func (s *Jobs) Find(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	return j, ok
+}
+
+// prune drops jobs finished more than Retention ago, s.mu must be held
+func (s *Jobs) prune() {
+	cutoff := time.Now().Add(-s.Retention)
+	for id, j := range s.jobs {
+		if sum := j.Summary(); sum.FinishedAt != nil && sum.FinishedAt.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
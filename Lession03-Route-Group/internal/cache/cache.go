@@ -0,0 +1,101 @@
+// Package cache keeps rendered GET responses (see middleware.Cache) in a
+// Store: in memory (LRU) or in Redis, shared by every replica.
+//
+// Entries are tagged with the resources they show, "products" or "users".
+// Invalidating a tag bumps its version, which is part of the key of every
+// entry carrying it: stale entries are never read again and age out. A
+// response rendered while its tag was bumped is stored under the old
+// version, so it can't bring stale data back either.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+)
+
+// Store is where entries and tag versions live. Versions of tags never
+// invalidated are 0.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Versions(ctx context.Context, tags []string) ([]uint64, error)
+	Bump(ctx context.Context, tags []string) error
+}
+
+// Entry is one cached response
+type Entry struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+type Cache struct {
+	store Store
+}
+
+func New(store Store) *Cache {
+	return &Cache{store: store}
+}
+
+// Key is base at the current versions of tags
+func (c *Cache) Key(ctx context.Context, base string, tags []string) (string, error) {
+	versions, err := c.store.Versions(ctx, tags)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(base)
+	for i, tag := range tags {
+		b.WriteString("\x00" + tag + "@" + strconv.FormatUint(versions[i], 10))
+	}
+	return b.String(), nil
+}
+
+// Get returns the entry under key, nil when there is none
+func (c *Cache) Get(ctx context.Context, key string) (*Entry, error) {
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (c *Cache) Set(ctx context.Context, key string, e Entry, ttl time.Duration) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return c.store.Set(ctx, key, data, ttl)
+}
+
+// Invalidate makes every entry tagged with one of tags stale
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) error {
+	return c.store.Bump(ctx, tags)
+}
+
+// invalidates is what goes stale when a resource changes
+var invalidates = map[string][]string{
+	"product":  {"products"},
+	"category": {"categories"},
+	"user":     {"users"},
+}
+
+// Publish invalidates what the event's change made stale, Cache is an
+// events.Publisher next to the webhooks and the hub
+func (c *Cache) Publish(ctx context.Context, e events.Event) error {
+	resource, _, _ := strings.Cut(e.Type, ".")
+	if tags := invalidates[resource]; len(tags) > 0 {
+		return c.Invalidate(ctx, tags...)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/cache"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	l := cache.NewLRU(30) // three 1-byte keys with 9-byte values
+	for _, k := range []string{"a", "b", "c"} {
+		l.Set(ctx, k, []byte("123456789"), time.Minute)
+	}
+	l.Get(ctx, "a")
+	l.Set(ctx, "d", []byte("123456789"), time.Minute)
+
+	for k, want := range map[string]bool{"a": true, "b": false, "c": true, "d": true} {
+		if _, ok, _ := l.Get(ctx, k); ok != want {
+			t.Errorf("%s held: %t, want %t", k, ok, want)
+		}
+	}
+
+	l.Set(ctx, "e", []byte("x"), -time.Second)
+	if _, ok, _ := l.Get(ctx, "e"); ok {
+		t.Error("got an expired entry")
+	}
+}
+
+// invalidation checks an entry is gone once its tag changed, on any store
+func invalidation(t *testing.T, store cache.Store) {
+	ctx := context.Background()
+	c := cache.New(store)
+	key, err := c.Key(ctx, "GET /products", []string{"products"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, key, cache.Entry{Status: 200, Body: []byte(`[]`)}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if e, err := c.Get(ctx, key); err != nil || e == nil || string(e.Body) != `[]` {
+		t.Fatalf("got %+v, %v", e, err)
+	}
+
+	c.Publish(ctx, events.New(events.UserCreated, 1, nil))
+	if again, _ := c.Key(ctx, "GET /products", []string{"products"}); again != key {
+		t.Error("a user change invalidated products")
+	}
+	c.Publish(ctx, events.New(events.ProductUpdated, 1, nil))
+	stale, _ := c.Key(ctx, "GET /products", []string{"products"})
+	if e, err := c.Get(ctx, stale); err != nil || e != nil {
+		t.Errorf("after the update got %+v, %v", e, err)
+	}
+}
+
+func TestLRUInvalidation(t *testing.T) {
+	invalidation(t, cache.NewLRU(0))
+}
+
+func TestRedisInvalidation(t *testing.T) {
+	r := cache.NewRedis(cache.RedisConfig{Addr: fakeRedis(t)})
+	t.Cleanup(func() { r.Close() })
+	invalidation(t, r)
+}
+
+func TestParseRedisURL(t *testing.T) {
+	cfg, err := cache.ParseRedisURL("redis://:s3cret@cache.internal/2")
+	if err != nil || cfg.Addr != "cache.internal:6379" || cfg.Password != "s3cret" || cfg.DB != 2 {
+		t.Errorf("got %+v, %v", cfg, err)
+	}
+	for _, bad := range []string{"memcached://host", "redis://host/x"} {
+		if _, err := cache.ParseRedisURL(bad); err == nil {
+			t.Errorf("%s: want an error", bad)
+		}
+	}
+}
+
+// fakeRedis serves the commands Redis uses from a map, and returns its address
+func fakeRedis(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	data := map[string]string{}
+	bulk := func(k string) string {
+		v, ok := data[k]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(r)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					var reply string
+					switch strings.ToUpper(args[0]) {
+					case "GET":
+						reply = bulk(args[1])
+					case "SET":
+						data[args[1]] = args[2]
+						reply = "+OK\r\n"
+					case "MGET":
+						reply = fmt.Sprintf("*%d\r\n", len(args)-1)
+						for _, k := range args[1:] {
+							reply += bulk(k)
+						}
+					case "INCR":
+						n, _ := strconv.Atoi(data[args[1]])
+						data[args[1]] = strconv.Itoa(n + 1)
+						reply = fmt.Sprintf(":%d\r\n", n+1)
+					default:
+						reply = "-ERR unknown command\r\n"
+					}
+					mu.Unlock()
+					io.WriteString(conn, reply)
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	var n int
+	if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		var size int
+		if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultLRUBytes is the size of NewLRU(0)
+const DefaultLRUBytes = 64 << 20
+
+// LRU is an in-memory Store holding up to a number of bytes of entries,
+// dropping the least recently used first. Each replica has its own.
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int
+	bytes    int
+	order    *list.List // of *lruEntry, most recently used first
+	entries  map[string]*list.Element
+	versions map[string]uint64
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRU holds up to maxBytes of keys and values, DefaultLRUBytes when 0
+func NewLRU(maxBytes int) *LRU {
+	if maxBytes <= 0 {
+		maxBytes = DefaultLRUBytes
+	}
+	return &LRU{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+		versions: map[string]uint64{},
+	}
+}
+
+func (l *LRU) Get(_ context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if time.Now().After(e.expires) {
+		l.remove(el)
+		return nil, false, nil
+	}
+	l.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (l *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	size := len(key) + len(value)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.entries[key]; ok {
+		l.remove(el)
+	}
+	if size > l.maxBytes {
+		return nil // would evict everything else, and then itself
+	}
+	l.entries[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: time.Now().Add(ttl)})
+	l.bytes += size
+	for l.bytes > l.maxBytes {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+func (l *LRU) remove(el *list.Element) {
+	e := l.order.Remove(el).(*lruEntry)
+	delete(l.entries, e.key)
+	l.bytes -= len(e.key) + len(e.value)
+}
+
+// Len is the number of entries held, expired ones included until they are
+// looked up or evicted
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries)
+}
+
+func (l *LRU) Versions(_ context.Context, tags []string) ([]uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]uint64, len(tags))
+	for i, tag := range tags {
+		out[i] = l.versions[tag]
+	}
+	return out, nil
+}
+
+func (l *LRU) Bump(_ context.Context, tags []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, tag := range tags {
+		l.versions[tag]++
+	}
+	return nil
+}
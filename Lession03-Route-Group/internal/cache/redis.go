@@ -0,0 +1,258 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RedisConfig struct {
+	Addr     string // host:port
+	Password string
+	DB       int
+	Prefix   string        // of every key, "lession03:cache:" by default
+	PoolSize int           // idle connections kept, 8 by default
+	Timeout  time.Duration // of dialing and of one command, 2s by default
+}
+
+// ParseRedisURL reads redis://[:password@]host[:port][/db]
+func ParseRedisURL(s string) (RedisConfig, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return RedisConfig{}, err
+	}
+	if u.Scheme != "redis" || u.Host == "" {
+		return RedisConfig{}, fmt.Errorf("%q is not a redis://host:port URL", s)
+	}
+	cfg := RedisConfig{Addr: u.Host}
+	if u.Port() == "" {
+		cfg.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		cfg.Password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if cfg.DB, err = strconv.Atoi(db); err != nil || cfg.DB < 0 {
+			return RedisConfig{}, fmt.Errorf("%q: database %q is not a number", s, db)
+		}
+	}
+	return cfg, nil
+}
+
+// Redis is a Store on a Redis server, or anything speaking its protocol
+// (Valkey, KeyDB). Replicas sharing it share their entries and their
+// invalidations. It only needs GET, SET, MGET and INCR.
+type Redis struct {
+	cfg  RedisConfig
+	idle chan *redisConn
+}
+
+func NewRedis(cfg RedisConfig) *Redis {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "lession03:cache:"
+	}
+	if cfg.PoolSize == 0 {
+		cfg.PoolSize = 8
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	return &Redis{cfg: cfg, idle: make(chan *redisConn, cfg.PoolSize)}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", r.cfg.Prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: GET answered %T", reply)
+	}
+	return []byte(s), true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.do(ctx, "SET", r.cfg.Prefix+key, string(value), "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+func (r *Redis) Versions(ctx context.Context, tags []string) ([]uint64, error) {
+	out := make([]uint64, len(tags))
+	if len(tags) == 0 {
+		return out, nil
+	}
+	args := []string{"MGET"}
+	for _, tag := range tags {
+		args = append(args, r.cfg.Prefix+"tag:"+tag)
+	}
+	reply, err := r.do(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := reply.([]any)
+	if !ok || len(values) != len(tags) {
+		return nil, fmt.Errorf("redis: MGET answered %v", reply)
+	}
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		s, _ := v.(string)
+		if out[i], err = strconv.ParseUint(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("redis: version of %s: %w", tags[i], err)
+		}
+	}
+	return out, nil
+}
+
+func (r *Redis) Bump(ctx context.Context, tags []string) error {
+	for _, tag := range tags {
+		if _, err := r.do(ctx, "INCR", r.cfg.Prefix+"tag:"+tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the idle connections
+func (r *Redis) Close() error {
+	for {
+		select {
+		case c := <-r.idle:
+			c.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// redisError is an error reply, the connection is still fine after one
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// do sends one command and returns its reply: a string, an int64, nil or
+// a []any of those
+func (r *Redis) do(ctx context.Context, args ...string) (any, error) {
+	c, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(r.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+
+	reply, err := c.command(args...)
+	var rerr redisError
+	if err != nil && !errors.As(err, &rerr) {
+		c.Close()
+		return nil, err
+	}
+	select {
+	case r.idle <- c:
+	default:
+		c.Close()
+	}
+	return reply, err
+}
+
+func (r *Redis) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.idle:
+		return c, nil
+	default:
+	}
+	d := net.Dialer{Timeout: r.cfg.Timeout}
+	nc, err := d.DialContext(ctx, "tcp", r.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	c.SetDeadline(time.Now().Add(r.cfg.Timeout))
+	if r.cfg.Password != "" {
+		if _, err := c.command("AUTH", r.cfg.Password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if r.cfg.DB != 0 {
+		if _, err := c.command("SELECT", strconv.Itoa(r.cfg.DB)); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) command(args ...string) (any, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.Conn, b.String()); err != nil {
+		return nil, err
+	}
+	return c.reply()
+}
+
+func (c *redisConn) reply() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch kind, rest := line[0], line[1:]; kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return nil, redisError(rest)
+	case ':':
+		return strconv.ParseInt(rest, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err // $-1 is nil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = c.reply(); err != nil {
+				var rerr redisError
+				if !errors.As(err, &rerr) {
+					return nil, err
+				}
+			}
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
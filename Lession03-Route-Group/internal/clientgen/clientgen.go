@@ -0,0 +1,299 @@
+// Package clientgen writes the typed methods of the client package from the
+// routes of the gin engine and the DTOs documented in the openapi registry,
+// the same two sources the OpenAPI document is built from.
+package clientgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/openapi"
+)
+
+// Prefix limits the client to the versioned API, static files and docs are left out
+const Prefix = "/api/v1/"
+
+type method struct {
+	Name     string
+	HTTP     string // GET
+	Verb     string // http.MethodGet etc., as Go source
+	Route    string // gin path, /api/v1/products/:id
+	Summary  string
+	Desc     []string
+	Headers  []string
+	Params   []param
+	PathExpr string
+	Query    bool
+	Body     string // "", "jsonBody", "formBody" or "multipartBody"
+	HasBody  bool
+	Files    []param
+	Response string
+	Stream   bool // the body is handed over as is, see Operation.Produces
+}
+
+type param struct {
+	Name     string
+	Type     string
+	Field    string // form field name, for files
+	Multiple bool
+}
+
+// Generate returns the formatted source of package pkg
+func Generate(routes gin.RoutesInfo, reg *openapi.Registry, pkg string) ([]byte, error) {
+	imports := map[string]bool{"context": true, "net/http": true}
+	var methods []method
+
+	for _, rt := range routes {
+		if rt.Method == http.MethodHead || !strings.HasPrefix(rt.Path, Prefix) {
+			continue
+		}
+		op, ok := reg.Lookup(rt.Handler)
+		if !ok {
+			continue
+		}
+		m, err := newMethod(rt, op, imports)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	for i := 1; i < len(methods); i++ {
+		if methods[i].Name == methods[i-1].Name {
+			return nil, fmt.Errorf("clientgen: %s is routed twice", methods[i].Name)
+		}
+	}
+
+	var std, other []string
+	for p := range imports {
+		if strings.Contains(strings.Split(p, "/")[0], ".") {
+			other = append(other, p)
+		} else {
+			std = append(std, p)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+
+	var buf bytes.Buffer
+	err := fileTemplate.Execute(&buf, map[string]any{"Package": pkg, "Std": std, "Imports": other, "Methods": methods})
+	if err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("clientgen: formatting generated code: %w\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+func newMethod(rt gin.RouteInfo, op openapi.Operation, imports map[string]bool) (method, error) {
+	name := strings.TrimSuffix(rt.Handler, "-fm")
+	m := method{
+		Name:     name[strings.LastIndex(name, ".")+1:],
+		HTTP:     rt.Method,
+		Verb:     verbs[rt.Method],
+		Route:    rt.Path,
+		Summary:  op.Summary,
+		PathExpr: fmt.Sprintf("%q", rt.Path),
+	}
+	if m.Verb == "" {
+		return m, fmt.Errorf("clientgen: unsupported method %s %s", rt.Method, rt.Path)
+	}
+	if op.Description != "" {
+		m.Desc = strings.Split(op.Description, "\n")
+	}
+	for _, h := range op.Headers {
+		if h.Required {
+			m.Headers = append(m.Headers, h.Name+" (required)")
+		} else {
+			m.Headers = append(m.Headers, h.Name)
+		}
+	}
+
+	var pathParams []string
+	for _, seg := range strings.Split(rt.Path, "/") {
+		if seg != "" && (seg[0] == ':' || seg[0] == '*') {
+			pathParams = append(pathParams, seg[1:])
+		}
+	}
+	switch {
+	case op.Path != nil:
+		m.Params = append(m.Params, param{Name: "path", Type: typeExpr(reflect.TypeOf(op.Path), imports)})
+		m.PathExpr = fmt.Sprintf("expandPath(%q, path)", rt.Path)
+	case len(pathParams) > 0:
+		// no uri DTO, plain strings in path order
+		var args []string
+		for _, p := range pathParams {
+			id := goIdent(p)
+			m.Params = append(m.Params, param{Name: id, Type: "string"})
+			args = append(args, fmt.Sprintf("%q, %s", p, id))
+		}
+		m.PathExpr = fmt.Sprintf("expandPathValues(%q, %s)", rt.Path, strings.Join(args, ", "))
+	}
+
+	if op.Query != nil {
+		m.Query = true
+		m.Params = append(m.Params, param{Name: "query", Type: typeExpr(reflect.TypeOf(op.Query), imports)})
+	}
+
+	switch {
+	case op.BodyKind == openapi.MultipartBody:
+		m.Body = "multipartBody"
+	case op.BodyKind == openapi.FormBody:
+		m.Body = "formBody"
+	case op.Body != nil:
+		m.Body = "jsonBody"
+	}
+	if op.Body != nil {
+		m.HasBody = true
+		m.Params = append(m.Params, param{Name: "body", Type: typeExpr(reflect.TypeOf(op.Body), imports)})
+	}
+	for _, f := range op.Files {
+		p := param{Name: goIdent(f.Name), Type: "Upload", Field: f.Name, Multiple: f.Multiple}
+		if f.Multiple {
+			p.Type = "[]Upload"
+		}
+		m.Params = append(m.Params, p)
+		m.Files = append(m.Files, p)
+	}
+
+	switch {
+	case len(op.Produces) > 0:
+		m.Stream = true
+		imports["io"] = true
+	case op.Response != nil:
+		m.Response = typeExpr(reflect.TypeOf(op.Response), imports)
+	}
+	return m, nil
+}
+
+var verbs = map[string]string{
+	http.MethodGet:    "http.MethodGet",
+	http.MethodPost:   "http.MethodPost",
+	http.MethodPut:    "http.MethodPut",
+	http.MethodPatch:  "http.MethodPatch",
+	http.MethodDelete: "http.MethodDelete",
+}
+
+// typeExpr writes t as Go source and records the packages it needs.
+// reflect spells generic arguments with their full import path,
+// Page[github.com/.../dto.ProductSearchResult], so those are rewritten too.
+func typeExpr(t reflect.Type, imports map[string]bool) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return "*" + typeExpr(t.Elem(), imports)
+	case reflect.Slice:
+		return "[]" + typeExpr(t.Elem(), imports)
+	case reflect.Map:
+		return "map[" + typeExpr(t.Key(), imports) + "]" + typeExpr(t.Elem(), imports)
+	}
+	if t.PkgPath() == "" {
+		return t.String()
+	}
+
+	imports[t.PkgPath()] = true
+	name, args, generic := strings.Cut(t.Name(), "[")
+	expr := path.Base(t.PkgPath()) + "." + name
+	if !generic {
+		return expr
+	}
+	var out []string
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		out = append(out, qualify(arg, imports))
+	}
+	return expr + "[" + strings.Join(out, ", ") + "]"
+}
+
+// qualify turns *github.com/x/dto.Thing into *dto.Thing
+func qualify(arg string, imports map[string]bool) string {
+	prefix := arg[:len(arg)-len(strings.TrimLeft(arg, "*[]"))]
+	arg = arg[len(prefix):]
+	dot := strings.LastIndex(arg, ".")
+	if dot < 0 {
+		return prefix + arg // builtin
+	}
+	imports[arg[:dot]] = true
+	return prefix + path.Base(arg[:dot]) + arg[dot:]
+}
+
+// goIdent makes a parameter name out of a form or path field: user_id -> userID
+func goIdent(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	for i := 1; i < len(parts); i++ {
+		if strings.EqualFold(parts[i], "id") {
+			parts[i] = "ID"
+			continue
+		}
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+var fileTemplate = template.Must(template.New("client").Parse(`// Code generated by clientgen from the v1 routes and DTOs. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range .Std}}
+	"{{.}}"
+{{- end}}
+{{range .Imports}}
+	"{{.}}"
+{{- end}}
+)
+{{range .Methods}}
+// {{.Name}} calls {{.HTTP}} {{.Route}}: {{.Summary}}.
+{{- if .Desc}}
+//
+{{- end}}
+{{- range .Desc}}
+// {{.}}
+{{- end}}
+{{- if .Headers}}
+//
+// Headers: {{range $i, $h := .Headers}}{{if $i}}, {{end}}{{$h}}{{end}}.
+{{- end}}
+func (c *Client) {{.Name}}(ctx context.Context{{range .Params}}, {{.Name}} {{.Type}}{{end}}, opts ...RequestOption) {{if .Stream}}(io.ReadCloser, error){{else if .Response}}(*{{.Response}}, error){{else}}error{{end}} {
+	req := call{
+		method: {{.Verb}},
+		path:   {{.PathExpr}},
+		{{- if .Query}}
+		query:  query,
+		{{- end}}
+		{{- if .Body}}
+		kind:   {{.Body}},
+		{{- end}}
+		{{- if .HasBody}}
+		body:   body,
+		{{- end}}
+	}
+	{{- range .Files}}
+	{{- if .Multiple}}
+	req.files = append(req.files, filePart{field: {{printf "%q" .Field}}, uploads: {{.Name}}})
+	{{- else}}
+	req.files = append(req.files, filePart{field: {{printf "%q" .Field}}, uploads: []Upload{ {{- .Name -}} }})
+	{{- end}}
+	{{- end}}
+	{{- if .Stream}}
+	return c.stream(ctx, req, opts)
+	{{- else if .Response}}
+	var out {{.Response}}
+	if err := c.do(ctx, req, &out, opts); err != nil {
+		return nil, err
+	}
+	return &out, nil
+	{{- else}}
+	return c.do(ctx, req, nil, opts)
+	{{- end}}
+}
+{{end}}`))
@@ -0,0 +1,53 @@
+package content
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true} // str8 and bin, as current MessagePack libraries expect
+	h.RawToString = true
+	h.MapType = reflect.TypeFor[map[string]any]()
+	return h
+}()
+
+func encodeMsgPack(w io.Writer, tree any) error {
+	return codec.NewEncoder(w, msgpackHandle).Encode(plain(tree))
+}
+
+func decodeMsgPack(body []byte, target any) error {
+	var tree any
+	if err := codec.NewDecoder(bytes.NewReader(body), msgpackHandle).Decode(&tree); err != nil {
+		return err
+	}
+	return fromTree(tree, target)
+}
+
+// Protobuf bodies are one google.protobuf.Value, numbers are doubles
+
+func encodeProtobuf(w io.Writer, tree any) error {
+	v, err := structpb.NewValue(plain(tree))
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func decodeProtobuf(body []byte, target any) error {
+	var v structpb.Value
+	if err := proto.Unmarshal(body, &v); err != nil {
+		return err
+	}
+	return fromTree(v.AsInterface(), target)
+}
@@ -0,0 +1,239 @@
+// Package content lets clients pick the format of API bodies: JSON, XML,
+// MessagePack or protobuf, from Accept for responses and Content-Type for
+// requests.
+//
+// Every format carries the JSON document: the same keys, the same nesting.
+// A response is marshalled to JSON first, then re-encoded (see tree.go), so
+// the DTOs only need their json tags. Protobuf bodies are a
+// google.protobuf.Value, which holds any JSON document without a schema of
+// our own.
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Format is one way of writing bodies
+type Format struct {
+	Name      string   // json, xml, msgpack or protobuf
+	MediaType string   // what responses are sent as
+	Aliases   []string // other media types accepted for it
+	encode    func(w io.Writer, tree any) error
+	decode    func(body []byte, target any) error // nil for JSON, gin binds it
+}
+
+// Formats in order of preference, JSON first: it is what a client that
+// accepts anything gets
+var (
+	JSON     = &Format{Name: "json", MediaType: "application/json"}
+	XML      = &Format{Name: "xml", MediaType: "application/xml", Aliases: []string{"text/xml"}, encode: encodeXML, decode: decodeXML}
+	MsgPack  = &Format{Name: "msgpack", MediaType: "application/msgpack", Aliases: []string{"application/x-msgpack", "application/vnd.msgpack"}, encode: encodeMsgPack, decode: decodeMsgPack}
+	Protobuf = &Format{Name: "protobuf", MediaType: "application/x-protobuf", Aliases: []string{"application/protobuf", "application/vnd.google.protobuf"}, encode: encodeProtobuf, decode: decodeProtobuf}
+	Formats  = []*Format{JSON, XML, MsgPack, Protobuf}
+)
+
+func (f *Format) is(mediaType string) bool {
+	if mediaType == f.MediaType {
+		return true
+	}
+	for _, a := range f.Aliases {
+		if mediaType == a {
+			return true
+		}
+	}
+	return false
+}
+
+// MediaTypes lists what Formats are sent as, for 406 and 415 answers
+func MediaTypes() []string {
+	out := make([]string, len(Formats))
+	for i, f := range Formats {
+		out[i] = f.MediaType
+	}
+	return out
+}
+
+const formatKey = "content.format"
+
+// Negotiate picks the response format from Accept and answers 406 when the
+// client takes none of them. Routes in skip write media types of their own
+// (CSV exports, event streams) and negotiate themselves.
+func Negotiate(skip ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, route := range skip {
+			if c.FullPath() == route {
+				c.Next()
+				return
+			}
+		}
+		c.Header("Vary", "Accept")
+		f, ok := Pick(c.GetHeader("Accept"))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+				"error": "None of the media types in Accept can be served",
+				"msg":   "accept one of " + strings.Join(MediaTypes(), ", "),
+			})
+			return
+		}
+		c.Set(formatKey, f)
+		c.Next()
+	}
+}
+
+// Negotiated is the format Negotiate picked, JSON on routes it skipped
+func Negotiated(c *gin.Context) *Format {
+	if f, ok := c.Get(formatKey); ok {
+		return f.(*Format)
+	}
+	return JSON
+}
+
+// Render writes obj in the negotiated format
+func Render(c *gin.Context, code int, obj any) {
+	f := Negotiated(c)
+	if f == JSON {
+		c.JSON(code, obj)
+		return
+	}
+	var buf bytes.Buffer
+	tree, err := toTree(obj)
+	if err == nil {
+		err = f.encode(&buf, tree)
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode the response as " + f.Name})
+		return
+	}
+	c.Data(code, f.MediaType, buf.Bytes())
+}
+
+// Abort is Render and c.Abort, for middlewares
+func Abort(c *gin.Context, code int, obj any) {
+	c.Abort()
+	Render(c, code, obj)
+}
+
+// ErrUnsupportedMediaType is Bind's error for a Content-Type of no Format
+var ErrUnsupportedMediaType = errors.New("unsupported Content-Type, send one of " + strings.Join(MediaTypes(), ", "))
+
+// maxBody caps non-JSON bodies, read whole before decoding
+const maxBody = 4 << 20
+
+// Bind decodes the request body into obj according to Content-Type, JSON
+// when there is none, then normalises and validates it like ShouldBindJSON
+func Bind(c *gin.Context, obj any) error {
+	mediaType := c.ContentType()
+	if mediaType == "" || JSON.is(mediaType) {
+		return c.ShouldBindWith(obj, binding.JSON)
+	}
+	for _, f := range Formats[1:] {
+		if !f.is(mediaType) {
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBody+1))
+		if err != nil {
+			return err
+		}
+		if len(body) > maxBody {
+			return errors.New("request body is too large")
+		}
+		if err := f.decode(body, obj); err != nil {
+			return err
+		}
+		if binding.Validator == nil {
+			return nil
+		}
+		return binding.Validator.ValidateStruct(obj)
+	}
+	return ErrUnsupportedMediaType
+}
+
+// fromTree fills target from a decoded document the way JSON would
+func fromTree(tree any, target any) error {
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// Pick is the Format Accept prefers. Each format gets the q of the most
+// specific range matching it, the highest q wins and ties go to the order
+// of Formats. false when every format has q=0.
+func Pick(accept string) (*Format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return JSON, true
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := (*Format)(nil), 0.0
+	for _, f := range Formats {
+		if q := quality(f, ranges); q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	return best, best != nil
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mt, "/")
+		if !ok {
+			continue
+		}
+		r := mediaRange{typ: typ, subtype: subtype, q: 1}
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v >= 0 && v <= 1 {
+				r.q = v
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	// most specific first: type/subtype, then type/*, then */*
+	sort.SliceStable(ranges, func(i, j int) bool { return specificity(ranges[i]) > specificity(ranges[j]) })
+	return ranges
+}
+
+func specificity(r mediaRange) int {
+	switch {
+	case r.typ == "*":
+		return 0
+	case r.subtype == "*":
+		return 1
+	}
+	return 2
+}
+
+func quality(f *Format, ranges []mediaRange) float64 {
+	types := append([]string{f.MediaType}, f.Aliases...)
+	for _, r := range ranges {
+		for _, mt := range types {
+			typ, subtype, _ := strings.Cut(mt, "/")
+			if (r.typ == "*" || r.typ == typ) && (r.subtype == "*" || r.subtype == subtype) {
+				return r.q
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package content_test
+
+import (
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+)
+
+func TestPick(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   *content.Format // nil: 406
+	}{
+		{"", content.JSON},
+		{"*/*", content.JSON},
+		{"application/*", content.JSON},
+		{"application/xml", content.XML},
+		{"text/xml", content.XML},
+		{"application/x-msgpack", content.MsgPack},
+		{"application/json;q=0.5, application/x-protobuf", content.Protobuf},
+		{"application/xml;q=0.8, application/json;q=0.8", content.JSON}, // a tie goes to JSON
+		{"application/json;q=0, */*;q=0.1", content.XML},
+		{"text/html, application/xhtml+xml", nil},
+		{"application/json;q=0", nil},
+		{"not a media type", nil},
+	}
+	for _, tc := range tests {
+		got, ok := content.Pick(tc.accept)
+		if ok != (tc.want != nil) || got != tc.want {
+			t.Errorf("Pick(%q) = %v, %t, want %v", tc.accept, got, ok, tc.want)
+		}
+	}
+}
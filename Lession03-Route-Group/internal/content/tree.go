@@ -0,0 +1,94 @@
+package content
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// A tree is a JSON document decoded for re-encoding: object, []any, string,
+// int64, float64, bool or nil. Objects keep the order of their keys.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+// toTree marshals obj to JSON and reads it back as a tree
+func toTree(obj any) (any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return readValue(dec)
+}
+
+func readValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			obj := object{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := readValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj = append(obj, member{key: key.(string), value: value})
+			}
+			_, err := dec.Token() // }
+			return obj, err
+		case '[':
+			arr := []any{}
+			for dec.More() {
+				value, err := readValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, value)
+			}
+			_, err := dec.Token() // ]
+			return arr, err
+		}
+		return nil, fmt.Errorf("unexpected %v", tok)
+	case json.Number:
+		if n, err := strconv.ParseInt(string(tok), 10, 64); err == nil {
+			return n, nil
+		}
+		return tok.Float64()
+	default:
+		return tok, nil // string, bool or nil
+	}
+}
+
+// plain turns the objects of tree into maps, for the formats whose maps
+// have no order anyway
+func plain(tree any) any {
+	switch v := tree.(type) {
+	case object:
+		m := make(map[string]any, len(v))
+		for _, kv := range v {
+			m[kv.key] = plain(kv.value)
+		}
+		return m
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = plain(item)
+		}
+		return out
+	}
+	return tree
+}
@@ -0,0 +1,257 @@
+package content
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// XML documents mirror the JSON ones: the root is <response>, a key is an
+// element, an array is an element per value named item, null is nil="true".
+// Keys that can't be element names (the uuid keys of product_info) become
+// <entry key="...">.
+//
+//	<response><id>3</id><tags><item>golang</item></tags></response>
+
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func elementFor(key string) xml.StartElement {
+	if xmlName.MatchString(key) && !strings.HasPrefix(strings.ToLower(key), "xml") {
+		return xml.StartElement{Name: xml.Name{Local: key}}
+	}
+	return xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}}}
+}
+
+func encodeXML(w io.Writer, tree any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := writeElement(enc, xml.StartElement{Name: xml.Name{Local: "response"}}, tree); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+func writeElement(enc *xml.Encoder, start xml.StartElement, value any) error {
+	if value == nil {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"})
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+	case object:
+		for _, kv := range v {
+			if err := writeElement(enc, elementFor(kv.key), kv.value); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if err := writeElement(enc, xml.StartElement{Name: xml.Name{Local: "item"}}, item); err != nil {
+				return err
+			}
+		}
+	case float64:
+		if err := enc.EncodeToken(xml.CharData(strconv.FormatFloat(v, 'g', -1, 64))); err != nil {
+			return err
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// node is an element of a request body
+type node struct {
+	name     string // the key attribute for <entry>
+	isNil    bool
+	text     string
+	children []*node
+}
+
+func parseXML(body []byte) (*node, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	var stack []*node
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("xml: no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: tok.Name.Local}
+			for _, a := range tok.Attr {
+				switch {
+				case a.Name.Local == "key" && n.name == "entry":
+					n.name = a.Value
+				case a.Name.Local == "nil" && a.Value == "true":
+					n.isNil = true
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(tok)
+			}
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			if stack = stack[:len(stack)-1]; len(stack) == 0 {
+				return n, nil
+			}
+		}
+	}
+}
+
+// decodeXML reads the document the way encodeXML writes it. XML has no
+// types, so the fields of target tell what each element holds.
+func decodeXML(body []byte, target any) error {
+	root, err := parseXML(body)
+	if err != nil {
+		return err
+	}
+	tree, err := fromXML(root, reflect.TypeOf(target))
+	if err != nil {
+		return err
+	}
+	return fromTree(tree, target)
+}
+
+var (
+	jsonUnmarshaler = reflect.TypeFor[json.Unmarshaler]()
+	textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+func fromXML(n *node, t reflect.Type) (any, error) {
+	if n.isNil {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// time.Time and the like read themselves from a JSON string
+	if p := reflect.PointerTo(t); p.Implements(jsonUnmarshaler) || p.Implements(textUnmarshaler) {
+		return n.text, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := jsonFields(t)
+		obj := map[string]any{}
+		for _, child := range n.children {
+			ft, ok := fields[child.name]
+			if !ok {
+				continue // like unknown JSON keys
+			}
+			v, err := fromXML(child, ft)
+			if err != nil {
+				return nil, err
+			}
+			obj[child.name] = v
+		}
+		return obj, nil
+	case reflect.Map:
+		obj := map[string]any{}
+		for _, child := range n.children {
+			v, err := fromXML(child, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			obj[child.name] = v
+		}
+		return obj, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return strings.TrimSpace(n.text), nil // []byte is base64 in JSON too
+		}
+		arr := make([]any, 0, len(n.children))
+		for _, child := range n.children {
+			v, err := fromXML(child, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(n.text))
+		if err != nil {
+			return nil, fmt.Errorf("xml: <%s> is not a boolean", n.name)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		s := strings.TrimSpace(n.text)
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("xml: <%s> is not a number", n.name)
+		}
+		return json.Number(s), nil
+	case reflect.Interface:
+		if len(n.children) == 0 {
+			return n.text, nil
+		}
+		obj := map[string]any{}
+		for _, child := range n.children {
+			v, err := fromXML(child, t)
+			if err != nil {
+				return nil, err
+			}
+			obj[child.name] = v
+		}
+		return obj, nil
+	default:
+		return n.text, nil
+	}
+}
+
+// jsonFields maps the JSON keys of struct t to the types of their fields,
+// the fields of embedded structs included
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range jsonFields(ft) {
+					if _, ok := fields[k]; !ok {
+						fields[k] = v
+					}
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
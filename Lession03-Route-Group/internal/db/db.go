@@ -0,0 +1,27 @@
+// Package db opens the SQLite database and carries the schema migrations,
+// embedded so the binary can migrate on its own (lession03 migrate up).
+package db
+
+import (
+	"database/sql"
+	"embed"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultPath is the database file when neither -db nor DATABASE_PATH says otherwise
+const DefaultPath = "lession03.db"
+
+// Migrations holds migrations/NNNN_name.{up,down}.sql, see package migrate
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
+
+// MigrationsDir is the directory of Migrations the files are in
+const MigrationsDir = "migrations"
+
+// Open opens (creating if needed) the SQLite file at path with foreign keys
+// on and a busy timeout, so concurrent writers wait instead of failing
+func Open(path string) (*sql.DB, error) {
+	return sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+}
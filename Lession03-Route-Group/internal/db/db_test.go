@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/migrate"
+)
+
+// Every migration applies on an empty database and rolls back cleanly
+func TestMigrationsRoundTrip(t *testing.T) {
+	conn, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	r, err := migrate.New(conn, Migrations, MigrationsDir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	up, err := r.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	down, err := r.Down(ctx, len(up))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(down) != len(up) {
+		t.Fatalf("rolled back %d of %d", len(down), len(up))
+	}
+
+	var tables int
+	if err := conn.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'schema_migrations%'`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("%d tables left after rolling everything back", tables)
+	}
+	if _, err := r.Up(ctx); err != nil {
+		t.Fatalf("up again: %v", err)
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE user_slugs;
+DROP TABLE users;
@@ -0,0 +1,15 @@
+CREATE TABLE users (
+    id         INTEGER PRIMARY KEY,
+    uuid       TEXT NOT NULL UNIQUE,
+    name       TEXT NOT NULL,
+    slug       TEXT NOT NULL UNIQUE,
+    version    INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Every slug a user ever had, old ones redirect to users.slug
+CREATE TABLE user_slugs (
+    slug    TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE
+);
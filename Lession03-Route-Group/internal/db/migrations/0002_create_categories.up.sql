@@ -0,0 +1,15 @@
+-- Materialised path tree: path is the IDs from the root down, '/1/4/7/'
+CREATE TABLE categories (
+    id          INTEGER PRIMARY KEY,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    image_url   TEXT NOT NULL,
+    parent_id   INTEGER REFERENCES categories (id),
+    path        TEXT NOT NULL UNIQUE,
+    depth       INTEGER NOT NULL DEFAULT 0,
+    version     INTEGER NOT NULL DEFAULT 1,
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX categories_parent_id ON categories (parent_id);
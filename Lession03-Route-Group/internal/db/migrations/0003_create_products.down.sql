@@ -0,0 +1,6 @@
+DROP TABLE product_categories;
+DROP TABLE product_info;
+DROP TABLE product_tags;
+DROP TABLE product_images;
+DROP TABLE product_slugs;
+DROP TABLE products;
@@ -0,0 +1,51 @@
+CREATE TABLE products (
+    id          INTEGER PRIMARY KEY,
+    name        TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    slug        TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    price       REAL NOT NULL,
+    stock       INTEGER NOT NULL DEFAULT 0,
+    display     BOOLEAN NOT NULL DEFAULT TRUE,
+    email       TEXT NOT NULL DEFAULT '',
+    avatar_url  TEXT NOT NULL,
+    avatar_alt  TEXT NOT NULL DEFAULT '',
+    version     INTEGER NOT NULL DEFAULT 1,
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL
+);
+
+CREATE TABLE product_slugs (
+    slug       TEXT PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE
+);
+
+CREATE TABLE product_images (
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL,
+    url        TEXT NOT NULL,
+    alt_text   TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (product_id, position)
+);
+
+CREATE TABLE product_tags (
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    tag        TEXT NOT NULL,
+    PRIMARY KEY (product_id, tag)
+);
+
+-- product_info, keyed by UUID
+CREATE TABLE product_info (
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    key        TEXT NOT NULL,
+    info_key   TEXT NOT NULL,
+    info_value TEXT NOT NULL,
+    PRIMARY KEY (product_id, key)
+);
+
+CREATE TABLE product_categories (
+    product_id  INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    category_id INTEGER NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    PRIMARY KEY (product_id, category_id)
+);
+
+CREATE INDEX product_categories_category_id ON product_categories (category_id);
@@ -0,0 +1,3 @@
+DROP TABLE reservations;
+DROP TABLE variant_prices;
+DROP TABLE product_variants;
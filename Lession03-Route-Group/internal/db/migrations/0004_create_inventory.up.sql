@@ -0,0 +1,29 @@
+CREATE TABLE product_variants (
+    product_id INTEGER NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+    sku        TEXT NOT NULL,
+    options    TEXT NOT NULL DEFAULT '{}', -- JSON, {"size": "L"}
+    stock      INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    reserved   INTEGER NOT NULL DEFAULT 0 CHECK (reserved >= 0 AND reserved <= stock),
+    PRIMARY KEY (product_id, sku)
+);
+
+-- Amounts in minor units of the currency, cents for USD
+CREATE TABLE variant_prices (
+    product_id INTEGER NOT NULL,
+    sku        TEXT NOT NULL,
+    currency   TEXT NOT NULL,
+    amount     INTEGER NOT NULL CHECK (amount > 0),
+    PRIMARY KEY (product_id, sku, currency),
+    FOREIGN KEY (product_id, sku) REFERENCES product_variants (product_id, sku) ON DELETE CASCADE
+);
+
+CREATE TABLE reservations (
+    id         TEXT PRIMARY KEY,
+    product_id INTEGER NOT NULL,
+    sku        TEXT NOT NULL,
+    quantity   INTEGER NOT NULL CHECK (quantity > 0),
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (product_id, sku) REFERENCES product_variants (product_id, sku) ON DELETE CASCADE
+);
+
+CREATE INDEX reservations_expires_at ON reservations (expires_at);
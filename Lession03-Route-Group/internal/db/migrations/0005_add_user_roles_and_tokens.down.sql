@@ -0,0 +1,3 @@
+DROP TABLE api_tokens;
+ALTER TABLE users DROP COLUMN role;
+ALTER TABLE users DROP COLUMN email;
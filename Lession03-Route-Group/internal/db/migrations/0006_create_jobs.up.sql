@@ -0,0 +1,33 @@
+-- The background job queue, see package jobs. A job is claimed by setting it
+-- running with a lease (locked_until); a worker that dies loses the lease and
+-- the job is claimed again. Jobs out of attempts stay here as 'dead'.
+CREATE TABLE jobs (
+    id           TEXT PRIMARY KEY,
+    kind         TEXT NOT NULL,
+    payload      TEXT NOT NULL DEFAULT 'null', -- JSON
+    status       TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'succeeded', 'dead')),
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL CHECK (max_attempts > 0),
+    run_at       TIMESTAMP NOT NULL,
+    locked_by    TEXT,
+    locked_until TIMESTAMP,
+    last_error   TEXT NOT NULL DEFAULT '',
+    result       TEXT, -- JSON, what the handler returned
+    created_at   TIMESTAMP NOT NULL,
+    updated_at   TIMESTAMP NOT NULL,
+    started_at   TIMESTAMP,
+    finished_at  TIMESTAMP
+);
+
+CREATE INDEX jobs_ready ON jobs (kind, status, run_at);
+CREATE INDEX jobs_dead ON jobs (kind, finished_at) WHERE status = 'dead';
+
+-- Recurring jobs: each due schedule enqueues one job of its kind
+CREATE TABLE job_schedules (
+    name        TEXT PRIMARY KEY,
+    spec        TEXT NOT NULL, -- cron expression
+    kind        TEXT NOT NULL,
+    payload     TEXT NOT NULL DEFAULT 'null',
+    next_run_at TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL
+);
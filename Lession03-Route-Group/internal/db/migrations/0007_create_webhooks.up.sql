@@ -0,0 +1,37 @@
+-- Partner endpoints notified of catalogue changes, see package webhook
+CREATE TABLE webhooks (
+    id          TEXT PRIMARY KEY,
+    url         TEXT NOT NULL,
+    events      TEXT NOT NULL, -- JSON array of event types
+    secret      TEXT NOT NULL, -- HMAC key of the signatures, kept as is since signing needs it
+    description TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMP NOT NULL
+);
+
+-- The transactional outbox: events are written together with the change
+-- they describe, then fanned out into one webhook.deliver job per endpoint
+CREATE TABLE outbox (
+    id            TEXT PRIMARY KEY,
+    type          TEXT NOT NULL,
+    payload       TEXT NOT NULL, -- JSON, the event as delivered
+    occurred_at   TIMESTAMP NOT NULL,
+    dispatched_at TIMESTAMP
+);
+
+CREATE INDEX outbox_pending ON outbox (occurred_at) WHERE dispatched_at IS NULL;
+
+-- Every delivery attempt, for partners asking why they missed one
+CREATE TABLE webhook_deliveries (
+    id          INTEGER PRIMARY KEY,
+    webhook_id  TEXT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id    TEXT NOT NULL,
+    event_type  TEXT NOT NULL,
+    job_id      TEXT NOT NULL,
+    attempt     INTEGER NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0, -- 0 when no response came
+    error       TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL,
+    created_at  TIMESTAMP NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id);
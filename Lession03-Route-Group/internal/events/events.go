@@ -0,0 +1,80 @@
+// Package events is what the handlers tell the rest of the system after a
+// change: a product was created, a category moved. Handlers publish, the
+// webhook service (see package webhook) stores the events in its outbox and
+// delivers them to the partners who asked for them, the hub (see package
+// hub) pushes them to the admin dashboards watching.
+package events
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types, resource.change
+const (
+	ProductCreated = "product.created"
+	ProductUpdated = "product.updated"
+	ProductDeleted = "product.deleted"
+	// ProductStockChanged is a reservation made, committed or released
+	ProductStockChanged = "product.stock_changed"
+	CategoryCreated     = "category.created"
+	CategoryUpdated     = "category.updated"
+	UserCreated         = "user.created"
+	UserUpdated         = "user.updated"
+	UserDeleted         = "user.deleted"
+)
+
+// Types lists every event type, for webhook subscriptions
+var Types = []string{
+	ProductCreated, ProductUpdated, ProductDeleted, ProductStockChanged,
+	CategoryCreated, CategoryUpdated,
+	UserCreated, UserUpdated, UserDeleted,
+}
+
+// Event is one change. Data is the resource as the API shows it after the
+// change, or Deleted for deletes.
+type Event struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Subject string `json:"subject"` // the resource changed, "product:12"
+	// OccurredAt is when the change was made
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// Deleted is the Data of a *.deleted event
+type Deleted struct {
+	ID int `json:"id"`
+}
+
+// New is an event of typ about the resource with the given ID
+func New(typ string, id int, data any) Event {
+	resource, _, _ := strings.Cut(typ, ".")
+	return Event{
+		ID: uuid.NewString(), Type: typ, Subject: resource + ":" + strconv.Itoa(id),
+		OccurredAt: time.Now().UTC(), Data: data,
+	}
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// All publishes to each of its publishers in turn, a failing one doesn't
+// stop the others
+type All []Publisher
+
+func (all All) Publish(ctx context.Context, e Event) error {
+	var errs []error
+	for _, p := range all {
+		if err := p.Publish(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,245 @@
+// Package hub pushes domain events (see package events) to WebSocket
+// clients, the admin dashboards watching products and categories change.
+//
+// A client sends {"op":"subscribe","topics":["product:12","category:*"]}
+// and gets {"type":"event","topic":"product:12","event":{...}} for every
+// event about a resource it subscribed to; "unsubscribe" undoes it. The hub
+// never waits on a client: one that falls SendBuffer messages behind is told
+// so and disconnected, it reconnects and reloads.
+package hub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+	"golang.org/x/net/websocket"
+)
+
+// MaxTopics is how many topics one connection may hold
+const MaxTopics = 100
+
+// topicPattern is product:<id> or category:<id>, * for every one
+var topicPattern = regexp.MustCompile(`^(product|category):(\*|[1-9][0-9]{0,9})$`)
+
+// Message is what the hub sends. Type is event, subscribed, unsubscribed,
+// pong or error.
+type Message struct {
+	Type   string        `json:"type"`
+	Topic  string        `json:"topic,omitempty"`
+	Event  *events.Event `json:"event,omitempty"`
+	Topics []string      `json:"topics,omitempty"` // all the connection holds now
+	Error  string        `json:"error,omitempty"`
+}
+
+// Request is what a client sends. Op is subscribe, unsubscribe or ping.
+type Request struct {
+	Op     string   `json:"op"`
+	Topics []string `json:"topics"`
+}
+
+// Hub is safe for concurrent use. Set the fields before the first Serve.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+	tickets map[string]ticket
+
+	SendBuffer   int           // messages a client may fall behind, 64 by default
+	WriteTimeout time.Duration // of one message, 10s by default
+	PingInterval time.Duration // between pings keeping idle connections open, 30s by default
+}
+
+func New() *Hub {
+	return &Hub{
+		clients:      map[*client]struct{}{},
+		tickets:      map[string]ticket{},
+		SendBuffer:   64,
+		WriteTimeout: 10 * time.Second,
+		PingInterval: 30 * time.Second,
+	}
+}
+
+type client struct {
+	principal string
+	send      chan []byte
+	slow      chan struct{} // closed when send overflowed
+	slowOnce  sync.Once
+
+	mu     sync.Mutex
+	topics map[string]bool
+}
+
+func (c *client) wants(subject string) bool {
+	resource, _, _ := strings.Cut(subject, ":")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.topics[subject] || c.topics[resource+":*"]
+}
+
+// queue hands msg to the writer, or cuts the client off when it is too far behind
+func (c *client) queue(msg []byte) {
+	select {
+	case c.send <- msg:
+	default:
+		c.slowOnce.Do(func() { close(c.slow) })
+	}
+}
+
+// Publish sends e to the clients subscribed to its subject, it never blocks
+// on them. Hub is an events.Publisher.
+func (h *Hub) Publish(_ context.Context, e events.Event) error {
+	msg, err := json.Marshal(Message{Type: "event", Topic: e.Subject, Event: &e})
+	if err != nil {
+		return err
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for c := range h.clients {
+		if c.wants(e.Subject) {
+			c.queue(msg)
+		}
+	}
+	return nil
+}
+
+// Serve runs one connection of an authenticated principal until it closes
+func (h *Hub) Serve(ws *websocket.Conn, principal string) {
+	ws.MaxPayloadBytes = 16 << 10
+	c := &client{
+		principal: principal,
+		send:      make(chan []byte, h.SendBuffer),
+		slow:      make(chan struct{}),
+		topics:    map[string]bool{},
+	}
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+	}()
+
+	done := make(chan struct{})
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		h.write(ws, c, done)
+	}()
+	for {
+		var req Request
+		if err := websocket.JSON.Receive(ws, &req); err != nil {
+			break // closed, or not JSON: either way the connection is done
+		}
+		c.queue(h.handle(c, req))
+	}
+	close(done)
+	<-written
+}
+
+// handle answers one request of c
+func (h *Hub) handle(c *client, req Request) []byte {
+	reply := Message{Type: req.Op + "d"}
+	switch req.Op {
+	case "ping":
+		reply.Type = "pong"
+	case "subscribe", "unsubscribe":
+		c.mu.Lock()
+		for _, t := range req.Topics {
+			if !topicPattern.MatchString(t) {
+				reply = Message{Type: "error", Error: "invalid topic " + t + ", want product:<id> or category:<id>, * for all"}
+				break
+			}
+			if req.Op == "unsubscribe" {
+				delete(c.topics, t)
+			} else if len(c.topics) < MaxTopics {
+				c.topics[t] = true
+			} else {
+				reply = Message{Type: "error", Error: "too many topics on one connection"}
+				break
+			}
+		}
+		for t := range c.topics {
+			reply.Topics = append(reply.Topics, t)
+		}
+		c.mu.Unlock()
+		sort.Strings(reply.Topics)
+	default:
+		reply = Message{Type: "error", Error: `unknown op, want "subscribe", "unsubscribe" or "ping"`}
+	}
+	msg, _ := json.Marshal(reply)
+	return msg
+}
+
+// write is the only writer of ws: queued messages, pings, and the notice
+// to a client cut off for being too slow
+func (h *Hub) write(ws *websocket.Conn, c *client, done <-chan struct{}) {
+	defer ws.Close()
+	ping := time.NewTicker(h.PingInterval)
+	defer ping.Stop()
+	for {
+		ws.SetWriteDeadline(time.Now().Add(h.WriteTimeout))
+		var err error
+		select {
+		case <-done:
+			return
+		case msg := <-c.send:
+			err = websocket.Message.Send(ws, string(msg))
+		case <-ping.C:
+			ws.PayloadType = websocket.PingFrame
+			_, err = ws.Write(nil)
+			ws.PayloadType = websocket.TextFrame
+		case <-c.slow:
+			log.Printf("hub: %s fell %d messages behind, disconnecting", c.principal, h.SendBuffer)
+			websocket.JSON.Send(ws, Message{Type: "error", Error: "Too slow, reconnect and reload"})
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// TicketTTL is how long a ticket can be redeemed
+const TicketTTL = 30 * time.Second
+
+type ticket struct {
+	principal string
+	expires   time.Time
+}
+
+// Ticket lets principal open one connection within TicketTTL, for browsers:
+// they can't send an Authorization header with the WebSocket handshake
+func (h *Hub) Ticket(principal string) (string, time.Time) {
+	now := time.Now()
+	t := ticket{principal: principal, expires: now.Add(TicketTTL)}
+	id := rand.Text()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k, old := range h.tickets {
+		if now.After(old.expires) {
+			delete(h.tickets, k)
+		}
+	}
+	h.tickets[id] = t
+	return id, t.expires
+}
+
+// Redeem uses up a ticket and returns whose it was
+func (h *Hub) Redeem(id string) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.tickets[id]
+	delete(h.tickets, id)
+	if !ok || time.Now().After(t.expires) {
+		return "", false
+	}
+	return t.principal, true
+}
@@ -0,0 +1,21 @@
+package hub_test
+
+import (
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/hub"
+)
+
+func TestTicketIsSingleUse(t *testing.T) {
+	h := hub.New()
+	id, _ := h.Ticket("user:1")
+	if principal, ok := h.Redeem(id); !ok || principal != "user:1" {
+		t.Fatalf("got %q, %t", principal, ok)
+	}
+	if _, ok := h.Redeem(id); ok {
+		t.Error("redeemed a ticket twice")
+	}
+	if _, ok := h.Redeem("made-up"); ok {
+		t.Error("redeemed a ticket nobody got")
+	}
+}
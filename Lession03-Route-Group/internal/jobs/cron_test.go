@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, 10, 19, 10, 30, 15, 0, time.UTC) // a Monday
+	for spec, want := range map[string]string{
+		"* * * * *":       "2026-10-19 10:31",
+		"*/15 * * * *":    "2026-10-19 10:45",
+		"5 * * * *":       "2026-10-19 11:05",
+		"@daily":          "2026-10-20 00:00",
+		"@weekly":         "2026-10-25 00:00",
+		"@monthly":        "2026-11-01 00:00",
+		"0 9-17/4 * * *":  "2026-10-19 13:00",
+		"30 2 * * 1-5":    "2026-10-20 02:30",
+		"0 0 * * 7":       "2026-10-25 00:00",
+		"0 0 29 2 *":      "2028-02-29 00:00",
+		"0 0 13 * 5":      "2026-10-23 00:00", // the 13th or a Friday
+		"0 12 1,15 1,7 *": "2027-01-01 12:00",
+		"@every 90s":      "2026-10-19 10:31", // 10:31:45, to the minute below
+	} {
+		c, err := ParseCron(spec)
+		if err != nil {
+			t.Errorf("%s: %v", spec, err)
+			continue
+		}
+		if got := c.Next(from).Format("2006-01-02 15:04"); got != want {
+			t.Errorf("%s: next is %s, want %s", spec, got, want)
+		}
+	}
+
+	c, _ := ParseCron("0 0 31 2 *")
+	if next := c.Next(from); !next.IsZero() {
+		t.Errorf("31 Feb came at %s", next)
+	}
+}
+
+func TestParseCronRejects(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "x * * * *", "@every 10ms", "@yearly"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("%q parsed", spec)
+		}
+	}
+}
@@ -0,0 +1,494 @@
+// Package jobs runs work outside of requests: a durable queue in the
+// SQLite jobs table, a pool of workers per kind of job, retries with
+// exponential backoff, dead jobs kept for inspection and recurring jobs on
+// cron schedules.
+//
+// A worker claims a job by marking it running with a lease. Jobs whose lease
+// runs out (the process died mid-job) are claimed again, so a handler may
+// see the same job twice and should be safe to repeat.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	mathrand "math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Status string
+
+const (
+	Queued    Status = "queued" // waiting for run_at, or for a retry
+	Running   Status = "running"
+	Succeeded Status = "succeeded"
+	Dead      Status = "dead" // out of attempts, or failed with a Permanent error
+)
+
+var (
+	ErrNotFound    = errors.New("jobs: no such job")
+	ErrUnknownKind = errors.New("jobs: no handler for this kind")
+	ErrNotDead     = errors.New("jobs: only dead jobs can be retried")
+)
+
+type Job struct {
+	ID          string
+	Kind        string
+	Payload     json.RawMessage
+	Status      Status
+	Attempts    int // started so far, the one running included
+	MaxAttempts int
+	LastError   string
+	Result      json.RawMessage // what the handler returned, once succeeded
+	RunAt       time.Time       // not before, for queued jobs
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	StartedAt   *time.Time // of the last attempt
+	FinishedAt  *time.Time
+
+	lease string // the claim this worker holds, see finish
+}
+
+// Decode unmarshals the payload into v
+func (j Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler does one job. The result, JSON encoded, is kept with the job for
+// whoever polls it. ctx ends at Options.Timeout or when the queue stops.
+type Handler func(ctx context.Context, job Job) (result any, err error)
+
+type Options struct {
+	Workers     int           // jobs of this kind run at once, 1 by default
+	MaxAttempts int           // before the job is dead, 5 by default
+	Timeout     time.Duration // of one attempt, 5 minutes by default
+}
+
+// Permanent wraps an error retrying won't fix, the job is dead right away
+func Permanent(err error) error { return permanentError{err} }
+
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+type kind struct {
+	handler Handler
+	opts    Options
+	wake    chan struct{} // Enqueue nudges an idle worker
+}
+
+type Queue struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	kinds     map[string]*kind
+	observers []func(Job)
+
+	// PollInterval is how often idle workers and the scheduler look for due jobs
+	PollInterval time.Duration
+	// Backoff is the wait before the retry that follows failed attempt n
+	Backoff func(attempt int) time.Duration
+
+	now func() time.Time
+}
+
+// New is a queue on db, migrated to the jobs table. Register handlers, then Run.
+func New(db *sql.DB) *Queue {
+	return &Queue{
+		db:           db,
+		kinds:        map[string]*kind{},
+		PollInterval: time.Second,
+		Backoff:      ExponentialBackoff(time.Second, time.Hour),
+		now:          func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// ExponentialBackoff doubles from base up to max, with up to 20% jitter so
+// jobs failing together don't all come back together
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := time.Duration(float64(base) * math.Pow(2, float64(attempt-1)))
+		if d > max || d <= 0 {
+			d = max
+		}
+		return d - time.Duration(mathrand.Int64N(int64(d)/5+1))
+	}
+}
+
+// Register sets the handler of kind. Register every kind before Run.
+func (q *Queue) Register(name string, h Handler, opts Options) {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Minute
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.kinds[name] = &kind{handler: h, opts: opts, wake: make(chan struct{}, 1)}
+}
+
+// Observe calls fn with the job after every status change this queue makes:
+// enqueued, claimed, finished, retried or given back. fn runs on the worker,
+// so it should be quick. Jobs enqueued with EnqueueTx are only seen once a
+// worker claims them.
+func (q *Queue) Observe(fn func(Job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.observers = append(q.observers, fn)
+}
+
+func (q *Queue) changed(job Job) {
+	q.mu.Lock()
+	observers := q.observers
+	q.mu.Unlock()
+	for _, fn := range observers {
+		fn(job)
+	}
+}
+
+func (q *Queue) kind(name string) (*kind, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	k, ok := q.kinds[name]
+	return k, ok
+}
+
+type EnqueueOption func(*enqueueOptions)
+
+type enqueueOptions struct {
+	runAt       time.Time
+	maxAttempts int
+}
+
+// At delays the job until t
+func At(t time.Time) EnqueueOption { return func(o *enqueueOptions) { o.runAt = t.UTC() } }
+
+// MaxAttempts overrides the Options of the kind for this job
+func MaxAttempts(n int) EnqueueOption { return func(o *enqueueOptions) { o.maxAttempts = n } }
+
+// Enqueue stores a job of kind with payload JSON encoded. It runs once a
+// worker is free, or at the time given with At.
+func (q *Queue) Enqueue(ctx context.Context, kindName string, payload any, opts ...EnqueueOption) (Job, error) {
+	job, k, err := q.enqueue(ctx, q.db, kindName, payload, opts)
+	if err != nil {
+		return Job{}, err
+	}
+	q.changed(job)
+	select {
+	case k.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// EnqueueTx is Enqueue as part of tx, the job exists if tx commits. Workers
+// find it on their next poll.
+func (q *Queue) EnqueueTx(ctx context.Context, tx *sql.Tx, kindName string, payload any, opts ...EnqueueOption) (Job, error) {
+	job, _, err := q.enqueue(ctx, tx, kindName, payload, opts)
+	return job, err
+}
+
+func (q *Queue) enqueue(ctx context.Context, db execer, kindName string, payload any, opts []EnqueueOption) (Job, *kind, error) {
+	k, ok := q.kind(kindName)
+	if !ok {
+		return Job{}, nil, fmt.Errorf("%w %q", ErrUnknownKind, kindName)
+	}
+	now := q.now()
+	o := enqueueOptions{runAt: now, maxAttempts: k.opts.MaxAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, nil, fmt.Errorf("jobs: payload of %s: %w", kindName, err)
+	}
+	job, err := insert(ctx, db, kindName, data, o, now)
+	return job, k, err
+}
+
+// DB is the database of the queue, for work that has to commit together
+// with EnqueueTx
+func (q *Queue) DB() *sql.DB { return q.db }
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insert(ctx context.Context, db execer, kindName string, payload []byte, o enqueueOptions, now time.Time) (Job, error) {
+	job := Job{
+		ID: uuid.NewString(), Kind: kindName, Payload: payload, Status: Queued,
+		MaxAttempts: o.maxAttempts, RunAt: o.runAt, CreatedAt: now, UpdatedAt: now,
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO jobs (id, kind, payload, status, max_attempts, run_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.Kind, string(payload), job.Status, job.MaxAttempts, job.RunAt, now, now)
+	if err != nil {
+		return Job{}, fmt.Errorf("jobs: enqueue %s: %w", kindName, err)
+	}
+	return job, nil
+}
+
+const jobColumns = `id, kind, payload, status, attempts, max_attempts, last_error, result, run_at, created_at, updated_at, started_at, finished_at`
+
+type scanner interface{ Scan(dest ...any) error }
+
+func scanJob(row scanner) (Job, error) {
+	var j Job
+	var payload string
+	var result sql.NullString
+	var started, finished sql.NullTime
+	err := row.Scan(&j.ID, &j.Kind, &payload, &j.Status, &j.Attempts, &j.MaxAttempts, &j.LastError, &result,
+		&j.RunAt, &j.CreatedAt, &j.UpdatedAt, &started, &finished)
+	if err != nil {
+		return Job{}, err
+	}
+	j.Payload = json.RawMessage(payload)
+	if result.Valid {
+		j.Result = json.RawMessage(result.String)
+	}
+	if started.Valid {
+		j.StartedAt = &started.Time
+	}
+	if finished.Valid {
+		j.FinishedAt = &finished.Time
+	}
+	return j, nil
+}
+
+func (q *Queue) Find(ctx context.Context, id string) (Job, error) {
+	j, err := scanJob(q.db.QueryRowContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Job{}, ErrNotFound
+	}
+	return j, err
+}
+
+// Dead lists the dead jobs of kind (every kind when empty), last died first
+func (q *Queue) Dead(ctx context.Context, kindName string, limit int) ([]Job, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT `+jobColumns+` FROM jobs WHERE status = 'dead' AND (? = '' OR kind = ?) ORDER BY finished_at DESC LIMIT ?`,
+		kindName, kindName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dead []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		dead = append(dead, j)
+	}
+	return dead, rows.Err()
+}
+
+// Retry queues a dead job again with all its attempts, once the cause is fixed
+func (q *Queue) Retry(ctx context.Context, id string) error {
+	now := q.now()
+	res, err := q.db.ExecContext(ctx,
+		`UPDATE jobs SET status = 'queued', attempts = 0, run_at = ?, updated_at = ?, finished_at = NULL WHERE id = ? AND status = 'dead'`,
+		now, now, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := q.Find(ctx, id); err != nil {
+			return err
+		}
+		return ErrNotDead
+	}
+	return nil
+}
+
+// claim marks the next due job of kind running, for timeout plus a minute.
+// Running jobs whose lease ran out are due again.
+func (q *Queue) claim(ctx context.Context, kindName string, timeout time.Duration) (Job, error) {
+	now := q.now()
+	lease := rand.Text()
+	row := q.db.QueryRowContext(ctx, `
+		UPDATE jobs SET status = 'running', attempts = attempts + 1, locked_by = ?, locked_until = ?, started_at = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = ? AND ((status = 'queued' AND run_at <= ?) OR (status = 'running' AND locked_until < ?))
+			ORDER BY run_at, created_at LIMIT 1
+		)
+		RETURNING `+jobColumns,
+		lease, now.Add(timeout+time.Minute), now, now, kindName, now, now)
+	job, err := scanJob(row)
+	job.lease = lease
+	return job, err
+}
+
+// RunNext runs the next due job of kind, if any, and tells whether it did
+func (q *Queue) RunNext(ctx context.Context, kindName string) (bool, error) {
+	k, ok := q.kind(kindName)
+	if !ok {
+		return false, fmt.Errorf("%w %q", ErrUnknownKind, kindName)
+	}
+	job, err := q.claim(ctx, kindName, k.opts.Timeout)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("jobs: claim %s: %w", kindName, err)
+	}
+	q.changed(job)
+
+	var result any
+	if job.Attempts > job.MaxAttempts {
+		err = Permanent(errors.New("lease ran out on the last attempt"))
+	} else {
+		result, err = q.call(ctx, k, job)
+	}
+
+	// The outcome is written even when ctx is done, the job is ours
+	ctx = context.WithoutCancel(ctx)
+	switch {
+	case err == nil:
+		data, merr := json.Marshal(result)
+		if merr != nil {
+			return true, q.finish(ctx, job, Dead, nil, Permanent(merr))
+		}
+		return true, q.finish(ctx, job, Succeeded, data, nil)
+	case errors.Is(err, errStopped):
+		return true, q.release(ctx, job)
+	case errors.As(err, new(permanentError)) || job.Attempts >= job.MaxAttempts:
+		log.Printf("jobs: %s %s is dead after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+		return true, q.finish(ctx, job, Dead, nil, err)
+	default:
+		return true, q.retry(ctx, job, err)
+	}
+}
+
+var errStopped = errors.New("jobs: queue stopped")
+
+// call runs the handler with the kind's timeout, a panic counts as a failure
+func (q *Queue) call(ctx context.Context, k *kind, job Job) (result any, err error) {
+	jctx, cancel := context.WithTimeout(ctx, k.opts.Timeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	result, err = k.handler(jctx, job)
+	if err != nil && ctx.Err() != nil {
+		return nil, errStopped
+	}
+	return result, err
+}
+
+// finish records the outcome, unless the lease was lost to another worker
+func (q *Queue) finish(ctx context.Context, job Job, status Status, result []byte, cause error) error {
+	now := q.now()
+	var msg string
+	if cause != nil {
+		msg = cause.Error()
+	}
+	var res any
+	if result != nil {
+		res = string(result)
+	}
+	return q.update(ctx,
+		`UPDATE jobs SET status = ?, result = ?, last_error = ?, locked_by = NULL, locked_until = NULL, updated_at = ?, finished_at = ?
+		WHERE id = ? AND locked_by = ?`,
+		status, res, msg, now, now, job.ID, job.lease)
+}
+
+func (q *Queue) retry(ctx context.Context, job Job, cause error) error {
+	now := q.now()
+	return q.update(ctx,
+		`UPDATE jobs SET status = 'queued', run_at = ?, last_error = ?, locked_by = NULL, locked_until = NULL, updated_at = ?
+		WHERE id = ? AND locked_by = ?`,
+		now.Add(q.Backoff(job.Attempts)), cause.Error(), now, job.ID, job.lease)
+}
+
+// release gives a job interrupted by shutdown back, the attempt doesn't count
+func (q *Queue) release(ctx context.Context, job Job) error {
+	return q.update(ctx,
+		`UPDATE jobs SET status = 'queued', attempts = attempts - 1, locked_by = NULL, locked_until = NULL, updated_at = ?
+		WHERE id = ? AND locked_by = ?`,
+		q.now(), job.ID, job.lease)
+}
+
+// update runs a status change of a leased job and tells the observers. When
+// the lease was lost nothing changes, the new holder reports instead.
+func (q *Queue) update(ctx context.Context, query string, args ...any) error {
+	job, err := scanJob(q.db.QueryRowContext(ctx, query+` RETURNING `+jobColumns, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	q.changed(job)
+	return nil
+}
+
+// Run works the queue until ctx is done: the workers of every registered
+// kind and the scheduler. Jobs interrupted by ctx go back to the queue.
+func (q *Queue) Run(ctx context.Context) {
+	q.mu.Lock()
+	names := make([]string, 0, len(q.kinds))
+	for name := range q.kinds {
+		names = append(names, name)
+	}
+	q.mu.Unlock()
+	sort.Strings(names)
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		k, _ := q.kind(name)
+		for range k.opts.Workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				q.worker(ctx, name, k)
+			}()
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.scheduler(ctx)
+	}()
+	wg.Wait()
+}
+
+func (q *Queue) worker(ctx context.Context, name string, k *kind) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-k.wake:
+		case <-timer.C:
+		}
+		// Drain the queue, then wait for a nudge or the next poll
+		for ctx.Err() == nil {
+			ran, err := q.RunNext(ctx, name)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("%v", err)
+			}
+			if !ran || err != nil {
+				break
+			}
+		}
+		timer.Reset(q.PollInterval)
+	}
+}
@@ -0,0 +1,236 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/db"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/migrate"
+)
+
+// newTestQueue is a queue on a migrated temp database, on a clock tests move
+func newTestQueue(t *testing.T) (*Queue, *time.Time) {
+	t.Helper()
+	conn, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	r, err := migrate.New(conn, db.Migrations, db.MigrationsDir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	q := New(conn)
+	q.now = func() time.Time { return now }
+	q.Backoff = func(attempt int) time.Duration { return time.Duration(attempt) * time.Minute }
+	return q, &now
+}
+
+func mustFind(t *testing.T, q *Queue, id string) Job {
+	t.Helper()
+	j, err := q.Find(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestJobSucceedsWithItsResult(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+	q.Register("greet", func(_ context.Context, j Job) (any, error) {
+		var p struct{ Name string }
+		if err := j.Decode(&p); err != nil {
+			return nil, Permanent(err)
+		}
+		return map[string]string{"greeting": "hello " + p.Name}, nil
+	}, Options{})
+
+	job, err := q.Enqueue(ctx, "greet", map[string]string{"name": "gopher"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ran, err := q.RunNext(ctx, "greet"); !ran || err != nil {
+		t.Fatalf("ran %t, %v", ran, err)
+	}
+	got := mustFind(t, q, job.ID)
+	if got.Status != Succeeded || got.Attempts != 1 || string(got.Result) != `{"greeting":"hello gopher"}` || got.FinishedAt == nil {
+		t.Errorf("%+v", got)
+	}
+	if ran, _ := q.RunNext(ctx, "greet"); ran {
+		t.Error("ran the job twice")
+	}
+	if _, err := q.Enqueue(ctx, "nope", nil); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("unknown kind: %v", err)
+	}
+	if _, err := q.Find(ctx, "3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("find: %v", err)
+	}
+}
+
+func TestFailedJobBacksOffThenDies(t *testing.T) {
+	q, now := newTestQueue(t)
+	ctx := context.Background()
+	var calls atomic.Int32
+	q.Register("flaky", func(context.Context, Job) (any, error) {
+		calls.Add(1)
+		return nil, errors.New("upstream is down")
+	}, Options{MaxAttempts: 3})
+	job, _ := q.Enqueue(ctx, "flaky", nil)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		if ran, err := q.RunNext(ctx, "flaky"); !ran || err != nil {
+			t.Fatalf("attempt %d: ran %t, %v", attempt, ran, err)
+		}
+		j := mustFind(t, q, job.ID)
+		if attempt < 3 {
+			if j.Status != Queued || !j.RunAt.Equal(now.Add(time.Duration(attempt)*time.Minute)) || j.LastError != "upstream is down" {
+				t.Fatalf("after attempt %d: %+v", attempt, j)
+			}
+			// not due before the backoff is over
+			if ran, _ := q.RunNext(ctx, "flaky"); ran {
+				t.Fatalf("attempt %d retried right away", attempt)
+			}
+			*now = j.RunAt
+		}
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d calls", calls.Load())
+	}
+
+	dead, err := q.Dead(ctx, "flaky", 10)
+	if err != nil || len(dead) != 1 || dead[0].ID != job.ID || dead[0].Attempts != 3 {
+		t.Fatalf("dead letters %+v, %v", dead, err)
+	}
+	if err := q.Retry(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if j := mustFind(t, q, job.ID); j.Status != Queued || j.Attempts != 0 {
+		t.Errorf("retried %+v", j)
+	}
+	if err := q.Retry(ctx, job.ID); !errors.Is(err, ErrNotDead) {
+		t.Errorf("retry a queued job: %v", err)
+	}
+}
+
+func TestPermanentErrorsAndPanicsSkipRetries(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+	q.Register("bad", func(context.Context, Job) (any, error) {
+		return nil, Permanent(errors.New("malformed payload"))
+	}, Options{MaxAttempts: 5})
+	q.Register("panics", func(context.Context, Job) (any, error) { panic("boom") }, Options{MaxAttempts: 1})
+
+	bad, _ := q.Enqueue(ctx, "bad", "x")
+	panics, _ := q.Enqueue(ctx, "panics", nil)
+	q.RunNext(ctx, "bad")
+	q.RunNext(ctx, "panics")
+
+	if j := mustFind(t, q, bad.ID); j.Status != Dead || j.Attempts != 1 || j.LastError != "malformed payload" {
+		t.Errorf("permanent: %+v", j)
+	}
+	if j := mustFind(t, q, panics.ID); j.Status != Dead || j.LastError != "panic: boom" {
+		t.Errorf("panic: %+v", j)
+	}
+}
+
+// A worker that died mid-job loses the job once its lease runs out
+func TestExpiredLeaseIsClaimedAgain(t *testing.T) {
+	q, now := newTestQueue(t)
+	ctx := context.Background()
+	q.Register("slow", func(context.Context, Job) (any, error) { return "done", nil }, Options{Timeout: time.Minute})
+	job, _ := q.Enqueue(ctx, "slow", nil)
+
+	if _, err := q.claim(ctx, "slow", time.Minute); err != nil { // and then the process dies
+		t.Fatal(err)
+	}
+	if ran, _ := q.RunNext(ctx, "slow"); ran {
+		t.Fatal("claimed a job under lease")
+	}
+	*now = now.Add(3 * time.Minute)
+	if ran, err := q.RunNext(ctx, "slow"); !ran || err != nil {
+		t.Fatalf("ran %t, %v", ran, err)
+	}
+	if j := mustFind(t, q, job.ID); j.Status != Succeeded || j.Attempts != 2 {
+		t.Errorf("%+v", j)
+	}
+}
+
+func TestSchedulesEnqueueOncePerRun(t *testing.T) {
+	q, now := newTestQueue(t)
+	ctx := context.Background()
+	q.Register("tick", func(context.Context, Job) (any, error) { return nil, nil }, Options{})
+	if err := q.Schedule(ctx, "hourly-tick", "@hourly", "tick", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	// a restart registers the same schedule again, it stays put
+	if err := q.Schedule(ctx, "hourly-tick", "@hourly", "tick", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Schedule(ctx, "bad", "@yearly", "tick", nil); err == nil {
+		t.Error("scheduled a bad spec")
+	}
+
+	count := func() (n int) {
+		q.db.QueryRow(`SELECT count(*) FROM jobs WHERE kind = 'tick'`).Scan(&n)
+		return n
+	}
+	if n, _ := q.RunSchedules(ctx); n != 0 {
+		t.Fatalf("%d enqueued before the hour", n)
+	}
+	*now = now.Add(3*time.Hour + time.Minute) // three runs missed
+	if n, err := q.RunSchedules(ctx); n != 1 || err != nil {
+		t.Fatalf("%d enqueued, %v", n, err)
+	}
+	if n, _ := q.RunSchedules(ctx); n != 0 || count() != 1 {
+		t.Fatalf("enqueued again: %d, %d jobs", n, count())
+	}
+	*now = now.Add(time.Hour)
+	q.RunSchedules(ctx)
+	if count() != 2 {
+		t.Errorf("%d jobs after the next hour", count())
+	}
+}
+
+// Run works jobs as they come and gives back the one it is stopped in
+func TestRunStopsAndReleases(t *testing.T) {
+	q, _ := newTestQueue(t)
+	q.PollInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := make(chan struct{})
+	q.Register("fast", func(context.Context, Job) (any, error) { return nil, nil }, Options{Workers: 2})
+	q.Register("block", func(ctx context.Context, _ Job) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, Options{})
+
+	fast, _ := q.Enqueue(ctx, "fast", nil)
+	block, _ := q.Enqueue(ctx, "block", nil)
+	done := make(chan struct{})
+	go func() { q.Run(ctx); close(done) }()
+
+	<-started
+	deadline := time.Now().Add(5 * time.Second)
+	for mustFind(t, q, fast.ID).Status != Succeeded {
+		if time.Now().After(deadline) {
+			t.Fatal("fast job never ran")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	if j := mustFind(t, q, block.ID); j.Status != Queued || j.Attempts != 0 {
+		t.Errorf("interrupted job %+v", j)
+	}
+}
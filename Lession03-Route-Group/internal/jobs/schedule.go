@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Schedule makes name enqueue a job of kind every time spec (see ParseCron)
+// comes due. Schedules live in job_schedules, so every replica calling
+// Schedule at start up agrees on them and only one enqueues each run.
+// Changing the spec of a schedule restarts it from now.
+func (q *Queue) Schedule(ctx context.Context, name, spec, kindName string, payload any) error {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	if _, ok := q.kind(kindName); !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKind, kindName)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("jobs: payload of schedule %s: %w", name, err)
+	}
+
+	now := q.now()
+	_, err = q.db.ExecContext(ctx, `
+		INSERT INTO job_schedules (name, spec, kind, payload, next_run_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			next_run_at = CASE WHEN spec = excluded.spec THEN next_run_at ELSE excluded.next_run_at END,
+			spec = excluded.spec, kind = excluded.kind, payload = excluded.payload, updated_at = excluded.updated_at`,
+		name, spec, kindName, string(data), cron.Next(now), now)
+	return err
+}
+
+// RunSchedules enqueues a job for every schedule that is due and returns how
+// many. A schedule missed while nothing was running fires once, not once per
+// missed run.
+func (q *Queue) RunSchedules(ctx context.Context) (int, error) {
+	now := q.now()
+	rows, err := q.db.QueryContext(ctx, `SELECT name, spec, kind, payload, next_run_at FROM job_schedules WHERE next_run_at <= ?`, now)
+	if err != nil {
+		return 0, err
+	}
+	type due struct {
+		name, spec, kind, payload string
+		next                      time.Time
+	}
+	var list []due
+	for rows.Next() {
+		var d due
+		if err := rows.Scan(&d.name, &d.spec, &d.kind, &d.payload, &d.next); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		list = append(list, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	enqueued := 0
+	var errs []error
+	for _, d := range list {
+		ok, err := q.fire(ctx, d.name, d.spec, d.kind, d.payload, d.next, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("jobs: schedule %s: %w", d.name, err))
+		}
+		if ok {
+			enqueued++
+		}
+	}
+	return enqueued, errors.Join(errs...)
+}
+
+// fire moves the schedule on and enqueues its job in one transaction. The
+// update only matches while next_run_at is still prev, so a replica that
+// got there first wins and the others do nothing.
+func (q *Queue) fire(ctx context.Context, name, spec, kindName, payload string, prev, now time.Time) (bool, error) {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return false, err
+	}
+	k, ok := q.kind(kindName)
+	if !ok {
+		return false, fmt.Errorf("%w %q", ErrUnknownKind, kindName)
+	}
+
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `UPDATE job_schedules SET next_run_at = ? WHERE name = ? AND next_run_at = ?`,
+		cron.Next(now), name, prev)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	job, err := insert(ctx, tx, kindName, []byte(payload), enqueueOptions{runAt: now, maxAttempts: k.opts.MaxAttempts}, now)
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	q.changed(job)
+	select {
+	case k.wake <- struct{}{}:
+	default:
+	}
+	return true, nil
+}
+
+func (q *Queue) scheduler(ctx context.Context) {
+	ticker := time.NewTicker(q.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := q.RunSchedules(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("%v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Package listquery holds the pagination, sorting and response envelope
+// shared by every list endpoint (products, users, categories).
+package listquery
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+type Direction string
+
+const (
+	Asc  Direction = "asc"
+	Desc Direction = "desc"
+)
+
+type SortField struct {
+	Field string
+	Dir   Direction
+}
+
+// Request is the raw list part of a query DTO, before it is checked against a Spec
+type Request struct {
+	Limit  int
+	Offset int
+	Cursor string
+	Sort   string
+}
+
+// Spec says what one list endpoint accepts
+type Spec struct {
+	SortFields  []string // whitelist, e.g. name, price, created_at
+	DefaultSort string   // used when the client sends no sort, e.g. "-created_at"
+}
+
+// Params is a Request that passed the Spec
+type Params struct {
+	Limit  int
+	Offset int
+	Sort   []SortField
+}
+
+// FieldError keeps the same shape as utils.FormatValidationErrors (field -> message)
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func (e *FieldError) Fields() map[string]string {
+	return map[string]string{e.Field: e.Message}
+}
+
+// cursor is what we hide behind the opaque base64 string
+type cursor struct {
+	Offset int    `json:"o"`
+	Sort   string `json:"s,omitempty"`
+}
+
+// Parse checks limit, cursor and sort against the spec.
+// A cursor wins over offset, and must have been issued for the same sort.
+func Parse(req Request, spec Spec) (Params, error) {
+	p := Params{Limit: req.Limit, Offset: req.Offset}
+
+	if p.Limit == 0 {
+		p.Limit = DefaultLimit
+	}
+	if p.Limit < 0 || p.Limit > MaxLimit {
+		return Params{}, &FieldError{"limit", fmt.Sprintf("Limit must be between 1 and %d", MaxLimit)}
+	}
+	if p.Offset < 0 {
+		return Params{}, &FieldError{"offset", "Offset must be greater than or equal to 0"}
+	}
+
+	sortParam := req.Sort
+	if req.Cursor != "" {
+		cur, err := decodeCursor(req.Cursor)
+		if err != nil {
+			return Params{}, &FieldError{"cursor", "Cursor is invalid or expired"}
+		}
+		if sortParam == "" {
+			sortParam = cur.Sort
+		}
+		if cur.Sort != sortParam {
+			return Params{}, &FieldError{"cursor", "Cursor was issued for a different sort"}
+		}
+		p.Offset = cur.Offset
+	}
+	if sortParam == "" {
+		sortParam = spec.DefaultSort
+	}
+
+	fields, err := parseSort(sortParam, spec.SortFields)
+	if err != nil {
+		return Params{}, err
+	}
+	p.Sort = fields
+	return p, nil
+}
+
+// parseSort reads "-price,name" into [{price desc} {name asc}]
+func parseSort(s string, allowed []string) ([]SortField, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var fields []SortField
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		dir := Asc
+		if strings.HasPrefix(part, "-") {
+			dir = Desc
+			part = part[1:]
+		}
+		if !slices.Contains(allowed, part) {
+			return nil, &FieldError{"sort", fmt.Sprintf("Sort must be one of: %s (prefix with - for descending)", strings.Join(allowed, ", "))}
+		}
+		fields = append(fields, SortField{Field: part, Dir: dir})
+	}
+	return fields, nil
+}
+
+// SortString turns the sort back into the "-price,name" form
+func (p Params) SortString() string {
+	parts := make([]string, 0, len(p.Sort))
+	for _, f := range p.Sort {
+		if f.Dir == Desc {
+			parts = append(parts, "-"+f.Field)
+		} else {
+			parts = append(parts, f.Field)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Comparator compares two items on one sort field, like cmp.Compare
+type Comparator[T any] func(a, b T) int
+
+// Sort orders items in place by the requested fields. Items that compare
+// equal keep their current order, so the repository order is the tie-breaker.
+func Sort[T any](items []T, fields []SortField, by map[string]Comparator[T]) {
+	if len(fields) == 0 {
+		return
+	}
+	slices.SortStableFunc(items, func(a, b T) int {
+		for _, f := range fields {
+			compare, ok := by[f.Field]
+			if !ok {
+				continue
+			}
+			if c := compare(a, b); c != 0 {
+				if f.Dir == Desc {
+					return -c
+				}
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+// Slice returns the page of items selected by p
+func Slice[T any](items []T, p Params) []T {
+	if p.Offset >= len(items) {
+		return []T{}
+	}
+	end := min(p.Offset+p.Limit, len(items))
+	return items[p.Offset:end]
+}
+
+// InRange reports whether v is inside the optional [lo, hi] bounds
+func InRange[N cmp.Ordered](v N, lo, hi *N) bool {
+	if lo != nil && v < *lo {
+		return false
+	}
+	if hi != nil && v > *hi {
+		return false
+	}
+	return true
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, err
+	}
+	if c.Offset < 0 {
+		return c, fmt.Errorf("negative offset in cursor")
+	}
+	return c, nil
+}
@@ -0,0 +1,78 @@
+package listquery
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// Page is the response envelope every list endpoint returns
+type Page[T any] struct {
+	Data  []T   `json:"data"`
+	Meta  Meta  `json:"meta"`
+	Links Links `json:"links"`
+}
+
+type Meta struct {
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Sort       string `json:"sort,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+type Links struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// NewPage sorts nothing and filters nothing: give it the already filtered and
+// sorted items, it cuts the page and builds the next/prev links from the request URL.
+func NewPage[T any](u *url.URL, items []T, p Params) Page[T] {
+	total := len(items)
+	page := Page[T]{
+		Data: Slice(items, p),
+		Meta: Meta{
+			Total:  total,
+			Limit:  p.Limit,
+			Offset: p.Offset,
+			Sort:   p.SortString(),
+		},
+		Links: Links{Self: selfLink(u)},
+	}
+
+	if next := p.Offset + p.Limit; next < total {
+		page.Meta.NextCursor = encodeCursor(cursor{Offset: next, Sort: page.Meta.Sort})
+		page.Links.Next = pageLink(u, p, page.Meta.NextCursor)
+	}
+	if p.Offset > 0 {
+		prev := max(p.Offset-p.Limit, 0)
+		page.Meta.PrevCursor = encodeCursor(cursor{Offset: prev, Sort: page.Meta.Sort})
+		page.Links.Prev = pageLink(u, p, page.Meta.PrevCursor)
+	}
+	return page
+}
+
+// selfLink is the request URL with its query sorted, the same for every
+// spelling of one query: a cached page is served for all of them
+func selfLink(u *url.URL) string {
+	link := *u
+	link.RawQuery = u.Query().Encode()
+	return link.RequestURI()
+}
+
+// pageLink keeps the filters of the current request and swaps offset for a cursor
+func pageLink(u *url.URL, p Params, cur string) string {
+	q := u.Query()
+	q.Del("offset")
+	q.Set("cursor", cur)
+	q.Set("limit", strconv.Itoa(p.Limit))
+	if s := p.SortString(); s != "" {
+		q.Set("sort", s)
+	}
+
+	link := *u
+	link.RawQuery = q.Encode()
+	return link.RequestURI()
+}
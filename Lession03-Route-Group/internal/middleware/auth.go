@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+)
+
+// RoleKey is the gin context key of the caller's role, "admin" or "user"
+const RoleKey = "role"
+
+// Authenticate identifies callers sending "Authorization: Bearer <token>"
+// with an API token from the api_tokens table (see lession03 user
+// create-admin): the principal becomes user:<id>. Requests without the
+// header go through anonymous, a token that isn't known is a 401. db may be
+// nil when the server runs without a database, then no token is known.
+func Authenticate(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || db == nil {
+			unauthorized(c)
+			return
+		}
+
+		sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+		var id int64
+		var role string
+		err := db.QueryRowContext(c.Request.Context(),
+			`SELECT u.id, u.role FROM api_tokens t JOIN users u ON u.id = t.user_id WHERE t.token_hash = ?`,
+			hex.EncodeToString(sum[:])).Scan(&id, &role)
+		if errors.Is(err, sql.ErrNoRows) {
+			unauthorized(c)
+			return
+		}
+		if err != nil {
+			log.Printf("authenticate: %v", err)
+			content.Abort(c, http.StatusInternalServerError, gin.H{"error": "Failed to check API token"})
+			return
+		}
+		c.Set(PrincipalKey, "user:"+strconv.FormatInt(id, 10))
+		c.Set(RoleKey, role)
+		c.Next()
+	}
+}
+
+func unauthorized(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Bearer realm="lession03"`)
+	content.Abort(c, http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
+}
+
+// RequireUser answers 401 to anonymous callers
+func RequireUser(c *gin.Context) {
+	if User(c) == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="lession03"`)
+		content.Abort(c, http.StatusUnauthorized, gin.H{"error": "Authentication required", "msg": "send an API token as Authorization: Bearer <token>"})
+	}
+}
+
+// RequireAdmin answers 401 to anonymous callers and 403 to the other users
+func RequireAdmin(c *gin.Context) {
+	switch c.GetString(RoleKey) {
+	case "admin":
+	case "":
+		c.Header("WWW-Authenticate", `Bearer realm="lession03"`)
+		content.Abort(c, http.StatusUnauthorized, gin.H{"error": "Authentication required", "msg": "send an admin's API token as Authorization: Bearer <token>"})
+	default:
+		content.Abort(c, http.StatusForbidden, gin.H{"error": "Admins only"})
+	}
+}
+
+// IsAdmin tells whether the caller authenticated with an admin's token
+func IsAdmin(c *gin.Context) bool {
+	return c.GetString(RoleKey) == "admin"
+}
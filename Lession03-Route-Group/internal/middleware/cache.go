@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/cache"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+)
+
+const (
+	CacheHeader        = "X-Cache" // HIT, MISS or BYPASS
+	SurrogateKeyHeader = "Surrogate-Key"
+)
+
+// CacheRule is how one route is cached
+type CacheRule struct {
+	TTL time.Duration
+	// Query is the route's query DTO, e.g. dto.ProductQuery{}: requests
+	// whose query doesn't bind to it aren't cached. The key has the query
+	// string with its parameters sorted, as the page links show it. Without
+	// a DTO the query string doesn't count.
+	Query any
+	// Private is for responses about people, e.g. users: browsers may keep
+	// them but shared caches (CDNs, proxies) must not
+	Private bool
+	// Tags name what the response shows, Cache.Publish invalidates them
+	// when it changes
+	Tags []string
+}
+
+// replayedHeaders are the headers of a response kept with it
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Cache answers GETs of the route from store while the resources tagged
+// haven't changed. Only 200s are kept; conditional requests (If-None-Match)
+// and requests whose query doesn't bind go to the handler, which answers
+// them itself. Public responses carry Cache-Control and the tags as
+// Surrogate-Key, for a CDN in front to purge by. A nil store turns caching off.
+func Cache(store *cache.Cache, rule CacheRule) gin.HandlerFunc {
+	if store == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		if rule.Private {
+			c.Header("Cache-Control", "private, max-age=0")
+		} else {
+			c.Header("Cache-Control", fmt.Sprintf("public, max-age=0, s-maxage=%d", int(rule.TTL.Seconds())))
+			c.Header(SurrogateKeyHeader, strings.Join(rule.Tags, " "))
+		}
+
+		base, ok := cacheKey(c, rule.Query)
+		if !ok || c.Request.Method != http.MethodGet || c.GetHeader("If-None-Match") != "" {
+			c.Header(CacheHeader, "BYPASS")
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		key, err := store.Key(ctx, base, rule.Tags)
+		if err != nil {
+			log.Printf("cache: %v", err)
+			c.Header(CacheHeader, "BYPASS")
+			c.Next()
+			return
+		}
+
+		if e, err := store.Get(ctx, key); err != nil {
+			log.Printf("cache: %v", err)
+		} else if e != nil {
+			for k, v := range e.Header {
+				c.Writer.Header()[k] = v
+			}
+			c.Header(CacheHeader, "HIT")
+			c.Status(e.Status)
+			_, _ = c.Writer.Write(e.Body)
+			c.Abort()
+			return
+		}
+
+		c.Header(CacheHeader, "MISS")
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if w.Status() != http.StatusOK || c.IsAborted() {
+			return
+		}
+		e := cache.Entry{Status: w.Status(), Header: http.Header{}, Body: w.body.Bytes()}
+		for _, k := range replayedHeaders {
+			if v := w.Header().Values(k); len(v) > 0 {
+				e.Header[http.CanonicalHeaderKey(k)] = v
+			}
+		}
+		if err := store.Set(ctx, key, e, rule.TTL); err != nil {
+			log.Printf("cache: %v", err)
+		}
+	}
+}
+
+// cacheKey is the response format, the route template, its parameters and
+// the sorted query string, which the page links are built from. false when
+// the query doesn't bind to a new value of query's type.
+func cacheKey(c *gin.Context, query any) (string, bool) {
+	var b strings.Builder
+	b.WriteString(content.Negotiated(c).Name + " " + c.Request.Method + " " + c.FullPath())
+	for _, p := range c.Params {
+		b.WriteString("\x00" + p.Key + "=" + p.Value)
+	}
+	if query == nil {
+		return b.String(), true
+	}
+	bound := reflect.New(reflect.TypeOf(query)).Interface()
+	if err := c.ShouldBindQuery(bound); err != nil {
+		return "", false
+	}
+	b.WriteString("?" + c.Request.URL.Query().Encode())
+	return b.String(), true
+}
@@ -0,0 +1,268 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+)
+
+const (
+	IdempotencyHeader = "Idempotency-Key"
+	ReplayedHeader    = "Idempotent-Replayed"
+
+	maxIdempotencyKey  = 255
+	maxIdempotentBody  = 32 << 20 // same order as the upload limits (5 images x 2MB + form)
+	defaultIdemTTL     = 24 * time.Hour
+	defaultIdemWait    = 5 * time.Second
+	idemSweepThreshold = 1000
+)
+
+type IdempotencyConfig struct {
+	TTL  time.Duration // how long a finished response can be replayed
+	Wait time.Duration // how long a duplicate waits for the first request before getting 409
+}
+
+type idemRecord struct {
+	hash      string
+	done      chan struct{} // closed when the first request finished
+	status    int
+	header    http.Header
+	body      []byte
+	expiresAt time.Time
+}
+
+// IdempotencyStore remembers responses by principal + key. It is in memory,
+// like the repositories, so replicas behind a load balancer don't share it.
+type IdempotencyStore struct {
+	mu      sync.Mutex
+	cfg     IdempotencyConfig
+	records map[string]*idemRecord
+}
+
+func NewIdempotencyStore(cfg IdempotencyConfig) *IdempotencyStore {
+	if cfg.TTL == 0 {
+		cfg.TTL = defaultIdemTTL
+	}
+	if cfg.Wait == 0 {
+		cfg.Wait = defaultIdemWait
+	}
+	return &IdempotencyStore{cfg: cfg, records: map[string]*idemRecord{}}
+}
+
+// Idempotency makes POSTs safe to retry when the client sends an Idempotency-Key:
+//   - first request: runs normally, its status and body are stored
+//   - retry with the same payload: gets the stored response back (Idempotent-Replayed: true)
+//   - same key, different payload: 422
+//   - retry while the first one is still running: waits up to cfg.Wait, then 409
+//
+// 5xx responses are not stored, so the client can retry them for real.
+// Requests without the header are not touched.
+func Idempotency(store *IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			content.Abort(c, http.StatusBadRequest, gin.H{
+				"error": "Idempotency-Key must be at most 255 characters",
+			})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentBody+1))
+		if err != nil || len(body) > maxIdempotentBody {
+			content.Abort(c, http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// the path, not the route: one key sent to /products/1 and /products/2 is two requests
+		id := Principal(c) + "\x00" + c.Request.Method + " " + c.Request.URL.Path + "\x00" + key
+		hash := payloadHash(c.ContentType(), c.GetHeader("Content-Type"), c.Request.URL.RawQuery, body)
+
+		rec, first := store.begin(id, hash)
+		if !first {
+			store.replay(c, rec, hash)
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		finished := false
+		defer func() {
+			if !finished {
+				// the handler panicked, Recovery answers 500: forget the key
+				// like any 5xx instead of leaving the duplicates waiting on it
+				store.forget(id, rec)
+			}
+		}()
+		c.Next()
+
+		store.finish(id, rec, w)
+		finished = true
+	}
+}
+
+func (s *IdempotencyStore) begin(id, hash string) (*idemRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.records) > idemSweepThreshold {
+		for k, r := range s.records {
+			if !r.expiresAt.IsZero() && now.After(r.expiresAt) {
+				delete(s.records, k)
+			}
+		}
+	}
+
+	if rec, ok := s.records[id]; ok && (rec.expiresAt.IsZero() || now.Before(rec.expiresAt)) {
+		return rec, false
+	}
+	rec := &idemRecord{hash: hash, done: make(chan struct{})}
+	s.records[id] = rec
+	return rec, true
+}
+
+func (s *IdempotencyStore) finish(id string, rec *idemRecord, w *recordingWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec.status = w.Status()
+	if rec.status >= http.StatusInternalServerError {
+		// don't pin a failure, let the next retry run again
+		delete(s.records, id)
+	} else {
+		rec.header = w.Header().Clone()
+		rec.body = w.body.Bytes()
+		rec.expiresAt = time.Now().Add(s.cfg.TTL)
+	}
+	close(rec.done)
+}
+
+// forget drops the record of a request that didn't finish, a retry runs again
+func (s *IdempotencyStore) forget(id string, rec *idemRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.records[id] == rec {
+		delete(s.records, id)
+	}
+	close(rec.done)
+}
+
+func (s *IdempotencyStore) replay(c *gin.Context, rec *idemRecord, hash string) {
+	if rec.hash != hash {
+		content.Abort(c, http.StatusUnprocessableEntity, gin.H{
+			"error": "Idempotency-Key was already used with a different request payload",
+		})
+		return
+	}
+
+	select {
+	case <-rec.done:
+	case <-time.After(s.cfg.Wait):
+		content.Abort(c, http.StatusConflict, gin.H{
+			"error": "A request with this Idempotency-Key is still being processed",
+		})
+		return
+	case <-c.Request.Context().Done():
+		c.Abort()
+		return
+	}
+
+	s.mu.Lock()
+	status, header, body := rec.status, rec.header, rec.body
+	s.mu.Unlock()
+
+	if header == nil {
+		// the first request failed with a 5xx and was forgotten, tell the client to try again
+		content.Abort(c, http.StatusConflict, gin.H{
+			"error": "The original request failed, retry it",
+		})
+		return
+	}
+
+	for k, v := range header {
+		c.Writer.Header()[k] = v
+	}
+	c.Header(ReplayedHeader, "true")
+	c.Status(status)
+	_, _ = c.Writer.Write(body)
+	c.Abort()
+}
+
+// payloadHash hashes what makes two requests "the same". Multipart bodies are
+// hashed part by part, because clients pick a new random boundary on every retry.
+func payloadHash(mediaType, contentType, query string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(query))
+	h.Write([]byte{0})
+
+	if mediaType == "multipart/form-data" {
+		if sum, ok := multipartHash(contentType, body); ok {
+			h.Write(sum)
+			return hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func multipartHash(contentType string, body []byte) ([]byte, bool) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["boundary"] == "" {
+		return nil, false
+	}
+
+	var parts []string
+	r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false
+		}
+		content, err := io.ReadAll(p)
+		if err != nil {
+			return nil, false
+		}
+		sum := sha256.Sum256(content)
+		parts = append(parts, p.FormName()+"\x00"+p.FileName()+"\x00"+hex.EncodeToString(sum[:]))
+	}
+
+	sort.Strings(parts)
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return sum[:], true
+}
+
+// recordingWriter keeps a copy of everything written to the client
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
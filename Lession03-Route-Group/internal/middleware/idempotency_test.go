@@ -0,0 +1,119 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/middleware"
+)
+
+// idempotent is an engine with POST /things behind Idempotency, answering
+// with handler
+func idempotent(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	store := middleware.NewIdempotencyStore(middleware.IdempotencyConfig{Wait: 50 * time.Millisecond})
+	r.POST("/things", middleware.Idempotency(store), handler)
+	return r
+}
+
+func post(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middleware.IdempotencyHeader, key)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysAndRejectsAnotherPayload(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotent(func(c *gin.Context) {
+		calls.Add(1)
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+
+	if w := post(r, "k1", `{"name":"a"}`); w.Code != http.StatusCreated {
+		t.Fatalf("first: %d", w.Code)
+	}
+	w := post(r, "k1", `{"name":"a"}`)
+	if w.Code != http.StatusCreated || w.Header().Get(middleware.ReplayedHeader) != "true" || w.Body.String() != `{"id":1}` {
+		t.Errorf("retry: %d %q %s", w.Code, w.Header().Get(middleware.ReplayedHeader), w.Body)
+	}
+	if w := post(r, "k1", `{"name":"b"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("another payload: %d %s", w.Code, w.Body)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times", n)
+	}
+}
+
+func TestIdempotencyConflictWhileInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	r := idempotent(func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- post(r, "k1", `{}`) }()
+	<-started
+
+	if w := post(r, "k1", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("duplicate in flight: %d %s", w.Code, w.Body)
+	}
+	close(release)
+	if w := <-first; w.Code != http.StatusCreated {
+		t.Errorf("first: %d", w.Code)
+	}
+	if w := post(r, "k1", `{}`); w.Code != http.StatusCreated || w.Header().Get(middleware.ReplayedHeader) != "true" {
+		t.Errorf("retry once done: %d", w.Code)
+	}
+}
+
+// A handler that panics must not hold the key: the retry runs again
+func TestIdempotencyForgetsPanics(t *testing.T) {
+	var calls atomic.Int32
+	r := idempotent(func(c *gin.Context) {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusCreated, gin.H{"id": 1})
+	})
+
+	if w := post(r, "k1", `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first: %d", w.Code)
+	}
+	if w := post(r, "k1", `{}`); w.Code != http.StatusCreated || w.Header().Get(middleware.ReplayedHeader) != "" {
+		t.Errorf("retry: %d %s", w.Code, w.Body)
+	}
+}
+
+// The key is scoped by the path, so path parameters tell requests apart
+func TestIdempotencyKeyIsPerPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	store := middleware.NewIdempotencyStore(middleware.IdempotencyConfig{})
+	r.POST("/things/:id/reservations", middleware.Idempotency(store), func(c *gin.Context) {
+		c.JSON(http.StatusCreated, gin.H{"thing": c.Param("id")})
+	})
+
+	for _, id := range []string{"1", "2", "1"} {
+		req := httptest.NewRequest(http.MethodPost, "/things/"+id+"/reservations", strings.NewReader(`{"quantity":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.IdempotencyHeader, "k1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated || w.Body.String() != `{"thing":"`+id+`"}` {
+			t.Errorf("/things/%s: %d %s", id, w.Code, w.Body)
+		}
+	}
+}
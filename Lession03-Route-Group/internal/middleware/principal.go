@@ -0,0 +1,21 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// PrincipalKey is the gin context key where an auth middleware puts the caller's identity
+const PrincipalKey = "principal"
+
+// Principal returns who is calling. Until every route is authenticated we
+// fall back to the client IP, which is good enough to keep callers apart
+// (see router.Config.TrustedProxies), not to trust them with anything.
+func Principal(c *gin.Context) string {
+	if p := c.GetString(PrincipalKey); p != "" {
+		return p
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// User returns the authenticated caller, "" for anonymous ones
+func User(c *gin.Context) string {
+	return c.GetString(PrincipalKey)
+}
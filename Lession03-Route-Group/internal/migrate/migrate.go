@@ -0,0 +1,313 @@
+// Package migrate applies versioned SQL migrations from an fs.FS (the
+// embedded internal/db/migrations in the binary, a fstest.MapFS in tests).
+//
+// Files are NNNN_name.up.sql and NNNN_name.down.sql. Applied versions are
+// recorded in schema_migrations with the checksum of their up file, so an
+// edited migration is caught instead of silently skipped. A row in
+// schema_migrations_lock keeps two replicas from migrating at once.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrChecksum = errors.New("applied migration was changed since")
+	ErrUnknown  = errors.New("applied migration has no file")
+	ErrNoDown   = errors.New("migration has no down file")
+	ErrLocked   = errors.New("another process holds the migration lock")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up, Down string
+	Checksum string // sha256 of Up
+}
+
+// Status is one migration as status reports it
+type Status struct {
+	Migration
+	AppliedAt time.Time // zero when pending
+}
+
+func (s Status) Applied() bool { return !s.AppliedAt.IsZero() }
+
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration // by Version
+	owner      string      // written into the lock row, for whoever finds it held
+	// LockTimeout is how long Up and Down wait for another runner to finish
+	LockTimeout time.Duration
+	// StaleAfter is when a lock is considered left behind by a crashed
+	// process and taken over; keep it above the longest migration
+	StaleAfter time.Duration
+}
+
+// New reads the migrations in dir of fsys. owner names this process in the
+// lock row, e.g. host name and pid.
+func New(db *sql.DB, fsys fs.FS, dir, owner string) (*Runner, error) {
+	migrations, err := load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Runner{
+		db:          db,
+		migrations:  migrations,
+		owner:       owner,
+		LockTimeout: 30 * time.Second,
+		StaleAfter:  10 * time.Minute,
+	}, nil
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+			sum := sha256.Sum256(body)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+func (r *Runner) ensureTables(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			checksum   TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+			id          INTEGER PRIMARY KEY CHECK (id = 1),
+			owner       TEXT NOT NULL,
+			acquired_at TIMESTAMP NOT NULL
+		);`)
+	return err
+}
+
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func (r *Runner) applied(ctx context.Context) (map[int]applied, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int]applied{}
+	for rows.Next() {
+		var (
+			version int
+			a       applied
+		)
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		out[version] = a
+	}
+	return out, rows.Err()
+}
+
+// verify checks every applied migration still has its file, unchanged
+func (r *Runner) verify(done map[int]applied) error {
+	known := make(map[int]Migration, len(r.migrations))
+	for _, m := range r.migrations {
+		known[m.Version] = m
+	}
+	for version, a := range done {
+		m, ok := known[version]
+		if !ok {
+			return fmt.Errorf("%w: version %d", ErrUnknown, version)
+		}
+		if m.Checksum != a.checksum {
+			return fmt.Errorf("%w: %d_%s", ErrChecksum, m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// Status lists every migration, applied or not, and fails like Up would on
+// changed or missing files
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	if err := r.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	done, err := r.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Status, len(r.migrations))
+	for i, m := range r.migrations {
+		list[i] = Status{Migration: m, AppliedAt: done[m.Version].appliedAt}
+	}
+	return list, r.verify(done)
+}
+
+// Up applies every pending migration in order, each in its own transaction,
+// and returns the ones it applied
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := r.locked(ctx, func(done map[int]applied) error {
+		for _, m := range r.migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			err := r.inTx(ctx, m.Up, `INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+				m.Version, m.Name, m.Checksum, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			ran = append(ran, m)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down rolls back the last steps applied migrations, newest first
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var ran []Migration
+	err := r.locked(ctx, func(done map[int]applied) error {
+		for i := len(r.migrations) - 1; i >= 0 && len(ran) < steps; i-- {
+			m := r.migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("%w: %d_%s", ErrNoDown, m.Version, m.Name)
+			}
+			if err := r.inTx(ctx, m.Down, `DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", m.Version, m.Name, err)
+			}
+			ran = append(ran, m)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// inTx runs the migration script and the bookkeeping statement together
+func (r *Runner) inTx(ctx context.Context, script, record string, args ...any) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// locked runs fn holding the migration lock, with the applied versions
+// already verified against the files
+func (r *Runner) locked(ctx context.Context, fn func(done map[int]applied) error) error {
+	if err := r.ensureTables(ctx); err != nil {
+		return err
+	}
+	if err := r.lock(ctx); err != nil {
+		return err
+	}
+	defer r.unlock()
+
+	done, err := r.applied(ctx)
+	if err != nil {
+		return err
+	}
+	if err := r.verify(done); err != nil {
+		return err
+	}
+	return fn(done)
+}
+
+func (r *Runner) lock(ctx context.Context) error {
+	deadline := time.Now().Add(r.LockTimeout)
+	for {
+		now := time.Now().UTC()
+		// Take the row over when its holder is long gone
+		if _, err := r.db.ExecContext(ctx, `DELETE FROM schema_migrations_lock WHERE acquired_at < ?`, now.Add(-r.StaleAfter)); err != nil {
+			return err
+		}
+		res, err := r.db.ExecContext(ctx, `INSERT INTO schema_migrations_lock (id, owner, acquired_at) VALUES (1, ?, ?) ON CONFLICT (id) DO NOTHING`, r.owner, now)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			var holder string
+			_ = r.db.QueryRowContext(ctx, `SELECT owner FROM schema_migrations_lock`).Scan(&holder)
+			return fmt.Errorf("%w (%s)", ErrLocked, holder)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (r *Runner) unlock() {
+	// Not the request's ctx: a cancelled request must still let go of the lock
+	_, _ = r.db.Exec(`DELETE FROM schema_migrations_lock WHERE owner = ?`, r.owner)
+}
@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func files() fstest.MapFS {
+	return fstest.MapFS{
+		"m/0001_widgets.up.sql":     {Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY);")},
+		"m/0001_widgets.down.sql":   {Data: []byte("DROP TABLE widgets;")},
+		"m/0002_gadgets.up.sql":     {Data: []byte("CREATE TABLE gadgets (id INTEGER PRIMARY KEY);")},
+		"m/0002_gadgets.down.sql":   {Data: []byte("DROP TABLE gadgets;")},
+		"m/0003_broken.up.sql":      {Data: []byte("CREATE TABLE gizmos (id INTEGER PRIMARY KEY); NOT SQL;")},
+		"m/0003_broken.down.sql":    {Data: []byte("DROP TABLE gizmos;")},
+		"m/README.md":               {Data: []byte("not a migration")},
+		"m/0004_skipped.up.sql.bak": {Data: []byte("neither")},
+	}
+}
+
+func newRunner(t *testing.T, db *sql.DB, fsys fstest.MapFS, owner string) *Runner {
+	t.Helper()
+	r, err := New(db, fsys, "m", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.LockTimeout = 300 * time.Millisecond
+	return r
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n == 1
+}
+
+func TestUpStopsAtTheFirstFailureAndRollsItBack(t *testing.T) {
+	db := openDB(t)
+	r := newRunner(t, db, files(), "test")
+
+	ran, err := r.Up(context.Background())
+	if err == nil {
+		t.Fatal("want the error of 0003_broken")
+	}
+	if len(ran) != 2 {
+		t.Errorf("applied %d migrations, want 2", len(ran))
+	}
+	if !tableExists(t, db, "gadgets") || tableExists(t, db, "gizmos") {
+		t.Error("want 0001 and 0002 applied and 0003 rolled back as a whole")
+	}
+
+	status, err := r.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 3 || !status[1].Applied() || status[2].Applied() {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	db := openDB(t)
+	fsys := files()
+	delete(fsys, "m/0003_broken.up.sql")
+	delete(fsys, "m/0003_broken.down.sql")
+	r := newRunner(t, db, fsys, "test")
+	ctx := context.Background()
+
+	if ran, err := r.Up(ctx); err != nil || len(ran) != 2 {
+		t.Fatalf("up: %d applied, %v", len(ran), err)
+	}
+	if ran, err := r.Up(ctx); err != nil || len(ran) != 0 {
+		t.Fatalf("second up: %d applied, %v", len(ran), err)
+	}
+
+	ran, err := r.Down(ctx, 1)
+	if err != nil || len(ran) != 1 || ran[0].Version != 2 {
+		t.Fatalf("down: %+v, %v", ran, err)
+	}
+	if tableExists(t, db, "gadgets") || !tableExists(t, db, "widgets") {
+		t.Error("want only 0002 rolled back")
+	}
+	if ran, _ := r.Down(ctx, 5); len(ran) != 1 {
+		t.Errorf("down 5 with one applied rolled back %d", len(ran))
+	}
+}
+
+func TestChangedMigrationsAreRefused(t *testing.T) {
+	db := openDB(t)
+	fsys := files()
+	delete(fsys, "m/0003_broken.up.sql")
+	delete(fsys, "m/0003_broken.down.sql")
+	if _, err := newRunner(t, db, fsys, "test").Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys["m/0001_widgets.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT);")}
+	if _, err := newRunner(t, db, fsys, "test").Up(context.Background()); !errors.Is(err, ErrChecksum) {
+		t.Errorf("edited file: err = %v, want ErrChecksum", err)
+	}
+
+	delete(fsys, "m/0001_widgets.up.sql")
+	delete(fsys, "m/0001_widgets.down.sql")
+	if _, err := newRunner(t, db, fsys, "test").Status(context.Background()); !errors.Is(err, ErrUnknown) {
+		t.Errorf("deleted file: err = %v, want ErrUnknown", err)
+	}
+}
+
+func TestLock(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+	a := newRunner(t, db, files(), "replica-a")
+	b := newRunner(t, db, files(), "replica-b")
+	if err := a.ensureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Up(ctx); !errors.Is(err, ErrLocked) {
+		t.Fatalf("while a migrates: err = %v, want ErrLocked", err)
+	}
+	if tableExists(t, db, "widgets") {
+		t.Error("b migrated without the lock")
+	}
+
+	// a crashed holding the lock: once stale, b takes it over
+	b.StaleAfter = 0
+	if err := b.lock(ctx); err != nil {
+		t.Fatalf("stale lock: %v", err)
+	}
+	b.unlock()
+}
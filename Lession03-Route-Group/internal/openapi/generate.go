@@ -0,0 +1,225 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+const errorSchema = "ErrorResponse"
+
+// Generate builds the document for every route of the engine. Routes whose
+// handler is not in reg still show up, with their path parameters only.
+func Generate(routes gin.RoutesInfo, reg *Registry, info Info) *Document {
+	b := &schemaBuilder{components: map[string]*Schema{}, patterns: reg.patterns}
+	b.components[errorSchema] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error":  {Type: "string"},
+			"fields": {Type: "object", AdditionalProperties: &Schema{Type: "string"}, Description: "Field name -> validation message"},
+			"msg":    {Type: "string"},
+		},
+		Required: []string{"error"},
+	}
+
+	sorted := append(gin.RoutesInfo(nil), routes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Method < sorted[j].Method
+	})
+
+	doc := &Document{
+		OpenAPI:    "3.1.0",
+		Info:       info,
+		Paths:      map[string]*PathItem{},
+		Components: Components{Schemas: b.components},
+	}
+	for _, rt := range sorted {
+		if rt.Method == http.MethodHead {
+			continue
+		}
+		path := toOpenAPIPath(rt.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = &PathItem{}
+		}
+		op, documented := reg.Lookup(rt.Handler)
+		(*doc.Paths[path])[strings.ToLower(rt.Method)] = b.operation(rt, op, documented)
+	}
+	return doc
+}
+
+func (b *schemaBuilder) operation(rt gin.RouteInfo, op Operation, documented bool) *OperationObject {
+	o := &OperationObject{
+		OperationID: operationID(rt, documented),
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Responses:   map[string]*Response{},
+	}
+
+	o.Parameters = append(o.Parameters, b.pathParams(rt.Path, op.Path)...)
+	if op.Query != nil {
+		for _, f := range fields(reflect.TypeOf(op.Query), "form") {
+			p := Parameter{Name: f.name, In: "query", Schema: b.inline(f.typ)}
+			p.Required = applyRules(p.Schema, f.typ, f.rules, b.patterns)
+			o.Parameters = append(o.Parameters, p)
+		}
+	}
+	for _, h := range op.Headers {
+		o.Parameters = append(o.Parameters, Parameter{
+			Name: h.Name, In: "header", Required: h.Required, Description: h.Description,
+			Schema: &Schema{Type: "string"},
+		})
+	}
+
+	if op.Body != nil || len(op.Files) > 0 {
+		o.RequestBody = b.requestBody(op)
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	ok := &Response{Description: http.StatusText(status)}
+	switch {
+	case len(op.Produces) > 0:
+		ok.Content = map[string]*MediaType{}
+		for _, mt := range op.Produces {
+			schema := &Schema{Type: "string"}
+			if mt == "application/x-ndjson" && op.Response != nil {
+				schema = b.ref(reflect.TypeOf(op.Response))
+			}
+			ok.Content[mt] = &MediaType{Schema: schema}
+		}
+	case op.Response != nil:
+		ok.Content = map[string]*MediaType{"application/json": {Schema: b.ref(reflect.TypeOf(op.Response))}}
+	}
+	o.Responses[strconv.Itoa(status)] = ok
+
+	errors := append([]int(nil), op.Errors...)
+	if op.Path != nil || op.Query != nil || op.Body != nil || len(op.Files) > 0 {
+		errors = append(errors, http.StatusBadRequest)
+	}
+	for _, code := range errors {
+		switch code {
+		case http.StatusNotModified:
+			o.Responses[strconv.Itoa(code)] = &Response{Description: http.StatusText(code)}
+			continue
+		case http.StatusMovedPermanently:
+			o.Responses[strconv.Itoa(code)] = &Response{
+				Description: http.StatusText(code),
+				Headers:     map[string]*Header{"Location": {Schema: &Schema{Type: "string", Format: "uri-reference"}}},
+			}
+			continue
+		}
+		o.Responses[strconv.Itoa(code)] = &Response{
+			Description: http.StatusText(code),
+			Content: map[string]*MediaType{
+				"application/json": {Schema: &Schema{Ref: "#/components/schemas/" + errorSchema}},
+			},
+		}
+	}
+	return o
+}
+
+// pathParams documents every :param of the path, with the rules of the
+// matching `uri` field when the handler binds one
+func (b *schemaBuilder) pathParams(path string, dto any) []Parameter {
+	byName := map[string]field{}
+	if dto != nil {
+		for _, f := range fields(reflect.TypeOf(dto), "uri") {
+			byName[f.name] = f
+		}
+	}
+
+	var params []Parameter
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			continue
+		}
+		name := seg[1:]
+		p := Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		if f, ok := byName[name]; ok {
+			p.Schema = b.inline(f.typ)
+			applyRules(p.Schema, f.typ, f.rules, b.patterns)
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+func (b *schemaBuilder) requestBody(op Operation) *RequestBody {
+	rb := &RequestBody{Required: true, Content: map[string]*MediaType{}}
+
+	switch op.BodyKind {
+	case FormBody, MultipartBody:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		if op.Body != nil {
+			s = b.object(reflect.TypeOf(op.Body), "form")
+		}
+		for _, f := range op.Files {
+			file := &Schema{Type: "string", ContentMediaType: "application/octet-stream", Description: f.Description}
+			if f.Multiple {
+				s.Properties[f.Name] = &Schema{Type: "array", Items: file}
+			} else {
+				s.Properties[f.Name] = file
+			}
+			s.Required = append(s.Required, f.Name)
+		}
+		rb.Content["multipart/form-data"] = &MediaType{Schema: s}
+		if op.BodyKind == FormBody {
+			rb.Content["application/x-www-form-urlencoded"] = &MediaType{Schema: s}
+		}
+	default:
+		rb.Content["application/json"] = &MediaType{Schema: b.ref(reflect.TypeOf(op.Body))}
+	}
+	return rb
+}
+
+// toOpenAPIPath turns /users/:id and /static/*filepath into /users/{id} and /static/{filepath}
+func toOpenAPIPath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if seg != "" && (seg[0] == ':' || seg[0] == '*') {
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// operationID is the handler method name (getProducts) when we know it,
+// otherwise built from method and path (getApiStaticCategoriesFilepath)
+func operationID(rt gin.RouteInfo, documented bool) string {
+	if documented {
+		name := strings.TrimSuffix(rt.Handler, "-fm")
+		return lowerFirst(name[strings.LastIndex(name, ".")+1:])
+	}
+
+	id := strings.ToLower(rt.Method)
+	for _, seg := range strings.Split(rt.Path, "/") {
+		seg = strings.TrimLeft(seg, ":*")
+		if seg == "" {
+			continue
+		}
+		for _, part := range strings.FieldsFunc(seg, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			id += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return id
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
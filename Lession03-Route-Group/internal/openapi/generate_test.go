@@ -0,0 +1,225 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/openapi"
+)
+
+type base struct {
+	ID int `json:"id"`
+}
+
+type tag struct {
+	Name string `json:"name" binding:"required,min=2,max=20"`
+}
+
+type widgetRequest struct {
+	base
+	Name     string            `json:"name" binding:"required,min=3,max=50"`
+	Email    string            `json:"email" binding:"omitempty,email"`
+	Site     string            `json:"site" binding:"url"`
+	Ref      string            `json:"ref" binding:"uuid"`
+	Price    float64           `json:"price" binding:"required,gt=0"`
+	Stock    int               `json:"stock" binding:"gte=0,lte=1000"`
+	Kind     string            `json:"kind" binding:"oneof=plain fancy"`
+	Level    int               `json:"level" binding:"oneof=1 2 3"`
+	Slug     string            `json:"slug" binding:"omitempty,slug"`
+	Colors   []string          `json:"colors" binding:"max=3,dive,min=1,max=10"`
+	Tags     []tag             `json:"tags" binding:"dive"`
+	Labels   map[string]string `json:"labels" binding:"dive,keys,max=8,endkeys,max=30"`
+	Born     string            `json:"born" binding:"datetime=2006-01-02"`
+	Optional *int              `json:"optional"`
+	Secret   string            `json:"-"`
+	internal string
+}
+
+type widgetQuery struct {
+	Search string `form:"search" binding:"max=100"`
+	Page   int    `form:"page" binding:"required,min=1"`
+}
+
+type widgetUri struct {
+	ID int `uri:"id" binding:"required,min=1"`
+}
+
+type widgetForm struct {
+	Title string `form:"title" binding:"required"`
+}
+
+type widgetHandler struct{}
+
+func (widgetHandler) CreateWidget(*gin.Context) {}
+func (widgetHandler) GetWidget(*gin.Context)    {}
+func (widgetHandler) UploadForm(*gin.Context)   {}
+
+// generate documents the widget routes and returns the document as the JSON
+// clients get, so the tests look at what is served
+func generate(t *testing.T) map[string]any {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	h := widgetHandler{}
+	r := gin.New()
+	r.POST("/widgets", h.CreateWidget)
+	r.GET("/widgets/:id", h.GetWidget)
+	r.POST("/widgets/:id/form", h.UploadForm)
+	r.GET("/static/*filepath", func(*gin.Context) {})
+
+	reg := openapi.NewRegistry()
+	reg.Pattern("slug", `^[a-z0-9]+(-[a-z0-9]+)*$`)
+	reg.Describe(widgetHandler.CreateWidget, openapi.Operation{
+		Summary: "Create a widget", Body: widgetRequest{}, Status: http.StatusCreated, Response: widgetRequest{},
+		Errors: []int{http.StatusConflict},
+	})
+	reg.Describe(widgetHandler.GetWidget, openapi.Operation{Path: widgetUri{}, Query: widgetQuery{}, Response: widgetRequest{}})
+	reg.Describe(widgetHandler.UploadForm, openapi.Operation{
+		Body: widgetForm{}, BodyKind: openapi.MultipartBody,
+		Files: []openapi.FileField{{Name: "images", Multiple: true}},
+	})
+
+	data, err := json.Marshal(openapi.Generate(r.Routes(), reg, openapi.Info{Title: "Widgets", Version: "1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// at walks the document along keys (object keys or array indexes)
+func at(t *testing.T, v any, keys ...any) any {
+	t.Helper()
+	for _, k := range keys {
+		switch k := k.(type) {
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				t.Fatalf("%v: not an object at %q", keys, k)
+			}
+			v = m[k]
+		case int:
+			a, ok := v.([]any)
+			if !ok || k >= len(a) {
+				t.Fatalf("%v: no item %d", keys, k)
+			}
+			v = a[k]
+		}
+	}
+	return v
+}
+
+func TestSchemaFromBindingTags(t *testing.T) {
+	schema := at(t, generate(t), "components", "schemas", "WidgetRequest")
+	prop := func(name string) any { return at(t, schema, "properties", name) }
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"embedded fields are flattened", at(t, prop("id"), "type"), "integer"},
+		{"min/max on a string", []any{at(t, prop("name"), "minLength"), at(t, prop("name"), "maxLength")}, []any{3.0, 50.0}},
+		{"email", at(t, prop("email"), "format"), "email"},
+		{"url", at(t, prop("site"), "format"), "uri"},
+		{"uuid", at(t, prop("ref"), "format"), "uuid"},
+		{"gt is exclusive", at(t, prop("price"), "exclusiveMinimum"), 0.0},
+		{"gte/lte on a number", []any{at(t, prop("stock"), "minimum"), at(t, prop("stock"), "maximum")}, []any{0.0, 1000.0}},
+		{"oneof of strings", at(t, prop("kind"), "enum"), []any{"plain", "fancy"}},
+		{"oneof of numbers", at(t, prop("level"), "enum"), []any{1.0, 2.0, 3.0}},
+		{"custom tag pattern", at(t, prop("slug"), "pattern"), `^[a-z0-9]+(-[a-z0-9]+)*$`},
+		{"max on a slice counts items", at(t, prop("colors"), "maxItems"), 3.0},
+		{"dive applies to items", []any{at(t, prop("colors"), "items", "minLength"), at(t, prop("colors"), "items", "maxLength")}, []any{1.0, 10.0}},
+		{"named structs are referenced", at(t, prop("tags"), "items", "$ref"), "#/components/schemas/Tag"},
+		{"keys rules go to property names", at(t, prop("labels"), "propertyNames", "maxLength"), 8.0},
+		{"rules after endkeys go to values", at(t, prop("labels"), "additionalProperties", "maxLength"), 30.0},
+		{"date layout", at(t, prop("born"), "format"), "date"},
+		{"pointers are their element", at(t, prop("optional"), "type"), "integer"},
+		{"json:\"-\" is left out", prop("Secret"), nil},
+		{"unexported fields are left out", prop("internal"), nil},
+		{"required fields", at(t, schema, "required"), []any{"name", "price"}},
+		{"referenced struct has its own rules", at(t, generate(t), "components", "schemas", "Tag", "properties", "name", "minLength"), 2.0},
+	}
+	for _, tc := range tests {
+		if !reflect.DeepEqual(tc.got, tc.want) {
+			t.Errorf("%s: got %#v, want %#v", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestOperations(t *testing.T) {
+	paths := at(t, generate(t), "paths")
+
+	create := at(t, paths, "/widgets", "post")
+	if id := at(t, create, "operationId"); id != "createWidget" {
+		t.Errorf("operationId %v", id)
+	}
+	if ref := at(t, create, "requestBody", "content", "application/json", "schema", "$ref"); ref != "#/components/schemas/WidgetRequest" {
+		t.Errorf("body %v", ref)
+	}
+	for _, code := range []string{"201", "400", "409"} {
+		if at(t, create, "responses", code) == nil {
+			t.Errorf("no %s response", code)
+		}
+	}
+
+	get := at(t, paths, "/widgets/{id}", "get")
+	params := map[string]any{}
+	for _, p := range at(t, get, "parameters").([]any) {
+		params[at(t, p, "in").(string)+" "+at(t, p, "name").(string)] = p
+	}
+	if p := params["path id"]; at(t, p, "schema", "type") != "integer" || at(t, p, "schema", "minimum") != 1.0 || at(t, p, "required") != true {
+		t.Errorf("path id %v", p)
+	}
+	if p := params["query page"]; at(t, p, "required") != true || at(t, p, "schema", "minimum") != 1.0 {
+		t.Errorf("query page %v", p)
+	}
+	if p := params["query search"]; at(t, p, "required") != nil || at(t, p, "schema", "maxLength") != 100.0 {
+		t.Errorf("query search %v", p)
+	}
+
+	form := at(t, paths, "/widgets/{id}/form", "post", "requestBody", "content")
+	if at(t, form, "application/x-www-form-urlencoded") != nil {
+		t.Error("files can't go urlencoded")
+	}
+	multipart := at(t, form, "multipart/form-data", "schema")
+	if at(t, multipart, "properties", "images", "type") != "array" || !reflect.DeepEqual(at(t, multipart, "required"), []any{"title", "images"}) {
+		t.Errorf("multipart %v", multipart)
+	}
+
+	// undocumented routes keep their path parameters
+	static := at(t, paths, "/static/{filepath}", "get")
+	if id := at(t, static, "operationId"); id != "getStaticFilepath" {
+		t.Errorf("operationId %v", id)
+	}
+	if at(t, static, "parameters", 0, "name") != "filepath" {
+		t.Errorf("parameters %v", at(t, static, "parameters"))
+	}
+}
+
+// The docs page works offline: everything it runs is served with it
+func TestDocsPageIsSelfContained(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	openapi.Register(r, openapi.NewRegistry(), openapi.Info{Title: "Widgets", Version: "1"}, "/openapi.json", "/docs")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	page := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(page, `data-spec-url="/openapi.json"`) || !strings.Contains(page, "function schemaView") {
+		t.Fatalf("%d:\n%s", w.Code, page)
+	}
+	if strings.Contains(page, "{{") || strings.Contains(page, "src=") || strings.Contains(page, "https://") {
+		t.Error("the page loads something from elsewhere, or has a placeholder left")
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'none'") {
+		t.Errorf("Content-Security-Policy %q", csp)
+	}
+}
@@ -0,0 +1,67 @@
+package openapi
+
+import (
+	"embed"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The docs page and its script are served from here, nothing is loaded from elsewhere
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// RedocVersion is the Redoc release vendored in ui/redoc. To update, bump it
+// and the go:generate line below, then run go generate.
+const RedocVersion = "2.1.5"
+
+//go:generate sh -c "curl -fsSL https://cdn.jsdelivr.net/npm/redoc@2.1.5/bundles/redoc.standalone.js -o ui/redoc/redoc.standalone.js"
+
+const redocBundle = "ui/redoc/redoc.standalone.js"
+
+// Register serves the document at specPath and the docs page at uiPath.
+// The document is built on the first request, once every route is registered.
+func Register(r *gin.Engine, reg *Registry, info Info, specPath, uiPath string) {
+	var (
+		once sync.Once
+		doc  *Document
+	)
+	r.GET(specPath, func(c *gin.Context) {
+		once.Do(func() { doc = Generate(r.Routes(), reg, info) })
+		c.JSON(http.StatusOK, doc)
+	})
+
+	if bundle, err := uiFiles.ReadFile(redocBundle); err == nil {
+		registerRedoc(r, bundle, specPath, uiPath)
+		return
+	}
+
+	// without the bundle, the small viewer of ui/docs.js
+	page, _ := uiFiles.ReadFile("ui/index.html")
+	style, _ := uiFiles.ReadFile("ui/docs.css")
+	script, _ := uiFiles.ReadFile("ui/docs.js")
+	html := strings.NewReplacer("{{SPEC_URL}}", specPath, "{{STYLE}}", string(style), "{{SCRIPT}}", string(script)).Replace(string(page))
+	r.GET(uiPath, func(c *gin.Context) {
+		c.Header("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+	})
+}
+
+// registerRedoc serves the Redoc page at uiPath and its bundle next to it.
+// Redoc styles inline and renders in a blob: worker, the CSP lets it.
+func registerRedoc(r *gin.Engine, bundle []byte, specPath, uiPath string) {
+	bundlePath := strings.TrimSuffix(uiPath, "/") + "/redoc.standalone.js"
+	page, _ := uiFiles.ReadFile("ui/redoc.html")
+	html := strings.NewReplacer("{{SPEC_URL}}", specPath, "{{BUNDLE_URL}}", bundlePath).Replace(string(page))
+	r.GET(uiPath, func(c *gin.Context) {
+		c.Header("Content-Security-Policy", "default-src 'none'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; font-src 'self' data:; worker-src blob:; connect-src 'self'")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+	})
+	r.GET(bundlePath, func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Data(http.StatusOK, "text/javascript; charset=utf-8", bundle)
+	})
+}
@@ -0,0 +1,36 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// With the bundle vendored, the page loads it from us and nowhere else
+func TestRedocPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRedoc(r, []byte("/* redoc */"), "/openapi.json", "/docs")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	page := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(page, `spec-url="/openapi.json"`) || !strings.Contains(page, `src="/docs/redoc.standalone.js"`) {
+		t.Fatalf("%d:\n%s", w.Code, page)
+	}
+	if strings.Contains(page, "{{") || strings.Contains(page, "https://") {
+		t.Error("the page loads something from elsewhere, or has a placeholder left")
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "script-src 'self'") {
+		t.Errorf("Content-Security-Policy %q", csp)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/redoc.standalone.js", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "/* redoc */" {
+		t.Errorf("bundle %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,80 @@
+package openapi
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+type BodyKind string
+
+const (
+	JSONBody      BodyKind = "json"
+	FormBody      BodyKind = "form"      // urlencoded or multipart, no files
+	MultipartBody BodyKind = "multipart" // form fields plus Files
+)
+
+// Operation describes one handler. Path, Query and Body are zero values of
+// the DTOs the handler binds, e.g. Query: dto.ProductQuery{}.
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Path        any // struct with `uri` tags
+	Query       any // struct with `form` tags, read from the query string
+	Body        any // struct with `json` tags, or `form` tags for FormBody/MultipartBody
+	BodyKind    BodyKind
+	Files       []FileField
+	Headers     []HeaderParam
+	Status      int      // success status, 200 when zero
+	Response    any      // success body
+	Produces    []string // media types of a streamed success body, Response is then one NDJSON line
+	Errors      []int
+}
+
+type FileField struct {
+	Name        string
+	Multiple    bool
+	Description string
+}
+
+type HeaderParam struct {
+	Name        string
+	Required    bool
+	Description string
+}
+
+// Registry holds the Operation of every documented handler, keyed by the
+// handler's function name so the paths come from the gin engine itself.
+type Registry struct {
+	ops      map[string]Operation
+	patterns map[string]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{ops: map[string]Operation{}, patterns: map[string]string{}}
+}
+
+// Describe documents handler, a method expression like (*ProductHandler).GetProducts
+func (r *Registry) Describe(handler any, op Operation) {
+	r.ops[funcName(handler)] = op
+}
+
+// Pattern tells the generator which regex a custom validator tag enforces,
+// e.g. Pattern("slug", utils.SlugPattern)
+func (r *Registry) Pattern(tag, pattern string) {
+	r.patterns[tag] = pattern
+}
+
+// Lookup finds the Operation for a gin RouteInfo.Handler name
+func (r *Registry) Lookup(handlerName string) (Operation, bool) {
+	op, ok := r.ops[strings.TrimSuffix(handlerName, "-fm")]
+	return op, ok
+}
+
+// funcName gives the same name gin puts in RouteInfo.Handler, minus the -fm
+// that Go appends to method values (h.GetProducts)
+func funcName(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	return strings.TrimSuffix(name, "-fm")
+}
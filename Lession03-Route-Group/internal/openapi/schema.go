@@ -0,0 +1,300 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaBuilder turns Go types into schemas. Named structs go to
+// components/schemas once and are referenced with $ref after that.
+type schemaBuilder struct {
+	components map[string]*Schema
+	patterns   map[string]string
+}
+
+// field is one struct field as the binding sees it
+type field struct {
+	name  string
+	typ   reflect.Type
+	rules []string
+}
+
+// fields lists the fields of t under tag (json, form or uri), flattening
+// embedded structs like encoding/json and gin do
+func fields(t reflect.Type, tag string) []field {
+	var out []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get(tag), ",")
+		if name == "-" {
+			continue
+		}
+
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				out = append(out, fields(ft, tag)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			if tag == "uri" {
+				continue // gin only binds uri fields that have the tag
+			}
+			name = sf.Name
+		}
+		out = append(out, field{name: name, typ: ft, rules: rules(sf.Tag)})
+	}
+	return out
+}
+
+// rules merges the `binding` (gin) and `validate` (our own validator) tags
+func rules(tag reflect.StructTag) []string {
+	var out []string
+	for _, key := range []string{"binding", "validate"} {
+		if v := tag.Get(key); v != "" {
+			out = append(out, strings.Split(v, ",")...)
+		}
+	}
+	return out
+}
+
+// ref returns a $ref for named structs and an inline schema for the rest
+func (b *schemaBuilder) ref(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || t.Name() == "" {
+		return b.inline(t)
+	}
+
+	name := schemaName(t)
+	if _, ok := b.components[name]; !ok {
+		b.components[name] = &Schema{} // placeholder, stops recursion on self references
+		b.components[name] = b.object(t, "json")
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (b *schemaBuilder) inline(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.ref(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.ref(t.Elem())}
+	case reflect.Struct:
+		return b.object(t, "json")
+	default:
+		return &Schema{} // interface{}: anything
+	}
+}
+
+// object builds an object schema from the fields under tag
+func (b *schemaBuilder) object(t reflect.Type, tag string) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, f := range fields(t, tag) {
+		prop := b.ref(f.typ)
+		// on a $ref only "required" matters, the referenced schema has its own rules
+		if applyRules(prop, f.typ, f.rules, b.patterns) {
+			s.Required = append(s.Required, f.name)
+		}
+		s.Properties[f.name] = prop
+	}
+	return s
+}
+
+// applyRules writes validator rules onto s and reports whether the field is required.
+// Rules after "dive" apply to the items of a slice or the values of a map,
+// rules between "keys" and "endkeys" to the map keys.
+func applyRules(s *Schema, t reflect.Type, rules []string, patterns map[string]string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	required := false
+	for i := 0; i < len(rules); i++ {
+		name, param, _ := strings.Cut(strings.TrimSpace(rules[i]), "=")
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			rest := rules[i+1:]
+			switch {
+			case t.Kind() == reflect.Map && s.AdditionalProperties != nil:
+				if len(rest) > 0 && rest[0] == "keys" {
+					end := indexOf(rest, "endkeys")
+					if end < 0 {
+						end = len(rest)
+					}
+					if s.PropertyNames == nil {
+						s.PropertyNames = &Schema{Type: "string"}
+					}
+					applyRules(s.PropertyNames, reflect.TypeOf(""), rest[1:end], patterns)
+					rest = rest[min(end+1, len(rest)):]
+				}
+				if s.AdditionalProperties.Ref == "" {
+					applyRules(s.AdditionalProperties, t.Elem(), rest, patterns)
+				}
+			case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && s.Items != nil:
+				if s.Items.Ref == "" {
+					applyRules(s.Items, t.Elem(), rest, patterns)
+				}
+			}
+			return required
+		default:
+			applyRule(s, t, name, param, patterns)
+		}
+	}
+	return required
+}
+
+func applyRule(s *Schema, t reflect.Type, name, param string, patterns map[string]string) {
+	if s.Ref != "" {
+		return
+	}
+	n, numErr := strconv.ParseFloat(param, 64)
+	hasNum := numErr == nil
+
+	switch name {
+	case "min", "max", "len", "gt", "gte", "lt", "lte":
+		if !hasNum {
+			return
+		}
+		bound(s, t, name, n)
+	case "oneof":
+		for _, v := range strings.Fields(param) {
+			if s.Type == "integer" || s.Type == "number" {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					s.Enum = append(s.Enum, f)
+					continue
+				}
+			}
+			s.Enum = append(s.Enum, v)
+		}
+	case "email":
+		s.Format = "email"
+	case "url", "uri", "http_url":
+		s.Format = "uri"
+	case "uuid", "uuid4":
+		s.Format = "uuid"
+	case "datetime":
+		if param == "2006-01-02" {
+			s.Format = "date"
+		} else {
+			s.Description = strings.TrimSpace(s.Description + " Layout: " + param)
+		}
+	case "base64rawurl":
+		s.Pattern = `^[A-Za-z0-9_-]*$`
+	default:
+		if p, ok := patterns[name]; ok {
+			s.Pattern = p
+		}
+	}
+}
+
+// bound maps min/max/gt/... to length, item count or value depending on the type
+func bound(s *Schema, t reflect.Type, name string, n float64) {
+	i := int(n)
+	switch t.Kind() {
+	case reflect.String:
+		switch name {
+		case "min", "gte":
+			s.MinLength = &i
+		case "gt":
+			i++
+			s.MinLength = &i
+		case "max", "lte":
+			s.MaxLength = &i
+		case "lt":
+			i--
+			s.MaxLength = &i
+		case "len":
+			s.MinLength, s.MaxLength = &i, &i
+		}
+	case reflect.Map:
+		// minProperties/maxProperties: not worth it for the DTOs we have
+	case reflect.Slice, reflect.Array:
+		switch name {
+		case "min", "gte":
+			s.MinItems = &i
+		case "gt":
+			i++
+			s.MinItems = &i
+		case "max", "lte":
+			s.MaxItems = &i
+		case "lt":
+			i--
+			s.MaxItems = &i
+		case "len":
+			s.MinItems, s.MaxItems = &i, &i
+		}
+	default:
+		switch name {
+		case "min", "gte":
+			s.Minimum = &n
+		case "gt":
+			s.ExclusiveMinimum = &n
+		case "max", "lte":
+			s.Maximum = &n
+		case "lt":
+			s.ExclusiveMaximum = &n
+		case "len":
+			s.Minimum, s.Maximum = &n, &n
+		}
+	}
+}
+
+// schemaName gives readable component names, also for generic types:
+// listquery.Page[dto.ProductSearchResult] becomes PageProductSearchResult
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	base, args, generic := strings.Cut(name, "[")
+	if generic {
+		for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+			arg = arg[strings.LastIndex(arg, ".")+1:]
+			base += strings.TrimLeft(arg, "*[]")
+		}
+	}
+	r := []rune(base)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func indexOf(list []string, v string) int {
+	for i, x := range list {
+		if strings.TrimSpace(x) == v {
+			return i
+		}
+	}
+	return -1
+}
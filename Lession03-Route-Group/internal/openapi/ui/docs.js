@@ -0,0 +1,148 @@
+// Renders the OpenAPI document of data-spec-url: operations by tag, their
+// parameters, bodies and responses. Only what package openapi generates.
+(function () {
+  "use strict";
+
+  var spec;
+
+  function el(tag, attrs, children) {
+    var e = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (k) {
+      if (k === "text") e.textContent = attrs[k];
+      else e.setAttribute(k, attrs[k]);
+    });
+    (children || []).forEach(function (c) {
+      if (c) e.appendChild(typeof c === "string" ? document.createTextNode(c) : c);
+    });
+    return e;
+  }
+
+  function resolve(schema) {
+    var seen = 0;
+    while (schema && schema.$ref && seen++ < 20) {
+      schema = spec.components.schemas[schema.$ref.split("/").pop()] || {};
+    }
+    return schema || {};
+  }
+
+  function typeName(schema) {
+    if (schema.$ref) return schema.$ref.split("/").pop();
+    var t = Array.isArray(schema.type) ? schema.type.join(" | ") : schema.type || "any";
+    if (t === "array" && schema.items) return typeName(schema.items) + "[]";
+    if (t === "object" && schema.additionalProperties) return "map of " + typeName(schema.additionalProperties);
+    return schema.format ? t + " (" + schema.format + ")" : t;
+  }
+
+  // rules lists the constraints the generator derived from binding tags
+  function rules(s) {
+    var out = [];
+    if (s.enum) out.push("one of " + s.enum.join(", "));
+    if (s.pattern) out.push("pattern " + s.pattern);
+    [["minLength", "min length"], ["maxLength", "max length"], ["minimum", "≥"], ["maximum", "≤"],
+     ["exclusiveMinimum", ">"], ["exclusiveMaximum", "<"], ["minItems", "min items"], ["maxItems", "max items"]]
+      .forEach(function (r) { if (s[r[0]] !== undefined) out.push(r[1] + " " + s[r[0]]); });
+    if (s.propertyNames && rules(s.propertyNames)) out.push("keys: " + rules(s.propertyNames));
+    return out.join(", ");
+  }
+
+  // schemaView is a table of the properties of an object, nested objects
+  // fold out on demand so self references don't recurse
+  function schemaView(schema) {
+    var s = resolve(schema);
+    if (s.type === "array" && s.items) s = resolve(s.items);
+    if (s.additionalProperties && !s.properties) s = resolve(s.additionalProperties);
+    if (!s.properties) return el("p", {}, [el("code", { text: typeName(schema) })]);
+
+    var required = s.required || [];
+    var rows = Object.keys(s.properties).sort().map(function (name) {
+      var p = s.properties[name];
+      var target = resolve(p.items || p.additionalProperties || p);
+      var nested = target.properties ? el("details", {}, [el("summary", { text: typeName(p) })]) : null;
+      if (nested) {
+        nested.addEventListener("toggle", function once() {
+          nested.removeEventListener("toggle", once);
+          nested.appendChild(schemaView(p));
+        });
+      }
+      return el("tr", {}, [
+        el("td", {}, [el("code", { text: name }), required.indexOf(name) >= 0 ? el("div", { class: "required", text: "required" }) : null]),
+        el("td", {}, [nested || el("code", { text: typeName(p) })]),
+        el("td", {}, [p.description || "", el("div", { class: "rules", text: rules(resolve(p)) })]),
+      ]);
+    });
+    return el("table", {}, [el("tr", {}, [el("th", { text: "field" }), el("th", { text: "type" }), el("th", { text: "" })])].concat(rows));
+  }
+
+  function operationView(method, path, op) {
+    var section = el("section", { id: op.operationId }, [
+      el("h3", {}, [el("span", { class: "method " + method, text: method.toUpperCase() }), el("code", { text: path })]),
+      op.summary ? el("p", { text: op.summary }) : null,
+      op.description ? el("pre", { text: op.description }) : null,
+    ]);
+
+    if (op.parameters && op.parameters.length) {
+      section.appendChild(el("table", {}, [el("tr", {}, [el("th", { text: "parameter" }), el("th", { text: "in" }), el("th", { text: "type" }), el("th", { text: "" })])]
+        .concat(op.parameters.map(function (p) {
+          return el("tr", {}, [
+            el("td", {}, [el("code", { text: p.name }), p.required ? el("div", { class: "required", text: "required" }) : null]),
+            el("td", { text: p.in }),
+            el("td", {}, [el("code", { text: typeName(p.schema || {}) })]),
+            el("td", {}, [p.description || "", el("div", { class: "rules", text: rules(p.schema || {}) })]),
+          ]);
+        }))));
+    }
+    if (op.requestBody) {
+      Object.keys(op.requestBody.content).forEach(function (mt) {
+        section.appendChild(el("h4", { text: "Body " + mt }));
+        section.appendChild(schemaView(op.requestBody.content[mt].schema));
+      });
+    }
+    Object.keys(op.responses).sort().forEach(function (code) {
+      var res = op.responses[code];
+      var d = el("details", {}, [el("summary", { text: code + " " + res.description })]);
+      Object.keys(res.content || {}).forEach(function (mt) {
+        d.appendChild(el("div", { class: "rules", text: mt }));
+        d.appendChild(schemaView(res.content[mt].schema));
+      });
+      section.appendChild(d);
+    });
+    return section;
+  }
+
+  function render() {
+    var nav = document.getElementById("nav");
+    var main = document.getElementById("main");
+    main.textContent = "";
+    main.appendChild(el("h1", { text: spec.info.title + " " + spec.info.version }));
+    nav.appendChild(el("a", { href: document.body.dataset.specUrl, text: "openapi.json" }));
+
+    var byTag = {};
+    Object.keys(spec.paths).sort().forEach(function (path) {
+      Object.keys(spec.paths[path]).forEach(function (method) {
+        var op = spec.paths[path][method];
+        var tag = (op.tags && op.tags[0]) || "other";
+        (byTag[tag] = byTag[tag] || []).push([method, path, op]);
+      });
+    });
+    Object.keys(byTag).sort().forEach(function (tag) {
+      nav.appendChild(el("h2", { text: tag }));
+      main.appendChild(el("h2", { text: tag }));
+      byTag[tag].forEach(function (o) {
+        nav.appendChild(el("a", { href: "#" + o[2].operationId, title: o[1] }, [el("span", { class: "method " + o[0], text: o[0].toUpperCase() }), o[2].summary || o[1]]));
+        main.appendChild(operationView(o[0], o[1], o[2]));
+      });
+    });
+    if (location.hash) {
+      var target = document.getElementById(location.hash.slice(1));
+      if (target) target.scrollIntoView();
+    }
+  }
+
+  fetch(document.body.dataset.specUrl)
+    .then(function (res) {
+      if (!res.ok) throw new Error(res.status + " " + res.statusText);
+      return res.json();
+    })
+    .then(function (doc) { spec = doc; render(); })
+    .catch(function (err) { document.getElementById("main").textContent = "Failed to load the API document: " + err.message; });
+})();
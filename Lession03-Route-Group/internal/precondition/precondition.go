@@ -0,0 +1,75 @@
+// Package precondition implements ETag based optimistic concurrency:
+// ETag on reads, If-None-Match -> 304, and If-Match required on writes.
+package precondition
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+)
+
+// ETag turns an entity version into a strong ETag, e.g. "v3"
+func ETag(version int) string {
+	return fmt.Sprintf(`"v%d"`, version)
+}
+
+// NotModified sets the ETag header, and answers 304 when If-None-Match already
+// names this version. The handler should stop when it returns true.
+func NotModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	// If-None-Match uses the weak comparison, W/"v3" matches "v3"
+	for _, tag := range splitTags(header) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// IfMatch checks that the client is writing over the version it has seen.
+// Without If-Match it answers 428, on a stale version 412, and returns false.
+func IfMatch(c *gin.Context, etag string) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		content.Abort(c, http.StatusPreconditionRequired, gin.H{
+			"error": "If-Match header is required, send the ETag you got from GET",
+		})
+		return false
+	}
+	// If-Match uses the strong comparison, a weak tag never matches
+	for _, tag := range splitTags(header) {
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	Failed(c, etag)
+	return false
+}
+
+// Failed answers 412 with the current ETag, so the client knows what to re-fetch
+func Failed(c *gin.Context, etag string) {
+	c.Header("ETag", etag)
+	content.Abort(c, http.StatusPreconditionFailed, gin.H{
+		"error": "Resource was modified by someone else, fetch it again and retry",
+	})
+}
+
+func splitTags(header string) []string {
+	parts := strings.Split(header, ",")
+	tags := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			tags = append(tags, p)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,298 @@
+// Package remoteimage checks that an image URL really points at an image
+// (the imgext tag only looks at how the URL ends) and can copy it into our
+// own storage. Requests only go to public addresses, see Config.
+package remoteimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrScheme     = errors.New("only http and https image URLs are allowed")
+	ErrBlocked    = errors.New("image URL points at a private or local address")
+	ErrRedirects  = errors.New("image URL redirects too many times")
+	ErrStatus     = errors.New("image URL did not answer 200")
+	ErrNotAnImage = errors.New("image URL does not serve a JPEG or PNG image")
+	ErrTooLarge   = errors.New("image at URL is too large")
+)
+
+// Rejected reports whether err is one of the above, a verdict on the URL,
+// rather than a failure to reach it that may pass on another try
+func Rejected(err error) bool {
+	for _, verdict := range []error{ErrScheme, ErrBlocked, ErrRedirects, ErrStatus, ErrNotAnImage, ErrTooLarge} {
+		if errors.Is(err, verdict) {
+			return true
+		}
+	}
+	return false
+}
+
+// Content types we accept and the extension an ingested file gets
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+type Config struct {
+	Timeout      time.Duration // whole request, redirects included; default 5s
+	MaxBytes     int64         // default 2MB, same as uploads
+	MaxRedirects int           // default 3
+	// AllowPrivate lets requests reach loopback and private networks.
+	// Only for tests against httptest servers, never in production.
+	AllowPrivate bool
+}
+
+// Verifier is safe for concurrent use
+type Verifier struct {
+	cfg    Config
+	client *http.Client
+}
+
+func New(cfg Config) *Verifier {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 2 << 20
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = 3
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = PublicOnly
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &Verifier{
+		cfg: cfg,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) > cfg.MaxRedirects {
+					return ErrRedirects
+				}
+				return checkScheme(req.URL)
+			},
+		},
+	}
+}
+
+// PublicOnly is a net.Dialer Control refusing loopback, private and
+// reserved addresses with ErrBlocked. It checks the address actually
+// dialled, after DNS, so a name that resolves (or later re-resolves) to
+// 127.0.0.1 is caught too.
+func PublicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublic(ip) {
+		return ErrBlocked
+	}
+	return nil
+}
+
+var blockedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"100.64.0.0/10", // carrier-grade NAT
+		"192.0.0.0/24",  // IETF protocol assignments
+		"198.18.0.0/15", // benchmarking
+		"240.0.0.0/4",   // reserved
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+func isPublic(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4 // so ::ffff:127.0.0.1 counts as loopback
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrScheme
+	}
+	return nil
+}
+
+// Image is what Verify found at the URL
+type Image struct {
+	URL         string // after redirects
+	ContentType string // sniffed from the bytes, not taken from the header
+	Size        int64
+	data        []byte
+}
+
+// Verify HEADs the URL to turn away obvious misses cheaply (wrong type, too
+// large) and then GETs it, sniffing the content type from the bytes
+// themselves: a server saying image/png about an HTML page doesn't pass.
+func (v *Verifier) Verify(ctx context.Context, rawURL string) (*Image, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScheme(u); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, v.cfg.Timeout)
+	defer cancel()
+
+	if err := v.head(ctx, u.String()); err != nil {
+		return nil, err
+	}
+	return v.get(ctx, u.String())
+}
+
+func (v *Verifier) head(ctx context.Context, rawURL string) error {
+	res, err := v.send(ctx, http.MethodHead, rawURL)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	// Plenty of servers don't do HEAD, the GET decides then
+	if res.StatusCode != http.StatusOK {
+		return nil
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "" {
+		if _, ok := allowedTypes[mediaType(ct)]; !ok {
+			return fmt.Errorf("%w (served as %s)", ErrNotAnImage, mediaType(ct))
+		}
+	}
+	if res.ContentLength > v.cfg.MaxBytes {
+		return ErrTooLarge
+	}
+	return nil
+}
+
+func (v *Verifier) get(ctx context.Context, rawURL string) (*Image, error) {
+	res, err := v.send(ctx, http.MethodGet, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w (got %d)", ErrStatus, res.StatusCode)
+	}
+	if res.ContentLength > v.cfg.MaxBytes {
+		return nil, ErrTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, v.cfg.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > v.cfg.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	ct := http.DetectContentType(data)
+	if _, ok := allowedTypes[ct]; !ok {
+		return nil, fmt.Errorf("%w (looks like %s)", ErrNotAnImage, mediaType(ct))
+	}
+	return &Image{URL: res.Request.URL.String(), ContentType: ct, Size: int64(len(data)), data: data}, nil
+}
+
+func (v *Verifier) send(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/png, image/jpeg")
+	res, err := v.client.Do(req)
+	if err != nil {
+		// Get the sentinel back out of *url.Error and the dialer's *net.OpError
+		for _, sentinel := range []error{ErrBlocked, ErrRedirects, ErrScheme} {
+			if errors.Is(err, sentinel) {
+				return nil, sentinel
+			}
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// "image/png; charset=binary" -> "image/png"
+func mediaType(ct string) string {
+	mt, _, _ := strings.Cut(ct, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// VerifyAll checks the URLs concurrently, keyed like the input (field name ->
+// URL), and returns the ones that failed with their error
+func (v *Verifier) VerifyAll(ctx context.Context, urls map[string]string) map[string]error {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed = map[string]error{}
+	)
+	for key, u := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.Verify(ctx, u); err != nil {
+				mu.Lock()
+				failed[key] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return failed
+}
+
+// Ingest verifies the URL and saves the image in dir under a new UUID name,
+// which it returns
+func (v *Verifier) Ingest(ctx context.Context, rawURL, dir string) (string, error) {
+	img, err := v.Verify(ctx, rawURL)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	name := uuid.NewString() + allowedTypes[img.ContentType]
+	if err := os.WriteFile(filepath.Join(dir, name), img.data, 0o644); err != nil {
+		return "", err
+	}
+	return name, nil
+}
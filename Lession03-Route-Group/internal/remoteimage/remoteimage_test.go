@@ -0,0 +1,162 @@
+package remoteimage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var png = append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...)
+
+func imageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	})
+	mux.HandleFunc("/no-head.png", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Write(png)
+	})
+	mux.HandleFunc("/evil.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png") // lies
+		w.Write([]byte("<!doctype html><p>not an image</p>"))
+	})
+	mux.HandleFunc("/page.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<p>hi</p>"))
+	})
+	mux.HandleFunc("/big.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(append(png, bytes.Repeat([]byte{0}, 4096)...))
+	})
+	mux.HandleFunc("/gone.png", http.NotFound)
+	mux.HandleFunc("/loop.png", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop.png", http.StatusFound)
+	})
+	mux.HandleFunc("/moved.png", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok.png", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/to-file.png", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+	})
+	mux.HandleFunc("/slow.png", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVerify(t *testing.T) {
+	srv := imageServer(t)
+	v := New(Config{AllowPrivate: true, MaxBytes: 1024, Timeout: 300 * time.Millisecond})
+
+	tests := []struct {
+		path string
+		want error
+	}{
+		{"/ok.png", nil},
+		{"/no-head.png", nil},
+		{"/moved.png", nil},
+		{"/evil.png", ErrNotAnImage},
+		{"/page.png", ErrNotAnImage},
+		{"/big.png", ErrTooLarge},
+		{"/gone.png", ErrStatus},
+		{"/loop.png", ErrRedirects},
+		{"/to-file.png", ErrScheme},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			img, err := v.Verify(context.Background(), srv.URL+tt.path)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if err == nil && img.ContentType != "image/png" {
+				t.Errorf("content type = %q", img.ContentType)
+			}
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		if _, err := v.Verify(context.Background(), srv.URL+"/slow.png"); err == nil {
+			t.Fatal("want a timeout")
+		}
+	})
+	t.Run("scheme", func(t *testing.T) {
+		if _, err := v.Verify(context.Background(), "ftp://example.com/a.png"); !errors.Is(err, ErrScheme) {
+			t.Fatalf("err = %v, want ErrScheme", err)
+		}
+	})
+}
+
+func TestVerifyBlocksPrivateAddresses(t *testing.T) {
+	srv := imageServer(t)
+	v := New(Config{})
+
+	// httptest listens on 127.0.0.1, which is exactly what must not be reachable
+	if _, err := v.Verify(context.Background(), srv.URL+"/ok.png"); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("err = %v, want ErrBlocked", err)
+	}
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	if _, err := v.Verify(context.Background(), "http://localhost:"+port+"/ok.png"); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("localhost: err = %v, want ErrBlocked", err)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for ip, want := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false, // cloud metadata
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+		"fd00::1":          false,
+		"fe80::1":          false,
+	} {
+		if got := isPublic(net.ParseIP(ip)); got != want {
+			t.Errorf("isPublic(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestIngest(t *testing.T) {
+	srv := imageServer(t)
+	v := New(Config{AllowPrivate: true})
+	dir := t.TempDir()
+
+	name, err := v.Ingest(context.Background(), srv.URL+"/no-head.png", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Ext(name) != ".png" {
+		t.Errorf("name = %q, want a .png", name)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil || !bytes.Equal(got, png) {
+		t.Errorf("saved %d bytes (%v), want the served image", len(got), err)
+	}
+
+	if _, err := v.Ingest(context.Background(), srv.URL+"/evil.png", dir); !errors.Is(err, ErrNotAnImage) {
+		t.Errorf("err = %v, want ErrNotAnImage", err)
+	}
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// CategoryRepository keeps the category tree as materialised paths: every
+// category stores the IDs from the root down to itself, "/1/4/7/". A subtree
+// is then every category whose Path starts with the root's Path, and sorting
+// by Path lists it parents first.
+type CategoryRepository struct {
+	mu     sync.RWMutex
+	nextID int
+	items  map[int]models.Category
+}
+
+func NewCategoryRepository() *CategoryRepository {
+	return &CategoryRepository{items: map[int]models.Category{}}
+}
+
+func childPath(parentPath string, id int) string {
+	if parentPath == "" {
+		parentPath = "/"
+	}
+	return parentPath + strconv.Itoa(id) + "/"
+}
+
+// Create assigns the ID, path and timestamps, then stores a copy of cat.
+// cat.ParentID must be 0 or an existing category, otherwise ErrNotFound.
+func (r *CategoryRepository) Create(cat *models.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	parent, ok := r.items[cat.ParentID]
+	if cat.ParentID != 0 && !ok {
+		return ErrNotFound
+	}
+
+	r.nextID++
+	cat.ID = r.nextID
+	cat.Path = childPath(parent.Path, cat.ID)
+	cat.Depth = strings.Count(cat.Path, "/") - 2
+	cat.Version = 1
+	if cat.CreatedAt.IsZero() {
+		cat.CreatedAt = time.Now()
+	}
+	cat.UpdatedAt = cat.CreatedAt
+
+	r.items[cat.ID] = *cat
+	return nil
+}
+
+// Move puts the category (and its whole subtree) under parentID, 0 for the
+// top level. version must be the one that was read, like ProductRepository.Update.
+// Moving a category under itself or its own subtree is ErrCycle.
+func (r *CategoryRepository) Move(id, parentID, version int) (models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cat, ok := r.items[id]
+	if !ok {
+		return models.Category{}, ErrNotFound
+	}
+	if cat.Version != version {
+		return models.Category{}, ErrVersionConflict
+	}
+	parent, ok := r.items[parentID]
+	if parentID != 0 && !ok {
+		return models.Category{}, ErrNotFound
+	}
+	if strings.HasPrefix(parent.Path, cat.Path) {
+		return models.Category{}, ErrCycle
+	}
+
+	oldPath, newPath := cat.Path, childPath(parent.Path, id)
+	now := time.Now()
+	for cid, c := range r.items {
+		if !strings.HasPrefix(c.Path, oldPath) {
+			continue
+		}
+		c.Path = newPath + strings.TrimPrefix(c.Path, oldPath)
+		c.Depth = strings.Count(c.Path, "/") - 2
+		c.Version++
+		c.UpdatedAt = now
+		if cid == id {
+			c.ParentID = parentID
+		}
+		r.items[cid] = c
+	}
+	return r.items[id], nil
+}
+
+// SetImage points the category at imageURL, "" for none. version must be the
+// one that was read, like Move.
+func (r *CategoryRepository) SetImage(id, version int, imageURL string) (models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cat, ok := r.items[id]
+	if !ok {
+		return models.Category{}, ErrNotFound
+	}
+	if cat.Version != version {
+		return models.Category{}, ErrVersionConflict
+	}
+	cat.ImageURL = imageURL
+	cat.Version++
+	cat.UpdatedAt = time.Now()
+	r.items[id] = cat
+	return cat, nil
+}
+
+func (r *CategoryRepository) FindByID(id int) (models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cat, ok := r.items[id]
+	if !ok {
+		return models.Category{}, ErrNotFound
+	}
+	return cat, nil
+}
+
+// Subtree returns the category and everything below it, parents before
+// their children
+func (r *CategoryRepository) Subtree(id int) ([]models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	root, ok := r.items[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var list []models.Category
+	for _, c := range r.items {
+		if strings.HasPrefix(c.Path, root.Path) {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list, nil
+}
+
+// Missing returns the IDs that aren't categories, in the order given
+func (r *CategoryRepository) Missing(ids []int) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var missing []int
+	for _, id := range ids {
+		if _, ok := r.items[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// FindAll returns every category ordered by ID
+func (r *CategoryRepository) FindAll() []models.Category {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]models.Category, 0, len(r.items))
+	for _, cat := range r.items {
+		list = append(list, cat)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+func TestCategoryTree(t *testing.T) {
+	r := NewCategoryRepository()
+	create := func(name string, parent int) models.Category {
+		t.Helper()
+		c := models.Category{Name: name, ParentID: parent}
+		if err := r.Create(&c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	clothes := create("Clothes", 0)
+	shirts := create("Shirts", clothes.ID)
+	tees := create("Tees", shirts.ID)
+	sale := create("Sale", 0)
+
+	if tees.Path != "/1/2/3/" || tees.Depth != 2 {
+		t.Fatalf("tees: path %q depth %d", tees.Path, tees.Depth)
+	}
+	if err := r.Create(&models.Category{Name: "Orphan", ParentID: 99}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown parent: err = %v, want ErrNotFound", err)
+	}
+
+	// cycles: under itself, under its child, under its grandchild
+	for _, parent := range []int{clothes.ID, shirts.ID, tees.ID} {
+		if _, err := r.Move(clothes.ID, parent, clothes.Version); !errors.Is(err, ErrCycle) {
+			t.Errorf("move under %d: err = %v, want ErrCycle", parent, err)
+		}
+	}
+
+	if _, err := r.Move(shirts.ID, sale.ID, shirts.Version); err != nil {
+		t.Fatal(err)
+	}
+	subtree, _ := r.Subtree(sale.ID)
+	var paths []string
+	for _, c := range subtree {
+		paths = append(paths, c.Path)
+	}
+	if want := []string{"/4/", "/4/2/", "/4/2/3/"}; len(paths) != 3 || paths[0] != want[0] || paths[1] != want[1] || paths[2] != want[2] {
+		t.Errorf("sale subtree = %v, want %v", paths, want)
+	}
+	if moved, _ := r.FindByID(tees.ID); moved.Depth != 2 || moved.ParentID != shirts.ID {
+		t.Errorf("tees after move: %+v", moved)
+	}
+	if rest, _ := r.Subtree(clothes.ID); len(rest) != 1 {
+		t.Errorf("clothes subtree has %d categories, want just itself", len(rest))
+	}
+
+	if _, err := r.Move(shirts.ID, 0, shirts.Version); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("stale move: err = %v, want ErrVersionConflict", err)
+	}
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// DefaultReservationTTL is how long a reservation holds stock when the caller doesn't say
+const DefaultReservationTTL = 15 * time.Minute
+
+// Reserve holds qty of the variant sku for ttl (DefaultReservationTTL when 0).
+// Check and hold happen under the write lock, so concurrent orders can't
+// reserve more than is available between them.
+func (r *ProductRepository) Reserve(productID int, sku string, qty int, ttl time.Duration) (models.Reservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expireReservations()
+
+	p, i, err := r.variant(productID, sku)
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	if p.Variants[i].Available() < qty {
+		return models.Reservation{}, ErrInsufficientStock
+	}
+	if ttl <= 0 {
+		ttl = DefaultReservationTTL
+	}
+
+	res := models.Reservation{
+		ID:        uuid.NewString(),
+		ProductID: productID,
+		SKU:       sku,
+		Quantity:  qty,
+		ExpiresAt: r.now().Add(ttl),
+	}
+	r.reservations[res.ID] = res
+	p.Variants[i].Reserved += qty
+	r.save(p)
+	return res, nil
+}
+
+// Commit turns the reservation into a sale: the quantity comes off the stock.
+// Unknown and expired reservations are ErrNotFound.
+func (r *ProductRepository) Commit(reservationID string) (models.Product, error) {
+	return r.settle(reservationID, true)
+}
+
+// Release gives the reserved quantity back, e.g. the order was cancelled
+func (r *ProductRepository) Release(reservationID string) (models.Product, error) {
+	return r.settle(reservationID, false)
+}
+
+func (r *ProductRepository) settle(reservationID string, sold bool) (models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expireReservations()
+
+	res, ok := r.reservations[reservationID]
+	if !ok {
+		return models.Product{}, ErrNotFound
+	}
+	p, i, err := r.variant(res.ProductID, res.SKU)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	delete(r.reservations, reservationID)
+	p.Variants[i].Reserved -= res.Quantity
+	if sold {
+		p.Variants[i].Stock -= res.Quantity
+	}
+	r.save(p)
+	return cloneProduct(p), nil
+}
+
+// variant finds the product and the index of its variant sku, r.mu must be held.
+// The product is a copy, ready to be changed and saved.
+func (r *ProductRepository) variant(productID int, sku string) (models.Product, int, error) {
+	p, ok := r.items[productID]
+	if !ok {
+		return models.Product{}, 0, ErrNotFound
+	}
+	p = cloneProduct(p)
+	for i, v := range p.Variants {
+		if v.SKU == sku {
+			return p, i, nil
+		}
+	}
+	return models.Product{}, 0, ErrNotFound
+}
+
+// save stores a stock change like Update would, r.mu must be held
+func (r *ProductRepository) save(p models.Product) {
+	p.Version++
+	p.UpdatedAt = r.now()
+	r.items[p.ID] = p
+	r.notify(Updated, p)
+}
+
+// expireReservations gives back the stock of reservations past their
+// ExpiresAt. Called at the start of every inventory operation, r.mu must be held.
+func (r *ProductRepository) expireReservations() {
+	now := r.now()
+	for id, res := range r.reservations {
+		if now.Before(res.ExpiresAt) {
+			continue
+		}
+		delete(r.reservations, id)
+		if p, i, err := r.variant(res.ProductID, res.SKU); err == nil {
+			p.Variants[i].Reserved -= res.Quantity
+			r.save(p)
+		}
+	}
+}
+
+// keepReserved copies the Reserved count of each old variant onto the new
+// one with the same SKU. Dropping a variant with reservations, or cutting its
+// stock below them, is ErrReserved.
+func keepReserved(old, updated []models.Variant) error {
+	reserved := make(map[string]int, len(old))
+	for _, v := range old {
+		if v.Reserved > 0 {
+			reserved[v.SKU] = v.Reserved
+		}
+	}
+	for i := range updated {
+		n := reserved[updated[i].SKU]
+		if updated[i].Stock < n {
+			return ErrReserved
+		}
+		updated[i].Reserved = n
+		delete(reserved, updated[i].SKU)
+	}
+	if len(reserved) > 0 {
+		return ErrReserved
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+func newTee(t *testing.T, r *ProductRepository, stock int) models.Product {
+	t.Helper()
+	p := models.Product{
+		Name: "Gopher Tee",
+		Variants: []models.Variant{{
+			SKU:    "TEE-L",
+			Prices: []models.Money{{Amount: 2500, Currency: "USD"}},
+			Stock:  stock,
+		}},
+	}
+	if err := r.Create(&p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func variantOf(t *testing.T, r *ProductRepository, id int) models.Variant {
+	t.Helper()
+	p, err := r.FindByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Variants[0]
+}
+
+func TestReserveIsSafeUnderConcurrentOrders(t *testing.T) {
+	r := NewProductRepository()
+	p := newTee(t, r, 10)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reserved int
+	)
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.Reserve(p.ID, "TEE-L", 1, 0)
+			switch {
+			case err == nil:
+				mu.Lock()
+				reserved++
+				mu.Unlock()
+			case !errors.Is(err, ErrInsufficientStock):
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if reserved != 10 {
+		t.Errorf("%d reservations went through, want 10", reserved)
+	}
+	if v := variantOf(t, r, p.ID); v.Reserved != 10 || v.Available() != 0 {
+		t.Errorf("reserved %d, available %d", v.Reserved, v.Available())
+	}
+}
+
+func TestCommitAndRelease(t *testing.T) {
+	r := NewProductRepository()
+	p := newTee(t, r, 5)
+
+	sold, _ := r.Reserve(p.ID, "TEE-L", 2, 0)
+	cancelled, _ := r.Reserve(p.ID, "TEE-L", 3, 0)
+	if _, err := r.Reserve(p.ID, "TEE-L", 1, 0); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("err = %v, want ErrInsufficientStock", err)
+	}
+
+	if _, err := r.Commit(sold.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Release(cancelled.ID); err != nil {
+		t.Fatal(err)
+	}
+	if v := variantOf(t, r, p.ID); v.Stock != 3 || v.Reserved != 0 {
+		t.Errorf("stock %d, reserved %d, want 3 and 0", v.Stock, v.Reserved)
+	}
+	if _, err := r.Commit(sold.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second commit: err = %v, want ErrNotFound", err)
+	}
+	if _, err := r.Reserve(p.ID, "TEE-XL", 1, 0); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown SKU: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReservationsExpire(t *testing.T) {
+	r := NewProductRepository()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	p := newTee(t, r, 1)
+
+	res, err := r.Reserve(p.ID, "TEE-L", 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+
+	// the next order gets the stock back
+	if _, err := r.Reserve(p.ID, "TEE-L", 1, 0); err != nil {
+		t.Fatalf("after expiry: %v", err)
+	}
+	if _, err := r.Commit(res.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("commit of expired reservation: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpdateKeepsReservations(t *testing.T) {
+	r := NewProductRepository()
+	p := newTee(t, r, 5)
+	if _, err := r.Reserve(p.ID, "TEE-L", 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	update := func(variants ...models.Variant) error {
+		current, _ := r.FindByID(p.ID)
+		current.Variants = variants
+		return r.Update(&current)
+	}
+	if err := update(); !errors.Is(err, ErrReserved) {
+		t.Errorf("dropping the variant: err = %v, want ErrReserved", err)
+	}
+	if err := update(models.Variant{SKU: "TEE-L", Stock: 1}); !errors.Is(err, ErrReserved) {
+		t.Errorf("stock below reserved: err = %v, want ErrReserved", err)
+	}
+	if err := update(models.Variant{SKU: "TEE-L", Stock: 8}); err != nil {
+		t.Fatal(err)
+	}
+	if v := variantOf(t, r, p.ID); v.Stock != 8 || v.Reserved != 2 {
+		t.Errorf("stock %d, reserved %d, want 8 and 2", v.Stock, v.Reserved)
+	}
+}
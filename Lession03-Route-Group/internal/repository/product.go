@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"cmp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/slug"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// ProductChange is sent to subscribers after every write
+type ProductChange struct {
+	Kind    ChangeKind
+	Product models.Product // for Deleted, the product as it was before
+}
+
+// ProductRepository keeps products in memory until we plug a real DB in
+type ProductRepository struct {
+	mu        sync.RWMutex
+	nextID    int
+	items     map[int]models.Product
+	slugs     *slug.Registry
+	listeners []func(ProductChange)
+
+	reservations map[string]models.Reservation // by ID, see inventory.go
+	now          func() time.Time
+}
+
+func NewProductRepository() *ProductRepository {
+	return &ProductRepository{
+		items:        map[int]models.Product{},
+		slugs:        slug.NewRegistry("product"),
+		reservations: map[string]models.Reservation{},
+		now:          time.Now,
+	}
+}
+
+// Create assigns the ID, slug and timestamps, then stores a copy of p.
+// The slug is made from p.Slug when set, from p.Name otherwise.
+func (r *ProductRepository) Create(p *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	p.ID = r.nextID
+	p.Slug = r.slugs.Assign(p.ID, cmp.Or(p.Slug, p.Name))
+	p.Version = 1
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	p.UpdatedAt = p.CreatedAt
+
+	r.items[p.ID] = cloneProduct(*p)
+	r.notify(Created, *p)
+	return nil
+}
+
+// Update replaces the stored product with the same ID. p.Version must be the
+// version that was read, otherwise ErrVersionConflict; on success it is bumped.
+// A new name (or p.Slug) gives a new slug, the old one keeps resolving.
+// Reserved counts of variants are kept by SKU, see keepReserved.
+func (r *ProductRepository) Update(p *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.items[p.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if old.Version != p.Version {
+		return ErrVersionConflict
+	}
+	if err := keepReserved(old.Variants, p.Variants); err != nil {
+		return err
+	}
+	p.Version++
+	p.Slug = r.slugs.Assign(p.ID, cmp.Or(p.Slug, p.Name))
+	p.CreatedAt = old.CreatedAt
+	p.UpdatedAt = time.Now()
+
+	r.items[p.ID] = cloneProduct(*p)
+	r.notify(Updated, *p)
+	return nil
+}
+
+// Delete removes the product if it is still at version
+func (r *ProductRepository) Delete(id, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.items[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if old.Version != version {
+		return ErrVersionConflict
+	}
+	delete(r.items, id)
+	r.slugs.Remove(id)
+	for rid, res := range r.reservations {
+		if res.ProductID == id {
+			delete(r.reservations, rid)
+		}
+	}
+	r.notify(Deleted, old)
+	return nil
+}
+
+// Subscribe registers fn to be called after each create, update and delete.
+// fn runs while the write lock is held, so listeners see changes in commit
+// order, but they must not call back into the repository.
+func (r *ProductRepository) Subscribe(fn func(ProductChange)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+func (r *ProductRepository) notify(kind ChangeKind, p models.Product) {
+	for _, fn := range r.listeners {
+		fn(ProductChange{Kind: kind, Product: cloneProduct(p)})
+	}
+}
+
+func (r *ProductRepository) FindByID(id int) (models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.items[id]
+	if !ok {
+		return models.Product{}, ErrNotFound
+	}
+	return cloneProduct(p), nil
+}
+
+// FindBySlug accepts current and old slugs. For an old one the product comes
+// back with its current Slug, so the caller can tell and redirect.
+func (r *ProductRepository) FindBySlug(s string) (models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, _, ok := r.slugs.Resolve(s)
+	if !ok {
+		return models.Product{}, ErrNotFound
+	}
+	return cloneProduct(r.items[id]), nil
+}
+
+// SlugTaken reports whether s is, or was, the slug of another product
+func (r *ProductRepository) SlugTaken(s string, exceptID int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.slugs.Taken(s, exceptID)
+}
+
+// FindAll returns every product ordered by ID
+func (r *ProductRepository) FindAll() []models.Product {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]models.Product, 0, len(r.items))
+	for _, p := range r.items {
+		list = append(list, cloneProduct(p))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// FindByCategories returns the products in any of the categories, ordered by ID
+func (r *ProductRepository) FindByCategories(categoryIDs map[int]bool) []models.Product {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var list []models.Product
+	for _, p := range r.items {
+		for _, id := range p.CategoryIDs {
+			if categoryIDs[id] {
+				list = append(list, cloneProduct(p))
+				break
+			}
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// NameExists reports whether another product (not exceptID) already uses name
+func (r *ProductRepository) NameExists(name string, exceptID int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, p := range r.items {
+		if p.ID != exceptID && strings.EqualFold(p.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Copy slices and maps so callers can't change what is stored
+func cloneProduct(p models.Product) models.Product {
+	p.Tags = append([]string(nil), p.Tags...)
+	p.Images = append([]models.Image(nil), p.Images...)
+	p.CategoryIDs = append([]int(nil), p.CategoryIDs...)
+	if p.Variants != nil {
+		variants := make([]models.Variant, len(p.Variants))
+		for i, v := range p.Variants {
+			v.Prices = append([]models.Money(nil), v.Prices...)
+			v.Images = append([]models.Image(nil), v.Images...)
+			if v.Options != nil {
+				options := make(map[string]string, len(v.Options))
+				for k, o := range v.Options {
+					options[k] = o
+				}
+				v.Options = options
+			}
+			variants[i] = v
+		}
+		p.Variants = variants
+	}
+	if p.Info != nil {
+		info := make(map[string]models.Info, len(p.Info))
+		for k, v := range p.Info {
+			info[k] = v
+		}
+		p.Info = info
+	}
+	return p
+}
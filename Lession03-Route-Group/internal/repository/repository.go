@@ -0,0 +1,25 @@
+// Package repository keeps the catalogue in memory until we plug a real DB in.
+// Users are the first to have one, see SQLUserRepository.
+package repository
+
+import "errors"
+
+var (
+	ErrNotFound = errors.New("record not found")
+	// ErrVersionConflict means someone else updated the record since it was read
+	ErrVersionConflict = errors.New("record was modified concurrently")
+	// ErrInsufficientStock means a variant has less available than asked for
+	ErrInsufficientStock = errors.New("not enough stock")
+	// ErrReserved means an update would drop a variant, or its stock, below what is reserved
+	ErrReserved = errors.New("variant has open reservations")
+	// ErrCycle means a category would move under itself or one of its own subcategories
+	ErrCycle = errors.New("category can't move into its own subtree")
+)
+
+type ChangeKind string
+
+const (
+	Created ChangeKind = "created"
+	Updated ChangeKind = "updated"
+	Deleted ChangeKind = "deleted"
+)
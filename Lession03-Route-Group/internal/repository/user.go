@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"cmp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/slug"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+type UserRepository struct {
+	mu     sync.RWMutex
+	nextID int
+	items  map[int]models.User
+	slugs  *slug.Registry
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{items: map[int]models.User{}, slugs: slug.NewRegistry("user")}
+}
+
+// Create assigns ID, UUID (when empty), slug and timestamps, then stores a copy of u.
+// The slug is made from u.Slug when set, from u.Name otherwise.
+func (r *UserRepository) Create(u *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	u.ID = r.nextID
+	u.Slug = r.slugs.Assign(u.ID, cmp.Or(u.Slug, u.Name))
+	u.Version = 1
+	if u.UUID == "" {
+		u.UUID = uuid.New().String()
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	u.UpdatedAt = u.CreatedAt
+
+	r.items[u.ID] = *u
+	return nil
+}
+
+// Update replaces the stored user with the same ID. u.Version must be the
+// version that was read, otherwise ErrVersionConflict; on success it is bumped.
+// A new name (or u.Slug) gives a new slug, the old one keeps resolving.
+func (r *UserRepository) Update(u *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.items[u.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if old.Version != u.Version {
+		return ErrVersionConflict
+	}
+	u.Version++
+	u.Slug = r.slugs.Assign(u.ID, cmp.Or(u.Slug, u.Name))
+	u.UUID = old.UUID
+	u.CreatedAt = old.CreatedAt
+	u.UpdatedAt = time.Now()
+
+	r.items[u.ID] = *u
+	return nil
+}
+
+// Delete removes the user if it is still at version
+func (r *UserRepository) Delete(id, version int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.items[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if old.Version != version {
+		return ErrVersionConflict
+	}
+	delete(r.items, id)
+	r.slugs.Remove(id)
+	return nil
+}
+
+func (r *UserRepository) FindByID(id int) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.items[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (r *UserRepository) FindByUUID(id string) (models.User, error) {
+	return r.findBy(func(u models.User) bool { return u.UUID == id })
+}
+
+// FindBySlug accepts current and old slugs. For an old one the user comes
+// back with its current Slug, so the caller can tell and redirect.
+func (r *UserRepository) FindBySlug(s string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, _, ok := r.slugs.Resolve(s)
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return r.items[id], nil
+}
+
+// SlugTaken reports whether s is, or was, the slug of another user
+func (r *UserRepository) SlugTaken(s string, exceptID int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.slugs.Taken(s, exceptID)
+}
+
+// FindAll returns every user ordered by ID, the error is for SQLUserRepository's sake
+func (r *UserRepository) FindAll() ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]models.User, 0, len(r.items))
+	for _, u := range r.items {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (r *UserRepository) findBy(match func(models.User) bool) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, u := range r.items {
+		if match(u) {
+			return u, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/slug"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// SQLUserRepository keeps users in the users table, the one the API tokens
+// of lession03 user create-admin point at. It behaves like UserRepository:
+// same slugs, old ones in user_slugs keep resolving, same version checks.
+type SQLUserRepository struct {
+	db *sql.DB
+}
+
+func NewSQLUserRepository(db *sql.DB) *SQLUserRepository {
+	return &SQLUserRepository{db: db}
+}
+
+const userColumns = `id, uuid, name, slug, email, version, created_at, updated_at`
+
+func scanUser(row interface{ Scan(...any) error }) (models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.UUID, &u.Name, &u.Slug, &u.Email, &u.Version, &u.CreatedAt, &u.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	}
+	return u, err
+}
+
+// Create assigns ID, UUID (when empty), slug and timestamps like UserRepository.Create
+func (r *SQLUserRepository) Create(u *models.User) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if u.UUID == "" {
+		u.UUID = uuid.New().String()
+	}
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now()
+	}
+	u.UpdatedAt = u.CreatedAt
+	u.Version = 1
+	s, err := freeSlug(tx, 0, cmp.Or(u.Slug, u.Name))
+	if err != nil {
+		return err
+	}
+	u.Slug = s
+
+	res, err := tx.Exec(`INSERT INTO users (uuid, name, slug, email, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		u.UUID, u.Name, u.Slug, u.Email, u.Version, u.CreatedAt, u.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	u.ID = int(id)
+	if _, err := tx.Exec(`INSERT INTO user_slugs (slug, user_id) VALUES (?, ?)`, u.Slug, u.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update is UserRepository.Update: u.Version must be the one read
+func (r *SQLUserRepository) Update(u *models.User) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := scanUser(tx.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, u.ID))
+	if err != nil {
+		return err
+	}
+	if old.Version != u.Version {
+		return ErrVersionConflict
+	}
+	u.Version++
+	u.UUID = old.UUID
+	u.CreatedAt = old.CreatedAt
+	u.UpdatedAt = time.Now()
+	source := cmp.Or(u.Slug, u.Name)
+	u.Slug = old.Slug
+	if !keepsSlug(old.Slug, slugBase(source)) {
+		if u.Slug, err = freeSlug(tx, u.ID, source); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.Exec(`UPDATE users SET name = ?, slug = ?, email = ?, version = ?, updated_at = ? WHERE id = ? AND version = ?`,
+		u.Name, u.Slug, u.Email, u.Version, u.UpdatedAt, u.ID, old.Version)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrVersionConflict
+	}
+	// the old slug may predate this repository (create-admin doesn't record it)
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO user_slugs (slug, user_id) VALUES (?, ?), (?, ?)`, old.Slug, u.ID, u.Slug, u.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete removes the user if it is still at version, its slugs and API
+// tokens go with it
+func (r *SQLUserRepository) Delete(id, version int) error {
+	res, err := r.db.Exec(`DELETE FROM users WHERE id = ? AND version = ?`, id, version)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	if _, err := r.FindByID(id); err != nil {
+		return err
+	}
+	return ErrVersionConflict
+}
+
+func (r *SQLUserRepository) FindByID(id int) (models.User, error) {
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = ?`, id))
+}
+
+func (r *SQLUserRepository) FindByUUID(id string) (models.User, error) {
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE uuid = ?`, id))
+}
+
+// FindBySlug accepts current and old slugs, like UserRepository.FindBySlug
+func (r *SQLUserRepository) FindBySlug(s string) (models.User, error) {
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users
+		WHERE slug = ?1 OR id = (SELECT user_id FROM user_slugs WHERE slug = ?1)
+		ORDER BY slug = ?1 DESC LIMIT 1`, s))
+}
+
+// SlugTaken reports whether s is, or was, the slug of another user. A
+// failing query reports false, the write that follows fails on it too.
+func (r *SQLUserRepository) SlugTaken(s string, exceptID int) bool {
+	taken, err := slugTaken(r.db, s, exceptID)
+	return err == nil && taken
+}
+
+// FindAll returns every user ordered by ID
+func (r *SQLUserRepository) FindAll() ([]models.User, error) {
+	rows, err := r.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
+
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func slugTaken(q queryer, s string, exceptID int) (bool, error) {
+	var taken bool
+	err := q.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE slug = ?1 AND id != ?2)
+		OR EXISTS (SELECT 1 FROM user_slugs WHERE slug = ?1 AND user_id != ?2)`, s, exceptID).Scan(&taken)
+	return taken, err
+}
+
+// slugBase is what slug.Registry.Assign starts from for a user
+func slugBase(source string) string {
+	return cmp.Or(slug.Make(source), "user")
+}
+
+// keepsSlug tells whether cur is base, or base-N from a collision, which is
+// when slug.Registry.Assign keeps the current slug
+func keepsSlug(cur, base string) bool {
+	if cur == base {
+		return true
+	}
+	n, ok := strings.CutPrefix(cur, base+"-")
+	_, err := strconv.Atoi(n)
+	return ok && err == nil
+}
+
+// freeSlug is the first of base, base-2, base-3... no other user has or had
+func freeSlug(q queryer, id int, source string) (string, error) {
+	base := slugBase(source)
+	candidate := base
+	for n := 2; ; n++ {
+		taken, err := slugTaken(q, candidate, id)
+		if err != nil || !taken {
+			return candidate, err
+		}
+		candidate = base + "-" + strconv.Itoa(n)
+	}
+}
@@ -0,0 +1,119 @@
+package router_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+)
+
+// importResults waits for the import to finish, by following its results
+func importResults(t *testing.T, h *apitest.Harness, id string) []dto.ImportResult {
+	t.Helper()
+	res := h.Do(http.MethodGet, "/api/v1/imports/"+id+"/results").Expect(http.StatusOK)
+	var results []dto.ImportResult
+	sc := bufio.NewScanner(res.Body)
+	for sc.Scan() {
+		var r dto.ImportResult
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("%q: %v", sc.Text(), err)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func startImportOf(t *testing.T, h *apitest.Harness, path string, opt apitest.RequestOption) dto.ImportSummary {
+	t.Helper()
+	var res dto.ImportResponse
+	h.Do(http.MethodPost, path, opt).Expect(http.StatusAccepted).Decode(&res)
+	return res.Data
+}
+
+func TestImportCSVDryRunThenForReal(t *testing.T) {
+	h := apitest.New(t)
+	csv := "name,price,currency,stock,avatar_url,images,product_info,tags\n" +
+		`Gopher Tee,2500,USD,10,https://example.com/a.png,"[{""url"":""https://example.com/f.jpg""}]","{""3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f"":{""info_key"":""size"",""info_value"":""L""}}",clothes|golang` + "\n" +
+		`Gopher Mug,abc,USD,10,https://example.com/m.png,,,` + "\n" +
+		`Gopher Tee,2500,USD,10,https://example.com/a.png,"[{""url"":""https://example.com/f.jpg""}]","{""3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f"":{""info_key"":""size"",""info_value"":""L""}}",` + "\n" +
+		`Go,0,USD,0,https://example.com/a.gif,,,` + "\n"
+
+	upload := apitest.Multipart(apitest.NewMultipart().File("file", "products.csv", []byte(csv)))
+	job := startImportOf(t, h, "/api/v1/products/import?dry_run=true", upload)
+	results := importResults(t, h, job.ID)
+
+	want := []struct {
+		line   int
+		status string
+		field  string
+	}{{2, "valid", ""}, {3, "invalid", ""}, {4, "invalid", "name"}, {5, "invalid", "Price.Amount"}}
+	if len(results) != len(want) {
+		t.Fatalf("results %+v", results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Line != w.line || r.Status != w.status || (w.field != "" && r.Fields[w.field] == "") {
+			t.Errorf("row %d: %+v, want line %d %s with a %q error", i, r, w.line, w.status, w.field)
+		}
+	}
+	h.Do(http.MethodGet, "/api/v1/products/1").Expect(http.StatusNotFound)
+
+	var summary dto.ImportResponse
+	h.Do(http.MethodGet, "/api/v1/imports/"+job.ID).Expect(http.StatusOK).Decode(&summary)
+	if s := summary.Data; s.Status != "done" || s.Rows != 4 || s.Valid != 1 || s.Invalid != 3 || s.FinishedAt == nil {
+		t.Errorf("summary %+v", s)
+	}
+
+	job = startImportOf(t, h, "/api/v1/products/import?format=csv", apitest.Body("application/octet-stream", []byte(csv)))
+	results = importResults(t, h, job.ID)
+	if results[0].Status != "created" || results[0].ID != 1 || results[0].Slug != "gopher-tee" {
+		t.Errorf("first row %+v", results[0])
+	}
+	h.Do(http.MethodGet, "/api/v1/products/1").Expect(http.StatusOK)
+}
+
+func TestImportRejectsBadCSVHeader(t *testing.T) {
+	h := apitest.New(t)
+	res := h.Do(http.MethodPost, "/api/v1/products/import", apitest.Body("text/csv", []byte("name,colour\nGopher Tee,blue\n")))
+	res.Expect(http.StatusBadRequest)
+	if !strings.Contains(res.Body.String(), `unknown CSV column \"colour\"`) {
+		t.Errorf("body %s", res.Body)
+	}
+}
+
+// An export imports again as is, here into a fresh server
+func TestExportRoundTrips(t *testing.T) {
+	for _, format := range []string{"csv", "ndjson"} {
+		t.Run(format, func(t *testing.T) {
+			src := apitest.New(t)
+			src.CreateProduct(apitest.ProductRequest(apitest.WithVariant))
+			src.CreateProduct(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+				p.Name, p.Tags = "Gopher Mug", nil
+			}))
+			export := src.Do(http.MethodGet, "/api/v1/products/export?format="+format).Expect(http.StatusOK)
+			if ct := export.Header().Get("Content-Type"); !strings.Contains(ct, map[string]string{"csv": "text/csv", "ndjson": "ndjson"}[format]) {
+				t.Errorf("Content-Type %q", ct)
+			}
+
+			dst := apitest.New(t)
+			job := startImportOf(t, dst, "/api/v1/products/import?format="+format, apitest.Body("application/octet-stream", export.Body.Bytes()))
+			for _, r := range importResults(t, dst, job.ID) {
+				if r.Status != "created" {
+					t.Errorf("%+v", r)
+				}
+			}
+
+			var before, after dto.ProductResponse
+			src.Do(http.MethodGet, "/api/v1/products/1").Expect(http.StatusOK).Decode(&before)
+			dst.Do(http.MethodGet, "/api/v1/products/1").Expect(http.StatusOK).Decode(&after)
+			b, a := before.Data, after.Data
+			if b.Name != a.Name || b.Slug != a.Slug || b.Price != a.Price || len(a.Variants) != 1 || a.Variants[0].Prices[1].Amount != 600000 {
+				t.Errorf("before %+v\nafter %+v", b, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package router_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/cache"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/middleware"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+)
+
+func TestProductListIsCachedUntilAProductChanges(t *testing.T) {
+	h := apitest.NewWithConfig(t, router.Config{Cache: cache.New(cache.NewLRU(0))})
+	id := h.CreateProduct(apitest.ProductRequest())
+
+	list := func(query string, want string) dto.ProductPage {
+		t.Helper()
+		var page dto.ProductPage
+		res := h.Do(http.MethodGet, "/api/v1/products?"+query).Expect(http.StatusOK).Decode(&page)
+		if got := res.Header().Get(middleware.CacheHeader); got != want {
+			t.Errorf("?%s: X-Cache %s, want %s", query, got, want)
+		}
+		return page
+	}
+	list("search=gopher", "MISS")
+	list("search=gopher", "HIT")
+	list("search=gopher&limit=5", "MISS")
+	list("limit=5&search=gopher", "HIT") // the same query once sorted
+	// the links show the query as sent, so another spelling is another entry
+	if page := list("search=%20gopher&utm_source=mail", "MISS"); page.Links.Self != "/api/v1/products?search=+gopher&utm_source=mail" {
+		t.Errorf("self %q", page.Links.Self)
+	}
+
+	res := h.Do(http.MethodGet, "/api/v1/products?search=gopher")
+	if res.Header().Get("Cache-Control") != "public, max-age=0, s-maxage=60" || res.Header().Get(middleware.SurrogateKeyHeader) != "products" {
+		t.Errorf("headers %v", res.Header())
+	}
+
+	h.Do(http.MethodDelete, "/api/v1/products/"+strconv.Itoa(id),
+		apitest.Header("If-Match", h.ETag("/api/v1/products/"+strconv.Itoa(id)))).Expect(http.StatusOK)
+	if page := list("search=gopher", "MISS"); len(page.Data) != 0 {
+		t.Errorf("deleted product still listed: %+v", page.Data)
+	}
+
+	// Validation errors aren't kept
+	h.Do(http.MethodGet, "/api/v1/products?search=go").Expect(http.StatusBadRequest)
+	if got := h.Do(http.MethodGet, "/api/v1/products?search=go").Header().Get(middleware.CacheHeader); got != "BYPASS" {
+		t.Errorf("invalid query: X-Cache %s", got)
+	}
+}
+
+func TestUserLookupIsCachedUntilAUserChanges(t *testing.T) {
+	h := apitest.NewWithConfig(t, router.Config{Cache: cache.New(cache.NewLRU(0))})
+	var created dto.UserResponse
+	h.Do(http.MethodPost, "/api/v1/users", apitest.JSON(dto.CreateUserRequest{Name: "Cached Carol"})).Expect(http.StatusCreated).Decode(&created)
+	path := "/api/v1/users/" + strconv.Itoa(created.Data.ID)
+
+	h.Do(http.MethodGet, path).Expect(http.StatusOK)
+	res := h.Do(http.MethodGet, path).Expect(http.StatusOK)
+	if res.Header().Get(middleware.CacheHeader) != "HIT" || res.Header().Get("ETag") == "" ||
+		res.Header().Get("Cache-Control") != "private, max-age=0" || res.Header().Get(middleware.SurrogateKeyHeader) != "" {
+		t.Errorf("headers %v", res.Header())
+	}
+	// Conditional requests are the handler's
+	etag := res.Header().Get("ETag")
+	h.Do(http.MethodGet, path, apitest.Header("If-None-Match", etag)).Expect(http.StatusNotModified)
+
+	h.Do(http.MethodPut, path, apitest.Header("If-Match", etag), apitest.JSON(dto.CreateUserRequest{Name: "Renamed Carol"})).Expect(http.StatusOK)
+	var got dto.UserResponse
+	h.Do(http.MethodGet, path).Expect(http.StatusOK).Decode(&got)
+	if got.Data.Name != "Renamed Carol" {
+		t.Errorf("got %q after the update", got.Data.Name)
+	}
+}
@@ -0,0 +1,146 @@
+package router_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// asMap is v as the generic JSON document the binary formats carry
+func asMap(t *testing.T, v any) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestProductInEveryFormat(t *testing.T) {
+	h := apitest.New(t)
+	msgpack := &codec.MsgpackHandle{WriteExt: true}
+	msgpack.MapType = reflect.TypeFor[map[string]any]()
+
+	formats := []struct {
+		mediaType string
+		encode    func(req dto.CreateProductRequest) []byte
+		decode    func(body []byte) (id int, name string)
+	}{
+		{
+			mediaType: "application/xml",
+			encode: func(req dto.CreateProductRequest) []byte {
+				return []byte(`<product><name>` + req.Name + `</name><price><amount>2500</amount><currency>USD</currency></price><stock>10</stock>
+					<avartar><url>https://example.com/avatar.png</url></avartar>
+					<image><item><url>https://example.com/front.jpg</url></item></image>
+					<product_info><entry key="3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f"><info_key>size</info_key><info_value>L</info_value></entry></product_info>
+				</product>`)
+			},
+			decode: func(body []byte) (int, string) {
+				var res struct {
+					ID   int    `xml:"id"`
+					Name string `xml:"data>name"`
+				}
+				if err := xml.Unmarshal(body, &res); err != nil {
+					t.Fatalf("%v: %s", err, body)
+				}
+				return res.ID, res.Name
+			},
+		},
+		{
+			mediaType: "application/msgpack",
+			encode: func(req dto.CreateProductRequest) []byte {
+				var buf bytes.Buffer
+				if err := codec.NewEncoder(&buf, msgpack).Encode(asMap(t, req)); err != nil {
+					t.Fatal(err)
+				}
+				return buf.Bytes()
+			},
+			decode: func(body []byte) (int, string) {
+				var res map[string]any
+				if err := codec.NewDecoderBytes(body, msgpack).Decode(&res); err != nil {
+					t.Fatal(err)
+				}
+				id, _ := res["id"].(int64)
+				name, _ := res["data"].(map[string]any)["name"].(string)
+				return int(id), name
+			},
+		},
+		{
+			mediaType: "application/x-protobuf",
+			encode: func(req dto.CreateProductRequest) []byte {
+				v, err := structpb.NewValue(asMap(t, req))
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := proto.Marshal(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return data
+			},
+			decode: func(body []byte) (int, string) {
+				var v structpb.Value
+				if err := proto.Unmarshal(body, &v); err != nil {
+					t.Fatal(err)
+				}
+				res := v.GetStructValue().AsMap()
+				id, _ := res["id"].(float64)
+				name, _ := res["data"].(map[string]any)["name"].(string)
+				return int(id), name
+			},
+		},
+	}
+	for i, f := range formats {
+		t.Run(f.mediaType, func(t *testing.T) {
+			name := "Format Tee " + strconv.Itoa(i)
+			req := apitest.ProductRequest(func(p *dto.CreateProductRequest) { p.Name = name })
+			res := h.Do(http.MethodPost, "/api/v1/products",
+				apitest.Body(f.mediaType, f.encode(req)), apitest.Header("Accept", f.mediaType),
+			).Expect(http.StatusCreated)
+			if got := res.Header().Get("Content-Type"); got != f.mediaType {
+				t.Errorf("Content-Type %s", got)
+			}
+			id, got := f.decode(res.Body.Bytes())
+			if id == 0 || got != name {
+				t.Fatalf("created %d %q", id, got)
+			}
+
+			var product dto.ProductResponse
+			h.Do(http.MethodGet, "/api/v1/products/"+strconv.Itoa(id)).Expect(http.StatusOK).Decode(&product)
+			if product.Data.Name != name || product.Data.Price.Amount != 2500 || product.Data.Info["3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f"].InfoValue != "L" {
+				t.Errorf("stored %+v", product.Data)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsFollowAccept(t *testing.T) {
+	h := apitest.New(t)
+	res := h.Do(http.MethodPost, "/api/v1/products",
+		apitest.Body("application/xml", []byte(`<product><name>No</name><price>x</price></product>`)),
+		apitest.Header("Accept", "application/json;q=0.5, application/xml"),
+	).Expect(http.StatusBadRequest)
+	var body struct {
+		Error string `xml:"error"`
+	}
+	if err := xml.Unmarshal(res.Body.Bytes(), &body); err != nil || body.Error == "" {
+		t.Errorf("%v: %s", err, res.Body)
+	}
+	if vary := res.Header().Get("Vary"); vary != "Accept" {
+		t.Errorf("Vary %q", vary)
+	}
+}
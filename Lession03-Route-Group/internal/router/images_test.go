@@ -0,0 +1,185 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	v1handler "github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/api/v1/handler"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/remoteimage"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// Serves PNG bytes for *.png, except /evil.png which is an HTML page
+func remoteImages(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		if r.URL.Path == "/evil.png" {
+			w.Write([]byte("<html>gotcha</html>"))
+			return
+		}
+		w.Write(apitest.PNG)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRemoteImageCheck(t *testing.T) {
+	remote := remoteImages(t)
+	h := apitest.NewWithConfig(t, router.Config{
+		Images: remoteimage.New(remoteimage.Config{AllowPrivate: true}),
+	})
+
+	fields := h.Do(http.MethodPost, "/api/v1/products", apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+		p.Avartar.URL = remote.URL + "/avatar.png"
+		p.Image = []dto.ProductImage{{URL: remote.URL + "/front.png"}, {URL: remote.URL + "/evil.png"}}
+	}))).Expect(http.StatusBadRequest).Fields()
+	if len(fields) != 1 || !strings.Contains(fields["Image[1].URL"], "not serve a JPEG or PNG") {
+		t.Errorf("fields = %v, want only Image[1].URL", fields)
+	}
+
+	h.CreateProduct(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+		p.Avartar.URL = remote.URL + "/avatar.png"
+		p.Image = []dto.ProductImage{{URL: remote.URL + "/front.png"}}
+	}))
+}
+
+func TestRemoteImageIngest(t *testing.T) {
+	remote := remoteImages(t)
+	h := apitest.NewWithConfig(t, router.Config{
+		Images:       remoteimage.New(remoteimage.Config{AllowPrivate: true}),
+		IngestImages: true,
+	})
+
+	var res struct {
+		Data dto.CreateCategoryRequest `json:"data"`
+	}
+	h.Do(http.MethodPost, "/api/v1/categories", apitest.Form(apitest.CategoryForm(apitest.CategoryRequest(func(c *dto.CreateCategoryRequest) {
+		c.ImageURL = remote.URL + "/shirts.png"
+	})))).Expect(http.StatusCreated).Decode(&res)
+
+	name, ok := strings.CutPrefix(res.Data.ImageURL, "/api/static/categories/")
+	if !ok {
+		t.Fatalf("image_url = %q, want our own copy", res.Data.ImageURL)
+	}
+	if _, err := os.Stat(filepath.Join(h.UploadDir, name)); err != nil {
+		t.Fatal(err)
+	}
+	h.Do(http.MethodGet, res.Data.ImageURL).Expect(http.StatusOK)
+}
+
+// With a queue the checks don't hold the request: the job hides a product
+// showing a bad image and copies a category's image in once it is saved
+func TestRemoteImageCheckJobs(t *testing.T) {
+	remote := remoteImages(t)
+	q := apitest.Queue(t)
+	h := apitest.NewWithConfig(t, router.Config{
+		Images:       remoteimage.New(remoteimage.Config{AllowPrivate: true}),
+		IngestImages: true,
+		Jobs:         q,
+	})
+	ctx := context.Background()
+
+	var created dto.CreateProductResponse
+	res := h.Do(http.MethodPost, "/api/v1/products", apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+		p.Avartar.URL = remote.URL + "/avatar.png"
+		p.Image = []dto.ProductImage{{URL: remote.URL + "/evil.png"}}
+	}))).Expect(http.StatusCreated).Decode(&created)
+	if !strings.HasPrefix(res.Header().Get(v1handler.ImageCheckHeader), "/api/v1/jobs/") {
+		t.Fatalf("%s: %q", v1handler.ImageCheckHeader, res.Header().Get(v1handler.ImageCheckHeader))
+	}
+	if ran, err := q.RunNext(ctx, v1handler.ProductImagesJob); !ran || err != nil {
+		t.Fatalf("ran %t, %v", ran, err)
+	}
+	var job struct {
+		Data struct {
+			Result dto.ImageCheckResult `json:"result"`
+		} `json:"data"`
+	}
+	h.Do(http.MethodGet, res.Header().Get(v1handler.ImageCheckHeader)).Expect(http.StatusOK).Decode(&job)
+	if result := job.Data.Result; !result.Hidden || result.Failed["Image[0].URL"] == "" {
+		t.Errorf("result %+v", result)
+	}
+	var product dto.ProductResponse
+	h.Do(http.MethodGet, "/api/v1/products/"+strconv.Itoa(created.ID)).Expect(http.StatusOK).Decode(&product)
+	if product.Data.Display || product.Data.Stock != 0 {
+		t.Errorf("product with a bad image still on the shelf: display %t, stock %d", product.Data.Display, product.Data.Stock)
+	}
+	var page dto.ProductPage
+	h.Do(http.MethodGet, "/api/v1/products?search=gopher").Expect(http.StatusOK).Decode(&page)
+	if len(page.Data) != 0 {
+		t.Errorf("hidden product listed: %+v", page.Data)
+	}
+
+	h.Do(http.MethodPost, "/api/v1/categories", apitest.Form(apitest.CategoryForm(apitest.CategoryRequest(func(c *dto.CreateCategoryRequest) {
+		c.ImageURL = remote.URL + "/shirts.png"
+	})))).Expect(http.StatusCreated)
+	if ran, err := q.RunNext(ctx, v1handler.CategoryImageJob); !ran || err != nil {
+		t.Fatalf("ran %t, %v", ran, err)
+	}
+	var list struct {
+		Data []models.Category `json:"data"`
+	}
+	h.Do(http.MethodGet, "/api/v1/categories").Expect(http.StatusOK).Decode(&list)
+	name, ok := strings.CutPrefix(list.Data[len(list.Data)-1].ImageURL, "/api/static/categories/")
+	if !ok {
+		t.Fatalf("image_url = %q, want our own copy", list.Data[len(list.Data)-1].ImageURL)
+	}
+	if _, err := os.Stat(filepath.Join(h.UploadDir, name)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Products off the shelf are left out of the listings and suggestions, and
+// come back with display true
+func TestHiddenProductsAreLeftOut(t *testing.T) {
+	h := apitest.New(t)
+	categoryID := h.CreateCategory(apitest.CategoryRequest())
+	id := strconv.Itoa(h.CreateProduct(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+		p.CategoryIDs = []int{categoryID}
+	})))
+
+	listed := func() (found, inCategory, suggested int) {
+		t.Helper()
+		var page dto.ProductPage
+		h.Do(http.MethodGet, "/api/v1/products?search=gopher").Expect(http.StatusOK).Decode(&page)
+		var category dto.CategoryProductPage
+		h.Do(http.MethodGet, "/api/v1/categories/"+strconv.Itoa(categoryID)+"/products").Expect(http.StatusOK).Decode(&category)
+		var suggestions dto.SuggestResponse
+		h.Do(http.MethodGet, "/api/v1/products/suggest?q=gopher").Expect(http.StatusOK).Decode(&suggestions)
+		return len(page.Data), len(category.Data), len(suggestions.Suggestions)
+	}
+	if found, inCategory, suggested := listed(); found != 1 || inCategory != 1 || suggested != 1 {
+		t.Fatalf("shown: search %d, category %d, suggestions %d", found, inCategory, suggested)
+	}
+
+	display := func(shown bool) {
+		t.Helper()
+		h.Do(http.MethodPut, "/api/v1/products/"+id, apitest.Header("If-Match", h.ETag("/api/v1/products/"+id)),
+			apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+				p.CategoryIDs = []int{categoryID}
+				p.Display = &shown
+				if !shown {
+					p.Stock = 0
+				}
+			}))).Expect(http.StatusOK)
+	}
+	display(false)
+	if found, inCategory, suggested := listed(); found != 0 || inCategory != 0 || suggested != 0 {
+		t.Errorf("hidden: search %d, category %d, suggestions %d", found, inCategory, suggested)
+	}
+	h.Do(http.MethodGet, "/api/v1/products/"+id).Expect(http.StatusOK) // still there for its editors
+
+	display(true)
+	if found, inCategory, suggested := listed(); found != 1 || inCategory != 1 || suggested != 1 {
+		t.Errorf("shown again: search %d, category %d, suggestions %d", found, inCategory, suggested)
+	}
+}
@@ -0,0 +1,66 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	v1handler "github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/api/v1/handler"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+)
+
+// The cleanup job removes old uploads no category uses, its result shows on GET /jobs/:id
+func TestCleanupUploadsJob(t *testing.T) {
+	q := apitest.Queue(t)
+	h := apitest.NewWithConfig(t, router.Config{Jobs: q})
+	ctx := context.Background()
+
+	old := time.Now().Add(-2 * v1handler.OrphanAge)
+	for _, name := range []string{"used.png", "orphan.png", "fresh.png"} {
+		file := filepath.Join(h.UploadDir, name)
+		if err := os.WriteFile(file, []byte("png"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if name != "fresh.png" {
+			os.Chtimes(file, old, old)
+		}
+	}
+	h.CreateCategory(apitest.CategoryRequest(func(c *dto.CreateCategoryRequest) {
+		c.ImageURL = "http://localhost:8080/api/static/categories/used.png"
+	}))
+
+	job, err := q.Enqueue(ctx, v1handler.CleanupUploadsJob, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res dto.JobResponse
+	h.Do(http.MethodGet, "/api/v1/jobs/"+job.ID).Expect(http.StatusOK).Decode(&res)
+	if res.Data.Status != "queued" || res.Data.Kind != "uploads.cleanup" || res.Data.Result != nil {
+		t.Errorf("queued: %+v", res.Data)
+	}
+
+	if ran, err := q.RunNext(ctx, v1handler.CleanupUploadsJob); !ran || err != nil {
+		t.Fatalf("ran %t, %v", ran, err)
+	}
+	h.Do(http.MethodGet, "/api/v1/jobs/"+job.ID).Expect(http.StatusOK).Decode(&res)
+	want := map[string]any{"removed": []any{"orphan.png"}, "kept": float64(2)}
+	if res.Data.Status != "succeeded" || res.Data.Attempts != 1 || !reflect.DeepEqual(res.Data.Result, want) {
+		t.Errorf("succeeded: %+v", res.Data)
+	}
+	if _, err := os.Stat(filepath.Join(h.UploadDir, "orphan.png")); !os.IsNotExist(err) {
+		t.Errorf("orphan.png is still there: %v", err)
+	}
+	for _, name := range []string{"used.png", "fresh.png"} {
+		if _, err := os.Stat(filepath.Join(h.UploadDir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	h.Do(http.MethodGet, "/api/v1/jobs/3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f").Expect(http.StatusNotFound)
+}
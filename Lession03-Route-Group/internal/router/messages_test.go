@@ -0,0 +1,125 @@
+package router_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
+)
+
+type messageCase struct {
+	field, tag string // the utils.staticMessages entry
+	key        string // where it shows up in "fields"
+	method     string
+	path       string
+	opts       []apitest.RequestOption
+	validate   any // for rules no request can break, e.g. required on a path parameter
+}
+
+func product(mod func(*dto.CreateProductRequest)) []apitest.RequestOption {
+	return []apitest.RequestOption{apitest.JSON(apitest.ProductRequest(mod))}
+}
+
+var messageCases = []messageCase{
+	{field: "URL", tag: "required", key: "Image[0].URL", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { p.Image[0].URL = "" })},
+	{field: "URL", tag: "imgext", key: "Avartar.URL", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { p.Avartar.URL = "https://example.com/avatar.gif" })},
+	{field: "Name", tag: "required", key: "Name", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { p.Name = "" })},
+	{field: "Name", tag: "min", key: "Name", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { p.Name = "ab" })},
+	{field: "Name", tag: "max", key: "Name", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { p.Name = strings.Repeat("a", 101) })},
+	{field: "Email", tag: "email", key: "Email", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { p.Email = "not-an-email" })},
+	{field: "Amount", tag: "gt", key: "Price.Amount", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { p.Price.Amount = -1 })},
+	{field: "Stock", tag: "gte", key: "Stock", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { p.Stock = -1 })},
+	{field: "Stock", tag: "hidden", key: "Stock", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { hidden := false; p.Display = &hidden })},
+	{field: "ProductInfo", tag: "uuid", key: "ProductInfo[size]", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) {
+			p.ProductInfo = map[string]dto.ProductInfo{"size": {InfoKey: "size", InfoValue: "L"}}
+		})},
+	{field: "Image", tag: "notavatar", key: "Image[1]", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) {
+			p.Image = append(p.Image, dto.ProductImage{URL: p.Avartar.URL})
+		})},
+	{field: "SKU", tag: "sku", key: "Variants[0].SKU", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { apitest.WithVariant(p); p.Variants[0].SKU = "TEE_GO" })},
+	{field: "Variants", tag: "unique", key: "Variants", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { apitest.WithVariant(p); apitest.WithVariant(p) })},
+	{field: "Prices", tag: "unique", key: "Variants[0].Prices", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) {
+			apitest.WithVariant(p)
+			p.Variants[0].Prices[1].Currency = "usd"
+		})},
+	{field: "Amount", tag: "gt", key: "Variants[0].Prices[0].Amount", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { apitest.WithVariant(p); p.Variants[0].Prices[0].Amount = 0 })},
+	{field: "Currency", tag: "iso4217", key: "Variants[0].Prices[0].Currency", method: http.MethodPost, path: "/api/v1/products",
+		opts: product(func(p *dto.CreateProductRequest) { apitest.WithVariant(p); p.Variants[0].Prices[0].Currency = "XYZ" })},
+	{field: "Currency", tag: "required_with", key: "Currency", method: http.MethodGet, path: "/api/v1/products?search=gopher&price_min=100"},
+	{field: "Quantity", tag: "gt", key: "Quantity", method: http.MethodPost, path: "/api/v1/products/1/reservations",
+		opts: []apitest.RequestOption{apitest.JSON(dto.ReserveRequest{SKU: "TEE-GO-L", Quantity: -1})}},
+	{field: "Lang", tag: "required", key: "Lang", validate: dto.ProductLangUri{}},
+	{field: "Slug", tag: "required", key: "Slug", validate: dto.UserSlugQuery{}},
+	{field: "Slug", tag: "slug", key: "Slug", method: http.MethodGet, path: "/api/v1/users/slug/Not_A_Slug"},
+	{field: "ID", tag: "gt", key: "ID", method: http.MethodGet, path: "/api/v1/products/0"},
+	{field: "Search", tag: "required", key: "Search", method: http.MethodGet, path: "/api/v1/products"},
+	{field: "Search", tag: "alphanumspace", key: "Search", method: http.MethodGet, path: "/api/v1/products?search=abc%21%21"},
+	{field: "Limit", tag: "gt", key: "Limit", method: http.MethodGet, path: "/api/v1/users?limit=-1"},
+	{field: "UUID", tag: "uuid4", key: "UUID", method: http.MethodGet, path: "/api/v1/users/uuid/not-a-uuid"},
+}
+
+func TestStaticMessages(t *testing.T) {
+	messages := utils.StaticMessages()
+	for _, mc := range messageCases {
+		t.Run(mc.field+"/"+mc.tag, func(t *testing.T) {
+			want, ok := messages[mc.field][mc.tag]
+			if !ok {
+				t.Fatalf("utils.staticMessages has no %s.%s", mc.field, mc.tag)
+			}
+
+			h := apitest.New(t) // also registers the custom validators
+			var fields map[string]string
+			if mc.validate != nil {
+				fields = utils.FormatValidationErrors(binding.Validator.ValidateStruct(mc.validate))
+			} else {
+				fields = h.Do(mc.method, mc.path, mc.opts...).Expect(http.StatusBadRequest).Fields()
+			}
+			if got := fields[mc.key]; got != want {
+				t.Errorf("fields[%q] = %q, want %q (all fields: %v)", mc.key, got, want, fields)
+			}
+		})
+	}
+}
+
+// A message without a case above is a message nobody has seen work
+func TestStaticMessagesAreCovered(t *testing.T) {
+	covered := map[string]bool{}
+	for _, mc := range messageCases {
+		covered[mc.field+"."+mc.tag] = true
+	}
+	for field, tags := range utils.StaticMessages() {
+		for tag := range tags {
+			if !covered[field+"."+tag] {
+				t.Errorf("no test case for staticMessages[%q][%q]", field, tag)
+			}
+		}
+	}
+}
+
+// Stock 0 is what hiddenProductsHaveNoStock asks of a hidden product, it has to get through
+func TestHiddenProductWithoutStock(t *testing.T) {
+	h := apitest.New(t)
+	h.Do(http.MethodPost, "/api/v1/products", product(func(p *dto.CreateProductRequest) {
+		hidden := false
+		p.Display, p.Stock = &hidden, 0
+	})...).Expect(http.StatusCreated)
+}
@@ -0,0 +1,85 @@
+package router_test
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/hub"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+	"golang.org/x/net/websocket"
+)
+
+func receive(t *testing.T, ws *websocket.Conn) hub.Message {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg hub.Message
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestNotificationsWebSocket(t *testing.T) {
+	q := apitest.Queue(t)
+	h := apitest.NewWithConfig(t, router.Config{DB: q.DB()})
+	srv := h.Server()
+	admin := apitest.Header("Authorization", "Bearer "+apiToken(t, q.DB(), "Root", "admin"))
+	user := apitest.Header("Authorization", "Bearer "+apiToken(t, q.DB(), "Ann", "user"))
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/notifications"
+
+	h.Do(http.MethodPost, "/api/v1/notifications/tickets", user).Expect(http.StatusForbidden)
+	var ticket dto.NotificationTicket
+	h.Do(http.MethodPost, "/api/v1/notifications/tickets", admin).Expect(http.StatusCreated).Decode(&ticket)
+
+	// A browser: the ticket stands in for the Authorization header
+	ws, err := websocket.Dial(wsURL+"?ticket="+ticket.Ticket, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	h.Do(http.MethodGet, "/api/v1/notifications?ticket="+ticket.Ticket).Expect(http.StatusUnauthorized) // used up
+
+	websocket.JSON.Send(ws, hub.Request{Op: "subscribe", Topics: []string{"product:*", "shop:1"}})
+	if msg := receive(t, ws); msg.Type != "error" || !strings.Contains(msg.Error, "shop:1") {
+		t.Errorf("bad topic: %+v", msg)
+	}
+	websocket.JSON.Send(ws, hub.Request{Op: "subscribe", Topics: []string{"product:*"}})
+	if msg := receive(t, ws); msg.Type != "subscribed" || len(msg.Topics) != 1 || msg.Topics[0] != "product:*" {
+		t.Errorf("subscribe: %+v", msg)
+	}
+
+	// Another client with the header, following one category only
+	cfg, err := websocket.NewConfig(wsURL, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Header.Set("Authorization", "Bearer token-of-Root")
+	other, err := websocket.DialConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	categoryID := h.CreateCategory(apitest.CategoryRequest())
+	websocket.JSON.Send(other, hub.Request{Op: "subscribe", Topics: []string{"category:" + strconv.Itoa(categoryID)}})
+	receive(t, other)
+
+	productID := h.CreateProduct(apitest.ProductRequest())
+	msg := receive(t, ws)
+	if msg.Type != "event" || msg.Topic != "product:"+strconv.Itoa(productID) || msg.Event.Type != "product.created" {
+		t.Errorf("product event: %+v", msg)
+	}
+
+	parentID := h.CreateCategory(apitest.CategoryRequest(func(c *dto.CreateCategoryRequest) { c.Name = "Parent" }))
+	tree := "/api/v1/categories/" + strconv.Itoa(categoryID) + "/tree"
+	h.Do(http.MethodPut, "/api/v1/categories/"+strconv.Itoa(categoryID)+"/parent",
+		apitest.Header("If-Match", h.ETag(tree)), apitest.JSON(dto.MoveCategoryRequest{ParentID: parentID}),
+	).Expect(http.StatusOK)
+	if msg := receive(t, other); msg.Type != "event" || msg.Event.Type != "category.updated" {
+		t.Errorf("category event: %+v", msg)
+	}
+}
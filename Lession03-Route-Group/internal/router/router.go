@@ -0,0 +1,251 @@
+// Package router wires the repositories, handlers and routes of the API into
+// a gin engine, so main, the client generator and the tests all serve the
+// exact same routes.
+package router
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	v1handler "github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/api/v1/handler"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/cache"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/content"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/hub"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/middleware"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/openapi"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/remoteimage"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/repository"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/search"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/seed"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/stream"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/suggest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/webhook"
+)
+
+const (
+	userByIDRoute    = "/:id"
+	productByIDRoute = "/:id"
+)
+
+// DefaultUploadDir is where category images go when Config.UploadDir is empty
+const DefaultUploadDir = "uploads/categories"
+
+// Info heads the OpenAPI document served at /openapi.json
+var Info = openapi.Info{
+	Title:   "Lession03 Route Group API",
+	Version: "1.0.0",
+}
+
+type Config struct {
+	UploadDir string
+	// Images, when set, fetches every image URL in product and category
+	// bodies to check it serves a real image (see remoteimage). With Jobs
+	// that happens in a job once they are saved, else before.
+	Images       *remoteimage.Verifier
+	IngestImages bool // with Images: category images are copied into UploadDir
+	// Jobs runs background work, the handlers of the job kinds are
+	// registered on it. Without it GET /jobs/:id answers 503.
+	Jobs *jobs.Queue
+	// Webhooks gets the domain events of the handlers (built on Jobs), next
+	// to the admin dashboards. Without it the /webhooks routes answer 503.
+	Webhooks *webhook.Service
+	// DB holds the API tokens of "Authorization: Bearer". Without it every
+	// caller is anonymous and a token is a 401.
+	DB *sql.DB
+	// Progress carries the events of GET /events, a new broker keeping
+	// ProgressHistory events when nil
+	Progress *stream.Broker
+	// TrustedProxies are the addresses or CIDRs of the reverse proxies whose
+	// X-Forwarded-For gives the client IP. With none, the default, it is the
+	// peer's address: the header is anyone's to send.
+	TrustedProxies []string
+	// Cache keeps the responses of the product and user reads, and gets the
+	// domain events to invalidate them. Without it nothing is cached.
+	Cache *cache.Cache
+}
+
+// CacheTTL is how long a cached read lives when nothing it shows changes
+const CacheTTL = time.Minute
+
+// ProgressHistory is how many events GET /events keeps for clients resuming
+// with Last-Event-ID
+const ProgressHistory = 1000
+
+// New builds the engine with fresh in-memory repositories, users in cfg.DB
+// when there is one
+func New(cfg Config) *gin.Engine {
+	if cfg.UploadDir == "" {
+		cfg.UploadDir = DefaultUploadDir
+	}
+
+	// gin.Default, minus the seeding requests below in the access log
+	r := gin.New()
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{
+		Skip: func(c *gin.Context) bool { return seed.IsInProcess(c.Request.Context()) },
+	}), gin.Recovery())
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(err)
+	}
+
+	// Users live where the API tokens are, when there is a database
+	var userRepo v1handler.UserStore = repository.NewUserRepository()
+	if cfg.DB != nil {
+		userRepo = repository.NewSQLUserRepository(cfg.DB)
+	}
+
+	productRepo := repository.NewProductRepository()
+	productIndex := search.NewProductIndex()
+	productIndex.SyncProducts(productRepo)
+	productSuggester := suggest.NewSuggester()
+	productSuggester.SyncProducts(productRepo)
+
+	// Domain events go to the admin dashboards, and to webhooks when there are
+	notifications := hub.New()
+	publisher := events.All{notifications}
+	if cfg.Webhooks != nil {
+		publisher = append(publisher, notSeeded{cfg.Webhooks})
+	}
+	if cfg.Cache != nil {
+		publisher = append(publisher, cfg.Cache)
+	}
+	cached := func(query any, tags ...string) gin.HandlerFunc {
+		return middleware.Cache(cfg.Cache, middleware.CacheRule{TTL: CacheTTL, Query: query, Tags: tags})
+	}
+	// users are people: kept here, not in a CDN
+	cachedUsers := func(query any) gin.HandlerFunc {
+		return middleware.Cache(cfg.Cache, middleware.CacheRule{TTL: CacheTTL, Query: query, Tags: []string{"users"}, Private: true})
+	}
+
+	progress := cfg.Progress
+	if progress == nil {
+		progress = stream.NewBroker(ProgressHistory)
+	}
+	streamHandler := v1handler.NewStreamHandler(progress)
+
+	userHandler := v1handler.NewUserHandler(userRepo, publisher)
+	images := v1handler.ImageCheck{Verifier: cfg.Images, Ingest: cfg.IngestImages, Queue: cfg.Jobs}
+	categoryRepo := repository.NewCategoryRepository()
+	productHandler := v1handler.NewProductHandler(productRepo, categoryRepo, productIndex, productSuggester, images, publisher, progress)
+	categoryHandler := v1handler.NewCategoryHandler(categoryRepo, productRepo, cfg.UploadDir, images, publisher, progress)
+	jobHandler := v1handler.NewJobHandler(cfg.Jobs)
+	webhookHandler := v1handler.NewWebhookHandler(cfg.Webhooks)
+	notificationHandler := v1handler.NewNotificationHandler(notifications)
+	if cfg.Jobs != nil {
+		cfg.Jobs.Register(v1handler.CleanupUploadsJob, categoryHandler.CleanupUploads, jobs.Options{MaxAttempts: 3})
+		if cfg.Images != nil {
+			cfg.Jobs.Register(v1handler.ProductImagesJob, productHandler.CheckImages, jobs.Options{Workers: 4})
+			cfg.Jobs.Register(v1handler.CategoryImageJob, categoryHandler.CheckImage, jobs.Options{Workers: 4})
+		}
+		cfg.Jobs.Observe(streamHandler.JobChanged)
+	}
+
+	// Retried POSTs with the same Idempotency-Key don't create duplicates
+	idempotent := middleware.Idempotency(middleware.NewIdempotencyStore(middleware.IdempotencyConfig{}))
+
+	// Serve files from "uploads" folder under /static/ path
+	r.Static("/api/static/categories", cfg.UploadDir)
+
+	// Group for version 1. Bodies are JSON, XML, MessagePack or protobuf as
+	// the client asks, but for the routes with media types of their own.
+	negotiate := content.Negotiate("/api/v1/products/export", "/api/v1/events", "/api/v1/notifications")
+	v1 := r.Group("/api/v1", negotiate, middleware.Authenticate(cfg.DB))
+	{
+		// /api/v1/users group
+		users := v1.Group("/users")
+		{
+			users.GET("", cachedUsers(dto.ListQuery{}), userHandler.GetUsers)
+			users.GET("/uuid/:uuid", userHandler.GetUserByUUID)
+			users.GET("/slug", userHandler.GetUserWithoutSlug)
+			users.GET("/slug/:slug", cachedUsers(nil), userHandler.GetUserBySlug)
+			users.GET(userByIDRoute, cachedUsers(nil), userHandler.GetUserByID)
+			users.POST("", userHandler.CreateUser)
+			users.PUT(userByIDRoute, userHandler.UpdateUser)
+			users.DELETE(userByIDRoute, userHandler.DeleteUser)
+		}
+
+		// /api/v1/products group
+		products := v1.Group("/products")
+		{
+			products.GET("", cached(dto.ProductQuery{}, "products"), productHandler.GetProducts)
+			products.GET("/suggest", productHandler.SuggestProducts)
+			products.GET("/category/:lang", cached(nil, "products", "categories"), productHandler.GetProductByLang)
+			products.GET("/slug/:slug", productHandler.GetProductBySlug)
+			products.GET("/export", productHandler.ExportProducts)
+			products.POST("/import", idempotent, productHandler.ImportProducts)
+			products.GET(productByIDRoute, productHandler.GetProductByID)
+			products.POST("", idempotent, productHandler.CreateProduct)
+			products.PUT(productByIDRoute, productHandler.UpdateProduct)
+			products.DELETE(productByIDRoute, productHandler.DeleteProduct)
+			products.POST(productByIDRoute+"/reservations", idempotent, productHandler.ReserveStock)
+		}
+
+		// Stock held by ReserveStock, until the order commits or releases it
+		reservations := v1.Group("/reservations")
+		{
+			reservations.POST("/:id/commit", idempotent, productHandler.CommitReservation)
+			reservations.DELETE("/:id", productHandler.ReleaseReservation)
+		}
+
+		// Bulk imports started by POST /products/import
+		imports := v1.Group("/imports")
+		{
+			imports.GET("/:id", productHandler.GetImport)
+			imports.GET("/:id/results", productHandler.GetImportResults)
+		}
+
+		// Background jobs, for clients to poll
+		v1.GET("/jobs/:id", jobHandler.GetJob)
+
+		// Upload and job progress as Server-Sent Events, for clients not to poll
+		v1.GET("/events", middleware.RequireUser, streamHandler.Stream)
+
+		// Live changes for admin dashboards over WebSocket, see package hub
+		v1.GET("/notifications", notificationHandler.Notifications)
+		v1.POST("/notifications/tickets", middleware.RequireAdmin, notificationHandler.CreateNotificationTicket)
+
+		// Partner endpoints notified of changes, see package webhook
+		webhooks := v1.Group("/webhooks", middleware.RequireAdmin)
+		{
+			webhooks.GET("", webhookHandler.GetWebhooks)
+			webhooks.POST("", idempotent, webhookHandler.CreateWebhook)
+			webhooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+			webhooks.GET("/:id/deliveries", webhookHandler.GetWebhookDeliveries)
+		}
+
+		categories := v1.Group("/categories")
+		{
+			categories.GET("", categoryHandler.GetCategories)
+			categories.POST("", categoryHandler.CreateCategory)
+			categories.GET("/:id/tree", categoryHandler.GetCategoryTree)
+			categories.GET("/:id/products", categoryHandler.GetCategoryProducts)
+			categories.PUT("/:id/parent", categoryHandler.MoveCategory)
+			categories.POST("/upload", idempotent, categoryHandler.UploadCategoryImage)
+			categories.POST("/upload-multiple", idempotent, categoryHandler.UploadMultipleCategoryImages)
+		}
+	}
+
+	// API documentation, generated from the routes above and the DTO binding tags
+	openapi.Register(r, v1handler.Docs(), Info, "/openapi.json", "/docs")
+
+	// The embedded base fixtures, a failure here is a bug in users.yaml
+	if _, err := seed.New(seed.InProcess(r)).Apply(context.Background(), seed.Base()); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// notSeeded leaves out the events of the seeding requests: every start
+// creates the same fixtures again, partners heard of them the first time
+type notSeeded struct{ events.Publisher }
+
+func (p notSeeded) Publish(ctx context.Context, e events.Event) error {
+	if seed.IsInProcess(ctx) {
+		return nil
+	}
+	return p.Publisher.Publish(ctx, e)
+}
@@ -0,0 +1,475 @@
+package router_test
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+)
+
+type routeCase struct {
+	name    string // also the golden file, testdata/golden/<name>.json
+	route   string // as gin registered it, "GET /api/v1/products/:id"
+	path    string
+	opts    []apitest.RequestOption
+	prepare func(h *apitest.Harness, rc *routeCase) // seeds data, fills in ETags
+	status  int
+	golden  bool
+}
+
+func seedProduct(h *apitest.Harness, _ *routeCase) { h.CreateProduct(apitest.ProductRequest()) }
+
+func withETag(path string) func(h *apitest.Harness, rc *routeCase) {
+	return func(h *apitest.Harness, rc *routeCase) {
+		rc.opts = append(rc.opts, apitest.Header("If-Match", h.ETag(path)))
+	}
+}
+
+func seedProductWithETag(h *apitest.Harness, rc *routeCase) {
+	seedProduct(h, rc)
+	withETag("/api/v1/products/1")(h, rc)
+}
+
+// renameProduct seeds product 1 and renames it, so "gopher-tee" becomes an old slug
+func renameProduct(h *apitest.Harness, rc *routeCase) {
+	seedProduct(h, rc)
+	h.Do("PUT", "/api/v1/products/1",
+		apitest.Header("If-Match", h.ETag("/api/v1/products/1")),
+		apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) { p.Name = "Gopher Hoodie" })),
+	).Expect(200)
+}
+
+func seedProductWithVariant(h *apitest.Harness, _ *routeCase) {
+	h.CreateProduct(apitest.ProductRequest(apitest.WithVariant))
+}
+
+// seedReservation reserves 2 of TEE-GO-L and points rc at the reservation
+func seedReservation(h *apitest.Harness, rc *routeCase) {
+	seedProductWithVariant(h, rc)
+	var res dto.ReservationResponse
+	h.Do("POST", "/api/v1/products/1/reservations",
+		apitest.JSON(dto.ReserveRequest{SKU: "TEE-GO-L", Quantity: 2}),
+	).Expect(201).Decode(&res)
+	rc.path = strings.Replace(rc.path, ":id", res.Data.ID, 1)
+}
+
+// ndjson is reqs as an NDJSON import file
+func ndjson(reqs ...dto.CreateProductRequest) []byte {
+	var buf bytes.Buffer
+	for _, req := range reqs {
+		if err := json.NewEncoder(&buf).Encode(req); err != nil {
+			panic(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// startImport imports the Gopher Tee and points rc at the import
+func startImport(h *apitest.Harness, rc *routeCase) {
+	var res dto.ImportResponse
+	h.Do("POST", "/api/v1/products/import",
+		apitest.Body("application/x-ndjson", ndjson(apitest.ProductRequest())),
+	).Expect(202).Decode(&res)
+	rc.path = strings.Replace(rc.path, ":id", res.Data.ID, 1)
+}
+
+// seedCategoryTree makes Clothes(1) > Shirts(2) > Tees(3) and Mugs(4), with
+// the Gopher Tee in Tees and the Gopher Hoodie straight in Clothes
+func seedCategoryTree(h *apitest.Harness, _ *routeCase) {
+	for _, c := range []struct {
+		name   string
+		parent int
+	}{{"Clothes", 0}, {"Shirts", 1}, {"Tees", 2}, {"Mugs", 0}} {
+		h.CreateCategory(apitest.CategoryRequest(func(req *dto.CreateCategoryRequest) {
+			req.Name, req.ParentID = c.name, c.parent
+		}))
+	}
+	h.CreateProduct(apitest.ProductRequest(func(p *dto.CreateProductRequest) { p.CategoryIDs = []int{3} }))
+	h.CreateProduct(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+		p.Name, p.CategoryIDs = "Gopher Hoodie", []int{1}
+	}))
+}
+
+var routeCases = []routeCase{
+	// users
+	{name: "users_list", route: "GET /api/v1/users", path: "/api/v1/users?sort=-name&limit=2", status: 200, golden: true},
+	{name: "users_list_bad_sort", route: "GET /api/v1/users", path: "/api/v1/users?sort=email", status: 400, golden: true},
+	{name: "users_uuid", route: "GET /api/v1/users/uuid/:uuid", path: "/api/v1/users/uuid/3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f", status: 200, golden: true},
+	{name: "users_slug_missing", route: "GET /api/v1/users/slug", path: "/api/v1/users/slug", status: 200, golden: true},
+	{name: "users_slug", route: "GET /api/v1/users/slug/:slug", path: "/api/v1/users/slug/alice-user", status: 200, golden: true},
+	{name: "users_slug_unknown", route: "GET /api/v1/users/slug/:slug", path: "/api/v1/users/slug/nobody", status: 404, golden: true},
+	{
+		name: "users_slug_old", route: "GET /api/v1/users/slug/:slug", path: "/api/v1/users/slug/bob-user",
+		prepare: func(h *apitest.Harness, _ *routeCase) {
+			h.Do("PUT", "/api/v1/users/2",
+				apitest.Header("If-Match", h.ETag("/api/v1/users/2")),
+				apitest.JSON(dto.CreateUserRequest{Name: "Robert"}),
+			).Expect(200)
+		},
+		status: 301,
+	},
+	{name: "users_get", route: "GET /api/v1/users/:id", path: "/api/v1/users/1", status: 200, golden: true},
+	{name: "users_get_not_found", route: "GET /api/v1/users/:id", path: "/api/v1/users/99", status: 404, golden: true},
+	{name: "users_get_not_a_number", route: "GET /api/v1/users/:id", path: "/api/v1/users/abc", status: 400, golden: true},
+	{
+		name: "users_create", route: "POST /api/v1/users", path: "/api/v1/users",
+		opts:   []apitest.RequestOption{apitest.JSON(dto.CreateUserRequest{Name: "Dana", Slug: "dana-user"})},
+		status: 201, golden: true,
+	},
+	{
+		name: "users_create_slug_taken", route: "POST /api/v1/users", path: "/api/v1/users",
+		opts:   []apitest.RequestOption{apitest.JSON(dto.CreateUserRequest{Name: "Alice", Slug: "alice-user"})},
+		status: 400, golden: true,
+	},
+	{
+		name: "users_update", route: "PUT /api/v1/users/:id", path: "/api/v1/users/2",
+		opts:    []apitest.RequestOption{apitest.JSON(dto.CreateUserRequest{Name: "Robert", Slug: "robert-user"})},
+		prepare: withETag("/api/v1/users/2"), status: 200, golden: true,
+	},
+	{
+		name: "users_update_without_if_match", route: "PUT /api/v1/users/:id", path: "/api/v1/users/2",
+		opts:   []apitest.RequestOption{apitest.JSON(dto.CreateUserRequest{Name: "Robert"})},
+		status: 428, golden: true,
+	},
+	{
+		name: "users_delete", route: "DELETE /api/v1/users/:id", path: "/api/v1/users/3",
+		prepare: withETag("/api/v1/users/3"), status: 200, golden: true,
+	},
+	{
+		name: "users_delete_stale", route: "DELETE /api/v1/users/:id", path: "/api/v1/users/3",
+		opts:   []apitest.RequestOption{apitest.Header("If-Match", `"v0"`)},
+		status: 412, golden: true,
+	},
+
+	// products
+	{name: "products_search", route: "GET /api/v1/products", path: "/api/v1/products?search=gopher", prepare: seedProduct, status: 200, golden: true},
+	{name: "products_search_padded", route: "GET /api/v1/products", path: "/api/v1/products?search=%20%20gopher%20", prepare: seedProduct, status: 200},
+	{name: "products_search_price_filter", route: "GET /api/v1/products", path: "/api/v1/products?search=gopher&price_max=1000&currency=USD", prepare: seedProduct, status: 200, golden: true},
+	{name: "products_suggest", route: "GET /api/v1/products/suggest", path: "/api/v1/products/suggest?q=go", prepare: seedProduct, status: 200, golden: true},
+	{name: "products_suggest_missing_q", route: "GET /api/v1/products/suggest", path: "/api/v1/products/suggest", status: 400, golden: true},
+	{name: "products_lang", route: "GET /api/v1/products/category/:lang", path: "/api/v1/products/category/golang", status: 200, golden: true},
+	{name: "products_lang_unknown", route: "GET /api/v1/products/category/:lang", path: "/api/v1/products/category/ruby", status: 400, golden: true},
+	{name: "products_slug", route: "GET /api/v1/products/slug/:slug", path: "/api/v1/products/slug/gopher-tee", prepare: seedProduct, status: 200, golden: true},
+	{name: "products_slug_old", route: "GET /api/v1/products/slug/:slug", path: "/api/v1/products/slug/gopher-tee", prepare: renameProduct, status: 301},
+	{name: "products_slug_not_found", route: "GET /api/v1/products/slug/:slug", path: "/api/v1/products/slug/gopher-tee", status: 404, golden: true},
+	{name: "products_slug_invalid", route: "GET /api/v1/products/slug/:slug", path: "/api/v1/products/slug/Gopher_Tee", status: 400, golden: true},
+	{name: "products_get", route: "GET /api/v1/products/:id", path: "/api/v1/products/1", prepare: seedProduct, status: 200, golden: true},
+	{name: "products_get_not_found", route: "GET /api/v1/products/:id", path: "/api/v1/products/1", status: 404, golden: true},
+	{
+		name: "products_create", route: "POST /api/v1/products", path: "/api/v1/products",
+		opts:   []apitest.RequestOption{apitest.JSON(apitest.ProductRequest())},
+		status: 201, golden: true,
+	},
+	{
+		name: "products_create_name_taken", route: "POST /api/v1/products", path: "/api/v1/products",
+		opts:    []apitest.RequestOption{apitest.JSON(apitest.ProductRequest())},
+		prepare: seedProduct, status: 400, golden: true,
+	},
+	{
+		name: "products_create_slug_taken", route: "POST /api/v1/products", path: "/api/v1/products",
+		opts: []apitest.RequestOption{apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+			p.Name, p.Slug = "Gopher Mug", "gopher-tee"
+		}))},
+		prepare: seedProduct, status: 400, golden: true,
+	},
+	{
+		name: "products_create_unknown_category", route: "POST /api/v1/products", path: "/api/v1/products",
+		opts: []apitest.RequestOption{apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+			p.CategoryIDs = []int{7}
+		}))},
+		status: 400, golden: true,
+	},
+	{
+		name: "products_create_invalid_json", route: "POST /api/v1/products", path: "/api/v1/products",
+		opts:   []apitest.RequestOption{apitest.Body("application/json", []byte(`{"name":`))},
+		status: 400,
+	},
+	{
+		name: "products_create_unsupported_media_type", route: "POST /api/v1/products", path: "/api/v1/products",
+		opts:   []apitest.RequestOption{apitest.Body("text/plain", []byte("Gopher Tee"))},
+		status: 415, golden: true,
+	},
+	{
+		name: "products_get_not_acceptable", route: "GET /api/v1/products/:id", path: "/api/v1/products/1",
+		opts:    []apitest.RequestOption{apitest.Header("Accept", "text/html, application/json;q=0")},
+		prepare: seedProduct, status: 406, golden: true,
+	},
+	{
+		name: "products_update", route: "PUT /api/v1/products/:id", path: "/api/v1/products/1",
+		opts: []apitest.RequestOption{apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+			p.Price.Amount = 3000
+		}))},
+		prepare: seedProductWithETag, status: 200, golden: true,
+	},
+	{
+		name: "products_delete", route: "DELETE /api/v1/products/:id", path: "/api/v1/products/1",
+		prepare: seedProductWithETag, status: 200, golden: true,
+	},
+
+	// inventory
+	{
+		name: "products_create_with_variant", route: "POST /api/v1/products", path: "/api/v1/products",
+		opts:   []apitest.RequestOption{apitest.JSON(apitest.ProductRequest(apitest.WithVariant))},
+		status: 201, golden: true,
+	},
+	{
+		name: "products_reserve", route: "POST /api/v1/products/:id/reservations", path: "/api/v1/products/1/reservations",
+		opts:    []apitest.RequestOption{apitest.JSON(dto.ReserveRequest{SKU: "tee-go-l", Quantity: 2})},
+		prepare: seedProductWithVariant, status: 201, golden: true,
+	},
+	{
+		name: "products_reserve_too_many", route: "POST /api/v1/products/:id/reservations", path: "/api/v1/products/1/reservations",
+		opts:    []apitest.RequestOption{apitest.JSON(dto.ReserveRequest{SKU: "TEE-GO-L", Quantity: 6})},
+		prepare: seedProductWithVariant, status: 409, golden: true,
+	},
+	{
+		name: "products_reserve_unknown_sku", route: "POST /api/v1/products/:id/reservations", path: "/api/v1/products/1/reservations",
+		opts:    []apitest.RequestOption{apitest.JSON(dto.ReserveRequest{SKU: "MUG", Quantity: 1})},
+		prepare: seedProductWithVariant, status: 404, golden: true,
+	},
+	{
+		name: "reservations_commit", route: "POST /api/v1/reservations/:id/commit", path: "/api/v1/reservations/:id/commit",
+		prepare: seedReservation, status: 200, golden: true,
+	},
+	{
+		name: "reservations_commit_unknown", route: "POST /api/v1/reservations/:id/commit",
+		path:   "/api/v1/reservations/3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f/commit",
+		status: 404, golden: true,
+	},
+	{
+		name: "reservations_release", route: "DELETE /api/v1/reservations/:id", path: "/api/v1/reservations/:id",
+		prepare: seedReservation, status: 200, golden: true,
+	},
+
+	// bulk import and export
+	{name: "products_export", route: "GET /api/v1/products/export", path: "/api/v1/products/export?format=csv", prepare: seedProduct, status: 200},
+	{
+		name: "products_import", route: "POST /api/v1/products/import", path: "/api/v1/products/import?dry_run=true",
+		opts:   []apitest.RequestOption{apitest.Body("application/x-ndjson", ndjson(apitest.ProductRequest()))},
+		status: 202,
+	},
+	{
+		name: "products_import_unknown_format", route: "POST /api/v1/products/import", path: "/api/v1/products/import",
+		opts:   []apitest.RequestOption{apitest.Body("text/plain", []byte("name\nGopher Tee\n"))},
+		status: 400, golden: true,
+	},
+	{name: "imports_get", route: "GET /api/v1/imports/:id", path: "/api/v1/imports/:id", prepare: startImport, status: 200},
+	{
+		name: "imports_get_not_found", route: "GET /api/v1/imports/:id",
+		path: "/api/v1/imports/3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f", status: 404, golden: true,
+	},
+	{name: "imports_results", route: "GET /api/v1/imports/:id/results", path: "/api/v1/imports/:id/results", prepare: startImport, status: 200},
+
+	// jobs, see jobs_test.go for a router with a queue
+	{
+		name: "jobs_get_no_queue", route: "GET /api/v1/jobs/:id",
+		path: "/api/v1/jobs/3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f", status: 503, golden: true,
+	},
+	{name: "jobs_get_bad_id", route: "GET /api/v1/jobs/:id", path: "/api/v1/jobs/42", status: 400, golden: true},
+
+	// events; the streams themselves are in stream_test.go
+	{name: "events_anonymous", route: "GET /api/v1/events", path: "/api/v1/events?topic=uploads", status: 401, golden: true},
+	{
+		name: "events_unknown_token", route: "GET /api/v1/events", path: "/api/v1/events?topic=jobs",
+		opts: []apitest.RequestOption{apitest.Header("Authorization", "Bearer nope")}, status: 401, golden: true,
+	},
+
+	// notifications; the WebSocket itself is in notifications_test.go
+	{name: "notifications_anonymous", route: "GET /api/v1/notifications", path: "/api/v1/notifications", status: 401, golden: true},
+	{
+		name: "notifications_bad_ticket", route: "GET /api/v1/notifications", path: "/api/v1/notifications?ticket=nope",
+		status: 401, golden: true,
+	},
+	{name: "notifications_ticket_anonymous", route: "POST /api/v1/notifications/tickets", path: "/api/v1/notifications/tickets", status: 401},
+
+	// webhooks are for admins, the rest is in webhooks_test.go
+	{name: "webhooks_list_anonymous", route: "GET /api/v1/webhooks", path: "/api/v1/webhooks", status: 401, golden: true},
+	{
+		name: "webhooks_create_anonymous", route: "POST /api/v1/webhooks", path: "/api/v1/webhooks",
+		opts:   []apitest.RequestOption{apitest.JSON(map[string]any{"url": "https://partner.example/hooks", "events": []string{"product.created"}})},
+		status: 401,
+	},
+	{name: "webhooks_delete_anonymous", route: "DELETE /api/v1/webhooks/:id", path: "/api/v1/webhooks/42", status: 401},
+	{
+		name: "webhooks_deliveries_anonymous", route: "GET /api/v1/webhooks/:id/deliveries",
+		path: "/api/v1/webhooks/3f2b8c1e-5d4a-4c6b-9e7f-1a2b3c4d5e6f/deliveries", status: 401,
+	},
+
+	// categories
+	{name: "categories_list", route: "GET /api/v1/categories", path: "/api/v1/categories", status: 200, golden: true},
+	{
+		name: "categories_create", route: "POST /api/v1/categories", path: "/api/v1/categories",
+		opts:   []apitest.RequestOption{apitest.Form(apitest.CategoryForm(apitest.CategoryRequest()))},
+		status: 201, golden: true,
+	},
+	{
+		name: "categories_create_unknown_parent", route: "POST /api/v1/categories", path: "/api/v1/categories",
+		opts: []apitest.RequestOption{apitest.Form(apitest.CategoryForm(apitest.CategoryRequest(func(c *dto.CreateCategoryRequest) {
+			c.ParentID = 42
+		})))},
+		status: 400, golden: true,
+	},
+	{name: "categories_tree", route: "GET /api/v1/categories/:id/tree", path: "/api/v1/categories/1/tree", prepare: seedCategoryTree, status: 200, golden: true},
+	{name: "categories_tree_not_found", route: "GET /api/v1/categories/:id/tree", path: "/api/v1/categories/1/tree", status: 404, golden: true},
+	{
+		name: "categories_products", route: "GET /api/v1/categories/:id/products", path: "/api/v1/categories/1/products?sort=name",
+		prepare: seedCategoryTree, status: 200, golden: true,
+	},
+	{
+		name: "categories_products_direct", route: "GET /api/v1/categories/:id/products", path: "/api/v1/categories/1/products?direct=true",
+		prepare: seedCategoryTree, status: 200, golden: true,
+	},
+	{
+		name: "categories_move", route: "PUT /api/v1/categories/:id/parent", path: "/api/v1/categories/2/parent",
+		opts: []apitest.RequestOption{apitest.JSON(dto.MoveCategoryRequest{ParentID: 4})},
+		prepare: func(h *apitest.Harness, rc *routeCase) {
+			seedCategoryTree(h, rc)
+			withETag("/api/v1/categories/2/tree")(h, rc)
+		},
+		status: 200, golden: true,
+	},
+	{
+		name: "categories_move_into_own_subtree", route: "PUT /api/v1/categories/:id/parent", path: "/api/v1/categories/1/parent",
+		opts: []apitest.RequestOption{apitest.JSON(dto.MoveCategoryRequest{ParentID: 3})},
+		prepare: func(h *apitest.Harness, rc *routeCase) {
+			seedCategoryTree(h, rc)
+			withETag("/api/v1/categories/1/tree")(h, rc)
+		},
+		status: 409, golden: true,
+	},
+	{
+		name: "categories_create_bad_image", route: "POST /api/v1/categories", path: "/api/v1/categories",
+		opts: []apitest.RequestOption{apitest.Form(apitest.CategoryForm(apitest.CategoryRequest(func(c *dto.CreateCategoryRequest) {
+			c.ImageURL = "https://example.com/shirts.gif"
+		})))},
+		status: 400, golden: true,
+	},
+	{
+		name: "categories_upload", route: "POST /api/v1/categories/upload", path: "/api/v1/categories/upload?source=admin",
+		opts: []apitest.RequestOption{apitest.Multipart(apitest.NewMultipart().
+			Field("name", "Shirts").Field("description", "summer").File("image", "shirts.png", apitest.PNG))},
+		status: 200, golden: true,
+	},
+	{
+		name: "categories_upload_not_an_image", route: "POST /api/v1/categories/upload", path: "/api/v1/categories/upload",
+		opts: []apitest.RequestOption{apitest.Multipart(apitest.NewMultipart().
+			Field("name", "Shirts").File("image", "shirts.png", []byte("<?php echo 1; ?>")))},
+		status: 400, golden: true,
+	},
+	{
+		name: "categories_upload_multiple", route: "POST /api/v1/categories/upload-multiple", path: "/api/v1/categories/upload-multiple",
+		opts: []apitest.RequestOption{apitest.Multipart(apitest.NewMultipart().
+			File("images", "a.png", apitest.PNG).File("images", "b.jpg", apitest.JPEG).File("images", "c.txt", []byte("hi")))},
+		status: 200, golden: true,
+	},
+	{
+		name: "categories_upload_multiple_none", route: "POST /api/v1/categories/upload-multiple", path: "/api/v1/categories/upload-multiple",
+		opts:   []apitest.RequestOption{apitest.Multipart(apitest.NewMultipart().Field("name", "x"))},
+		status: 400, golden: true,
+	},
+
+	// static files and docs
+	{
+		name: "static_get", route: "GET /api/static/categories/*filepath", path: "/api/static/categories/logo.png",
+		prepare: writeUpload("logo.png"), status: 200,
+	},
+	{
+		name: "static_head", route: "HEAD /api/static/categories/*filepath", path: "/api/static/categories/logo.png",
+		prepare: writeUpload("logo.png"), status: 200,
+	},
+	{name: "openapi", route: "GET /openapi.json", path: "/openapi.json", status: 200},
+	{name: "docs", route: "GET /docs", path: "/docs", status: 200},
+}
+
+func writeUpload(name string) func(h *apitest.Harness, _ *routeCase) {
+	return func(h *apitest.Harness, _ *routeCase) {
+		if err := os.WriteFile(filepath.Join(h.UploadDir, name), apitest.PNG, 0o644); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	for _, rc := range routeCases {
+		t.Run(rc.name, func(t *testing.T) {
+			h := apitest.New(t)
+			if rc.prepare != nil {
+				rc.opts = append([]apitest.RequestOption(nil), rc.opts...)
+				rc.prepare(h, &rc)
+			}
+			method, _, _ := strings.Cut(rc.route, " ")
+			res := h.Do(method, rc.path, rc.opts...).Expect(rc.status)
+			if rc.golden {
+				res.Golden(rc.name)
+			}
+		})
+	}
+}
+
+// Every route the router registers needs at least one case above
+func TestRoutesAreCovered(t *testing.T) {
+	covered := map[string]bool{}
+	for _, rc := range routeCases {
+		covered[rc.route] = true
+	}
+	for _, rt := range apitest.New(t).Engine.Routes() {
+		if route := rt.Method + " " + rt.Path; !covered[route] {
+			t.Errorf("no test case for %s", route)
+		}
+	}
+}
+
+// X-Forwarded-For only counts from TrustedProxies: otherwise a caller could
+// pick the IP, and with it the Idempotency-Key scope, of anyone
+func TestForwardedForNeedsTrustedProxy(t *testing.T) {
+	tests := []struct {
+		proxies  []string
+		replayed string // to the second caller
+	}{
+		{nil, "true"},
+		{[]string{"192.0.2.1"}, ""}, // httptest's peer address
+	}
+	for _, tc := range tests {
+		h := apitest.NewWithConfig(t, router.Config{TrustedProxies: tc.proxies})
+		body := apitest.JSON(apitest.ProductRequest())
+		key := apitest.Header("Idempotency-Key", "same-key")
+		h.Do(http.MethodPost, "/api/v1/products", body, key, apitest.Header("X-Forwarded-For", "198.51.100.7")).Expect(http.StatusCreated)
+		res := h.Do(http.MethodPost, "/api/v1/products", body, key, apitest.Header("X-Forwarded-For", "203.0.113.9"))
+		if got := res.Header().Get("Idempotent-Replayed"); got != tc.replayed {
+			t.Errorf("trusting %v: replayed %q, want %q", tc.proxies, got, tc.replayed)
+		}
+	}
+}
+
+// A 201 carries the ETag of what was created, good for the first update
+func TestCreatedHasETag(t *testing.T) {
+	h := apitest.New(t)
+	for path, body := range map[string]any{
+		"/api/v1/users":    dto.CreateUserRequest{Name: "Dana", Email: "dana@example.com"},
+		"/api/v1/products": apitest.ProductRequest(),
+	} {
+		var created struct {
+			ID   int              // products
+			Data struct{ ID int } // users
+		}
+		res := h.Do(http.MethodPost, path, apitest.JSON(body)).Expect(http.StatusCreated).Decode(&created)
+		etag := res.Header().Get("ETag")
+		if etag == "" {
+			t.Errorf("POST %s: no ETag", path)
+			continue
+		}
+		id := cmp.Or(created.ID, created.Data.ID)
+		if got := h.ETag(path + "/" + strconv.Itoa(id)); got != etag {
+			t.Errorf("POST %s: ETag %s, GET has %s", path, etag, got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package router_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+func TestProductSlugs(t *testing.T) {
+	h := apitest.New(t)
+
+	var created dto.CreateProductResponse
+	h.Do(http.MethodPost, "/api/v1/products", apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+		p.Name = "Áo thun Golang"
+	}))).Expect(http.StatusCreated).Decode(&created)
+	if created.Slug != "ao-thun-golang" {
+		t.Fatalf("slug = %q, want ao-thun-golang", created.Slug)
+	}
+
+	// same slug source, different name: gets a suffix
+	var second dto.CreateProductResponse
+	h.Do(http.MethodPost, "/api/v1/products", apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+		p.Name = "Ao Thun Golang"
+	}))).Expect(http.StatusCreated).Decode(&second)
+	if second.Slug != "ao-thun-golang-2" {
+		t.Fatalf("slug = %q, want ao-thun-golang-2", second.Slug)
+	}
+
+	// rename: the old slug redirects, query string and all
+	h.Do(http.MethodPut, "/api/v1/products/1",
+		apitest.Header("If-Match", h.ETag("/api/v1/products/1")),
+		apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) { p.Name = "Áo khoác Golang" })),
+	).Expect(http.StatusOK)
+
+	res := h.Do(http.MethodGet, "/api/v1/products/slug/ao-thun-golang?ref=mail").Expect(http.StatusMovedPermanently)
+	if got, want := res.Header().Get("Location"), "/api/v1/products/slug/ao-khoac-golang?ref=mail"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+	h.Do(http.MethodGet, "/api/v1/products/slug/ao-khoac-golang").Expect(http.StatusOK)
+
+	// old slugs stay reserved for the product that had them
+	res = h.Do(http.MethodPost, "/api/v1/products", apitest.JSON(apitest.ProductRequest(func(p *dto.CreateProductRequest) {
+		p.Name, p.Slug = "Something Else", "ao-thun-golang"
+	}))).Expect(http.StatusBadRequest)
+	if res.Fields()["slug"] == "" {
+		t.Errorf("want a slug field error, got %v", res.Fields())
+	}
+}
+
+func TestUserSlugRedirect(t *testing.T) {
+	for name, cfg := range map[string]func(t *testing.T) router.Config{
+		"memory":   func(t *testing.T) router.Config { return router.Config{} },
+		"database": func(t *testing.T) router.Config { return router.Config{DB: apitest.Queue(t).DB()} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := apitest.NewWithConfig(t, cfg(t))
+
+			h.Do(http.MethodPut, "/api/v1/users/1",
+				apitest.Header("If-Match", h.ETag("/api/v1/users/1")),
+				apitest.JSON(dto.CreateUserRequest{Name: "Alicia"}),
+			).Expect(http.StatusOK)
+
+			res := h.Do(http.MethodGet, "/api/v1/users/slug/alice-user").Expect(http.StatusMovedPermanently)
+			if got, want := res.Header().Get("Location"), "/api/v1/users/slug/alicia"; got != want {
+				t.Errorf("Location = %q, want %q", got, want)
+			}
+			// old slugs stay reserved
+			h.Do(http.MethodPost, "/api/v1/users", apitest.JSON(dto.CreateUserRequest{Name: "Someone", Slug: "alice-user"})).Expect(http.StatusBadRequest)
+		})
+	}
+}
+
+// The users of lession03 user create-admin are the ones the API serves
+func TestAdminsAreUsers(t *testing.T) {
+	q := apitest.Queue(t)
+	h := apitest.NewWithConfig(t, router.Config{DB: q.DB()})
+	root := apiToken(t, q.DB(), "Root", "admin")
+
+	var user struct{ Data models.User }
+	h.Do(http.MethodGet, "/api/v1/users/slug/root").Expect(http.StatusOK).Decode(&user)
+	if user.Data.Name != "Root" || user.Data.Version != 1 {
+		t.Fatalf("admin %+v", user.Data)
+	}
+	path := "/api/v1/users/" + strconv.Itoa(user.Data.ID)
+	h.Do(http.MethodPut, path, apitest.Header("If-Match", h.ETag(path)), apitest.JSON(dto.CreateUserRequest{Name: "Root Admin"})).Expect(http.StatusOK)
+	h.Do(http.MethodGet, "/api/v1/users/slug/root").Expect(http.StatusMovedPermanently)
+
+	// its token goes with it
+	h.Do(http.MethodDelete, path, apitest.Header("If-Match", h.ETag(path))).Expect(http.StatusOK)
+	h.Do(http.MethodGet, "/api/v1/webhooks", apitest.Header("Authorization", "Bearer "+root)).Expect(http.StatusUnauthorized)
+}
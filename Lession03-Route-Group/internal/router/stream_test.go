@@ -0,0 +1,206 @@
+package router_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	v1handler "github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/api/v1/handler"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+)
+
+type sseEvent struct {
+	id, name, data string
+}
+
+// follow opens GET /events?query on srv and hands its events over as they come
+func follow(t *testing.T, srv *httptest.Server, query string, headers map[string]string) <-chan sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/events?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("status %d, Content-Type %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer close(events)
+		defer res.Body.Close()
+		var e sseEvent
+		for sc := bufio.NewScanner(res.Body); sc.Scan(); {
+			line := sc.Text()
+			switch {
+			case line == "":
+				if e.data != "" { // the retry and heartbeat blocks have none
+					events <- e
+				}
+				e = sseEvent{}
+			case strings.HasPrefix(line, "id:"):
+				e.id = line[len("id:"):]
+			case strings.HasPrefix(line, "event:"):
+				e.name = line[len("event:"):]
+			case strings.HasPrefix(line, "data:"):
+				e.data += line[len("data:"):]
+			}
+		}
+	}()
+	return events
+}
+
+func next(t *testing.T, events <-chan sseEvent, name string, v any) sseEvent {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("stream ended")
+		}
+		if e.name != name {
+			t.Fatalf("got %q event %s, want %q", e.name, e.data, name)
+		}
+		if err := json.Unmarshal([]byte(e.data), v); err != nil {
+			t.Fatal(err)
+		}
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no %q event", name)
+	}
+	return sseEvent{}
+}
+
+// none checks nothing comes for a moment
+func none(t *testing.T, events <-chan sseEvent) {
+	t.Helper()
+	select {
+	case e := <-events:
+		t.Errorf("unexpected %q event %s", e.name, e.data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestUploadProgressStream(t *testing.T) {
+	q := apitest.Queue(t)
+	h := apitest.NewWithConfig(t, router.Config{DB: q.DB()})
+	srv := h.Server()
+	me := map[string]string{"Authorization": "Bearer " + apiToken(t, q.DB(), "Ann", "user")}
+	mine := follow(t, srv, "topic=uploads", me)
+	theirs := follow(t, srv, "topic=uploads", map[string]string{"Authorization": "Bearer " + apiToken(t, q.DB(), "Bob", "user")})
+
+	h.Do(http.MethodPost, "/api/v1/categories/upload-multiple",
+		apitest.Header("Authorization", me["Authorization"]), apitest.Header(v1handler.UploadIDHeader, "batch-1"),
+		apitest.Multipart(apitest.NewMultipart().File("images", "a.png", apitest.PNG).File("images", "c.txt", []byte("hi"))),
+	).Expect(http.StatusOK)
+
+	var saved, failed dto.UploadProgress
+	first := next(t, mine, "upload", &saved)
+	second := next(t, mine, "upload", &failed)
+	if saved.UploadID != "batch-1" || saved.File != "a.png" || saved.Status != "saved" || saved.Done != 1 || saved.Total != 2 || saved.URL == "" {
+		t.Errorf("first: %+v", saved)
+	}
+	if failed.File != "c.txt" || failed.Status != "failed" || failed.Done != 2 || failed.Error == "" {
+		t.Errorf("second: %+v", failed)
+	}
+	none(t, theirs)
+
+	// Resuming replays what came after Last-Event-ID
+	resumed := follow(t, srv, "topic=uploads", map[string]string{"Authorization": me["Authorization"], "Last-Event-ID": first.id})
+	var again dto.UploadProgress
+	if e := next(t, resumed, "upload", &again); e.id != second.id || again != failed {
+		t.Errorf("replayed %s: %+v", e.id, again)
+	}
+
+	// An ID from before a restart can't be resumed
+	restarted := follow(t, srv, "topic=uploads", map[string]string{"Authorization": me["Authorization"], "Last-Event-ID": "999"})
+	next(t, restarted, "reset", &dto.StreamReset{})
+	next(t, restarted, "upload", &dto.UploadProgress{})
+
+	h.Do(http.MethodGet, "/api/v1/events?topic=orders", apitest.Header("Authorization", me["Authorization"])).Expect(http.StatusBadRequest)
+	h.Do(http.MethodGet, "/api/v1/events?topic=uploads",
+		apitest.Header("Authorization", me["Authorization"]), apitest.Header("Last-Event-ID", "abc"),
+	).Expect(http.StatusBadRequest)
+}
+
+// apiToken makes a user with role and a token the way lession03 user
+// create-admin does
+func apiToken(t *testing.T, conn *sql.DB, name, role string) string {
+	t.Helper()
+	now := time.Now().UTC()
+	res, err := conn.Exec(`INSERT INTO users (uuid, name, slug, email, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		uuid.NewString(), name, strings.ToLower(name), strings.ToLower(name)+"@example.com", role, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	token := "token-of-" + name
+	sum := sha256.Sum256([]byte(token))
+	if _, err := conn.Exec(`INSERT INTO api_tokens (token_hash, user_id, created_at) VALUES (?, ?, ?)`, hex.EncodeToString(sum[:]), id, now); err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// Queue jobs belong to nobody, only admins follow them; imports are their
+// caller's, and anonymous callers follow nothing: their IP is theirs to make up
+func TestJobStreamAuthorization(t *testing.T) {
+	q := apitest.Queue(t)
+	h := apitest.NewWithConfig(t, router.Config{Jobs: q, DB: q.DB()})
+	srv := h.Server()
+	admin := follow(t, srv, "topic=jobs", map[string]string{"Authorization": "Bearer " + apiToken(t, q.DB(), "Root", "admin")})
+	ann := "Bearer " + apiToken(t, q.DB(), "Ann", "user")
+	user := follow(t, srv, "topic=jobs&topic=uploads", map[string]string{"Authorization": ann})
+
+	ctx := context.Background()
+	job, err := q.Enqueue(ctx, v1handler.CleanupUploadsJob, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ran, err := q.RunNext(ctx, v1handler.CleanupUploadsJob); !ran || err != nil {
+		t.Fatalf("ran %t, %v", ran, err)
+	}
+	for _, want := range []string{"queued", "running", "succeeded"} {
+		var got dto.Job
+		next(t, admin, "job", &got)
+		if got.ID != job.ID || got.Status != want {
+			t.Errorf("got %s %s, want %s", got.ID, got.Status, want)
+		}
+	}
+	none(t, user)
+
+	summary := startImportOf(t, h, "/api/v1/products/import", func(r *http.Request) {
+		apitest.Body("application/x-ndjson", ndjson(apitest.ProductRequest()))(r)
+		r.Header.Set("Authorization", ann)
+	})
+	for _, events := range []<-chan sseEvent{user, admin} {
+		var got dto.ImportSummary
+		for got.Status != "done" {
+			next(t, events, "import", &got)
+			if got.ID != summary.ID {
+				t.Fatalf("import %s, want %s", got.ID, summary.ID)
+			}
+		}
+	}
+
+	h.Do(http.MethodGet, "/api/v1/events?topic=jobs", apitest.Header("Authorization", "Bearer wrong")).Expect(http.StatusUnauthorized)
+	h.Do(http.MethodGet, "/api/v1/events?topic=jobs", apitest.Header("X-Forwarded-For", "198.51.100.7")).Expect(http.StatusUnauthorized)
+}
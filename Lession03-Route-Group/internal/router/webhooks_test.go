@@ -0,0 +1,125 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/webhook"
+)
+
+// A product created through the API reaches the subscribed endpoint, signed
+// with the secret the subscription answered with
+func TestProductEventsReachWebhooks(t *testing.T) {
+	q := apitest.Queue(t)
+	svc := webhook.New(q, webhook.Config{AllowPrivate: true})
+	h := apitest.NewWithConfig(t, router.Config{Jobs: q, DB: q.DB(), Webhooks: svc})
+	admin := apitest.Header("Authorization", "Bearer "+apiToken(t, q.DB(), "Root", "admin"))
+	ctx := context.Background()
+
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan request, 4)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- request{r.Header, body}
+	}))
+	t.Cleanup(receiver.Close)
+
+	var created dto.WebhookResponse
+	h.Do(http.MethodPost, "/api/v1/webhooks", apitest.JSON(map[string]any{
+		"url": receiver.URL, "events": []string{"product.created"},
+	}), admin).Expect(http.StatusCreated).Decode(&created)
+	if created.Data.Secret == "" {
+		t.Fatal("no secret in the create answer")
+	}
+	var list dto.WebhookListResponse
+	h.Do(http.MethodGet, "/api/v1/webhooks", admin).Expect(http.StatusOK).Decode(&list)
+	if len(list.Data) != 1 || list.Data[0].Secret != "" {
+		t.Errorf("list: %+v", list.Data)
+	}
+
+	id := h.CreateProduct(apitest.ProductRequest())
+	if _, err := svc.Dispatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ran, err := q.RunNext(ctx, webhook.DeliverJob); !ran || err != nil {
+		t.Fatalf("ran %t, %v", ran, err)
+	}
+
+	req := <-got
+	if err := webhook.Verify(created.Data.Secret, req.header.Get(webhook.SignatureHeader), req.body, time.Now(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var event struct {
+		Type string `json:"type"`
+		Data struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "product.created" || event.Data.ID != id || req.header.Get(webhook.EventHeader) != "product.created" {
+		t.Errorf("event %+v, header %q", event, req.header.Get(webhook.EventHeader))
+	}
+	if ran, _ := q.RunNext(ctx, webhook.DeliverJob); ran {
+		t.Error("more than one delivery for one event")
+	}
+
+	var log dto.WebhookDeliveriesResponse
+	h.Do(http.MethodGet, "/api/v1/webhooks/"+created.Data.ID+"/deliveries", admin).Expect(http.StatusOK).Decode(&log)
+	if len(log.Data) != 1 || log.Data[0].StatusCode != http.StatusOK || log.Data[0].DeliveryID != req.header.Get(webhook.DeliveryHeader) {
+		t.Errorf("deliveries: %+v", log.Data)
+	}
+
+	h.Do(http.MethodDelete, "/api/v1/webhooks/"+created.Data.ID, admin).Expect(http.StatusOK)
+	h.Do(http.MethodGet, "/api/v1/webhooks/"+created.Data.ID+"/deliveries", admin).Expect(http.StatusNotFound)
+}
+
+// Subscriptions carry partner secrets: anonymous callers get a 401, users a 403
+func TestWebhooksAreForAdmins(t *testing.T) {
+	q := apitest.Queue(t)
+	h := apitest.NewWithConfig(t, router.Config{Jobs: q, DB: q.DB(), Webhooks: webhook.New(q, webhook.Config{})})
+	admin := apitest.Header("Authorization", "Bearer "+apiToken(t, q.DB(), "Root", "admin"))
+	user := apitest.Header("Authorization", "Bearer "+apiToken(t, q.DB(), "Ann", "user"))
+	subscribe := apitest.JSON(map[string]any{"url": "https://partner.example/hooks", "events": []string{"product.created"}})
+
+	res := h.Do(http.MethodGet, "/api/v1/webhooks").Expect(http.StatusUnauthorized)
+	if res.Header().Get("WWW-Authenticate") == "" {
+		t.Error("no WWW-Authenticate on the 401")
+	}
+	h.Do(http.MethodPost, "/api/v1/webhooks", subscribe).Expect(http.StatusUnauthorized)
+	h.Do(http.MethodPost, "/api/v1/webhooks", subscribe, user).Expect(http.StatusForbidden)
+	h.Do(http.MethodGet, "/api/v1/webhooks", user).Expect(http.StatusForbidden)
+
+	h.Do(http.MethodPost, "/api/v1/webhooks", admin, apitest.JSON(map[string]any{
+		"url": "https://partner.example/hooks", "events": []string{"product.sold"},
+	})).Expect(http.StatusBadRequest)
+	h.Do(http.MethodDelete, "/api/v1/webhooks/42", admin).Expect(http.StatusBadRequest)
+}
+
+// Seeding happens on every start, its events don't reach the outbox
+func TestSeedingIsNotPublishedToWebhooks(t *testing.T) {
+	q := apitest.Queue(t)
+	svc := webhook.New(q, webhook.Config{AllowPrivate: true})
+	h := apitest.NewWithConfig(t, router.Config{Jobs: q, DB: q.DB(), Webhooks: svc})
+	ctx := context.Background()
+
+	if n, err := svc.Dispatch(ctx); n != 0 || err != nil {
+		t.Fatalf("after seeding: %d events, %v", n, err)
+	}
+	h.Do(http.MethodPost, "/api/v1/users", apitest.JSON(dto.CreateUserRequest{Name: "Dana", Email: "dana@example.com"})).Expect(http.StatusCreated)
+	if n, err := svc.Dispatch(ctx); n != 1 || err != nil {
+		t.Errorf("after a create: %d events, %v", n, err)
+	}
+}
@@ -0,0 +1,82 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// Around each snippet we keep this many bytes of context on both sides
+const snippetContext = 60
+
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+)
+
+// snippets returns, for each field containing a matched term, a short
+// HTML-escaped extract with the matches wrapped in <mark>
+func (ix *Index) snippets(id int, terms map[string]bool) map[string]string {
+	doc := ix.docs[id]
+	if doc == nil || len(terms) == 0 {
+		return nil
+	}
+
+	out := map[string]string{}
+	for name, text := range doc.fields {
+		if s, ok := highlight(text, terms); ok {
+			out[name] = s
+		}
+	}
+	return out
+}
+
+func highlight(text string, terms map[string]bool) (string, bool) {
+	var spans []token
+	for _, t := range tokenize(text) {
+		if terms[t.term] {
+			spans = append(spans, t)
+		}
+	}
+	if len(spans) == 0 {
+		return "", false
+	}
+
+	from := clampToRune(text, spans[0].start-snippetContext)
+	to := clampToRune(text, spans[0].end+snippetContext)
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+	pos := from
+	for _, s := range spans {
+		if s.start < pos || s.end > to {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[pos:s.start]))
+		sb.WriteString(markOpen)
+		sb.WriteString(html.EscapeString(text[s.start:s.end]))
+		sb.WriteString(markClose)
+		pos = s.end
+	}
+	sb.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		sb.WriteString("…")
+	}
+	return sb.String(), true
+}
+
+// clampToRune keeps i inside text and moves it back to the start of a rune
+func clampToRune(text string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	if i >= len(text) {
+		return len(text)
+	}
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,249 @@
+// Package search is a small embedded full-text index (BM25 ranking, prefix and
+// typo tolerant matching, highlighted snippets) used behind ProductQuery.Search.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BM25 tuning, the usual defaults
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// How much a match counts depending on how the query word matched
+const (
+	exactMatch  = 1.0
+	prefixMatch = 0.7
+	fuzzyMatch  = 0.5
+)
+
+type Hit struct {
+	ID       int               `json:"id"`
+	Score    float64           `json:"score"`
+	Snippets map[string]string `json:"highlights,omitempty"`
+}
+
+type document struct {
+	fields map[string]string
+	length float64 // weighted number of tokens
+}
+
+// Index is safe for concurrent use
+type Index struct {
+	mu       sync.RWMutex
+	weights  map[string]float64
+	docs     map[int]*document
+	postings map[string]map[int]float64 // term -> doc ID -> weighted term frequency
+	vocab    []string                   // sorted terms, rebuilt lazily
+	dirty    bool
+	totalLen float64
+}
+
+// New creates an index. weights gives the boost of each field, fields not
+// listed count as 1.
+func New(weights map[string]float64) *Index {
+	return &Index{
+		weights:  weights,
+		docs:     map[int]*document{},
+		postings: map[string]map[int]float64{},
+	}
+}
+
+// Put adds or replaces the document with this ID
+func (ix *Index) Put(id int, fields map[string]string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+
+	doc := &document{fields: fields}
+	for name, text := range fields {
+		w := ix.weight(name)
+		for _, t := range tokenize(text) {
+			if ix.postings[t.term] == nil {
+				ix.postings[t.term] = map[int]float64{}
+				ix.dirty = true
+			}
+			ix.postings[t.term][id] += w
+			doc.length += w
+		}
+	}
+	ix.docs[id] = doc
+	ix.totalLen += doc.length
+}
+
+func (ix *Index) Remove(id int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id int) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, text := range doc.fields {
+		for _, t := range tokenize(text) {
+			delete(ix.postings[t.term], id)
+			if len(ix.postings[t.term]) == 0 {
+				delete(ix.postings, t.term)
+				ix.dirty = true
+			}
+		}
+	}
+	ix.totalLen -= doc.length
+	delete(ix.docs, id)
+}
+
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Search returns every document matching all words of q, best first.
+func (ix *Index) Search(q string) []Hit {
+	queryTerms := unique(Terms(q))
+	if len(queryTerms) == 0 {
+		return nil
+	}
+
+	ix.mu.Lock()
+	if ix.dirty {
+		ix.rebuildVocab()
+	}
+	ix.mu.Unlock()
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	n := float64(len(ix.docs))
+	if n == 0 {
+		return nil
+	}
+	avgLen := ix.totalLen / n
+
+	scores := map[int]float64{}
+	matched := map[int]map[string]bool{} // doc -> index terms that matched, for highlighting
+	for i, qt := range queryTerms {
+		best := map[int]float64{}
+		for term, quality := range ix.expand(qt) {
+			posting := ix.postings[term]
+			df := float64(len(posting))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for id, tf := range posting {
+				norm := tf * (k1 + 1) / (tf + k1*(1-b+b*ix.docs[id].length/avgLen))
+				if s := quality * idf * norm; s > best[id] {
+					best[id] = s
+				}
+				if matched[id] == nil {
+					matched[id] = map[string]bool{}
+				}
+				matched[id][term] = true
+			}
+		}
+
+		// every query word has to match something
+		for id, s := range best {
+			if i == 0 {
+				scores[id] = s
+			} else if _, ok := scores[id]; ok {
+				scores[id] += s
+			}
+		}
+		for id := range scores {
+			if _, ok := best[id]; !ok {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{
+			ID:       id,
+			Score:    math.Round(score*1000) / 1000,
+			Snippets: ix.snippets(id, matched[id]),
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+// expand finds the index terms a query word should match: itself, words it
+// is a prefix of, and words a typo or two away. Short words only match exactly
+// or as a prefix, otherwise "ao" would match half the catalogue.
+func (ix *Index) expand(qt string) map[string]float64 {
+	out := map[string]float64{}
+	if _, ok := ix.postings[qt]; ok {
+		out[qt] = exactMatch
+	}
+
+	length := len([]rune(qt))
+	if length >= 2 {
+		i := sort.SearchStrings(ix.vocab, qt)
+		for ; i < len(ix.vocab) && strings.HasPrefix(ix.vocab[i], qt); i++ {
+			if _, ok := out[ix.vocab[i]]; !ok {
+				out[ix.vocab[i]] = prefixMatch
+			}
+		}
+	}
+
+	maxTypos := 0
+	switch {
+	case length >= 8:
+		maxTypos = 2
+	case length >= 4:
+		maxTypos = 1
+	}
+	if maxTypos > 0 {
+		for _, term := range ix.vocab {
+			if _, ok := out[term]; ok {
+				continue
+			}
+			if editDistance(qt, term, maxTypos) <= maxTypos {
+				out[term] = fuzzyMatch
+			}
+		}
+	}
+	return out
+}
+
+func (ix *Index) rebuildVocab() {
+	ix.vocab = ix.vocab[:0]
+	for term := range ix.postings {
+		ix.vocab = append(ix.vocab, term)
+	}
+	sort.Strings(ix.vocab)
+	ix.dirty = false
+}
+
+func (ix *Index) weight(field string) float64 {
+	if w, ok := ix.weights[field]; ok {
+		return w
+	}
+	return 1
+}
+
+func unique(terms []string) []string {
+	seen := map[string]bool{}
+	out := terms[:0]
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func catalogue() *Index {
+	ix := NewProductIndex()
+	ix.Put(1, map[string]string{"name": "Áo thun Golang", "description": "Áo thun cotton in logo gopher", "tags": "golang, áo"})
+	ix.Put(2, map[string]string{"name": "Sách lập trình Go", "description": "Học Golang từ cơ bản đến nâng cao"})
+	ix.Put(3, map[string]string{"name": "Đồng hồ đeo tay", "description": "Dây da, mặt kính sapphire"})
+	ix.Put(4, map[string]string{"name": "Áo khoác", "description": "Chống nước", "tags": "áo, mùa đông"})
+	return ix
+}
+
+func ids(hits []Hit) []int {
+	out := make([]int, len(hits))
+	for i, h := range hits {
+		out[i] = h.ID
+	}
+	return out
+}
+
+func TestSearchMatching(t *testing.T) {
+	ix := catalogue()
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"without diacritics", "dong ho", []int{3}},
+		{"with diacritics", "Đồng hồ", []int{3}},
+		{"every word has to match", "áo golang", []int{1}},
+		{"prefix", "gola", []int{1, 2}},
+		{"prefix of a short word", "kh", []int{4}},
+		{"one letter is no prefix", "k", []int{}},
+		{"one typo", "golnag", []int{1, 2}},
+		{"two typos in a long word", "sapphrei", []int{3}},
+		{"one typo in a four letter word", "sacj", []int{2}},
+		{"short words have no typos", "hox", []int{}},
+		{"two typos in a short word", "gulnag", []int{}},
+		{"nothing", "laptop", []int{}},
+		{"no words", "?!", nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ix.Search(tc.query)
+			if tc.want == nil {
+				if got != nil {
+					t.Errorf("Search(%q) = %v, want nil", tc.query, ids(got))
+				}
+				return
+			}
+			if !reflect.DeepEqual(ids(got), tc.want) {
+				t.Errorf("Search(%q) = %v, want %v", tc.query, ids(got), tc.want)
+			}
+		})
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	ix := catalogue()
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		// 1 has golang in the name (boost 3) and tags, 2 only in the description
+		{"field weights", "golang", []int{1, 2}},
+		// 1 and 4 both have áo in the name and tags, 1 once more in the
+		// description but it is twice as long
+		{"length normalization", "ao", []int{4, 1}},
+		// exact "go" beats the prefix match of "golang"
+		{"exact before prefix", "go", []int{2, 1}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ix.Search(tc.query)
+			if !reflect.DeepEqual(ids(got), tc.want) {
+				t.Errorf("Search(%q) = %+v, want %v", tc.query, got, tc.want)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Score > got[i-1].Score {
+					t.Errorf("not by score: %+v", got)
+				}
+			}
+		})
+	}
+
+	// a term in few documents weighs more than one in many
+	ix = New(nil)
+	ix.Put(1, map[string]string{"name": "alpha beta"})
+	ix.Put(2, map[string]string{"name": "alpha gamma"})
+	ix.Put(3, map[string]string{"name": "alpha delta"})
+	if rare, common := ix.Search("beta")[0].Score, ix.Search("alpha")[0].Score; rare <= common {
+		t.Errorf("beta %v, alpha %v", rare, common)
+	}
+}
+
+func TestSearchFollowsChanges(t *testing.T) {
+	ix := catalogue()
+	ix.Put(3, map[string]string{"name": "Đồng hồ treo tường"})
+	if got := ids(ix.Search("deo")); len(got) != 0 {
+		t.Errorf("old text still found: %v", got)
+	}
+	if got := ids(ix.Search("treo")); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("new text: %v", got)
+	}
+
+	ix.Remove(1)
+	if got := ids(ix.Search("golang")); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("after remove: %v", got)
+	}
+	// its words left the vocabulary, no prefix or typo matches them
+	if got := ids(ix.Search("gophr")); len(got) != 0 {
+		t.Errorf("removed word still matches: %v", got)
+	}
+	if ix.Len() != 3 {
+		t.Errorf("Len() = %d", ix.Len())
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	long := strings.Repeat("mềm ", 30)
+	tests := []struct {
+		name  string
+		text  string
+		terms []string
+		want  string
+	}{
+		{"word", "Áo thun Golang", []string{"golang"}, "Áo thun <mark>Golang</mark>"},
+		{"original spelling is kept", "Đồng hồ đeo tay", []string{"dong", "ho"}, "<mark>Đồng</mark> <mark>hồ</mark> đeo tay"},
+		{"every occurrence", "áo và Áo", []string{"ao"}, "<mark>áo</mark> và <mark>Áo</mark>"},
+		{"html is escaped", "<b>Go</b> & Rust", []string{"go"}, "&lt;b&gt;<mark>Go</mark>&lt;/b&gt; &amp; Rust"},
+		{"no part of a word", "gopher", []string{"go"}, ""},
+		{"context is cut on runes", long + "x Golang.", []string{"golang"},
+			"…ềm " + strings.Repeat("mềm ", 9) + "x <mark>Golang</mark>."},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			terms := map[string]bool{}
+			for _, term := range tc.terms {
+				terms[term] = true
+			}
+			got, ok := highlight(tc.text, terms)
+			if ok != (tc.want != "") || got != tc.want {
+				t.Errorf("highlight = %q, %v\nwant %q", got, ok, tc.want)
+			}
+		})
+	}
+}
+
+func TestSearchSnippets(t *testing.T) {
+	hits := catalogue().Search("gopher golnag")
+	if len(hits) != 1 {
+		t.Fatalf("hits %+v", hits)
+	}
+	want := map[string]string{
+		"name":        "Áo thun <mark>Golang</mark>",
+		"description": "Áo thun cotton in logo <mark>gopher</mark>",
+		"tags":        "<mark>golang</mark>, áo",
+	}
+	if !reflect.DeepEqual(hits[0].Snippets, want) {
+		t.Errorf("snippets %q", hits[0].Snippets)
+	}
+}
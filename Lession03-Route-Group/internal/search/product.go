@@ -0,0 +1,50 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/repository"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// A word in the name is worth three in the description
+var productWeights = map[string]float64{
+	"name":         3,
+	"tags":         2,
+	"description":  1,
+	"product_info": 1,
+}
+
+func NewProductIndex() *Index {
+	return New(productWeights)
+}
+
+// ProductFields is what we index for a product. The keys are also the keys of Hit.Snippets.
+func ProductFields(p models.Product) map[string]string {
+	values := make([]string, 0, len(p.Info))
+	for _, info := range p.Info {
+		values = append(values, info.InfoValue)
+	}
+	return map[string]string{
+		"name":         p.Name,
+		"description":  p.Description,
+		"tags":         strings.Join(p.Tags, ", "),
+		"product_info": strings.Join(values, ", "),
+	}
+}
+
+// SyncProducts indexes what is already in repo and keeps the index up to date
+// on every create, update and delete
+func (ix *Index) SyncProducts(repo *repository.ProductRepository) {
+	repo.Subscribe(func(ch repository.ProductChange) {
+		switch ch.Kind {
+		case repository.Deleted:
+			ix.Remove(ch.Product.ID)
+		default:
+			ix.Put(ch.Product.ID, ProductFields(ch.Product))
+		}
+	})
+	for _, p := range repo.FindAll() {
+		ix.Put(p.ID, ProductFields(p))
+	}
+}
@@ -0,0 +1,68 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Áo Thun Đẹp", "ao thun dep"},
+		{"Đồng hồ đeo tay", "dong ho deo tay"},
+		{"Cà phê sữa đá", "ca phe sua da"},
+		{"Trường Đại học", "truong dai hoc"},
+		{"Nguyễn Thị Ánh", "nguyen thi anh"},
+		{"Quẩy, Ưng, Ở", "quay, ung, o"},
+		{"áo", "ao"}, // decomposed á
+		{"GOLANG 2024", "golang 2024"},
+		{"", ""},
+	}
+	for _, tc := range tests {
+		if got := Fold(tc.in); got != tc.want {
+			t.Errorf("Fold(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Áo thun Golang, size L!", []string{"ao", "thun", "golang", "size", "l"}},
+		{"áo-thun", []string{"ao", "thun"}},
+		{"  Đen/Trắng  ", []string{"den", "trang"}},
+		{"100% cotton", []string{"100", "cotton"}},
+		{"...", []string{}},
+	}
+	for _, tc := range tests {
+		if got := Terms(tc.in); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"golang", "golang", 2, 0},
+		{"golang", "golnag", 2, 1}, // swapped neighbours
+		{"golang", "golag", 2, 1},  // missing letter
+		{"golang", "gollang", 2, 1},
+		{"golang", "gulang", 2, 1},
+		{"golang", "gulnag", 2, 2},
+		{"golang", "python", 2, 3}, // cut short at max+1
+		{"thun", "thunder", 2, 3},  // lengths alone are too far apart
+		{"dien thoai", "dien thaoi", 1, 1},
+		{"đen", "den", 1, 1}, // runes, not bytes
+		{"", "ab", 2, 2},
+	}
+	for _, tc := range tests {
+		if got := editDistance(tc.a, tc.b, tc.max); got != tc.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tc.a, tc.b, tc.max, got, tc.want)
+		}
+	}
+}
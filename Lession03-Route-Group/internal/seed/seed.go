@@ -0,0 +1,176 @@
+// Package seed loads demo and test data from fixture files into the API.
+// Fixtures are YAML or JSON and spell out the same request bodies clients
+// send, and Seeder posts them through the API itself, so they pass exactly
+// the validation a client's request would.
+package seed
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed users.yaml demo.yaml
+var files embed.FS
+
+// Fixtures is one fixture file. Categories and products refer to
+// categories by name, IDs are only known once they are created.
+type Fixtures struct {
+	Users      []dto.CreateUserRequest `json:"users,omitempty"`
+	Categories []Category              `json:"categories,omitempty"`
+	Products   []Product               `json:"products,omitempty"`
+}
+
+// Category is dto.CreateCategoryRequest with the parent given by name
+type Category struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url"`
+	Parent      string `json:"parent,omitempty"` // an earlier category of the same fixtures
+}
+
+func (c Category) request(parentID int) dto.CreateCategoryRequest {
+	return dto.CreateCategoryRequest{Name: c.Name, Description: c.Description, ImageURL: c.ImageURL, ParentID: parentID}
+}
+
+// Product is the body of POST /products, plus category names for category_ids
+type Product struct {
+	dto.CreateProductRequest
+	Categories []string `json:"categories,omitempty"`
+}
+
+// Base is what every fresh server starts with (the users GetUsers used to hard-code)
+func Base() *Fixtures { return mustLoad("users.yaml") }
+
+// Demo is a small catalogue to click around in
+func Demo() *Fixtures { return mustLoad("demo.yaml") }
+
+func mustLoad(name string) *Fixtures {
+	data, err := files.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	f, err := Load(name, data)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// LoadFile reads a .yaml, .yml or .json fixture file
+func LoadFile(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(path, data)
+}
+
+// Load decodes data, YAML or JSON depending on name's extension. YAML goes
+// through JSON so both formats use the json tags of the DTOs. Unknown keys
+// are an error, a typo shouldn't silently drop a field.
+func Load(name string, data []byte) (*Fixtures, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+	case ".yaml", ".yml":
+		var doc any
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		var err error
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	default:
+		return nil, fmt.Errorf("%s: fixtures must be .yaml, .yml or .json", name)
+	}
+
+	var f Fixtures
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &f, nil
+}
+
+// Append adds the records of more after those of f
+func (f *Fixtures) Append(more *Fixtures) {
+	f.Users = append(f.Users, more.Users...)
+	f.Categories = append(f.Categories, more.Categories...)
+	f.Products = append(f.Products, more.Products...)
+}
+
+// Validate runs the API's binding validation on every record, and checks
+// the category names resolve, so a bad file fails before anything is written.
+// Records are normalised in place, like ShouldBind does.
+func (f *Fixtures) Validate() error {
+	utils.SetupBinding()
+	var problems []string
+	check := func(kind string, i int, name string, obj any) {
+		if err := binding.Validator.ValidateStruct(obj); err != nil {
+			problems = append(problems, fmt.Sprintf("%s[%d] %q: %s", kind, i, name, describe(err)))
+		}
+	}
+
+	for i := range f.Users {
+		check("users", i, f.Users[i].Name, &f.Users[i])
+	}
+
+	known := map[string]bool{}
+	for i, c := range f.Categories {
+		req := c.request(0)
+		check("categories", i, c.Name, &req)
+		if c.Parent != "" && !known[c.Parent] {
+			problems = append(problems, fmt.Sprintf("categories[%d] %q: parent %q is not an earlier category", i, c.Name, c.Parent))
+		}
+		if known[req.Name] {
+			problems = append(problems, fmt.Sprintf("categories[%d] %q: name used twice", i, c.Name))
+		}
+		known[req.Name] = true
+		f.Categories[i] = Category{Name: req.Name, Description: req.Description, ImageURL: req.ImageURL, Parent: c.Parent}
+	}
+
+	for i := range f.Products {
+		p := &f.Products[i]
+		check("products", i, p.Name, &p.CreateProductRequest)
+		for _, name := range p.Categories {
+			if !known[name] {
+				problems = append(problems, fmt.Sprintf("products[%d] %q: unknown category %q", i, p.Name, name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid fixtures:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// describe is the fields map of an API error response, on one line
+func describe(err error) string {
+	fields := utils.FormatValidationErrors(err)
+	if len(fields) == 0 {
+		return err.Error()
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + ": " + fields[k]
+	}
+	return strings.Join(parts, "; ")
+}
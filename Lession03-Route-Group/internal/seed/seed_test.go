@@ -0,0 +1,91 @@
+package seed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadYAMLAndJSONAgree(t *testing.T) {
+	fromYAML, err := Load("f.yaml", []byte("users:\n  - name: Dana\n    email: dana@example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromJSON, err := Load("f.json", []byte(`{"users": [{"name": "Dana", "email": "dana@example.com"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) || fromYAML.Users[0].Email != "dana@example.com" {
+		t.Errorf("yaml %+v, json %+v", fromYAML, fromJSON)
+	}
+
+	if _, err := Load("f.yaml", []byte("users:\n  - nmae: Dana\n")); err == nil {
+		t.Error("unknown key: want an error")
+	}
+	if _, err := Load("f.toml", nil); err == nil {
+		t.Error("unknown extension: want an error")
+	}
+}
+
+func TestEmbeddedFixturesAreValid(t *testing.T) {
+	for name, f := range map[string]*Fixtures{"base": Base(), "demo": Demo(), "synthetic": Synthetic(50, 7)} {
+		if err := f.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestValidateUsesAPIRules(t *testing.T) {
+	f := Demo()
+	f.Products[0].Price.Currency = "XYZ"
+	f.Products[1].Categories = []string{"Garden"}
+	f.Categories[1].Parent = "Audio"
+
+	err := f.Validate()
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	for _, want := range []string{`products[0] "Laptop": Price.Currency:`, `unknown category "Garden"`, `parent "Audio" is not an earlier category`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in\n%v", want, err)
+		}
+	}
+}
+
+func TestSyntheticIsDeterministic(t *testing.T) {
+	a, b := Synthetic(20, 42), Synthetic(20, 42)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("same seed, different products")
+	}
+	names := map[string]bool{}
+	for _, p := range a.Products {
+		if names[p.Name] {
+			t.Errorf("name %q twice", p.Name)
+		}
+		names[p.Name] = true
+	}
+}
+
+func TestIsInProcess(t *testing.T) {
+	var got []bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, IsInProcess(r.Context()))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	})
+
+	if _, err := InProcess(h).GetWebhooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the User-Agent alone is anyone's to send
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("User-Agent", InProcessAgent)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !reflect.DeepEqual(got, []bool{true, false}) {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,195 @@
+package seed
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/client"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/slug"
+)
+
+// Seeder writes fixtures through the API. It is idempotent: users are
+// matched by slug, categories by name and parent, products by slug and
+// name, and the ones already there are skipped.
+type Seeder struct {
+	api *client.Client
+}
+
+func New(api *client.Client) *Seeder {
+	return &Seeder{api: api}
+}
+
+// InProcessAgent is the User-Agent of InProcess clients
+const InProcessAgent = "lession03-seed/1 (in-process)"
+
+// inProcessKey marks the context of requests made by InProcess clients.
+// Unlike a header, nothing coming over the network can set it.
+type inProcessKey struct{}
+
+// IsInProcess reports whether ctx is, or comes from, the context of a
+// request made by an InProcess client. The router leaves those out of the
+// access log and doesn't tell webhooks about them.
+func IsInProcess(ctx context.Context) bool {
+	v, _ := ctx.Value(inProcessKey{}).(bool)
+	return v
+}
+
+// InProcess is a client calling h directly, no socket involved
+func InProcess(h http.Handler) *client.Client {
+	return client.New("http://seed.local",
+		client.WithHTTPClient(&http.Client{Transport: handlerTransport{h}}),
+		client.WithRetryPolicy(client.RetryPolicy{MaxAttempts: 1}),
+		client.WithUserAgent(InProcessAgent))
+}
+
+type handlerTransport struct{ h http.Handler }
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(context.WithValue(req.Context(), inProcessKey{}, true))
+	req.RequestURI = req.URL.RequestURI()
+	req.RemoteAddr = "127.0.0.1:0"
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+	rec := httptest.NewRecorder()
+	t.h.ServeHTTP(rec, req)
+	res := rec.Result()
+	res.Request = req
+	return res, nil
+}
+
+// Count is how many records of one kind a run created and skipped
+type Count struct {
+	Created, Skipped int
+}
+
+type Report struct {
+	Users, Categories, Products Count
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("users %d created %d skipped, categories %d created %d skipped, products %d created %d skipped",
+		r.Users.Created, r.Users.Skipped, r.Categories.Created, r.Categories.Skipped, r.Products.Created, r.Products.Skipped)
+}
+
+// Apply validates f, then creates users, categories (parents first) and
+// products. It stops at the first record the API refuses.
+func (s *Seeder) Apply(ctx context.Context, f *Fixtures) (Report, error) {
+	var report Report
+	if err := f.Validate(); err != nil {
+		return report, err
+	}
+
+	for _, u := range f.Users {
+		created, err := s.user(ctx, u)
+		if err != nil {
+			return report, fmt.Errorf("user %q: %w", u.Name, err)
+		}
+		report.Users.add(created)
+	}
+
+	categoryIDs, err := s.existingCategories(ctx)
+	if err != nil {
+		return report, err
+	}
+	byName := map[string]int{}
+	for _, c := range f.Categories {
+		parentID := byName[c.Parent]
+		key := categoryKey(parentID, c.Name)
+		id, ok := categoryIDs[key]
+		if !ok {
+			res, err := s.api.CreateCategory(ctx, c.request(parentID))
+			if err != nil {
+				return report, fmt.Errorf("category %q: %w", c.Name, err)
+			}
+			id = res.ID
+			categoryIDs[key] = id
+		}
+		byName[c.Name] = id
+		report.Categories.add(!ok)
+	}
+
+	for _, p := range f.Products {
+		req := p.CreateProductRequest
+		for _, name := range p.Categories {
+			req.CategoryIDs = append(req.CategoryIDs, byName[name])
+		}
+		created, err := s.product(ctx, req)
+		if err != nil {
+			return report, fmt.Errorf("product %q: %w", p.Name, err)
+		}
+		report.Products.add(created)
+	}
+	return report, nil
+}
+
+func (c *Count) add(created bool) {
+	if created {
+		c.Created++
+	} else {
+		c.Skipped++
+	}
+}
+
+func (s *Seeder) user(ctx context.Context, u dto.CreateUserRequest) (created bool, err error) {
+	_, err = s.api.GetUserBySlug(ctx, dto.UserSlugQuery{Slug: cmpSlug(u.Slug, u.Name)})
+	if err == nil {
+		return false, nil
+	}
+	if client.StatusCode(err) != http.StatusNotFound {
+		return false, err
+	}
+	_, err = s.api.CreateUser(ctx, u)
+	return err == nil, err
+}
+
+func (s *Seeder) product(ctx context.Context, req dto.CreateProductRequest) (created bool, err error) {
+	// The slug may belong to another product when ours got a -2 suffix, so check the name too
+	res, err := s.api.GetProductBySlug(ctx, dto.ProductSlugUri{Slug: cmpSlug(req.Slug, req.Name)})
+	if err == nil && strings.EqualFold(res.Data.Name, req.Name) {
+		return false, nil
+	}
+	if err != nil && client.StatusCode(err) != http.StatusNotFound {
+		return false, err
+	}
+	_, err = s.api.CreateProduct(ctx, req)
+	if fields, ok := client.ValidationErrors(err); ok && len(fields) == 1 && fields["name"] != "" {
+		return false, nil // "This product name is already in use"
+	}
+	return err == nil, err
+}
+
+// existingCategories maps categoryKey to the ID of every category on the server
+func (s *Seeder) existingCategories(ctx context.Context) (map[string]int, error) {
+	ids := map[string]int{}
+	query := dto.ListQuery{Limit: 100}
+	for {
+		page, err := s.api.GetCategories(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("list categories: %w", err)
+		}
+		for _, c := range page.Data {
+			ids[categoryKey(c.ParentID, c.Name)] = c.ID
+		}
+		if page.Meta.NextCursor == "" || len(page.Data) == 0 {
+			return ids, nil
+		}
+		query.Cursor = page.Meta.NextCursor
+	}
+}
+
+func categoryKey(parentID int, name string) string {
+	return fmt.Sprintf("%d/%s", parentID, strings.ToLower(name))
+}
+
+// cmpSlug is the slug the API gives a record: its own, or one made from its name
+func cmpSlug(own, name string) string {
+	if own != "" {
+		return own
+	}
+	return slug.Make(name)
+}
@@ -0,0 +1,61 @@
+package seed
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/google/uuid"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+)
+
+var (
+	adjectives = []string{"Classic", "Compact", "Deluxe", "Eco", "Lightweight", "Rugged", "Smart", "Vintage"}
+	materials  = []string{"Bamboo", "Canvas", "Ceramic", "Cotton", "Leather", "Steel", "Walnut", "Wool"}
+	nouns      = []string{"Backpack", "Bottle", "Desk Lamp", "Headphones", "Keyboard", "Mug", "Notebook", "T-shirt"}
+	sizes      = []string{"S", "M", "L", "XL"}
+)
+
+// Synthetic makes n valid products for load tests. The same seed gives the
+// same products, and names are numbered so they never collide.
+func Synthetic(n int, seed uint64) *Fixtures {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	f := &Fixtures{Products: make([]Product, 0, n)}
+	for i := 1; i <= n; i++ {
+		name := fmt.Sprintf("%s %s %s %05d", pick(rng, adjectives), pick(rng, materials), pick(rng, nouns), i)
+		code := fmt.Sprintf("SYN-%05d", i)
+		display := true
+		cents := 100 + rng.IntN(9900) // 1.00 to 99.99
+
+		p := dto.CreateProductRequest{
+			Name:        name,
+			Description: "Synthetic product for load testing",
+			Price:       dto.MoneyRequest{Amount: int64(cents), Currency: "USD"},
+			Stock:       1 + rng.IntN(500),
+			Display:     &display,
+			Tags:        []string{"synthetic", fmt.Sprintf("batch-%d", i/1000)},
+			Avartar:     dto.AvartarImage{URL: imageURL(code, "avatar"), Alt: name},
+			Image:       []dto.ProductImage{{URL: imageURL(code, "1"), AltText: name}},
+			ProductInfo: map[string]dto.ProductInfo{
+				uuid.NewSHA1(uuid.NameSpaceOID, []byte(code)).String(): {InfoKey: "generator", InfoValue: "seed.Synthetic"},
+			},
+		}
+		for _, size := range sizes[:1+rng.IntN(len(sizes))] {
+			p.Variants = append(p.Variants, dto.VariantRequest{
+				SKU:     code + "-" + size,
+				Options: map[string]string{"size": size},
+				Prices:  []dto.MoneyRequest{{Amount: int64(cents), Currency: "USD"}},
+				Stock:   rng.IntN(50),
+			})
+		}
+		f.Products = append(f.Products, Product{CreateProductRequest: p})
+	}
+	return f
+}
+
+func pick(rng *rand.Rand, words []string) string {
+	return words[rng.IntN(len(words))]
+}
+
+func imageURL(code, name string) string {
+	return fmt.Sprintf("https://picsum.photos/seed/%s-%s/640/480.jpg", code, name)
+}
@@ -0,0 +1,78 @@
+package slug
+
+import "strconv"
+
+// Registry hands out unique slugs for one entity type (users, products).
+// A slug, once given to an id, keeps pointing at it after a rename, so old
+// links can redirect; it is only freed when the id is removed.
+//
+// Not safe for concurrent use, the repositories call it under their own lock.
+type Registry struct {
+	fallback string         // used when a name has no latin letters at all
+	current  map[int]string // id -> slug it is served under
+	owner    map[string]int // every slug, current or old -> id
+	// id -> base its current slug is base-N of, when Assign added the -N
+	// because base was taken
+	suffixed map[int]string
+}
+
+func NewRegistry(fallback string) *Registry {
+	return &Registry{fallback: fallback, current: map[int]string{}, owner: map[string]int{}, suffixed: map[int]string{}}
+}
+
+// Assign gives id a slug made from source (a name, or a slug the client asked
+// for). It keeps id's current slug when that already matches source, as is
+// or with the -N it got for a collision, otherwise it takes the first free one
+// of base, base-2, base-3... The previous slug stays in the history.
+func (r *Registry) Assign(id int, source string) string {
+	base := Make(source)
+	if base == "" {
+		base = r.fallback
+	}
+	if cur, ok := r.current[id]; ok && (cur == base || r.suffixed[id] == base) {
+		return cur
+	}
+
+	candidate := base
+	for n := 2; ; n++ {
+		if owner, taken := r.owner[candidate]; !taken || owner == id {
+			break
+		}
+		candidate = base + "-" + strconv.Itoa(n)
+	}
+	r.owner[candidate] = id
+	r.current[id] = candidate
+	if candidate != base {
+		r.suffixed[id] = base
+	} else {
+		delete(r.suffixed, id)
+	}
+	return candidate
+}
+
+// Taken reports whether slug (current or old) belongs to an id other than exceptID
+func (r *Registry) Taken(slug string, exceptID int) bool {
+	owner, ok := r.owner[slug]
+	return ok && owner != exceptID
+}
+
+// Resolve finds the id behind slug and the slug it is served under now;
+// current differs from slug when slug is an old one
+func (r *Registry) Resolve(slug string) (id int, current string, ok bool) {
+	id, ok = r.owner[slug]
+	if !ok {
+		return 0, "", false
+	}
+	return id, r.current[id], true
+}
+
+// Remove frees every slug id ever had
+func (r *Registry) Remove(id int) {
+	for s, owner := range r.owner {
+		if owner == id {
+			delete(r.owner, s)
+		}
+	}
+	delete(r.current, id)
+	delete(r.suffixed, id)
+}
@@ -0,0 +1,62 @@
+// Package slug turns names into URL slugs and keeps them unique per entity
+// type, remembering old slugs so links to them can redirect.
+package slug
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLen leaves room for a "-NN" suffix under the 100 characters the DTOs allow
+const MaxLen = 90
+
+// letters that don't decompose into a base letter plus marks
+var special = map[rune]string{
+	'đ': "d", 'Đ': "d", 'ð': "d", 'Ð': "d",
+	'ß': "ss", 'æ': "ae", 'Æ': "ae", 'œ': "oe", 'Œ': "oe",
+	'ø': "o", 'Ø': "o", 'ł': "l", 'Ł': "l", 'þ': "th", 'Þ': "th", 'ı': "i",
+}
+
+// Make builds a slug from any text: "Áo thun Golang" -> "ao-thun-golang".
+// Diacritics are dropped, everything that isn't a-z or 0-9 separates words.
+// Scripts with no latin spelling (日本語) give "", callers pick a fallback.
+func Make(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range norm.NFKD.String(s) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		repl, ok := special[r]
+		if !ok {
+			r = unicode.ToLower(r)
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				repl = string(r)
+			}
+		}
+		if repl == "" {
+			dash = b.Len() > 0
+			continue
+		}
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteString(repl)
+	}
+	return truncate(b.String())
+}
+
+// truncate cuts at the last word boundary before MaxLen
+func truncate(s string) string {
+	if len(s) <= MaxLen {
+		return s
+	}
+	s = s[:MaxLen]
+	if i := strings.LastIndexByte(s, '-'); i > 0 {
+		s = s[:i]
+	}
+	return strings.TrimSuffix(s, "-")
+}
@@ -0,0 +1,86 @@
+package slug
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Áo thun Golang", "ao-thun-golang"},
+		{"Đồng hồ đeo tay", "dong-ho-deo-tay"},
+		{"Cà phê sữa đá", "ca-phe-sua-da"},
+		{"Trường Đại học", "truong-dai-hoc"},
+		{"Straße & Smørrebrød", "strasse-smorrebrod"},
+		{"Crème brûlée", "creme-brulee"},
+		{"  --Golang   T-Shirt!! ", "golang-t-shirt"},
+		{"C++ 2024", "c-2024"},
+		{"日本語", ""},
+		{"Tokyo 東京 2", "tokyo-2"},
+		{"ﬁle", "file"},
+		{"ＧＯ　１２", "go-12"}, // full width
+	}
+	for _, tt := range tests {
+		if got := Make(tt.in); got != tt.want {
+			t.Errorf("Make(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	long := Make(strings.Repeat("word ", 40))
+	if len(long) > MaxLen || strings.HasSuffix(long, "-") {
+		t.Errorf("long slug %q (%d)", long, len(long))
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry("product")
+
+	if got := r.Assign(1, "Áo thun Golang"); got != "ao-thun-golang" {
+		t.Fatalf("first = %q", got)
+	}
+	if got := r.Assign(2, "Ao Thun Golang"); got != "ao-thun-golang-2" {
+		t.Fatalf("duplicate = %q", got)
+	}
+	if got := r.Assign(2, "ao thun golang"); got != "ao-thun-golang-2" {
+		t.Fatalf("same name again must keep the slug, got %q", got)
+	}
+	if got := r.Assign(3, "日本語"); got != "product" {
+		t.Fatalf("fallback = %q", got)
+	}
+
+	// rename: the old slug stays with id 1 and resolves to the new one
+	if got := r.Assign(1, "Golang Tee"); got != "golang-tee" {
+		t.Fatalf("rename = %q", got)
+	}
+	id, current, ok := r.Resolve("ao-thun-golang")
+	if !ok || id != 1 || current != "golang-tee" {
+		t.Fatalf("Resolve(old) = %d, %q, %v", id, current, ok)
+	}
+	if !r.Taken("ao-thun-golang", 2) || r.Taken("ao-thun-golang", 1) {
+		t.Fatal("old slug must stay reserved for its owner")
+	}
+	if got := r.Assign(4, "Áo thun Golang"); got != "ao-thun-golang-3" {
+		t.Fatalf("old slugs are not reused, got %q", got)
+	}
+
+	// renaming back reclaims the old slug
+	if got := r.Assign(1, "Áo thun Golang"); got != "ao-thun-golang" {
+		t.Fatalf("rename back = %q", got)
+	}
+
+	r.Remove(1)
+	if _, _, ok := r.Resolve("golang-tee"); ok {
+		t.Fatal("slugs of a removed id must be freed")
+	}
+	if got := r.Assign(5, "Golang Tee"); got != "golang-tee" {
+		t.Fatalf("freed slug = %q", got)
+	}
+	// a number that is part of the name is not a collision suffix: dropping
+	// it from the name drops it from the slug
+	if got := r.Assign(6, "Gopher Tee 2024"); got != "gopher-tee-2024" {
+		t.Fatalf("numbered name = %q", got)
+	}
+	if got := r.Assign(6, "Gopher Tee"); got != "gopher-tee" {
+		t.Fatalf("rename without the number = %q", got)
+	}
+}
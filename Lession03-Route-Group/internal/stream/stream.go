@@ -0,0 +1,155 @@
+// Package stream fans progress events out to Server-Sent Events clients.
+//
+// Every event gets the next ID of one counter shared by all topics, and the
+// last History events are kept, so a client reconnecting with Last-Event-ID
+// gets what it missed. Events are in memory only: they are progress for
+// whoever is watching now, not a record.
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// Event is one message of a topic. Owner is the principal (see
+// middleware.Principal) it is about, empty for events only admins see.
+type Event struct {
+	ID    uint64
+	Topic string
+	Owner string
+	Name  string // the SSE event name
+	Data  any
+	At    time.Time
+}
+
+// Broker is safe for concurrent use
+type Broker struct {
+	mu      sync.Mutex
+	last    uint64
+	history []Event // a ring, oldest at start
+	start   int
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// Buffer is how many events a subscriber may fall behind before it is cut
+// off, it reconnects and catches up from the history
+const Buffer = 64
+
+// NewBroker keeps the last history events for resuming clients
+func NewBroker(history int) *Broker {
+	return &Broker{history: make([]Event, 0, max(history, 1)), subs: map[*Subscription]struct{}{}}
+}
+
+// Publish sends an event to the subscribers of topic and returns it
+func (b *Broker) Publish(topic, owner, name string, data any) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.last++
+	e := Event{ID: b.last, Topic: topic, Owner: owner, Name: name, Data: data, At: time.Now().UTC()}
+	if len(b.history) < cap(b.history) {
+		b.history = append(b.history, e)
+	} else {
+		b.history[b.start] = e
+		b.start = (b.start + 1) % len(b.history)
+	}
+
+	for s := range b.subs {
+		if !s.wants(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			// Too slow: closing makes it reconnect and catch up from the history
+			delete(b.subs, s)
+			close(s.events)
+		}
+	}
+	return e
+}
+
+// Subscription is one client listening. Events is closed when the client
+// fell too far behind.
+type Subscription struct {
+	broker *Broker
+	topics map[string]bool
+	allow  func(Event) bool
+	events chan Event
+}
+
+func (s *Subscription) Events() <-chan Event { return s.events }
+
+func (s *Subscription) wants(e Event) bool {
+	return s.topics[e.Topic] && s.allow(e)
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.events)
+	}
+}
+
+// Subscribe listens to the events of topics that allow accepts. With a
+// lastID, the events after it still in the history come first; complete is
+// false when some were already dropped from it.
+func (b *Broker) Subscribe(topics []string, allow func(Event) bool, lastID uint64) (s *Subscription, missed []Event, complete bool) {
+	s = &Subscription{broker: b, topics: map[string]bool{}, allow: allow, events: make(chan Event, Buffer)}
+	for _, t := range topics {
+		s.topics[t] = true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(s.events)
+		return s, nil, true
+	}
+	complete = true
+	switch {
+	case lastID > b.last:
+		// From before a restart, the IDs started over
+		complete = false
+		missed = b.since(0, s)
+	case lastID > 0:
+		// IDs in the ring are consecutive, so anything between lastID and
+		// the oldest kept event is gone
+		complete = len(b.history) == 0 || b.at(0).ID <= lastID+1
+		missed = b.since(lastID, s)
+	}
+	b.subs[s] = struct{}{}
+	return s, missed, complete
+}
+
+// Close ends every subscription, now and to come, for the server to shut
+// down without waiting on the streams. Their clients reconnect elsewhere.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		delete(b.subs, s)
+		close(s.events)
+	}
+}
+
+// since is the history after id that s wants, b.mu must be held
+func (b *Broker) since(id uint64, s *Subscription) []Event {
+	var events []Event
+	for i := range len(b.history) {
+		if e := b.at(i); e.ID > id && s.wants(e) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// at is the i-th oldest event of the history, b.mu must be held
+func (b *Broker) at(i int) Event {
+	return b.history[(b.start+i)%len(b.history)]
+}
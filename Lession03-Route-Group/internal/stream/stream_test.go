@@ -0,0 +1,84 @@
+package stream_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/stream"
+)
+
+func all(stream.Event) bool { return true }
+
+func ids(events []stream.Event) []uint64 {
+	var out []uint64
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func TestResume(t *testing.T) {
+	b := stream.NewBroker(3)
+	for range 5 {
+		b.Publish("uploads", "", "upload", nil)
+	}
+	b.Publish("jobs", "", "job", nil)
+
+	tests := []struct {
+		name     string
+		lastID   uint64
+		want     []uint64
+		complete bool
+	}{
+		{"new client", 0, nil, true},
+		{"caught up", 6, nil, true},
+		{"in the history", 4, []uint64{5}, true}, // 6 is another topic
+		{"right before the history", 3, []uint64{4, 5}, true},
+		{"dropped from the history", 2, []uint64{4, 5}, false},
+		{"before a restart", 42, []uint64{4, 5}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, missed, complete := b.Subscribe([]string{"uploads"}, all, tc.lastID)
+			defer s.Close()
+			if got := ids(missed); !slices.Equal(got, tc.want) || complete != tc.complete {
+				t.Errorf("got %v complete %t, want %v complete %t", got, complete, tc.want, tc.complete)
+			}
+		})
+	}
+}
+
+func TestSlowSubscriberIsCutOff(t *testing.T) {
+	b := stream.NewBroker(10)
+	s, _, _ := b.Subscribe([]string{"uploads"}, func(e stream.Event) bool { return e.Owner == "ip:1" }, 0)
+	for range stream.Buffer {
+		b.Publish("uploads", "ip:1", "upload", nil)
+	}
+	b.Publish("uploads", "ip:2", "upload", nil) // not for s, doesn't count
+	if len(s.Events()) != stream.Buffer {
+		t.Fatalf("%d events buffered", len(s.Events()))
+	}
+
+	b.Publish("uploads", "ip:1", "upload", nil)
+	n := 0
+	for range s.Events() {
+		n++
+	}
+	if n != stream.Buffer {
+		t.Errorf("got %d events before the close, want %d", n, stream.Buffer)
+	}
+	s.Close() // after the broker closed it, a no-op
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	b := stream.NewBroker(10)
+	before, _, _ := b.Subscribe([]string{"uploads"}, all, 0)
+	b.Close()
+	after, _, _ := b.Subscribe([]string{"uploads"}, all, 0)
+	b.Publish("uploads", "", "upload", nil)
+	for _, s := range []*stream.Subscription{before, after} {
+		if _, ok := <-s.Events(); ok {
+			t.Error("got an event after Close")
+		}
+	}
+}
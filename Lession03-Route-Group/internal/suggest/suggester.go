@@ -0,0 +1,105 @@
+package suggest
+
+import (
+	"sync"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/repository"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+// Suggester keeps a Trie in sync with the products on display.
+// Popularity: a product name scores 1 + its views, a tag scores one per product using it.
+type Suggester struct {
+	trie *Trie
+
+	mu       sync.Mutex
+	products map[int]indexed // what we put in the trie for each product, to undo it on update/delete
+}
+
+type indexed struct {
+	name   string
+	tags   []string
+	views  int
+	hidden bool // display false: kept for its views, not in the trie
+}
+
+func NewSuggester() *Suggester {
+	return &Suggester{trie: NewTrie(), products: map[int]indexed{}}
+}
+
+func (s *Suggester) Suggest(prefix string, limit int) []Suggestion {
+	return s.trie.Lookup(prefix, limit)
+}
+
+// Viewed makes the product a bit more popular
+func (s *Suggester) Viewed(productID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.products[productID]
+	if !ok {
+		return
+	}
+	p.views++
+	s.products[productID] = p
+	if !p.hidden {
+		s.trie.Add(KindProduct, p.name, productID, 1)
+	}
+}
+
+// SyncProducts loads what is already in repo and follows every change after that
+func (s *Suggester) SyncProducts(repo *repository.ProductRepository) {
+	repo.Subscribe(func(ch repository.ProductChange) {
+		switch ch.Kind {
+		case repository.Deleted:
+			s.remove(ch.Product.ID)
+		default:
+			s.put(ch.Product)
+		}
+	})
+	for _, p := range repo.FindAll() {
+		s.put(p)
+	}
+}
+
+func (s *Suggester) put(p models.Product) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, exists := s.products[p.ID]
+	s.removeLocked(p.ID)
+
+	next := indexed{name: p.Name, tags: p.Tags, views: old.views, hidden: !p.Display}
+	if !exists {
+		next.views = 0
+	}
+	s.products[p.ID] = next
+	if next.hidden {
+		return
+	}
+	s.trie.Add(KindProduct, next.name, p.ID, 1+next.views)
+	for _, tag := range next.tags {
+		s.trie.Add(KindTag, tag, 0, 1)
+	}
+}
+
+func (s *Suggester) remove(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(id)
+}
+
+func (s *Suggester) removeLocked(id int) {
+	old, ok := s.products[id]
+	if !ok {
+		return
+	}
+	delete(s.products, id)
+	if old.hidden {
+		return
+	}
+	s.trie.Add(KindProduct, old.name, id, -(1 + old.views))
+	for _, tag := range old.tags {
+		s.trie.Add(KindTag, tag, 0, -1)
+	}
+}
@@ -0,0 +1,243 @@
+// Package suggest answers as-you-type queries from a prefix trie over product
+// names and tags. Every node keeps its best entries precomputed, so a lookup
+// is a walk down the prefix and a copy, whatever the size of the catalogue.
+package suggest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/search"
+)
+
+// TopK is how many suggestions each node keeps, and the largest limit a client may ask for
+const TopK = 10
+
+type Kind string
+
+const (
+	KindProduct Kind = "product"
+	KindTag     Kind = "tag"
+)
+
+type Suggestion struct {
+	Text      string `json:"text"`
+	Kind      Kind   `json:"type"`
+	ProductID int    `json:"product_id,omitempty"`
+	Score     int    `json:"score"`
+}
+
+type entry struct {
+	key    string // folded text, unique per kind
+	s      Suggestion
+	paths  []string // the trie keys this entry is stored under
+	owners []owner  // what the score is made of, oldest first
+}
+
+// owner is one product's share of an entry. Names that differ only in
+// diacritics ("Áo thun", "Ao thun") fold to one key: the suggestion shows
+// the first product that had it, the next one once that is gone.
+type owner struct {
+	productID int
+	text      string
+	score     int
+}
+
+type node struct {
+	children map[rune]*node
+	entries  []*entry // entries whose key ends exactly here
+	top      []*entry // best TopK entries of this subtree
+}
+
+// Trie is safe for concurrent use. Reads only take the read lock.
+type Trie struct {
+	mu      sync.RWMutex
+	root    *node
+	entries map[string]*entry // kind + ":" + key
+}
+
+func NewTrie() *Trie {
+	return &Trie{root: &node{}, entries: map[string]*entry{}}
+}
+
+// Lookup returns up to limit suggestions for prefix, best first
+func (t *Trie) Lookup(prefix string, limit int) []Suggestion {
+	prefix = search.Fold(strings.TrimSpace(prefix))
+	if prefix == "" || limit <= 0 {
+		return []Suggestion{}
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	n := t.root
+	for _, r := range prefix {
+		n = n.children[r]
+		if n == nil {
+			return []Suggestion{}
+		}
+	}
+
+	out := make([]Suggestion, 0, min(limit, len(n.top)))
+	for _, e := range n.top[:min(limit, len(n.top))] {
+		out = append(out, e.s)
+	}
+	return out
+}
+
+// Add adds delta to the score productID gives the entry for text (creating
+// it when needed), and drops that share once it falls to zero or below
+func (t *Trie) Add(kind Kind, text string, productID, delta int) {
+	key := search.Fold(strings.TrimSpace(text))
+	if key == "" {
+		return
+	}
+	id := string(kind) + ":" + key
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[id]
+	if !ok {
+		if delta <= 0 {
+			return
+		}
+		e = &entry{key: key, s: Suggestion{Kind: kind}, paths: wordStarts(key)}
+		t.entries[id] = e
+		for _, p := range e.paths {
+			n := t.walk(p, true)
+			n.entries = append(n.entries, e)
+		}
+	}
+
+	if !e.add(productID, text, delta) {
+		return
+	}
+	if len(e.owners) == 0 {
+		delete(t.entries, id)
+		for _, p := range e.paths {
+			n := t.walk(p, false)
+			n.entries = removeEntry(n.entries, e)
+		}
+	}
+
+	for _, p := range e.paths {
+		t.refresh(p)
+	}
+}
+
+// add applies delta to the share of productID, false when there was
+// nothing to take it from
+func (e *entry) add(productID int, text string, delta int) bool {
+	i := 0
+	for i < len(e.owners) && e.owners[i].productID != productID {
+		i++
+	}
+	if i == len(e.owners) {
+		if delta <= 0 {
+			return false
+		}
+		e.owners = append(e.owners, owner{productID: productID, text: text})
+	}
+
+	e.owners[i].score += delta
+	if e.owners[i].score <= 0 {
+		e.owners = append(e.owners[:i], e.owners[i+1:]...)
+	}
+
+	e.s.Score = 0
+	for _, o := range e.owners {
+		e.s.Score += o.score
+	}
+	if len(e.owners) > 0 {
+		e.s.Text, e.s.ProductID = e.owners[0].text, e.owners[0].productID
+	}
+	return true
+}
+
+// wordStarts returns the key and every suffix starting at a word, so
+// "ao thun golang" can be found by typing "thun" or "gol"
+func wordStarts(key string) []string {
+	paths := []string{key}
+	for i := 0; i < len(key); i++ {
+		if key[i] == ' ' && i+1 < len(key) && key[i+1] != ' ' {
+			paths = append(paths, key[i+1:])
+		}
+	}
+	return paths
+}
+
+func (t *Trie) walk(path string, create bool) *node {
+	n := t.root
+	for _, r := range path {
+		child := n.children[r]
+		if child == nil {
+			if !create {
+				return nil
+			}
+			if n.children == nil {
+				n.children = map[rune]*node{}
+			}
+			child = &node{}
+			n.children[r] = child
+		}
+		n = child
+	}
+	return n
+}
+
+// refresh recomputes the top lists from the end of path back up to the root
+func (t *Trie) refresh(path string) {
+	stack := []*node{t.root}
+	n := t.root
+	for _, r := range path {
+		n = n.children[r]
+		if n == nil {
+			break
+		}
+		stack = append(stack, n)
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		n := stack[i]
+		candidates := append([]*entry(nil), n.entries...)
+		for _, child := range n.children {
+			candidates = append(candidates, child.top...)
+		}
+		n.top = best(candidates)
+	}
+}
+
+// best sorts candidates by score (then text) and keeps the first TopK distinct
+// ones. An entry stored under several paths of one subtree shows up more than once.
+func best(candidates []*entry) []*entry {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].s.Score != candidates[j].s.Score {
+			return candidates[i].s.Score > candidates[j].s.Score
+		}
+		return candidates[i].key < candidates[j].key
+	})
+
+	out := make([]*entry, 0, TopK)
+	seen := map[*entry]bool{}
+	for _, e := range candidates {
+		if len(out) == TopK {
+			break
+		}
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func removeEntry(list []*entry, e *entry) []*entry {
+	for i, x := range list {
+		if x == e {
+			return append(list[:i], list[i+1:]...)
+		}
+	}
+	return list
+}
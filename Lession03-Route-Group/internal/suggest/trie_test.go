@@ -0,0 +1,222 @@
+package suggest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/repository"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/models"
+)
+
+func texts(got []Suggestion) []string {
+	out := make([]string, len(got))
+	for i, s := range got {
+		out[i] = s.Text
+	}
+	return out
+}
+
+func TestLookup(t *testing.T) {
+	trie := NewTrie()
+	trie.Add(KindProduct, "Áo thun Golang", 1, 5)
+	trie.Add(KindProduct, "Áo khoác", 2, 3)
+	trie.Add(KindProduct, "Đồng hồ đeo tay", 3, 1)
+	trie.Add(KindProduct, "Quần jean", 4, 1)
+	trie.Add(KindTag, "golang", 0, 2)
+
+	tests := []struct {
+		name   string
+		prefix string
+		limit  int
+		want   []string
+	}{
+		{"best first", "ao", 10, []string{"Áo thun Golang", "Áo khoác"}},
+		{"limit", "ao", 1, []string{"Áo thun Golang"}},
+		{"diacritics typed", "Áo kh", 10, []string{"Áo khoác"}},
+		{"đ folds to d", "dong", 10, []string{"Đồng hồ đeo tay"}},
+		{"upper case", "QUẦN", 10, []string{"Quần jean"}},
+		{"any word of the name", "deo", 10, []string{"Đồng hồ đeo tay"}},
+		{"names and tags", "gol", 10, []string{"Áo thun Golang", "golang"}},
+		{"surrounding spaces", "  quan ", 10, []string{"Quần jean"}},
+		{"mid-word is no match", "hun", 10, []string{}},
+		{"nothing", "xyz", 10, []string{}},
+		{"empty prefix", " ", 10, []string{}},
+		{"no limit", "ao", 0, []string{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := texts(trie.Lookup(tc.prefix, tc.limit)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Lookup(%q, %d) = %q, want %q", tc.prefix, tc.limit, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRanking(t *testing.T) {
+	trie := NewTrie()
+	trie.Add(KindProduct, "Bút bi", 1, 1)
+	trie.Add(KindProduct, "Bút chì", 2, 1)
+	trie.Add(KindProduct, "Bàn", 3, 1)
+
+	// same score: by folded text
+	if got := texts(trie.Lookup("b", 10)); !reflect.DeepEqual(got, []string{"Bàn", "Bút bi", "Bút chì"}) {
+		t.Errorf("ties %q", got)
+	}
+
+	trie.Add(KindProduct, "Bút chì", 2, 2)
+	got := trie.Lookup("b", 10)
+	if texts(got)[0] != "Bút chì" || got[0].Score != 3 || got[0].ProductID != 2 {
+		t.Errorf("after views %+v", got)
+	}
+
+	for i := range TopK + 5 {
+		trie.Add(KindProduct, fmt.Sprintf("Bảng %02d", i), 10+i, 1)
+	}
+	if got := trie.Lookup("b", 100); len(got) != TopK || got[0].Text != "Bút chì" {
+		t.Errorf("kept %d, first %q", len(got), got[0].Text)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	trie := NewTrie()
+	trie.Add(KindProduct, "Áo thun", 1, 3)
+	trie.Add(KindProduct, "Áo len", 2, 1)
+
+	trie.Add(KindProduct, "Áo thun", 1, -1)
+	if got := trie.Lookup("ao", 10); got[0].Score != 2 {
+		t.Errorf("score %+v", got)
+	}
+
+	trie.Add(KindProduct, "Áo thun", 1, -2)
+	if got := texts(trie.Lookup("ao", 10)); !reflect.DeepEqual(got, []string{"Áo len"}) {
+		t.Errorf("after removal %q", got)
+	}
+	if got := trie.Lookup("thun", 10); len(got) != 0 {
+		t.Errorf("word start kept %+v", got)
+	}
+
+	trie.Add(KindProduct, "Áo thun", 1, -1)
+	trie.Add(KindProduct, "Áo len", 2, -1)
+	if len(trie.entries) != 0 || len(trie.root.top) != 0 {
+		t.Errorf("left %d entries, %d at the root", len(trie.entries), len(trie.root.top))
+	}
+}
+
+// Names that only differ in diacritics are one suggestion, the first product wins
+func TestFoldedDuplicates(t *testing.T) {
+	trie := NewTrie()
+	trie.Add(KindProduct, "Áo thun", 1, 1)
+	trie.Add(KindProduct, "Ao thun", 2, 4)
+
+	got := trie.Lookup("ao", 10)
+	want := []Suggestion{{Text: "Áo thun", Kind: KindProduct, ProductID: 1, Score: 5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	trie.Add(KindProduct, "Áo thun", 1, -1)
+	want = []Suggestion{{Text: "Ao thun", Kind: KindProduct, ProductID: 2, Score: 4}}
+	if got := trie.Lookup("ao", 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("first removed: got %+v, want %+v", got, want)
+	}
+
+	// removing what was never added changes nothing
+	trie.Add(KindProduct, "Áo thun", 1, -1)
+	if got := trie.Lookup("ao", 10); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestSyncProducts(t *testing.T) {
+	repo := repository.NewProductRepository()
+	first := models.Product{Name: "Áo thun Golang", Tags: []string{"golang"}, Display: true}
+	if err := repo.Create(&first); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSuggester()
+	s.SyncProducts(repo)
+
+	second := models.Product{Name: "Sách Golang", Tags: []string{"golang", "sách"}, Display: true}
+	if err := repo.Create(&second); err != nil {
+		t.Fatal(err)
+	}
+	s.Viewed(second.ID)
+	s.Viewed(second.ID)
+
+	got := s.Suggest("gol", 10)
+	want := []Suggestion{
+		{Text: "Sách Golang", Kind: KindProduct, ProductID: second.ID, Score: 3},
+		{Text: "golang", Kind: KindTag, Score: 2},
+		{Text: "Áo thun Golang", Kind: KindProduct, ProductID: first.ID, Score: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+
+	// a rename keeps the views
+	second.Name = "Sách học Go"
+	if err := repo.Update(&second); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Suggest("hoc", 10); len(got) != 1 || got[0].Score != 3 {
+		t.Errorf("renamed %+v", got)
+	}
+	if got := texts(s.Suggest("gol", 10)); !reflect.DeepEqual(got, []string{"golang", "Áo thun Golang"}) {
+		t.Errorf("old name %q", got)
+	}
+
+	// off the shelf: gone from the suggestions, views kept for when it is back
+	second.Display = false
+	if err := repo.Update(&second); err != nil {
+		t.Fatal(err)
+	}
+	s.Viewed(second.ID)
+	if got := s.Suggest("hoc", 10); len(got) != 0 {
+		t.Errorf("hidden %+v", got)
+	}
+	second.Display = true
+	if err := repo.Update(&second); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Suggest("hoc", 10); len(got) != 1 || got[0].Score != 4 {
+		t.Errorf("shown again %+v", got)
+	}
+
+	if err := repo.Delete(first.ID, first.Version); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Suggest("gol", 10); len(got) != 1 || got[0].Kind != KindTag || got[0].Score != 1 {
+		t.Errorf("after delete %+v", got)
+	}
+}
+
+// BenchmarkSuggest looks up prefixes of a few letters in a catalogue of 10k
+// products made of common Vietnamese shop words
+func BenchmarkSuggest(b *testing.B) {
+	kinds := []string{"Áo thun", "Áo khoác", "Quần jean", "Giày thể thao", "Túi xách", "Đồng hồ", "Mũ lưỡi trai", "Ví da", "Kính mát", "Thắt lưng"}
+	styles := []string{"nam", "nữ", "trẻ em", "cổ điển", "thời trang", "cao cấp", "giá rẻ", "Hàn Quốc", "công sở", "dạo phố"}
+	colors := []string{"đen", "trắng", "đỏ", "xanh dương", "vàng", "hồng", "xám", "nâu", "be", "tím"}
+
+	s := NewSuggester()
+	for i := range 10000 {
+		s.put(models.Product{
+			ID:      i + 1,
+			Name:    fmt.Sprintf("%s %s màu %s %d", kinds[i%len(kinds)], styles[i/len(kinds)%len(styles)], colors[i/100%len(colors)], i),
+			Tags:    []string{styles[i%len(styles)], colors[i%len(colors)]},
+			Display: true,
+		})
+	}
+	for i := range 2000 {
+		s.Viewed(i*5%10000 + 1)
+	}
+	prefixes := []string{"a", "ao", "áo th", "quan", "giay the", "dong ho", "den", "xanh", "han q", "tui x"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if got := s.Suggest(prefixes[i%len(prefixes)], TopK); len(got) == 0 {
+			b.Fatalf("nothing for %q", prefixes[i%len(prefixes)])
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/remoteimage"
+)
+
+// Headers of a delivery. The delivery ID stays the same across retries, the
+// event ID across the endpoints it goes to.
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const userAgent = "lession03-webhooks/1"
+
+// deliver is the webhook.deliver job: one POST of the event to the endpoint.
+// 2xx is done, 410 Gone gives up, anything else is retried.
+func (s *Service) deliver(ctx context.Context, job jobs.Job) (any, error) {
+	var p deliverPayload
+	if err := job.Decode(&p); err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	w, err := s.Find(ctx, p.WebhookID)
+	if errors.Is(err, ErrNotFound) {
+		return map[string]string{"skipped": "webhook was deleted"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var typ, body string
+	err = s.db.QueryRowContext(ctx, `SELECT type, payload FROM outbox WHERE id = ?`, p.EventID).Scan(&typ, &body)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, jobs.Permanent(fmt.Errorf("event %s is not in the outbox", p.EventID))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, strings.NewReader(body))
+	if err != nil {
+		return nil, jobs.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventHeader, typ)
+	req.Header.Set(DeliveryHeader, job.ID)
+	req.Header.Set(SignatureHeader, Sign(w.Secret, time.Now(), []byte(body)))
+
+	start := time.Now()
+	res, err := s.client.Do(req)
+	d := Delivery{WebhookID: w.ID, EventID: p.EventID, EventType: typ, JobID: job.ID, Attempt: job.Attempts}
+	if err == nil {
+		d.StatusCode = res.StatusCode
+		io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10)) // so the connection is reused
+		res.Body.Close()
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			err = fmt.Errorf("endpoint answered %s", res.Status)
+		}
+	} else if errors.Is(err, remoteimage.ErrBlocked) {
+		err = jobs.Permanent(errors.New("webhook URL points at a private or local address"))
+	}
+	d.Duration = time.Since(start)
+	if err != nil {
+		d.Error = err.Error()
+	}
+	if lerr := s.logDelivery(context.WithoutCancel(ctx), d); lerr != nil {
+		log.Printf("webhook: delivery log of %s: %v", job.ID, lerr)
+	}
+
+	if d.StatusCode == http.StatusGone {
+		return nil, jobs.Permanent(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int{"status_code": d.StatusCode}, nil
+}
+
+func (s *Service) logDelivery(ctx context.Context, d Delivery) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, job_id, attempt, status_code, error, duration_ms, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		d.WebhookID, d.EventID, d.EventType, d.JobID, d.Attempt, d.StatusCode, d.Error, d.Duration.Milliseconds(), time.Now().UTC())
+	return err
+}
+
+// Sign is the X-Webhook-Signature of body sent at t: t=<unix seconds>,v1=<hex
+// HMAC-SHA256 of "<unix seconds>.<body>" keyed with the secret>. The time is
+// signed too, so a captured request can't be replayed later on.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+func mac(secret, ts string, body []byte) []byte {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(ts))
+	m.Write([]byte{'.'})
+	m.Write(body)
+	return m.Sum(nil)
+}
+
+var (
+	ErrBadSignature = errors.New("webhook: signature doesn't match")
+	ErrStale        = errors.New("webhook: signature is too old")
+)
+
+// Verify checks a delivery the way receivers should: the signature header
+// against the raw body, and that it was signed within tolerance of now
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts string
+	var sigs [][]byte
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			if sig, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrBadSignature
+	}
+	want := mac(secret, ts, body)
+	ok := false
+	for _, sig := range sigs {
+		ok = ok || hmac.Equal(sig, want)
+	}
+	if !ok {
+		return ErrBadSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrStale
+	}
+	return nil
+}
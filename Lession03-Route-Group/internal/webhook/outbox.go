@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+)
+
+// Publish writes e to the outbox, the relay delivers it from there. The
+// repositories are in memory for now, so this comes right after their
+// write; once they are tables it belongs in the same transaction.
+func (s *Service) Publish(ctx context.Context, e events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("webhook: event %s: %w", e.Type, err)
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO outbox (id, type, payload, occurred_at) VALUES (?, ?, ?, ?)`,
+		e.ID, e.Type, string(payload), e.OccurredAt.UTC())
+	if err != nil {
+		return fmt.Errorf("webhook: outbox: %w", err)
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// deliverPayload is the payload of a webhook.deliver job
+type deliverPayload struct {
+	WebhookID string `json:"webhook_id"`
+	EventID   string `json:"event_id"`
+}
+
+// Dispatch fans the pending outbox events out into delivery jobs, in order,
+// and returns how many events it took. Events no webhook wants are marked
+// dispatched all the same.
+func (s *Service) Dispatch(ctx context.Context) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Marking the batch dispatched comes first: it takes the write lock, so
+	// a second relay waits for this one instead of reading the same rows
+	now := time.Now().UTC()
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE outbox SET dispatched_at = ?
+		WHERE id IN (SELECT id FROM outbox WHERE dispatched_at IS NULL ORDER BY occurred_at, id LIMIT 100)
+		RETURNING id, type, occurred_at`, now)
+	if err != nil {
+		return 0, err
+	}
+	type pending struct {
+		id, typ  string
+		occurred time.Time
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.typ, &p.occurred); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(batch) == 0 {
+		return 0, err
+	}
+	// RETURNING comes in no particular order
+	sort.Slice(batch, func(i, j int) bool {
+		if !batch[i].occurred.Equal(batch[j].occurred) {
+			return batch[i].occurred.Before(batch[j].occurred)
+		}
+		return batch[i].id < batch[j].id
+	})
+
+	hooks, err := list(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range batch {
+		for _, w := range hooks {
+			if !w.Wants(p.typ) {
+				continue
+			}
+			if _, err := s.queue.EnqueueTx(ctx, tx, DeliverJob, deliverPayload{WebhookID: w.ID, EventID: p.id}); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(batch), tx.Commit()
+}
+
+// Run is the relay: it dispatches the outbox as events come, until ctx is done
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		// Drain, a batch at a time
+		for ctx.Err() == nil {
+			n, err := s.Dispatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("webhook: dispatch: %v", err)
+			}
+			if n == 0 || err != nil {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Package webhook delivers domain events (see package events) to partner
+// endpoints.
+//
+// Publish writes the event to the outbox table. The relay (Run) moves
+// outbox events into one webhook.deliver job per subscribed endpoint, in the
+// same transaction that marks them dispatched, so an event is never lost nor
+// fanned out twice. The job queue then does the retrying, every attempt is
+// kept in webhook_deliveries.
+//
+// Each request carries the event as JSON and an X-Webhook-Signature header,
+// see Sign and Verify.
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/remoteimage"
+)
+
+// DeliverJob is the job kind of one event to one endpoint
+const DeliverJob = "webhook.deliver"
+
+var ErrNotFound = errors.New("webhook: no such webhook")
+
+type Webhook struct {
+	ID          string
+	URL         string
+	Events      []string // types it gets
+	Secret      string   // signs the deliveries, shown to the partner once
+	Description string
+	CreatedAt   time.Time
+}
+
+// Wants tells whether w subscribed to events of typ
+func (w Webhook) Wants(typ string) bool {
+	for _, e := range w.Events {
+		if e == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is one attempt to deliver an event
+type Delivery struct {
+	ID         int64
+	WebhookID  string
+	EventID    string
+	EventType  string
+	JobID      string
+	Attempt    int
+	StatusCode int    // 0 when no response came
+	Error      string // empty when the endpoint answered 2xx
+	Duration   time.Duration
+	CreatedAt  time.Time
+}
+
+type Config struct {
+	Timeout     time.Duration // of one request, 10s by default
+	MaxAttempts int           // per delivery, 8 by default: about two hours of retries
+	Workers     int           // deliveries at once, 4 by default
+	// AllowPrivate lets deliveries reach loopback and private networks.
+	// Only for tests against httptest receivers, never in production.
+	AllowPrivate bool
+}
+
+// Service is safe for concurrent use
+type Service struct {
+	queue  *jobs.Queue
+	db     *sql.DB
+	client *http.Client
+	wake   chan struct{} // Publish nudges the relay
+
+	// PollInterval is how often the relay looks at the outbox without a nudge
+	PollInterval time.Duration
+}
+
+// New registers the delivery job on queue, whose database holds the
+// webhook tables too. Run the relay next to queue.Run.
+func New(queue *jobs.Queue, cfg Config) *Service {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = remoteimage.PublicOnly
+	}
+	s := &Service{
+		queue: queue,
+		db:    queue.DB(),
+		client: &http.Client{
+			Transport: &http.Transport{DialContext: dialer.DialContext, ResponseHeaderTimeout: cfg.Timeout},
+			Timeout:   cfg.Timeout,
+			// A redirect is an answer of its own, the signature was for this URL
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		wake:         make(chan struct{}, 1),
+		PollInterval: time.Second,
+	}
+	queue.Register(DeliverJob, s.deliver, jobs.Options{
+		Workers: cfg.Workers, MaxAttempts: cfg.MaxAttempts, Timeout: cfg.Timeout + 5*time.Second,
+	})
+	return s
+}
+
+// Create registers an endpoint for events of the given types, with a new secret
+func (s *Service) Create(ctx context.Context, url string, types []string, description string) (Webhook, error) {
+	w := Webhook{
+		ID: uuid.NewString(), URL: url, Events: types, Secret: "whsec_" + rand.Text(),
+		Description: description, CreatedAt: time.Now().UTC(),
+	}
+	events, err := json.Marshal(w.Events)
+	if err != nil {
+		return Webhook{}, err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO webhooks (id, url, events, secret, description, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		w.ID, w.URL, string(events), w.Secret, w.Description, w.CreatedAt)
+	if err != nil {
+		return Webhook{}, err
+	}
+	return w, nil
+}
+
+const webhookColumns = `id, url, events, secret, description, created_at`
+
+func scanWebhook(row interface{ Scan(...any) error }) (Webhook, error) {
+	var w Webhook
+	var events string
+	if err := row.Scan(&w.ID, &w.URL, &events, &w.Secret, &w.Description, &w.CreatedAt); err != nil {
+		return Webhook{}, err
+	}
+	return w, json.Unmarshal([]byte(events), &w.Events)
+}
+
+// List is every webhook, oldest first
+func (s *Service) List(ctx context.Context) ([]Webhook, error) {
+	return list(ctx, s.db)
+}
+
+func list(ctx context.Context, db interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}) ([]Webhook, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var all []Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, w)
+	}
+	return all, rows.Err()
+}
+
+func (s *Service) Find(ctx context.Context, id string) (Webhook, error) {
+	w, err := scanWebhook(s.db.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Webhook{}, ErrNotFound
+	}
+	return w, err
+}
+
+// Delete removes the webhook and its delivery log. Deliveries still queued
+// find it gone and give up.
+func (s *Service) Delete(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Deliveries is the delivery log of a webhook, newest attempt first
+func (s *Service) Deliveries(ctx context.Context, webhookID string, limit int) ([]Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, webhook_id, event_id, event_type, job_id, attempt, status_code, error, duration_ms, created_at
+		FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var log []Delivery
+	for rows.Next() {
+		var d Delivery
+		var ms int64
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.JobID, &d.Attempt, &d.StatusCode, &d.Error, &ms, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		d.Duration = time.Duration(ms) * time.Millisecond
+		log = append(log, d)
+	}
+	return log, rows.Err()
+}
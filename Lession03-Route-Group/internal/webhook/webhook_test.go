@@ -0,0 +1,188 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/events"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/webhook"
+)
+
+// receiver is a partner endpoint: it checks signatures and answers with
+// the next status of script, then 200
+type receiver struct {
+	*httptest.Server
+	secret string
+
+	mu     sync.Mutex
+	script []int
+	got    []events.Event
+	bad    []error
+}
+
+func newReceiver(t *testing.T, script ...int) *receiver {
+	r := &receiver{script: script}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if err := webhook.Verify(r.secret, req.Header.Get(webhook.SignatureHeader), body, time.Now(), time.Minute); err != nil {
+			r.bad = append(r.bad, err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		status := http.StatusOK
+		if len(r.script) > 0 {
+			status, r.script = r.script[0], r.script[1:]
+		}
+		if status == http.StatusOK {
+			var e events.Event
+			json.Unmarshal(body, &e)
+			if req.Header.Get(webhook.EventHeader) != e.Type {
+				r.bad = append(r.bad, errors.New("event header "+req.Header.Get(webhook.EventHeader)))
+			}
+			r.got = append(r.got, e)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func setup(t *testing.T) (*webhook.Service, func(kind string) bool) {
+	q := apitest.Queue(t)
+	q.Backoff = func(int) time.Duration { return 0 }
+	s := webhook.New(q, webhook.Config{AllowPrivate: true, MaxAttempts: 3})
+	run := func(kind string) bool {
+		ran, err := q.RunNext(context.Background(), kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ran
+	}
+	return s, run
+}
+
+func TestDeliverRetriesAndLogs(t *testing.T) {
+	s, run := setup(t)
+	ctx := context.Background()
+	rcv := newReceiver(t, http.StatusServiceUnavailable)
+	hook, err := s.Create(ctx, rcv.URL, []string{events.ProductCreated}, "partner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rcv.secret = hook.Secret
+	other := newReceiver(t)
+	s.Create(ctx, other.URL, []string{events.UserCreated}, "")
+
+	s.Publish(ctx, events.New(events.ProductCreated, 7, map[string]any{"id": 7, "name": "Gopher Tee"}))
+	s.Publish(ctx, events.New(events.CategoryCreated, 1, map[string]any{"id": 1})) // wanted by nobody
+	if n, err := s.Dispatch(ctx); n != 2 || err != nil {
+		t.Fatalf("dispatched %d, %v", n, err)
+	}
+	if n, _ := s.Dispatch(ctx); n != 0 {
+		t.Fatalf("dispatched %d again", n)
+	}
+
+	for run(webhook.DeliverJob) {
+	}
+	if len(rcv.got) != 1 || rcv.got[0].Type != events.ProductCreated || len(rcv.bad) != 0 || len(other.got) != 0 {
+		t.Fatalf("got %+v, bad %v, other got %d", rcv.got, rcv.bad, len(other.got))
+	}
+	if data, _ := rcv.got[0].Data.(map[string]any); data["name"] != "Gopher Tee" {
+		t.Errorf("data %+v", rcv.got[0].Data)
+	}
+
+	log, err := s.Deliveries(ctx, hook.ID, 10)
+	if err != nil || len(log) != 2 {
+		t.Fatalf("log %+v, %v", log, err)
+	}
+	if log[0].Attempt != 2 || log[0].StatusCode != 200 || log[0].Error != "" ||
+		log[1].Attempt != 1 || log[1].StatusCode != 503 || log[1].Error != "endpoint answered 503 Service Unavailable" ||
+		log[0].JobID != log[1].JobID || log[0].EventType != events.ProductCreated {
+		t.Errorf("log %+v", log)
+	}
+}
+
+func TestGoneEndpointStopsRetries(t *testing.T) {
+	s, run := setup(t)
+	ctx := context.Background()
+	rcv := newReceiver(t, http.StatusGone, http.StatusGone)
+	hook, _ := s.Create(ctx, rcv.URL, []string{events.UserDeleted}, "")
+	rcv.secret = hook.Secret
+
+	s.Publish(ctx, events.New(events.UserDeleted, 3, events.Deleted{ID: 3}))
+	s.Dispatch(ctx)
+	for run(webhook.DeliverJob) {
+	}
+	if log, _ := s.Deliveries(ctx, hook.ID, 10); len(log) != 1 || log[0].StatusCode != http.StatusGone {
+		t.Errorf("log %+v", log)
+	}
+
+	// Deleting the webhook drops its queued deliveries
+	s.Publish(ctx, events.New(events.UserDeleted, 4, events.Deleted{ID: 4}))
+	s.Dispatch(ctx)
+	if err := s.Delete(ctx, hook.ID); err != nil {
+		t.Fatal(err)
+	}
+	for run(webhook.DeliverJob) {
+	}
+	if len(rcv.script) != 1 {
+		t.Error("delivered to a deleted webhook")
+	}
+	if err := s.Delete(ctx, hook.ID); !errors.Is(err, webhook.ErrNotFound) {
+		t.Errorf("delete twice: %v", err)
+	}
+}
+
+// Without AllowPrivate deliveries to local addresses are refused, once
+func TestPrivateAddressesAreBlocked(t *testing.T) {
+	q := apitest.Queue(t)
+	s := webhook.New(q, webhook.Config{MaxAttempts: 3})
+	ctx := context.Background()
+	rcv := newReceiver(t)
+	hook, _ := s.Create(ctx, rcv.URL, []string{events.ProductDeleted}, "")
+
+	s.Publish(ctx, events.New(events.ProductDeleted, 1, events.Deleted{ID: 1}))
+	s.Dispatch(ctx)
+	q.RunNext(ctx, webhook.DeliverJob)
+	log, _ := s.Deliveries(ctx, hook.ID, 10)
+	if len(log) != 1 || log[0].Error != "webhook URL points at a private or local address" {
+		t.Errorf("log %+v", log)
+	}
+	if ran, _ := q.RunNext(ctx, webhook.DeliverJob); ran {
+		t.Error("retried a blocked delivery")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	at := time.Unix(1_800_000_000, 0)
+	sig := webhook.Sign("whsec_test", at, body)
+
+	for name, tc := range map[string]struct {
+		secret, header string
+		body           []byte
+		now            time.Time
+		want           error
+	}{
+		"ok":            {"whsec_test", sig, body, at.Add(time.Minute), nil},
+		"other secret":  {"whsec_other", sig, body, at, webhook.ErrBadSignature},
+		"changed body":  {"whsec_test", sig, []byte(`{"id":"2"}`), at, webhook.ErrBadSignature},
+		"replayed late": {"whsec_test", sig, body, at.Add(10 * time.Minute), webhook.ErrStale},
+		"garbage":       {"whsec_test", "v1=zz", body, at, webhook.ErrBadSignature},
+		"rotated key":   {"whsec_test", "t=1800000000,v1=00ff," + sig[len("t=1800000000,"):], body, at, nil},
+	} {
+		if err := webhook.Verify(tc.secret, tc.header, tc.body, tc.now, 5*time.Minute); !errors.Is(err, tc.want) {
+			t.Errorf("%s: %v, want %v", name, err, tc.want)
+		}
+	}
+}
@@ -1,81 +1,140 @@
+// Command lession03 is the service binary: the HTTP server and the ops
+// tasks around it, all sharing one Config.
+//
+//	lession03 [global flags] serve | migrate | seed | routes | validate-config | user create-admin
 package main
 
 import (
-	"net/http"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
-	v1handler "github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/api/v1/handler"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/db"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/jobs"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/router"
 )
 
-func GetProductByIdV1(c *gin.Context) {
-	id := c.Param("id")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Product details for ID " + id,
-	})
+// app is the wiring every command shares
+type app struct {
+	cfg  Config
+	out  io.Writer
+	conn *sql.DB // opened by DB on first use
 }
 
-func PutProductV1(c *gin.Context) {
-	id := c.Param("id")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Updated product with ID " + id,
-	})
+// DB opens the configured database once per run
+func (a *app) DB() (*sql.DB, error) {
+	if a.conn == nil {
+		conn, err := db.Open(a.cfg.DatabasePath)
+		if err != nil {
+			return nil, err
+		}
+		a.conn = conn
+	}
+	return a.conn, nil
 }
 
-func DeleteProductV1(c *gin.Context) {
-	id := c.Param("id")
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Deleted product with ID " + id,
-	})
+// Engine builds the same gin engine serve runs, minus the job queue
+func (a *app) Engine() *gin.Engine {
+	return router.New(a.cfg.Router())
 }
 
-const (
-	userByIDRoute    = "/:id"
-	productByIDRoute = "/:id"
-)
+// Queue is the job queue on the database, which has to be migrated up to
+// date: serve doesn't migrate, deployments run migrate up first
+func (a *app) Queue(ctx context.Context) (*jobs.Queue, error) {
+	conn, err := a.DB()
+	if err != nil {
+		return nil, err
+	}
+	runner, err := newMigrateRunner(conn)
+	if err != nil {
+		return nil, err
+	}
+	list, err := runner.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending := 0
+	for _, s := range list {
+		if !s.Applied() {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return nil, fmt.Errorf("database %s has %d pending migrations, run lession03 migrate up", a.cfg.DatabasePath, pending)
+	}
+	return jobs.New(conn), nil
+}
 
-func main() {
-	r := gin.Default()
-	userHandler := v1handler.NewUserHandler()
-	productHandler := v1handler.NewProductHandler()
-	categoryHandler := v1handler.NewCategoryHandler()
+func (a *app) Close() {
+	if a.conn != nil {
+		a.conn.Close()
+	}
+}
 
-	// Serve files from "uploads" folder under /static/ path
-	r.Static("/api/static/categories", "./uploads/categories")
+type command struct {
+	summary string
+	run     func(ctx context.Context, a *app, args []string) error
+}
 
-	// Group for version 1
-	v1 := r.Group("/api/v1")
-	{
-		// /api/v1/users group
-		users := v1.Group("/users")
-		{
-			users.GET("", userHandler.GetUsers)
-			users.GET("/uuid/:uuid", userHandler.GetUserByUUID)
-			users.GET("/slug", userHandler.GetUserWithoutSlug)
-			users.GET("/slug/:slug", userHandler.GetUserBySlug)
-			users.GET(userByIDRoute, userHandler.GetUserByID)
-			users.POST("", userHandler.CreateUser)
-			users.PUT(userByIDRoute, userHandler.UpdateUser)
-			users.DELETE(userByIDRoute, userHandler.DeleteUser)
-		}
+var commands = map[string]command{
+	"serve":           {"run the HTTP server (the default)", runServe},
+	"migrate":         {"apply or roll back database migrations, see migrate -h", runMigrate},
+	"seed":            {"load -fixtures (the demo catalogue by default) into a running server", runSeed},
+	"routes":          {"print the route table", runRoutes},
+	"validate-config": {"check the configuration and exit", runValidateConfig},
+	"user":            {"manage users: user create-admin", runUser},
+}
 
-		// /api/v1/products group
-		products := v1.Group("/products")
-		{
-			products.GET("", productHandler.GetProducts)
-			products.GET("/category/:lang", productHandler.GetProductByLang)
-			products.GET(productByIDRoute, GetProductByIdV1)
-			products.POST("", productHandler.CreateProduct)
-			products.PUT(productByIDRoute, PutProductV1)
-			products.DELETE(productByIDRoute, DeleteProductV1)
-		}
+func usage(out io.Writer) {
+	fmt.Fprint(out, "usage: lession03 [global flags] <command> [arguments]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-16s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprint(out, "\nglobal flags:\n")
+}
 
-		categories := v1.Group("/categories")
-		{
-			categories.POST("", categoryHandler.CreateCategory)
-			categories.POST("/upload", categoryHandler.UploadCategoryImage)
-			categories.POST("/upload-multiple", categoryHandler.UploadMultipleCategoryImages)
-		}
+func run(ctx context.Context, args []string, getenv func(string) string, out io.Writer) error {
+	cfg, rest, err := loadConfig(args, getenv, out)
+	if err != nil {
+		return err
+	}
+	name := "serve" // plain `lession03` still starts the server
+	if len(rest) > 0 {
+		name, rest = rest[0], rest[1:]
 	}
+	cmd, ok := commands[name]
+	if !ok {
+		usage(out)
+		return fmt.Errorf("unknown command %q", name)
+	}
+
+	a := &app{cfg: cfg, out: out}
+	defer a.Close()
+	return cmd.run(ctx, a, rest)
+}
 
-	r.Run(":8080")
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Getenv, os.Stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "lession03:", err)
+		os.Exit(1)
+	}
 }
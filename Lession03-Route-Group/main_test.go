@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/apitest"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestLoadConfigFlagsOverrideEnv(t *testing.T) {
+	getenv := env(map[string]string{"LESSION03_ADDR": ":9000", "DATABASE_PATH": "env.db", "LESSION03_VERIFY_IMAGES": "true"})
+
+	cfg, rest, err := loadConfig([]string{"-addr", ":9090", "routes", "-x"}, getenv, new(bytes.Buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":9090" || cfg.DatabasePath != "env.db" || !cfg.VerifyImages {
+		t.Errorf("config %+v", cfg)
+	}
+	if strings.Join(rest, " ") != "routes -x" {
+		t.Errorf("rest %q", rest)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	cfg := Config{Addr: "8080", DatabasePath: "/does/not/exist/x.db", UploadDir: t.TempDir(), IngestImages: true, Cache: "memcached://x", TrustedProxies: "10.0.0.0/8, proxy"}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("want an error")
+	}
+	for _, want := range []string{"addr", "db", "ingest-images", "cache", `trusted-proxies: "proxy"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("%q doesn't mention %s", err, want)
+		}
+	}
+
+	cfg = Config{Addr: ":8080", DatabasePath: filepath.Join(t.TempDir(), "x.db"), UploadDir: t.TempDir(), TrustedProxies: "10.0.0.0/8,192.0.2.1"}
+	if err := cfg.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+// validate-config only looks: the upload dir serve would create isn't there after it
+func TestValidateChangesNothing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "uploads", "categories")
+	cfg := Config{Addr: ":8080", DatabasePath: filepath.Join(t.TempDir(), "x.db"), UploadDir: dir}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Dir(dir)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Validate made %s: %v", filepath.Dir(dir), err)
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.UploadDir = filepath.Join(file, "uploads")
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("upload dir under a file: %v", err)
+	}
+}
+
+// cli runs the binary's run with a temp database and upload dir
+func cli(t *testing.T, dbPath string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	getenv := env(map[string]string{"DATABASE_PATH": dbPath, "LESSION03_UPLOAD_DIR": t.TempDir()})
+	err := run(context.Background(), args, getenv, &out)
+	return out.String(), err
+}
+
+func TestRoutesCommand(t *testing.T) {
+	out, err := cli(t, "unused.db", "routes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "GET     /api/v1/products/:id") || !strings.Contains(out, "handler.(*ProductHandler).GetProductByID") {
+		t.Errorf("routes output:\n%s", out)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	out, err := cli(t, "unused.db", "frobnicate")
+	if err == nil || !strings.Contains(out, "validate-config") {
+		t.Errorf("err %v, usage:\n%s", err, out)
+	}
+}
+
+func TestSeedIsIdempotent(t *testing.T) {
+	srv := apitest.New(t).Server()
+
+	out, err := cli(t, "unused.db", "-synthetic", "3", "-fixtures", "demo", "seed", "-url", srv.URL)
+	if err != nil || !strings.Contains(out, "categories 3 created 0 skipped, products 8 created 0 skipped") {
+		t.Fatalf("seed: %v\n%s", err, out)
+	}
+	out, err = cli(t, "unused.db", "-synthetic", "3", "-fixtures", "demo", "seed", "-url", srv.URL)
+	if err != nil || !strings.Contains(out, "categories 0 created 3 skipped, products 0 created 8 skipped") {
+		t.Errorf("second seed: %v\n%s", err, out)
+	}
+}
+
+func TestMigrateCreateAdmin(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cli.db")
+
+	if _, err := cli(t, dbPath, "user", "create-admin", "-name", "Ada Lovelace", "-email", "ada@example.com"); err == nil {
+		t.Error("create-admin before migrate up should fail")
+	}
+	if _, err := cli(t, dbPath, "migrate", "up"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cli(t, dbPath, "user", "create-admin", "-name", "Al", "-email", "nope"); err == nil {
+		t.Error("want a validation error")
+	}
+	for _, wantSlug := range []string{"slug ada-lovelace)", "slug ada-lovelace-2)"} {
+		out, err := cli(t, dbPath, "user", "create-admin", "-name", "Ada Lovelace", "-email", "ADA@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, wantSlug) || !strings.Contains(out, "api token: ") {
+			t.Errorf("create-admin output:\n%s", out)
+		}
+	}
+}
+
+func TestServeNeedsMigratedDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "cli.db")
+	_, err := cli(t, dbPath, "-addr", "127.0.0.1:0", "serve")
+	if err == nil || !strings.Contains(err.Error(), "pending migrations, run lession03 migrate up") {
+		t.Errorf("serve on an empty database: %v", err)
+	}
+	if _, err := cli(t, dbPath, "-cleanup-schedule", "every day", "validate-config"); err == nil || !strings.Contains(err.Error(), "cleanup-schedule") {
+		t.Errorf("bad schedule: %v", err)
+	}
+}
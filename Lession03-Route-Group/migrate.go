@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/db"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/migrate"
+)
+
+const migrateUsage = `usage: lession03 [global flags] migrate [-steps n] up|down|status
+
+  up      apply every pending migration
+  down    roll back the last -steps migrations (default 1)
+  status  list migrations and whether they are applied
+`
+
+// newMigrateRunner runs the embedded migrations, as host:pid in the lock row
+func newMigrateRunner(conn *sql.DB) (*migrate.Runner, error) {
+	host, _ := os.Hostname()
+	return migrate.New(conn, db.Migrations, db.MigrationsDir, host+":"+strconv.Itoa(os.Getpid()))
+}
+
+// lession03 migrate up, run by deployments before serve
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(a.out)
+	fs.Usage = func() { fmt.Fprint(a.out, migrateUsage); fs.PrintDefaults() }
+	steps := fs.Int("steps", 1, "how many migrations down rolls back")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("migrate: want exactly one of up, down, status")
+	}
+
+	conn, err := a.DB()
+	if err != nil {
+		return err
+	}
+	runner, err := newMigrateRunner(conn)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "up":
+		ran, err := runner.Up(ctx)
+		for _, m := range ran {
+			fmt.Fprintf(a.out, "applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(ran) == 0 {
+			fmt.Fprintln(a.out, "nothing to apply")
+		}
+		return err
+	case "down":
+		ran, err := runner.Down(ctx, *steps)
+		for _, m := range ran {
+			fmt.Fprintf(a.out, "reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		list, err := runner.Status(ctx)
+		w := tabwriter.NewWriter(a.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range list {
+			applied := "pending"
+			if s.Applied() {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+		return err
+	default:
+		fs.Usage()
+		return fmt.Errorf("migrate: unknown command %q", fs.Arg(0))
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+type Category struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	ImageURL    string    `json:"image_url"`
+	ParentID    int       `json:"parent_id,omitempty"` // 0 for top level categories
+	Path        string    `json:"path"`                // IDs from the root down, "/1/4/7/", see CategoryRepository
+	Depth       int       `json:"depth"`               // 0 for top level categories
+	Version     int       `json:"version"`             // bumped on every update, exposed as ETag
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in the currency's minor unit (cents for USD, dong for
+// VND), so prices add up without float rounding
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"` // ISO 4217, "USD"
+}
+
+// Currencies whose minor unit isn't 1/100 of the major one
+var minorUnitDigits = map[string]int{
+	"VND": 0, "JPY": 0, "KRW": 0, "CLP": 0, "ISK": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+// MinorUnitDigits is how many decimals Amount has, 2 for most currencies
+func MinorUnitDigits(currency string) int {
+	if d, ok := minorUnitDigits[currency]; ok {
+		return d
+	}
+	return 2
+}
+
+// String is "12.50 USD", "250000 VND"
+func (m Money) String() string {
+	digits := MinorUnitDigits(m.Currency)
+	s := strconv.FormatInt(m.Amount, 10)
+	sign := ""
+	if m.Amount < 0 {
+		sign, s = "-", s[1:]
+	}
+	if digits > 0 {
+		if len(s) <= digits {
+			s = strings.Repeat("0", digits-len(s)+1) + s
+		}
+		s = s[:len(s)-digits] + "." + s[len(s)-digits:]
+	}
+	return sign + s + " " + m.Currency
+}
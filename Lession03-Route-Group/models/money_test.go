@@ -0,0 +1,17 @@
+package models
+
+import "testing"
+
+func TestMoneyString(t *testing.T) {
+	for m, want := range map[Money]string{
+		{Amount: 1250, Currency: "USD"}:   "12.50 USD",
+		{Amount: 5, Currency: "EUR"}:      "0.05 EUR",
+		{Amount: -199, Currency: "USD"}:   "-1.99 USD",
+		{Amount: 250000, Currency: "VND"}: "250000 VND",
+		{Amount: 1500, Currency: "KWD"}:   "1.500 KWD",
+	} {
+		if got := m.String(); got != want {
+			t.Errorf("%#v = %q, want %q", m, got, want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type Image struct {
+	URL     string `json:"url"`
+	AltText string `json:"alt_text,omitempty"`
+}
+
+type Info struct {
+	InfoKey   string `json:"info_key"`
+	InfoValue string `json:"info_value"`
+}
+
+// Product is what we keep in the store, the DTO is only what the client sends
+type Product struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Slug        string          `json:"slug"`
+	Description string          `json:"description,omitempty"`
+	Price       Money           `json:"price"`
+	Stock       int             `json:"stock"`
+	Tags        []string        `json:"tags,omitempty"`
+	Display     bool            `json:"display"`
+	Email       string          `json:"email,omitempty"`
+	Avatar      Image           `json:"avatar"`
+	Images      []Image         `json:"images"`
+	Info        map[string]Info `json:"product_info"`
+	Variants    []Variant       `json:"variants,omitempty"` // Price and Stock above are for products without any
+	CategoryIDs []int           `json:"category_ids,omitempty"`
+	Version     int             `json:"version"` // bumped on every update, exposed as ETag
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
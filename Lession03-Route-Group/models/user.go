@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type User struct {
+	ID        int       `json:"id"`
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	Version   int       `json:"version"` // bumped on every update, exposed as ETag
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Variant is one SKU of a product, e.g. the L/red tee, with its own prices,
+// stock and images
+type Variant struct {
+	SKU      string            `json:"sku"`
+	Options  map[string]string `json:"options,omitempty"` // "size": "L", "colour": "red"
+	Prices   []Money           `json:"prices"`            // one per currency
+	Stock    int               `json:"stock"`             // on hand
+	Reserved int               `json:"reserved"`          // held by open reservations
+	Images   []Image           `json:"images,omitempty"`
+}
+
+// Available is what can still be reserved
+func (v Variant) Available() int {
+	return v.Stock - v.Reserved
+}
+
+// Price in currency, if the variant is sold in it
+func (v Variant) Price(currency string) (Money, bool) {
+	for _, m := range v.Prices {
+		if m.Currency == currency {
+			return m, true
+		}
+	}
+	return Money{}, false
+}
+
+// Reservation holds Quantity of a variant for an order. Committing it takes
+// the quantity off the stock, releasing it (or letting it expire) gives it back.
+type Reservation struct {
+	ID        string    `json:"id"`
+	ProductID int       `json:"product_id"`
+	SKU       string    `json:"sku"`
+	Quantity  int       `json:"quantity"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
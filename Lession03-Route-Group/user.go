@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/slug"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/utils"
+)
+
+func runUser(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 || args[0] != "create-admin" {
+		fmt.Fprintln(a.out, "usage: lession03 [global flags] user create-admin -name NAME -email EMAIL")
+		return errors.New("user: want create-admin")
+	}
+	return createAdmin(ctx, a, args[1:])
+}
+
+// createAdmin adds an admin user to the database and prints an API token
+// for it. The token is only ever shown here, the database keeps its hash.
+func createAdmin(ctx context.Context, a *app, args []string) error {
+	var req dto.CreateAdminRequest
+	fs := flag.NewFlagSet("user create-admin", flag.ContinueOnError)
+	fs.SetOutput(a.out)
+	fs.StringVar(&req.Name, "name", "", "display name")
+	fs.StringVar(&req.Email, "email", "", "email address")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// Same tags, normalisers and messages as the HTTP API
+	utils.SetupBinding()
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		fields := utils.FormatValidationErrors(err)
+		msgs := make([]string, 0, len(fields))
+		for _, msg := range fields {
+			msgs = append(msgs, msg)
+		}
+		sort.Strings(msgs)
+		return errors.New(strings.Join(msgs, "; "))
+	}
+
+	conn, err := a.DB()
+	if err != nil {
+		return err
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	userSlug, err := freeUserSlug(ctx, tx, req.Name)
+	if err != nil {
+		return fmt.Errorf("create-admin (did you run migrate up?): %w", err)
+	}
+	now := time.Now().UTC()
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO users (uuid, name, slug, email, role, created_at, updated_at) VALUES (?, ?, ?, ?, 'admin', ?, ?)`,
+		uuid.NewString(), req.Name, userSlug, req.Email, now, now)
+	if err != nil {
+		return err
+	}
+	userID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	token := rand.Text()
+	sum := sha256.Sum256([]byte(token))
+	if _, err := tx.ExecContext(ctx, `INSERT INTO api_tokens (token_hash, user_id, created_at) VALUES (?, ?, ?)`,
+		hex.EncodeToString(sum[:]), userID, now); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "admin %s (id %d, slug %s)\napi token: %s\nkeep it safe, it is not shown again\n", req.Name, userID, userSlug, token)
+	return nil
+}
+
+// freeUserSlug is slug.Make(name), with -2, -3... when that is taken
+func freeUserSlug(ctx context.Context, tx *sql.Tx, name string) (string, error) {
+	base := slug.Make(name)
+	if base == "" {
+		base = "admin"
+	}
+	for n := 1; ; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+		var taken int
+		err := tx.QueryRowContext(ctx,
+			`SELECT count(*) FROM users WHERE slug = ?1 OR EXISTS (SELECT 1 FROM user_slugs WHERE slug = ?1)`, candidate).Scan(&taken)
+		if err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			return candidate, nil
+		}
+	}
+}
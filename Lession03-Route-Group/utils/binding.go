@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+var setupOnce sync.Once
+
+// SetupBinding registers our custom tags on gin's validator and makes every
+// ShouldBind* normalise the struct before validating it. Safe to call from
+// every handler constructor, only the first call does anything.
+func SetupBinding() {
+	setupOnce.Do(func() {
+		if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+			RegisterValidations(v)
+		}
+		binding.Validator = normalizingValidator{binding.Validator}
+	})
+}
+
+// RegisterValidations adds slug, sku, alphanumspace, imgext and the DTO rules to v
+func RegisterValidations(v *validator.Validate) {
+	_ = v.RegisterValidation("alphanumspace", AlphaNumSpace)
+	_ = v.RegisterValidation("slug", ValidateSlug)
+	_ = v.RegisterValidation("sku", ValidateSKU)
+	_ = v.RegisterValidation("imgext", ValidateImageExtension)
+	registerRules(v)
+}
+
+type normalizingValidator struct {
+	binding.StructValidator
+}
+
+func (v normalizingValidator) ValidateStruct(obj any) error {
+	if err := Normalize(obj); err != nil {
+		return err
+	}
+	return v.StructValidator.ValidateStruct(obj)
+}
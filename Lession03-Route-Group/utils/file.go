@@ -5,6 +5,7 @@ import (
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/google/uuid"
@@ -73,5 +74,6 @@ func allowedList(allowed map[string]bool) string {
 	for ext := range allowed {
 		exts = append(exts, strings.TrimPrefix(ext, "."))
 	}
+	sort.Strings(exts) // map order changes from run to run
 	return strings.Join(exts, ", ")
 }
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memFile is a multipart.File backed by memory
+type memFile struct{ *bytes.Reader }
+
+func (memFile) Close() error { return nil }
+
+func FuzzValidateFileExtension(f *testing.F) {
+	for _, s := range tricky {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		err := ValidateFileExtension(name, AllowedExtensions)
+		if err == nil {
+			if !AllowedExtensions[strings.ToLower(filepath.Ext(name))] {
+				t.Errorf("accepted %q", name)
+			}
+			return
+		}
+		// the message lists the allowed types, it must read the same every time
+		if again := ValidateFileExtension(name, AllowedExtensions); again.Error() != err.Error() {
+			t.Errorf("message changed between calls: %q vs %q", err, again)
+		}
+	})
+}
+
+func FuzzValidateImageMIME(f *testing.F) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	jpeg := []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+	for _, seed := range [][]byte{
+		png, jpeg, {}, {0}, []byte("GIF89a"), []byte("<?php echo 1; ?>"), []byte("<svg onload=alert(1)>"),
+		append(append([]byte{}, png...), "<?php system($_GET['c']); ?>"...), // polyglot, still a PNG by its magic number
+		append([]byte("<?php ?>"), png...),
+		append([]byte("<html>"), jpeg...),
+		bytes.Repeat([]byte{0xff}, 600),
+		[]byte("\xff\xd8"),
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		file := memFile{bytes.NewReader(data)}
+		err := ValidateImageMIME(file)
+
+		if pos, _ := file.Seek(0, io.SeekCurrent); len(data) > 0 && pos != 0 {
+			t.Errorf("file left at offset %d, saving it would drop the first bytes", pos)
+		}
+		if err != nil {
+			return
+		}
+		ct := http.DetectContentType(data)
+		if ct != "image/png" && ct != "image/jpeg" {
+			t.Errorf("accepted %q sniffed as %s", data, ct)
+		}
+	})
+}
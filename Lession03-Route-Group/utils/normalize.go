@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizers run before validation, in the order of the `normalize` tag:
+//
+//	Search string `form:"search" normalize:"trim,nfc" binding:"required,min=3"`
+//
+// so " ab " and "ab" are the same input for every rule after it.
+var (
+	normalizersMu sync.RWMutex
+	normalizers   = map[string]func(string) string{
+		"trim":    strings.TrimSpace,
+		"lower":   strings.ToLower,
+		"upper":   strings.ToUpper,
+		"nfc":     norm.NFC.String,
+		"squash":  func(s string) string { return strings.Join(strings.Fields(s), " ") }, // inner whitespace runs become one space
+		"nonulls": func(s string) string { return strings.ReplaceAll(s, "\x00", "") },
+	}
+)
+
+// RegisterNormalizer adds a normalizer usable in `normalize` tags
+func RegisterNormalizer(name string, fn func(string) string) {
+	normalizersMu.Lock()
+	defer normalizersMu.Unlock()
+	normalizers[name] = fn
+}
+
+// NormalizeString applies a tag value like "trim,nfc" to s
+func NormalizeString(s, tag string) (string, error) {
+	normalizersMu.RLock()
+	defer normalizersMu.RUnlock()
+	for _, name := range strings.Split(tag, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		fn, ok := normalizers[name]
+		if !ok {
+			return s, fmt.Errorf("unknown normalizer %q", name)
+		}
+		s = fn(s)
+	}
+	return s, nil
+}
+
+// Normalize rewrites the fields of the struct ptr points to according to their
+// `normalize` tags, descending into nested structs, slices and maps. A tag on
+// a []string applies to every element. Anything that isn't a pointer is left alone.
+func Normalize(ptr any) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return nil
+	}
+	return normalizeValue(rv.Elem(), "")
+}
+
+func normalizeValue(v reflect.Value, tag string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return normalizeValue(v.Elem(), tag)
+	case reflect.String:
+		if tag == "" || !v.CanSet() {
+			return nil
+		}
+		s, err := NormalizeString(v.String(), tag)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if err := normalizeValue(v.Field(i), t.Field(i).Tag.Get("normalize")); err != nil {
+				return fmt.Errorf("%s: %w", t.Field(i).Name, err)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := normalizeValue(v.Index(i), tag); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// map values can't be set in place, normalize a copy and put it back
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			if err := normalizeValue(elem, tag); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+	return nil
+}
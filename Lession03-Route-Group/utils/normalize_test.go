@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+type normalizeForm struct {
+	Name   string            `normalize:"trim,nfc"`
+	Email  *string           `normalize:"trim,lower"`
+	Tags   []string          `normalize:"squash"`
+	Info   map[string]item   // normalised through the struct tags of item
+	Nested item              // same
+	Raw    string            // no tag, untouched
+	Labels map[string]string `normalize:"upper"`
+}
+
+type item struct {
+	Value string `normalize:"trim"`
+}
+
+func TestNormalize(t *testing.T) {
+	email := "  Bob@Example.COM "
+	form := normalizeForm{
+		Name:   "  Cafe\u0301 ", // e + combining acute, NFC makes it one rune
+		Email:  &email,
+		Tags:   []string{" a   b ", "c\t\td"},
+		Info:   map[string]item{"k": {Value: " v "}},
+		Nested: item{Value: " n "},
+		Raw:    " raw ",
+		Labels: map[string]string{"x": "low"},
+	}
+	if err := Normalize(&form); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct{ name, got, want string }{
+		{"Name", form.Name, "Café"},
+		{"Email", *form.Email, "bob@example.com"},
+		{"Tags[0]", form.Tags[0], "a b"},
+		{"Tags[1]", form.Tags[1], "c d"},
+		{"Info[k]", form.Info["k"].Value, "v"},
+		{"Nested", form.Nested.Value, "n"},
+		{"Raw", form.Raw, " raw "},
+		{"Labels[x]", form.Labels["x"], "LOW"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestNormalizeUnknownNormalizer(t *testing.T) {
+	var form struct {
+		Name string `normalize:"trim,shout"`
+	}
+	if err := Normalize(&form); err == nil {
+		t.Fatal("want an error for an unknown normalizer")
+	}
+}
+
+// The DTO tag and the manual helper used to disagree on padded input
+func TestSearchAndTagAgree(t *testing.T) {
+	SetupBinding()
+	for _, search := range []string{"  ab ", "  abc ", "Cafe\u0301", "ab!", "   "} {
+		var q struct {
+			Search string `normalize:"trim,nfc" binding:"required,min=3,max=50,alphanumspace"`
+		}
+		q.Search = search
+		tagErr := binding.Validator.ValidateStruct(&q)
+		if (tagErr == nil) != (ValidateSearch(search) == nil) {
+			t.Errorf("%q: tag says %v, ValidateSearch says %v", search, tagErr, ValidateSearch(search))
+		}
+	}
+}
+
+func TestValidateLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"", 10, false},
+		{"0", 10, false},
+		{"25", 25, false},
+		{"100", 100, false},
+		{"101", 0, true},
+		{"-1", 0, true},
+		{"ten", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ValidateLimit(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ValidateLimit(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
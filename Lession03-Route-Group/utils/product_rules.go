@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+)
+
+func init() {
+	RegisterRules(
+		productInfoKeysAreUUIDs,
+		hiddenProductsHaveNoStock,
+		imagesDontRepeatAvatar,
+	)
+}
+
+func isUUID(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil
+}
+
+func productInfoKeysAreUUIDs(sl validator.StructLevel, p dto.CreateProductRequest) {
+	EachKey(sl, "ProductInfo", p.ProductInfo, "uuid", isUUID)
+}
+
+// Display=false is for products taken off the shelf, they can't have stock left
+func hiddenProductsHaveNoStock(sl validator.StructLevel, p dto.CreateProductRequest) {
+	if p.Display != nil && !*p.Display && p.Stock != 0 {
+		sl.ReportError(p.Stock, "Stock", "Stock", "hidden", "")
+	}
+}
+
+// The avatar is stored on its own, listing it again in Image is a mistake
+func imagesDontRepeatAvatar(sl validator.StructLevel, p dto.CreateProductRequest) {
+	for i, img := range p.Image {
+		if img.URL != "" && img.URL == p.Avartar.URL {
+			name := "Image[" + strconv.Itoa(i) + "]"
+			sl.ReportError(img.URL, name, name, "notavatar", "")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// A Rule checks what a single field's binding tag can't: one field against
+// another ("Stock must be 0 when Display is false") or the keys of a map.
+// Report problems with sl.ReportError, they come out of
+// FormatValidationErrors like any tag does.
+type Rule[T any] func(sl validator.StructLevel, v T)
+
+var (
+	rulesMu     sync.Mutex
+	structRules = map[reflect.Type][]func(validator.StructLevel){}
+)
+
+// RegisterRules adds rules for the DTO T. Call it from an init func:
+// RegisterValidations (so SetupBinding) only picks up what is there by then.
+func RegisterRules[T any](rules ...Rule[T]) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	t := reflect.TypeFor[T]()
+	for _, rule := range rules {
+		structRules[t] = append(structRules[t], func(sl validator.StructLevel) {
+			rule(sl, sl.Current().Interface().(T))
+		})
+	}
+}
+
+func registerRules(v *validator.Validate) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	for t, rules := range structRules {
+		v.RegisterStructValidation(func(sl validator.StructLevel) {
+			for _, rule := range rules {
+				rule(sl)
+			}
+		}, reflect.Zero(t).Interface())
+	}
+}
+
+// EachKey reports every key of m that ok rejects, as field[key] with tag,
+// the way a dive into the map would
+func EachKey[V any](sl validator.StructLevel, field string, m map[string]V, tag string, ok func(string) bool) {
+	for key := range m {
+		if !ok(key) {
+			sl.ReportError(key, field+"["+key+"]", field, tag, "")
+		}
+	}
+}
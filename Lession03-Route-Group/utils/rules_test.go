@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type ruleForm struct {
+	Min, Max int
+	Labels   map[string]string
+}
+
+func TestRules(t *testing.T) {
+	RegisterRules(func(sl validator.StructLevel, f ruleForm) {
+		if f.Min > f.Max {
+			sl.ReportError(f.Min, "Min", "Min", "ltefield", "Max")
+		}
+		EachKey(sl, "Labels", f.Labels, "slug", slugRegex.MatchString)
+	})
+	v := validator.New()
+	RegisterValidations(v)
+
+	tests := []struct {
+		name string
+		form ruleForm
+		want []string
+	}{
+		{"valid", ruleForm{Min: 1, Max: 2, Labels: map[string]string{"ok-key": "x"}}, nil},
+		{"min over max", ruleForm{Min: 3, Max: 2}, []string{"Min"}},
+		{"bad keys", ruleForm{Labels: map[string]string{"Bad Key": "x", "ok": "y"}}, []string{"Labels[Bad Key]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slices.Sorted(maps.Keys(FormatValidationErrors(v.Struct(tt.form))))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -8,9 +8,15 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/dto"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/listquery"
 )
 
+// ImageExtensionPattern is what ValidateImageExtension accepts, as a regex for the API docs
+const ImageExtensionPattern = `\.([jJ][pP][eE]?[gG]|[pP][nN][gG])$`
+
 // Accept only .jpg, .jpeg, .png
 func ValidateImageExtension(fl validator.FieldLevel) bool {
 	url := fl.Field().String()
@@ -30,7 +36,18 @@ func ValidateImageExtension(fl validator.FieldLevel) bool {
 	return false
 }
 
-var slugRegex = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+// SKUPattern is "TEE-GO-L": upper case letters and digits, hyphen separated
+const SKUPattern = `^[A-Z0-9]+(-[A-Z0-9]+)*$`
+
+var skuRegex = regexp.MustCompile(SKUPattern)
+
+func ValidateSKU(fl validator.FieldLevel) bool {
+	return skuRegex.MatchString(fl.Field().String())
+}
+
+const SlugPattern = `^[a-z0-9]+(-[a-z0-9]+)*$`
+
+var slugRegex = regexp.MustCompile(SlugPattern)
 
 func ValidateSlug(fl validator.FieldLevel) bool {
 	return slugRegex.MatchString(fl.Field().String())
@@ -52,11 +69,15 @@ var staticMessages = map[string]map[string]string{
 	"Email": {
 		"email": "Email must be a valid email address",
 	},
-	"Price": {
-		"gt": "Price must be greater than 0",
-	},
 	"Stock": {
-		"gte": "Stock must be greater than or equal to 0",
+		"gte":    "Stock must be greater than or equal to 0",
+		"hidden": "Stock must be 0 when display is false",
+	},
+	"ProductInfo": {
+		"uuid": "product_info keys must be UUIDs",
+	},
+	"Image": {
+		"notavatar": "Image must not repeat the avatar URL",
 	},
 	"Lang": {
 		"required": "Language is required",
@@ -75,11 +96,44 @@ var staticMessages = map[string]map[string]string{
 	"UUID": {
 		"uuid4": "Invalid UUID format bro",
 	},
+	"Limit": {
+		"gt": "Limit must be greater than 0",
+	},
+	"SKU": {
+		"sku": "SKU can only contain upper case letters, numbers, and hyphens",
+	},
+	"Variants": {
+		"unique": "Variant SKUs must be unique",
+	},
+	"Prices": {
+		"unique": "Only one price per currency",
+	},
+	"Amount": {
+		"gt": "Amount must be greater than 0, in minor units (cents for USD)",
+	},
+	"Currency": {
+		"iso4217":       "Currency must be an ISO 4217 code, e.g. USD or VND",
+		"required_with": "currency is required with price_min and price_max, they are in its minor units",
+	},
+	"Quantity": {
+		"gt": "Quantity must be greater than 0",
+	},
 }
+
+// StaticMessages is a copy of the fixed messages, field -> tag -> message,
+// so tests can check every one of them is reachable
+func StaticMessages() map[string]map[string]string {
+	out := make(map[string]map[string]string, len(staticMessages))
+	for field, tags := range staticMessages {
+		out[field] = make(map[string]string, len(tags))
+		for tag, msg := range tags {
+			out[field][tag] = msg
+		}
+	}
+	return out
+}
+
 var formattedMessages = map[string]map[string]string{
-	"Price": {
-		"lte": "Price must be less than or equal to %s",
-	},
 	"Slug": {
 		"min": "%s must be at least %s characters",
 		"max": "%s must be at most %s characters",
@@ -91,6 +145,9 @@ var formattedMessages = map[string]map[string]string{
 	"Lang": {
 		"oneof": "Language must be one of: %s",
 	},
+	"Limit": {
+		"lte": "Limit must be at most %s",
+	},
 }
 
 // utils/validator.go
@@ -117,14 +174,19 @@ func FormatValidationErrors(err error) map[string]string {
 
 func GetCustomErrorMessage(field, tag string, fe validator.FieldError) string {
 	log.Printf("[Validation] Field: %s | Tag: %s | Param: %s", field, tag, fe.Param())
-	if msg, ok := staticMessages[field][tag]; ok {
+	// Image[2] and ProductInfo[key] share the messages of Image and ProductInfo
+	name := field
+	if i := strings.IndexByte(field, '['); i > 0 {
+		name = field[:i]
+	}
+	if msg, ok := staticMessages[name][tag]; ok {
 		log.Printf("[Validation] Message returned: %s", msg)
 		return msg
 	}
 
-	if format, ok := formattedMessages[field][tag]; ok {
+	if format, ok := formattedMessages[name][tag]; ok {
 		switch tag {
-		case "min", "max":
+		case "min", "max", "lt", "lte", "gt", "gte":
 			if strings.Contains(format, "%s must") {
 				return fmt.Sprintf(format, field, fe.Param())
 			}
@@ -137,40 +199,53 @@ func GetCustomErrorMessage(field, tag string, fe validator.FieldError) string {
 	return fmt.Sprintf("Invalid value for %s", field)
 }
 
-var alphaNumSpaceRegex = regexp.MustCompile(`^[a-zA-Z0-9 ]+$`)
+// Letters of any script (so Vietnamese "áo thun" is fine), digits and spaces
+const AlphaNumSpacePattern = `^[\p{L}\p{M}\p{N} ]+$`
+
+var alphaNumSpaceRegex = regexp.MustCompile(AlphaNumSpacePattern)
 
 func AlphaNumSpace(fl validator.FieldLevel) bool {
 	val := fl.Field().String()
 	return alphaNumSpaceRegex.MatchString(val)
 }
 
-// ValidateSearch checks if a search string is valid
+// ValidateSearch checks search exactly like GET /products checks ?search=:
+// same normalisers, same rules, same messages (they all come from the tags
+// of dto.ProductQuery.Search)
 func ValidateSearch(search string) error {
-	search = strings.TrimSpace(search)
-
-	if len(search) < 3 || len(search) > 50 {
-		return errors.New("search must be between 3 and 50 characters")
-	}
-
-	// Only allow letters, numbers, and spaces
-	validSearch := regexp.MustCompile(`^[a-zA-Z0-9 ]+$`)
-	if !validSearch.MatchString(search) {
-		return errors.New("search may only contain letters, numbers, and spaces")
+	SetupBinding()
+	q := dto.ProductQuery{Search: search}
+	if err := binding.Validator.ValidateStruct(&q); err != nil {
+		if msg := FormatValidationErrors(err)["Search"]; msg != "" {
+			return errors.New(msg)
+		}
+		return err
 	}
-
 	return nil
 }
 
-// ValidateLimit parses and validates the limit string
+// ValidateLimit parses and validates the limit string with the rules of
+// dto.ListQuery.Limit. Empty or 0 means listquery.DefaultLimit, like the list endpoints.
 func ValidateLimit(limitStr string) (int, error) {
 	if limitStr == "" {
-		return 10, nil // default limit
+		return listquery.DefaultLimit, nil
 	}
 
 	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
+	if err != nil {
 		return 0, errors.New("limit must be a positive number")
 	}
 
+	SetupBinding()
+	q := dto.ListQuery{Limit: limit}
+	if err := binding.Validator.ValidateStruct(&q); err != nil {
+		if msg := FormatValidationErrors(err)["Limit"]; msg != "" {
+			return 0, errors.New(msg)
+		}
+		return 0, err
+	}
+	if limit == 0 {
+		return listquery.DefaultLimit, nil
+	}
 	return limit, nil
 }
@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/thinhnguyen-com/CodeWithTuan/Lession03-Route-Group/internal/listquery"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard) // the validators log every call, too much for a fuzzer
+	os.Exit(m.Run())
+}
+
+// tricky is the seed corpus shared by the string fuzzers
+var tricky = []string{
+	"", " ", "abc", "  ab ", "golang t-shirt", "a-b-c", "-abc", "abc-", "a--b", "ABC",
+	"áo thun", "Tiếng Việt", "é", "ǅ", "日本語", "١٢٣", "Ⅻ", "ﬁ", "K", "İstanbul",
+	"a\x00b", "\x00", "ab\ncd", "ab\tcd", " abc", "​abc", "abc‮", "\xff\xfe", "a\xc0\x80",
+	"x.png", "x.PNG", "x.jpeg", "x.JpG", "x.png.php", "x.php.png", "x.php\x00.png", "x.png ", ".png",
+	"https://example.com/a.png?x=1", "https://example.com/a.png#x", "x.pnɡ", "x.рng",
+	"0", "-1", "+5", "007", "1e3", "9223372036854775807", "9223372036854775808", " 5", "5 ",
+	"<script>", "../../etc/passwd", "%2e%2e%2f", strings.Repeat("a", 51), strings.Repeat("ā", 50),
+}
+
+func newVar(tag string, fn validator.Func) func(string) bool {
+	v := validator.New()
+	_ = v.RegisterValidation(tag, fn)
+	return func(s string) bool { return v.Var(s, tag) == nil }
+}
+
+func FuzzValidateSlug(f *testing.F) {
+	for _, s := range tricky {
+		f.Add(s)
+	}
+	accepts := newVar("slug", ValidateSlug)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if !accepts(s) {
+			return
+		}
+		if url.PathEscape(s) != s {
+			t.Errorf("slug %q needs escaping in a URL", s)
+		}
+		if s == "" || strings.HasPrefix(s, "-") || strings.HasSuffix(s, "-") || strings.Contains(s, "--") {
+			t.Errorf("slug %q has an empty segment", s)
+		}
+		for _, r := range s {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				t.Errorf("slug %q has %q", s, r)
+			}
+		}
+	})
+}
+
+func FuzzAlphaNumSpace(f *testing.F) {
+	for _, s := range tricky {
+		f.Add(s)
+	}
+	accepts := newVar("alphanumspace", AlphaNumSpace)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if !accepts(s) {
+			return
+		}
+		if s == "" || !utf8.ValidString(s) {
+			t.Fatalf("accepted empty or invalid UTF-8 %q", s)
+		}
+		for _, r := range s {
+			if r != ' ' && !unicode.In(r, unicode.L, unicode.M, unicode.N) {
+				t.Errorf("accepted %q in %q", r, s)
+			}
+		}
+	})
+}
+
+func FuzzValidateSearch(f *testing.F) {
+	for _, s := range tricky {
+		f.Add(s)
+	}
+	accepts := newVar("alphanumspace", AlphaNumSpace)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		if ValidateSearch(s) != nil {
+			return
+		}
+		normalized, _ := NormalizeString(s, "trim,nfc")
+		if n := utf8.RuneCountInString(normalized); n < 3 || n > 50 {
+			t.Errorf("accepted %q with %d runes", s, n)
+		}
+		// the manual check and the DTO tag must agree on what a search is
+		if !accepts(normalized) {
+			t.Errorf("ValidateSearch accepts %q, the alphanumspace tag does not", normalized)
+		}
+	})
+}
+
+func FuzzValidateLimit(f *testing.F) {
+	for _, s := range tricky {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		limit, err := ValidateLimit(s)
+		switch {
+		case s == "":
+			if err != nil || limit != listquery.DefaultLimit {
+				t.Errorf("empty limit gave %d, %v, want the default", limit, err)
+			}
+		case err != nil:
+			if limit != 0 {
+				t.Errorf("error for %q but limit %d", s, limit)
+			}
+		default:
+			n, aerr := strconv.Atoi(s)
+			if aerr != nil || limit <= 0 || limit > listquery.MaxLimit || (n != limit && n != 0) {
+				t.Errorf("accepted %q as %d", s, limit)
+			}
+		}
+	})
+}
+
+func FuzzValidateImageExtension(f *testing.F) {
+	for _, s := range tricky {
+		f.Add(s)
+	}
+	accepts := newVar("imgext", ValidateImageExtension)
+	documented := regexp.MustCompile(ImageExtensionPattern)
+
+	f.Fuzz(func(t *testing.T, s string) {
+		ok := accepts(s)
+		// the OpenAPI document publishes ImageExtensionPattern as the rule
+		if ok != documented.MatchString(s) {
+			t.Errorf("%q: validator says %v, ImageExtensionPattern says %v", s, ok, !ok)
+		}
+		if !ok {
+			return
+		}
+		lower := strings.ToLower(s)
+		if !strings.HasSuffix(lower, ".jpg") && !strings.HasSuffix(lower, ".jpeg") && !strings.HasSuffix(lower, ".png") {
+			t.Errorf("accepted %q", s)
+		}
+	})
+}
+
+// fuzzForm mirrors the shapes our DTOs use: plain fields, a nested struct,
+// a slice with dive and a map with dive
+type fuzzForm struct {
+	Name  string            `validate:"required,min=3,max=100"`
+	Slug  string            `validate:"omitempty,slug"`
+	Email string            `validate:"omitempty,email"`
+	Lang  string            `validate:"required,oneof=php golang python"`
+	Image []fuzzImage       `validate:"required,dive"`
+	Info  map[string]string `validate:"dive,keys,uuid4,endkeys,required"`
+}
+
+type fuzzImage struct {
+	URL string `validate:"required,imgext"`
+}
+
+func FuzzFormatValidationErrors(f *testing.F) {
+	for _, s := range tricky {
+		f.Add(s, s, s)
+	}
+	f.Add("Gopher", "gopher-tee", "golang")
+	v := validator.New()
+	_ = v.RegisterValidation("slug", ValidateSlug)
+	_ = v.RegisterValidation("imgext", ValidateImageExtension)
+
+	f.Fuzz(func(t *testing.T, a, b, c string) {
+		form := fuzzForm{
+			Name: a, Slug: b, Email: c, Lang: c,
+			Image: []fuzzImage{{URL: a}, {URL: b}},
+			Info:  map[string]string{a: b, c: ""},
+		}
+		err := v.Struct(form)
+		fields := FormatValidationErrors(err)
+
+		var ve validator.ValidationErrors
+		if !errors.As(err, &ve) {
+			if len(fields) != 0 {
+				t.Fatalf("no validation errors but fields %v", fields)
+			}
+			return
+		}
+		if len(fields) == 0 || len(fields) > len(ve) {
+			t.Errorf("%d validation errors became %d fields: %v", len(ve), len(fields), fields)
+		}
+		for key, msg := range fields {
+			if key == "" || msg == "" {
+				t.Errorf("empty key or message: %q: %q", key, msg)
+			}
+			if strings.HasPrefix(key, "fuzzForm.") {
+				t.Errorf("key %q still has the struct name", key)
+			}
+		}
+	})
+}